@@ -0,0 +1,210 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudwatch provides a way to export Prometheus metrics as log lines
+// in the CloudWatch Embedded Metric Format (EMF). This is useful in
+// environments without a Prometheus server scraping the process (like AWS
+// Lambda or ECS tasks), where CloudWatch extracts the metrics from the logs
+// written to stdout. See
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+package cloudwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+const (
+	// MaxDimensions is the maximum number of dimensions CloudWatch accepts
+	// for a metric. Metrics with more labels are not written.
+	MaxDimensions = 30
+	// maxMetrics is the maximum number of metrics in one EMF directive.
+	maxMetrics = 100
+	// metadataKey is the key of the EMF metadata in a log line.
+	metadataKey = "_aws"
+)
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+// WriteEMF gathers metrics from the provided Gatherer and writes them to w in
+// the CloudWatch Embedded Metric Format, using the provided CloudWatch
+// namespace. Each metric (i.e. each combination of label values) is written as
+// a separate JSON log line, terminated by a newline. The labels become
+// CloudWatch dimensions (labels with an empty value are omitted).
+//
+// CloudWatch has neither counters nor Prometheus-style histograms and
+// summaries. Counters, gauges, and untyped metrics are written with their
+// current value, i.e. counters are written as their cumulative value. For
+// histograms and summaries, their statistics are written as separate metrics
+// in the same log line: the sample count with the suffix "_count" and the sum
+// with the suffix "_sum". For summaries, each quantile is written in addition
+// with the suffix "_p" followed by the quantile as a percentage, e.g. "_p99"
+// for the 0.99 quantile. The buckets of histograms are not written. The
+// timestamp of a metric is used if set, otherwise the current time. The unit
+// is derived from the metric name if it ends in "_seconds" or "_bytes" (with
+// the exception of sample counts, which have the unit "Count").
+//
+// Metrics that cannot be represented are skipped: those with more than
+// MaxDimensions labels, with a label name that collides with the name of a
+// metric in the same line, or with a non-finite value (which JSON cannot
+// represent). The errors for those metrics are returned together in a
+// prometheus.MultiError, after writing all other metrics. An error during
+// gathering is returned the same way, while whatever could be gathered is
+// still written. An error writing to w is returned immediately.
+func WriteEMF(w io.Writer, g prometheus.Gatherer, namespace string) error {
+	return writeEMF(w, g, namespace, time.Now())
+}
+
+func writeEMF(w io.Writer, g prometheus.Gatherer, namespace string, now time.Time) error {
+	var errs prometheus.MultiError
+	mfs, err := g.Gather()
+	errs.Append(err)
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			line, err := emfLine(mf, m, namespace, now)
+			if err != nil {
+				errs.Append(fmt.Errorf("metric %s%s skipped: %w", mf.GetName(), labelString(m), err))
+				continue
+			}
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	return errs.MaybeUnwrap()
+}
+
+// emfLine returns the EMF log line (including the trailing newline) for the
+// provided metric.
+func emfLine(mf *dto.MetricFamily, m *dto.Metric, namespace string, now time.Time) ([]byte, error) {
+	name := mf.GetName()
+	values := map[string]float64{}
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		values[name] = m.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		values[name] = m.GetGauge().GetValue()
+	case dto.MetricType_UNTYPED:
+		values[name] = m.GetUntyped().GetValue()
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		values[name+"_count"] = float64(m.GetHistogram().GetSampleCount())
+		values[name+"_sum"] = m.GetHistogram().GetSampleSum()
+	case dto.MetricType_SUMMARY:
+		values[name+"_count"] = float64(m.GetSummary().GetSampleCount())
+		values[name+"_sum"] = m.GetSummary().GetSampleSum()
+		for _, q := range m.GetSummary().GetQuantile() {
+			values[name+"_p"+strconv.FormatFloat(q.GetQuantile()*100, 'f', -1, 64)] = q.GetValue()
+		}
+	default:
+		return nil, fmt.Errorf("unsupported metric type %s", mf.GetType())
+	}
+	if len(values) > maxMetrics {
+		return nil, fmt.Errorf("%d values exceed the limit of %d metrics", len(values), maxMetrics)
+	}
+
+	line := make(map[string]interface{}, len(values)+len(m.GetLabel())+1)
+	dimensions := make([]string, 0, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		if lp.GetValue() == "" {
+			continue
+		}
+		if _, exists := values[lp.GetName()]; exists || lp.GetName() == metadataKey {
+			return nil, fmt.Errorf("label name %q collides with a reserved name", lp.GetName())
+		}
+		dimensions = append(dimensions, lp.GetName())
+		line[lp.GetName()] = lp.GetValue()
+	}
+	if len(dimensions) > MaxDimensions {
+		return nil, fmt.Errorf("%d labels exceed the limit of %d dimensions", len(dimensions), MaxDimensions)
+	}
+
+	metrics := make([]emfMetric, 0, len(values))
+	for n, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("non-finite value %v for %s", v, n)
+		}
+		u := unit(name)
+		if strings.HasSuffix(n, "_count") && n != name {
+			u = "Count"
+		}
+		metrics = append(metrics, emfMetric{Name: n, Unit: u})
+		line[n] = v
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+	ts := now.UnixMilli()
+	if m.TimestampMs != nil {
+		ts = m.GetTimestampMs()
+	}
+	line[metadataKey] = emfMetadata{
+		Timestamp: ts,
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  namespace,
+			Dimensions: [][]string{dimensions},
+			Metrics:    metrics,
+		}},
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// unit returns the CloudWatch unit for the provided metric name, or "" if it
+// cannot be derived from the name (which CloudWatch treats as "None").
+func unit(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return "Seconds"
+	case strings.HasSuffix(name, "_bytes"):
+		return "Bytes"
+	}
+	return ""
+}
+
+func labelString(m *dto.Metric) string {
+	if len(m.GetLabel()) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", lp.GetName(), lp.GetValue()))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
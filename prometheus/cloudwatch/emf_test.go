@@ -0,0 +1,88 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudwatch
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestWriteEMF(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	cnt := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Number of requests.",
+	}, []string{"code", "method"})
+	cnt.WithLabelValues("200", "GET").Add(3)
+	his := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "request_duration_seconds",
+		Help:    "Duration of requests.",
+		Buckets: []float64{1},
+	})
+	his.Observe(0.5)
+	his.Observe(1.5)
+	sum := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "response_size_bytes",
+		Help:       "Size of responses.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.99: 0.001},
+	})
+	sum.Observe(100)
+	reg.MustRegister(cnt, his, sum)
+
+	var buf bytes.Buffer
+	if err := writeEMF(&buf, reg, "my-service", time.UnixMilli(1700000000000)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"_aws":{"Timestamp":1700000000000,"CloudWatchMetrics":[{"Namespace":"my-service","Dimensions":[[]],"Metrics":[{"Name":"request_duration_seconds_count","Unit":"Count"},{"Name":"request_duration_seconds_sum","Unit":"Seconds"}]}]},"request_duration_seconds_count":2,"request_duration_seconds_sum":2}
+{"_aws":{"Timestamp":1700000000000,"CloudWatchMetrics":[{"Namespace":"my-service","Dimensions":[["code","method"]],"Metrics":[{"Name":"requests_total"}]}]},"code":"200","method":"GET","requests_total":3}
+{"_aws":{"Timestamp":1700000000000,"CloudWatchMetrics":[{"Namespace":"my-service","Dimensions":[[]],"Metrics":[{"Name":"response_size_bytes_count","Unit":"Count"},{"Name":"response_size_bytes_p50","Unit":"Bytes"},{"Name":"response_size_bytes_p99","Unit":"Bytes"},{"Name":"response_size_bytes_sum","Unit":"Bytes"}]}]},"response_size_bytes_count":1,"response_size_bytes_p50":100,"response_size_bytes_p99":100,"response_size_bytes_sum":100}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteEMFSkipsUnrepresentableMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	names := make([]string, MaxDimensions+1)
+	values := make([]string, len(names))
+	for i := range names {
+		names[i] = "l" + strconv.Itoa(i)
+		values[i] = "v"
+	}
+	wide := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "wide", Help: "Too many labels."}, names)
+	wide.WithLabelValues(values...).Set(1)
+	nan := prometheus.NewGauge(prometheus.GaugeOpts{Name: "nan", Help: "Not a number."})
+	nan.Set(math.NaN())
+	ok := prometheus.NewGauge(prometheus.GaugeOpts{Name: "ok", Help: "Fine."})
+	reg.MustRegister(wide, nan, ok)
+
+	var buf bytes.Buffer
+	err := writeEMF(&buf, reg, "ns", time.UnixMilli(0))
+	var errs prometheus.MultiError
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	want := `{"_aws":{"Timestamp":0,"CloudWatchMetrics":[{"Namespace":"ns","Dimensions":[[]],"Metrics":[{"Name":"ok"}]}]},"ok":0}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// AggregatingHistogram is a Collector for a classic histogram that is fed with
+// already bucketed data rather than with individual observations, e.g. for a
+// sidecar that merges the histograms of many children. Create instances with
+// NewAggregatingHistogram.
+type AggregatingHistogram struct {
+	desc    *prometheus.Desc
+	buckets []float64
+
+	mtx    sync.Mutex // Protects the fields below.
+	sum    float64
+	count  uint64
+	counts []uint64 // Non-cumulative, in the order of buckets.
+}
+
+// NewAggregatingHistogram returns an AggregatingHistogram for the provided
+// Desc and bucket upper bounds. The Desc must not have any variable labels.
+// The buckets must be in strictly increasing order. A trailing +Inf bucket is
+// allowed but not required, as the count of the histogram always acts as the
+// +Inf bucket. NewAggregatingHistogram panics if the buckets are not in
+// strictly increasing order.
+//
+// Unlike a histogram created with prometheus.NewConstHistogram, an
+// AggregatingHistogram is mutable: The data passed to each Add call
+// accumulates, and each Collect call exposes the accumulated data as a
+// cumulative histogram. Unlike a regular prometheus.Histogram, it does not
+// bucket observations itself. It is safe for concurrent use.
+func NewAggregatingHistogram(desc *prometheus.Desc, buckets []float64) *AggregatingHistogram {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			panic(fmt.Errorf(
+				"histogram buckets must be in strictly increasing order: %f >= %f",
+				buckets[i-1], buckets[i],
+			))
+		}
+	}
+	return &AggregatingHistogram{
+		desc:    desc,
+		buckets: append([]float64(nil), buckets...),
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Add adds bucketed data to the histogram: sum and count are the sum and count
+// of the added observations, and bucketCounts contains the number of added
+// observations in each bucket (not cumulative, i.e. bucketCounts[i] is the
+// number of observations greater than buckets[i-1] and less than or equal to
+// buckets[i]). Observations greater than the largest bucket are only reflected
+// in count. Add panics if the length of bucketCounts doesn't match the number
+// of buckets or if the bucketCounts add up to more than count.
+func (h *AggregatingHistogram) Add(sum float64, count uint64, bucketCounts []uint64) {
+	if len(bucketCounts) != len(h.buckets) {
+		panic(fmt.Errorf(
+			"got %d bucket counts for %d buckets", len(bucketCounts), len(h.buckets),
+		))
+	}
+	var inBuckets uint64
+	for _, c := range bucketCounts {
+		inBuckets += c
+	}
+	if inBuckets > count {
+		panic(fmt.Errorf(
+			"bucket counts add up to %d, which is more than the count %d", inBuckets, count,
+		))
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.sum += sum
+	h.count += count
+	for i, c := range bucketCounts {
+		h.counts[i] += c
+	}
+}
+
+// Describe implements Collector.
+func (h *AggregatingHistogram) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+// Collect implements Collector.
+func (h *AggregatingHistogram) Collect(ch chan<- prometheus.Metric) {
+	// The map is retained by the const histogram, so create a new one.
+	cumCounts := make(map[float64]uint64, len(h.buckets))
+	h.mtx.Lock()
+	var cum uint64
+	for i, upperBound := range h.buckets {
+		cum += h.counts[i]
+		cumCounts[upperBound] = cum
+	}
+	count, sum := h.count, h.sum
+	h.mtx.Unlock()
+
+	m, err := prometheus.NewConstHistogram(h.desc, count, sum, cumCounts)
+	if err != nil {
+		m = prometheus.NewInvalidMetric(h.desc, err)
+	}
+	ch <- m
+}
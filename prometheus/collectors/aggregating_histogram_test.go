@@ -0,0 +1,85 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestAggregatingHistogram(t *testing.T) {
+	h := NewAggregatingHistogram(
+		prometheus.NewDesc("span_duration_seconds", "Duration of spans.", nil, nil),
+		[]float64{0.1, 1},
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Add(2.5, 4, []uint64{1, 2})
+		}()
+	}
+	wg.Wait()
+
+	want := `
+		# HELP span_duration_seconds Duration of spans.
+		# TYPE span_duration_seconds histogram
+		span_duration_seconds_bucket{le="0.1"} 10
+		span_duration_seconds_bucket{le="1"} 30
+		span_duration_seconds_bucket{le="+Inf"} 40
+		span_duration_seconds_sum 25
+		span_duration_seconds_count 40
+	`
+	if err := testutil.CollectAndCompare(h, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// Collecting doesn't reset the histogram.
+	h.Add(1, 1, []uint64{1, 0})
+	want = strings.NewReplacer(
+		`{le="0.1"} 10`, `{le="0.1"} 11`,
+		`{le="1"} 30`, `{le="1"} 31`,
+		`{le="+Inf"} 40`, `{le="+Inf"} 41`,
+		"_sum 25", "_sum 26",
+		"_count 40", "_count 41",
+	).Replace(want)
+	if err := testutil.CollectAndCompare(h, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAggregatingHistogramPanics(t *testing.T) {
+	desc := prometheus.NewDesc("span_duration_seconds", "Duration of spans.", nil, nil)
+	for name, f := range map[string]func(){
+		"unsorted buckets": func() { NewAggregatingHistogram(desc, []float64{1, 0.1}) },
+		"too few counts":   func() { NewAggregatingHistogram(desc, []float64{0.1, 1}).Add(1, 1, []uint64{1}) },
+		"too many counts":  func() { NewAggregatingHistogram(desc, []float64{0.1, 1}).Add(1, 1, []uint64{1, 0, 0}) },
+		"counts too high":  func() { NewAggregatingHistogram(desc, []float64{0.1, 1}).Add(1, 1, []uint64{1, 1}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// diskUsage is the usage of the filesystem containing a path, as returned by
+// statDisk.
+type diskUsage struct {
+	totalBytes, freeBytes, usedBytes uint64
+	// The inode counts are only set if hasInodes is true.
+	hasInodes               bool
+	totalInodes, freeInodes uint64
+}
+
+type diskUsageCollector struct {
+	paths []string
+
+	totalBytes  *prometheus.Desc
+	freeBytes   *prometheus.Desc
+	usedBytes   *prometheus.Desc
+	totalInodes *prometheus.Desc
+	freeInodes  *prometheus.Desc
+	errors      *prometheus.CounterVec
+}
+
+// NewDiskUsageCollector returns a collector that exposes the usage of the
+// filesystems containing the provided paths (typically mount points), with
+// the path as the "path" label:
+//
+//   - "disk_total_bytes": the size of the filesystem.
+//   - "disk_free_bytes": the free space available to the process (which
+//     excludes space reserved for privileged users).
+//   - "disk_used_bytes": the used space, i.e. the size minus all free space
+//     (including reserved space).
+//   - "disk_inodes_total" and "disk_inodes_free": the total and free number
+//     of inodes. Those are not exposed on Windows.
+//
+// The usage is read upon each collection, via statfs on Unix-like systems and
+// via GetDiskFreeSpaceEx on Windows. If that fails for a path (e.g. because it
+// does not exist or its filesystem is not mounted), the path is skipped, and
+// the counter "disk_usage_errors_total" with the same "path" label is
+// incremented instead. On platforms without support, reading the usage fails
+// for all paths.
+//
+// NewDiskUsageCollector panics if a path is provided more than once.
+func NewDiskUsageCollector(paths []string) prometheus.Collector {
+	c := &diskUsageCollector{
+		paths: make([]string, 0, len(paths)),
+		totalBytes: prometheus.NewDesc(
+			"disk_total_bytes",
+			"Size of the filesystem containing the path.",
+			[]string{"path"}, nil,
+		),
+		freeBytes: prometheus.NewDesc(
+			"disk_free_bytes",
+			"Free space available to the process on the filesystem containing the path.",
+			[]string{"path"}, nil,
+		),
+		usedBytes: prometheus.NewDesc(
+			"disk_used_bytes",
+			"Used space on the filesystem containing the path.",
+			[]string{"path"}, nil,
+		),
+		totalInodes: prometheus.NewDesc(
+			"disk_inodes_total",
+			"Total number of inodes of the filesystem containing the path.",
+			[]string{"path"}, nil,
+		),
+		freeInodes: prometheus.NewDesc(
+			"disk_inodes_free",
+			"Number of free inodes of the filesystem containing the path.",
+			[]string{"path"}, nil,
+		),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "disk_usage_errors_total",
+				Help: "Total number of errors reading the usage of the filesystem containing the path.",
+			},
+			[]string{"path"},
+		),
+	}
+	seen := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		if _, ok := seen[path]; ok {
+			panic(fmt.Errorf("duplicate disk usage path %q", path))
+		}
+		seen[path] = struct{}{}
+		c.paths = append(c.paths, path)
+		// Initialize so that the counters are visible before the first error.
+		c.errors.WithLabelValues(path)
+	}
+	return c
+}
+
+// Describe implements Collector.
+func (c *diskUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.totalBytes
+	ch <- c.freeBytes
+	ch <- c.usedBytes
+	ch <- c.totalInodes
+	ch <- c.freeInodes
+	c.errors.Describe(ch)
+}
+
+// Collect implements Collector.
+func (c *diskUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, path := range c.paths {
+		u, err := statDisk(path)
+		if err != nil {
+			c.errors.WithLabelValues(path).Inc()
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.totalBytes, prometheus.GaugeValue, float64(u.totalBytes), path)
+		ch <- prometheus.MustNewConstMetric(c.freeBytes, prometheus.GaugeValue, float64(u.freeBytes), path)
+		ch <- prometheus.MustNewConstMetric(c.usedBytes, prometheus.GaugeValue, float64(u.usedBytes), path)
+		if u.hasInodes {
+			ch <- prometheus.MustNewConstMetric(c.totalInodes, prometheus.GaugeValue, float64(u.totalInodes), path)
+			ch <- prometheus.MustNewConstMetric(c.freeInodes, prometheus.GaugeValue, float64(u.freeInodes), path)
+		}
+	}
+	c.errors.Collect(ch)
+}
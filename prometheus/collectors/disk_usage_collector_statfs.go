@@ -0,0 +1,35 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin || freebsd || dragonfly
+// +build linux darwin freebsd dragonfly
+
+package collectors
+
+import "syscall"
+
+func statDisk(path string) (diskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return diskUsage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return diskUsage{
+		totalBytes:  uint64(st.Blocks) * bsize,
+		freeBytes:   uint64(st.Bavail) * bsize,
+		usedBytes:   (uint64(st.Blocks) - uint64(st.Bfree)) * bsize,
+		hasInodes:   true,
+		totalInodes: uint64(st.Files),
+		freeInodes:  uint64(st.Ffree),
+	}, nil
+}
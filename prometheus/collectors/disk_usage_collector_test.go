@@ -0,0 +1,78 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestDiskUsageCollector(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd", "dragonfly", "windows":
+	default:
+		t.Skipf("disk usage not supported on %s", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	c := NewDiskUsageCollector([]string{dir, missing})
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			if m.Label[0].GetValue() != dir {
+				if mf.GetName() != "disk_usage_errors_total" {
+					t.Errorf("unexpected metric %s for path %q", mf.GetName(), m.Label[0].GetValue())
+				}
+				continue
+			}
+			if m.Gauge != nil {
+				values[mf.GetName()] = m.Gauge.GetValue()
+			} else {
+				values[mf.GetName()] = m.Counter.GetValue()
+			}
+		}
+	}
+	if values["disk_total_bytes"] <= 0 {
+		t.Errorf("got total bytes %v, want > 0", values["disk_total_bytes"])
+	}
+	if values["disk_free_bytes"]+values["disk_used_bytes"] > values["disk_total_bytes"] {
+		t.Errorf("free and used bytes exceed total bytes: %v", values)
+	}
+	if values["disk_usage_errors_total"] != 0 {
+		t.Errorf("got %v errors for existing path, want 0", values["disk_usage_errors_total"])
+	}
+	if got := testutil.ToFloat64(c.(*diskUsageCollector).errors.WithLabelValues(missing)); got != 1 {
+		t.Errorf("got %v errors for missing path, want 1", got)
+	}
+}
+
+func TestNewDiskUsageCollectorPanicsOnDuplicatePath(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	NewDiskUsageCollector([]string{"/", "/"})
+}
@@ -0,0 +1,32 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import "golang.org/x/sys/windows"
+
+func statDisk(path string) (diskUsage, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return diskUsage{}, err
+	}
+	var avail, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &avail, &total, &free); err != nil {
+		return diskUsage{}, err
+	}
+	return diskUsage{
+		totalBytes: total,
+		freeBytes:  avail,
+		usedBytes:  total - free,
+	}, nil
+}
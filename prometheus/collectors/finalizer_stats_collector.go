@@ -0,0 +1,139 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"runtime/metrics"
+	"sync"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+const (
+	goFinalizersQueuedMetric   = "/gc/finalizers/queued:finalizers"
+	goFinalizersExecutedMetric = "/gc/finalizers/executed:finalizers"
+	goCleanupsQueuedMetric     = "/gc/cleanups/queued:cleanups"
+	goCleanupsExecutedMetric   = "/gc/cleanups/executed:cleanups"
+)
+
+type finalizerStatsCollector struct {
+	mtx     sync.Mutex // Protects samples.
+	samples []metrics.Sample
+	// Indices of the metrics in samples, -1 if not supported.
+	finalizersQueuedIdx, finalizersExecutedIdx int
+	cleanupsQueuedIdx, cleanupsExecutedIdx     int
+
+	finalizers *prometheus.Desc
+	cleanups   *prometheus.Desc
+}
+
+// NewFinalizerStatsCollector returns a collector that exposes the number of
+// finalizers (as set by runtime.SetFinalizer) that have been queued for
+// execution but have not run yet as the gauge "go_finalizers_pending", and the
+// same for cleanups (as set by runtime.AddCleanup) as the gauge
+// "go_cleanups_pending". The finalizers and cleanups run on a single goroutine,
+// so a steadily growing value hints at a blocking or slow finalizer, which
+// also keeps the memory of all objects waiting behind it alive.
+//
+// The metrics are calculated from the cumulative counters of queued and
+// executed finalizers and cleanups in runtime/metrics. They are only available
+// with Go1.25 or later. With earlier versions, the metrics are omitted.
+func NewFinalizerStatsCollector() prometheus.Collector {
+	c := &finalizerStatsCollector{
+		finalizersQueuedIdx:   -1,
+		finalizersExecutedIdx: -1,
+		cleanupsQueuedIdx:     -1,
+		cleanupsExecutedIdx:   -1,
+		finalizers: prometheus.NewDesc(
+			"go_finalizers_pending",
+			"Number of finalizers queued for execution that have not run yet.",
+			nil, nil,
+		),
+		cleanups: prometheus.NewDesc(
+			"go_cleanups_pending",
+			"Number of cleanups queued for execution that have not run yet.",
+			nil, nil,
+		),
+	}
+	for _, d := range metrics.All() {
+		switch d.Name {
+		case goFinalizersQueuedMetric:
+			c.finalizersQueuedIdx = len(c.samples)
+		case goFinalizersExecutedMetric:
+			c.finalizersExecutedIdx = len(c.samples)
+		case goCleanupsQueuedMetric:
+			c.cleanupsQueuedIdx = len(c.samples)
+		case goCleanupsExecutedMetric:
+			c.cleanupsExecutedIdx = len(c.samples)
+		default:
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+	}
+	return c
+}
+
+func (c *finalizerStatsCollector) hasFinalizers() bool {
+	return c.finalizersQueuedIdx >= 0 && c.finalizersExecutedIdx >= 0
+}
+
+func (c *finalizerStatsCollector) hasCleanups() bool {
+	return c.cleanupsQueuedIdx >= 0 && c.cleanupsExecutedIdx >= 0
+}
+
+// Describe implements Collector.
+func (c *finalizerStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	if c.hasFinalizers() {
+		ch <- c.finalizers
+	}
+	if c.hasCleanups() {
+		ch <- c.cleanups
+	}
+}
+
+// Collect implements Collector.
+func (c *finalizerStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	if len(c.samples) == 0 {
+		return
+	}
+	c.mtx.Lock()
+	metrics.Read(c.samples)
+	var finalizers, cleanups float64
+	if c.hasFinalizers() {
+		finalizers = pending(c.samples[c.finalizersQueuedIdx], c.samples[c.finalizersExecutedIdx])
+	}
+	if c.hasCleanups() {
+		cleanups = pending(c.samples[c.cleanupsQueuedIdx], c.samples[c.cleanupsExecutedIdx])
+	}
+	c.mtx.Unlock()
+
+	if c.hasFinalizers() {
+		ch <- prometheus.MustNewConstMetric(c.finalizers, prometheus.GaugeValue, finalizers)
+	}
+	if c.hasCleanups() {
+		ch <- prometheus.MustNewConstMetric(c.cleanups, prometheus.GaugeValue, cleanups)
+	}
+}
+
+// pending returns the difference between the queued and the executed count.
+// Both are read independently of each other, so the executed count might
+// already include a finalizer that the queued count does not yet, in which
+// case 0 is returned.
+func pending(queued, executed metrics.Sample) float64 {
+	q, e := queued.Value.Uint64(), executed.Value.Uint64()
+	if e >= q {
+		return 0
+	}
+	return float64(q - e)
+}
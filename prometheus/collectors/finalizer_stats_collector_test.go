@@ -0,0 +1,75 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestFinalizerStatsCollector(t *testing.T) {
+	var supported bool
+	for _, d := range metrics.All() {
+		if d.Name == goFinalizersQueuedMetric {
+			supported = true
+		}
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewFinalizerStatsCollector())
+	if !supported {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mfs) != 0 {
+			t.Errorf("expected no metrics without runtime support, got %v", mfs)
+		}
+		return
+	}
+
+	// Block the finalizer goroutine with the first finalizer so that the
+	// other ones stay pending.
+	release := make(chan struct{})
+	defer close(release)
+	for i := 0; i < 3; i++ {
+		runtime.SetFinalizer(new([16]byte), func(*[16]byte) { <-release })
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runtime.GC()
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pending float64
+		for _, mf := range mfs {
+			if mf.GetName() == "go_finalizers_pending" {
+				pending = mf.Metric[0].Gauge.GetValue()
+			}
+		}
+		if pending >= 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v pending finalizers, want at least 1", pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.25
+// +build go1.25
+
+package collectors
+
+import (
+	"runtime/trace"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+type flightRecorderCollector struct {
+	fr  *trace.FlightRecorder
+	cfg trace.FlightRecorderConfig
+
+	enabled  *prometheus.Desc
+	minAge   *prometheus.Desc
+	maxBytes *prometheus.Desc
+}
+
+// NewFlightRecorderCollector returns a collector that exposes the state of the
+// provided flight recorder of the Go execution tracer, so that it can be
+// confirmed that the flight recorder is armed. The gauge
+// "go_trace_flight_recorder_enabled" is 1 while the flight recorder is
+// started and 0 otherwise. The provided config, which has to be the one the
+// flight recorder has been created with, is exposed as the gauges
+// "go_trace_flight_recorder_min_age_seconds" and
+// "go_trace_flight_recorder_max_bytes". A value of 0 means that the runtime
+// uses an implementation-defined default.
+//
+// The runtime doesn't provide access to its flight recorder, hence the
+// flight recorder has to be passed in. The collector is only available with
+// Go1.25 or later, as earlier versions lack the flight recorder API.
+func NewFlightRecorderCollector(fr *trace.FlightRecorder, cfg trace.FlightRecorderConfig) prometheus.Collector {
+	return &flightRecorderCollector{
+		fr:  fr,
+		cfg: cfg,
+		enabled: prometheus.NewDesc(
+			"go_trace_flight_recorder_enabled",
+			"Whether the flight recorder of the Go execution tracer is started (1) or not (0).",
+			nil, nil,
+		),
+		minAge: prometheus.NewDesc(
+			"go_trace_flight_recorder_min_age_seconds",
+			"Configured lower bound on the age of events in the flight recorder window. 0 means implementation defined.",
+			nil, nil,
+		),
+		maxBytes: prometheus.NewDesc(
+			"go_trace_flight_recorder_max_bytes",
+			"Configured upper bound on the size of the flight recorder window in bytes. 0 means implementation defined.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements Collector.
+func (c *flightRecorderCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.enabled
+	ch <- c.minAge
+	ch <- c.maxBytes
+}
+
+// Collect implements Collector.
+func (c *flightRecorderCollector) Collect(ch chan<- prometheus.Metric) {
+	var enabled float64
+	if c.fr.Enabled() {
+		enabled = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.enabled, prometheus.GaugeValue, enabled)
+	ch <- prometheus.MustNewConstMetric(c.minAge, prometheus.GaugeValue, c.cfg.MinAge.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxBytes, prometheus.GaugeValue, float64(c.cfg.MaxBytes))
+}
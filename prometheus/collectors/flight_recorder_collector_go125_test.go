@@ -0,0 +1,55 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.25
+// +build go1.25
+
+package collectors
+
+import (
+	"runtime/trace"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestFlightRecorderCollector(t *testing.T) {
+	cfg := trace.FlightRecorderConfig{MinAge: 5 * time.Second, MaxBytes: 1 << 20}
+	fr := trace.NewFlightRecorder(cfg)
+	c := NewFlightRecorderCollector(fr, cfg)
+
+	const expected = `
+# HELP go_trace_flight_recorder_enabled Whether the flight recorder of the Go execution tracer is started (1) or not (0).
+# TYPE go_trace_flight_recorder_enabled gauge
+go_trace_flight_recorder_enabled %d
+# HELP go_trace_flight_recorder_max_bytes Configured upper bound on the size of the flight recorder window in bytes. 0 means implementation defined.
+# TYPE go_trace_flight_recorder_max_bytes gauge
+go_trace_flight_recorder_max_bytes 1.048576e+06
+# HELP go_trace_flight_recorder_min_age_seconds Configured lower bound on the age of events in the flight recorder window. 0 means implementation defined.
+# TYPE go_trace_flight_recorder_min_age_seconds gauge
+go_trace_flight_recorder_min_age_seconds 5
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(strings.Replace(expected, "%d", "0", 1))); err != nil {
+		t.Error(err)
+	}
+
+	if err := fr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer fr.Stop()
+	if err := testutil.CollectAndCompare(c, strings.NewReader(strings.Replace(expected, "%d", "1", 1))); err != nil {
+		t.Error(err)
+	}
+}
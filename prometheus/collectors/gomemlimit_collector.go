@@ -0,0 +1,106 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"runtime/metrics"
+	"sync"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+const (
+	goMemLimitMetric = "/gc/gomemlimit:bytes"
+	goHeapLiveMetric = "/gc/heap/live:bytes"
+)
+
+type goMemLimitCollector struct {
+	mtx     sync.Mutex // Protects samples.
+	samples []metrics.Sample
+	// Indices of the metrics in samples, -1 if not supported.
+	limitIdx, liveIdx int
+
+	limit *prometheus.Desc
+	ratio *prometheus.Desc
+}
+
+// NewGoMemLimitCollector returns a collector that exposes the soft memory limit
+// of the Go runtime (as set by GOMEMLIMIT or debug.SetMemoryLimit) as the gauge
+// "go_memory_limit_bytes", and the ratio of the live heap (as of the last GC)
+// to the memory limit as the gauge "go_memory_limit_heap_live_ratio". The
+// latter allows alerting before the GC has to work hard to stay below the
+// limit. Note that without a configured limit, the limit is math.MaxInt64, and
+// the ratio is accordingly close to 0.
+//
+// The metrics are read from runtime/metrics. They are only available with
+// Go1.21 or later. With earlier versions, the metrics are omitted.
+func NewGoMemLimitCollector() prometheus.Collector {
+	c := &goMemLimitCollector{
+		limitIdx: -1,
+		liveIdx:  -1,
+		limit: prometheus.NewDesc(
+			"go_memory_limit_bytes",
+			"Go runtime soft memory limit.",
+			nil, nil,
+		),
+		ratio: prometheus.NewDesc(
+			"go_memory_limit_heap_live_ratio",
+			"Ratio of the live heap as of the last GC to the Go runtime soft memory limit.",
+			nil, nil,
+		),
+	}
+	for _, d := range metrics.All() {
+		switch d.Name {
+		case goMemLimitMetric:
+			c.limitIdx = len(c.samples)
+		case goHeapLiveMetric:
+			c.liveIdx = len(c.samples)
+		default:
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+	}
+	return c
+}
+
+// Describe implements Collector.
+func (c *goMemLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	if c.limitIdx < 0 {
+		return
+	}
+	ch <- c.limit
+	if c.liveIdx >= 0 {
+		ch <- c.ratio
+	}
+}
+
+// Collect implements Collector.
+func (c *goMemLimitCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.limitIdx < 0 {
+		return
+	}
+	c.mtx.Lock()
+	metrics.Read(c.samples)
+	limit := float64(c.samples[c.limitIdx].Value.Uint64())
+	var live float64
+	if c.liveIdx >= 0 {
+		live = float64(c.samples[c.liveIdx].Value.Uint64())
+	}
+	c.mtx.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(c.limit, prometheus.GaugeValue, limit)
+	if c.liveIdx >= 0 && limit > 0 {
+		ch <- prometheus.MustNewConstMetric(c.ratio, prometheus.GaugeValue, live/limit)
+	}
+}
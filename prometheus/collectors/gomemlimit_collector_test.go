@@ -0,0 +1,61 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestGoMemLimitCollector(t *testing.T) {
+	var supported bool
+	for _, d := range metrics.All() {
+		if d.Name == goMemLimitMetric {
+			supported = true
+		}
+	}
+
+	const limit = 1 << 40
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(limit))
+	runtime.GC()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewGoMemLimitCollector())
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !supported {
+		if len(mfs) != 0 {
+			t.Errorf("expected no metrics without runtime support, got %v", mfs)
+		}
+		return
+	}
+	if len(mfs) != 2 {
+		t.Fatalf("expected 2 metric families, got %v", mfs)
+	}
+	if got := mfs[0].GetName(); got != "go_memory_limit_bytes" {
+		t.Errorf("unexpected metric family %q", got)
+	}
+	if got := mfs[0].Metric[0].Gauge.GetValue(); got != limit {
+		t.Errorf("got limit %v, want %v", got, float64(limit))
+	}
+	if got := mfs[1].Metric[0].Gauge.GetValue(); got <= 0 || got >= 1 {
+		t.Errorf("got live heap ratio %v, want between 0 and 1", got)
+	}
+}
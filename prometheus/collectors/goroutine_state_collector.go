@@ -0,0 +1,153 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+const (
+	// maxGoroutineStates is the maximum number of distinct states exposed.
+	// The goroutines in all less frequent states are counted as "other".
+	maxGoroutineStates  = 16
+	otherGoroutineState = "other"
+	// initialStackBufSize is the initial size of the buffer the stacks of
+	// all goroutines are written to. It grows as needed.
+	initialStackBufSize = 64 << 10
+)
+
+type goroutineStateCollector struct {
+	mtx sync.Mutex // Protects buf.
+	buf []byte
+
+	desc *prometheus.Desc
+}
+
+// NewGoroutineStateCollector returns a collector that exposes the number of
+// goroutines by their state as shown in a goroutine dump (e.g. "running",
+// "select", "chan receive", "IO wait") as the gauge "go_goroutines_by_state"
+// with the label "state". It helps debugging goroutine leaks, which typically
+// show up as a growing number of goroutines in one particular state. To limit
+// the cardinality, only the 16 most frequent states are exposed. The
+// goroutines in all other states are counted in the state "other".
+//
+// This collector is expensive: Upon each collection, the stacks of all
+// goroutines are dumped (as with runtime.Stack), which stops the world for a
+// duration proportional to the number of goroutines. In a process with many
+// goroutines, consider a long scrape interval or wrapping the collector with
+// prometheus.NewPeriodicCollector. For the total number of goroutines, use the
+// much cheaper "go_goroutines" of the Go collector.
+func NewGoroutineStateCollector() prometheus.Collector {
+	return &goroutineStateCollector{
+		desc: prometheus.NewDesc(
+			"go_goroutines_by_state",
+			"Number of goroutines by state as shown in a goroutine dump.",
+			[]string{"state"}, nil,
+		),
+	}
+}
+
+// Describe implements Collector.
+func (c *goroutineStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements Collector.
+func (c *goroutineStateCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	counts := countGoroutineStates(c.stacks())
+	c.mtx.Unlock()
+
+	for state, count := range capGoroutineStates(counts, maxGoroutineStates) {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), state)
+	}
+}
+
+// stacks returns the stacks of all goroutines. It needs mtx locked.
+func (c *goroutineStateCollector) stacks() []byte {
+	if c.buf == nil {
+		c.buf = make([]byte, initialStackBufSize)
+	}
+	for {
+		n := runtime.Stack(c.buf, true)
+		if n < len(c.buf) {
+			return c.buf[:n]
+		}
+		c.buf = make([]byte, 2*len(c.buf))
+	}
+}
+
+var goroutineHeaderPrefix = []byte("goroutine ")
+
+// countGoroutineStates counts the goroutines in a goroutine dump by state.
+// Each goroutine starts with a header like "goroutine 42 [chan receive, 5
+// minutes]:". The state is the part within brackets up to the first comma.
+func countGoroutineStates(stacks []byte) map[string]int {
+	counts := map[string]int{}
+	for len(stacks) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(stacks, '\n'); i >= 0 {
+			line, stacks = stacks[:i], stacks[i+1:]
+		} else {
+			line, stacks = stacks, nil
+		}
+		if !bytes.HasPrefix(line, goroutineHeaderPrefix) {
+			continue
+		}
+		start := bytes.IndexByte(line, '[')
+		end := bytes.LastIndexByte(line, ']')
+		if start < 0 || end < start {
+			continue
+		}
+		state := line[start+1 : end]
+		if i := bytes.IndexByte(state, ','); i >= 0 {
+			state = state[:i]
+		}
+		counts[string(state)]++
+	}
+	return counts
+}
+
+// capGoroutineStates returns counts with all but the limit most frequent states
+// merged into otherGoroutineState. Ties are broken by state name to keep the
+// result stable.
+func capGoroutineStates(counts map[string]int, limit int) map[string]int {
+	if len(counts) <= limit {
+		return counts
+	}
+	states := make([]string, 0, len(counts))
+	for state := range counts {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if counts[states[i]] != counts[states[j]] {
+			return counts[states[i]] > counts[states[j]]
+		}
+		return states[i] < states[j]
+	})
+	capped := make(map[string]int, limit+1)
+	for i, state := range states {
+		if i < limit {
+			capped[state] += counts[state]
+		} else {
+			capped[otherGoroutineState] += counts[state]
+		}
+	}
+	return capped
+}
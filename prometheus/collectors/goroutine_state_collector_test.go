@@ -0,0 +1,106 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestGoroutineStateCollector(t *testing.T) {
+	const n = 10
+	block := make(chan struct{})
+	defer close(block)
+	for i := 0; i < n; i++ {
+		go func() { <-block }()
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewGoroutineStateCollector())
+	// The goroutines might not have blocked yet, so retry for a while.
+	var counts map[string]float64
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mfs) != 1 || mfs[0].GetName() != "go_goroutines_by_state" {
+			t.Fatalf("unexpected metric families %v", mfs)
+		}
+		counts = map[string]float64{}
+		for _, m := range mfs[0].Metric {
+			counts[m.Label[0].GetValue()] = m.Gauge.GetValue()
+		}
+		if counts["chan receive"] >= n {
+			break
+		}
+	}
+	if counts["chan receive"] < n {
+		t.Errorf("expected at least %d goroutines in state \"chan receive\", got %v", n, counts)
+	}
+	if counts["running"] < 1 {
+		t.Errorf("expected at least one running goroutine, got %v", counts)
+	}
+}
+
+func TestCountGoroutineStates(t *testing.T) {
+	stacks := []byte(`goroutine 1 [running]:
+main.main()
+	/tmp/main.go:10 +0x1d
+
+goroutine 18 [chan receive, 5 minutes]:
+main.worker()
+	/tmp/main.go:20 +0x2e
+
+goroutine 19 [chan receive]:
+main.worker()
+
+goroutine 20 [select, locked to thread]:
+runtime.ensureSigM.func1()
+
+goroutine 21 [IO wait]:
+internal/poll.runtime_pollWait()`)
+
+	want := map[string]int{"running": 1, "chan receive": 2, "select": 1, "IO wait": 1}
+	if got := countGoroutineStates(stacks); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCapGoroutineStates(t *testing.T) {
+	counts := map[string]int{}
+	for i := 0; i < 20; i++ {
+		counts[fmt.Sprintf("state%02d", i)] = i + 1
+	}
+
+	got := capGoroutineStates(counts, 16)
+	if len(got) != 17 {
+		t.Fatalf("expected 16 states and %q, got %v", otherGoroutineState, got)
+	}
+	for i := 0; i < 4; i++ {
+		if _, ok := got[fmt.Sprintf("state%02d", i)]; ok {
+			t.Errorf("expected rare state%02d to be merged into %q", i, otherGoroutineState)
+		}
+	}
+	if got[otherGoroutineState] != 1+2+3+4 {
+		t.Errorf("got %d goroutines in %q, want %d", got[otherGoroutineState], otherGoroutineState, 1+2+3+4)
+	}
+	if got["state19"] != 20 {
+		t.Errorf("got %d goroutines in state19, want 20", got["state19"])
+	}
+}
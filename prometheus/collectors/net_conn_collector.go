@@ -0,0 +1,176 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// netConnStates are the names of the socket states in the "st" column of the
+// socket tables in /proc/net, indexed by their numeric value (see
+// include/net/tcp_states.h in the Linux sources). UDP sockets use the same
+// values, but only "established" (connected) and "close" (unconnected).
+var netConnStates = [...]string{
+	1:  "established",
+	2:  "syn_sent",
+	3:  "syn_recv",
+	4:  "fin_wait1",
+	5:  "fin_wait2",
+	6:  "time_wait",
+	7:  "close",
+	8:  "close_wait",
+	9:  "last_ack",
+	10: "listen",
+	11: "closing",
+	12: "new_syn_recv",
+}
+
+// netConnUnknownState is the state label value for any state not contained in
+// netConnStates.
+const netConnUnknownState = "unknown"
+
+// netConnProtos are the protocols in the order they are collected, which are
+// also the names of their socket tables in /proc/net.
+var netConnProtos = []string{"tcp", "tcp6", "udp", "udp6"}
+
+type netConnCollector struct {
+	procPath string
+
+	conns *prometheus.Desc
+}
+
+// NewNetConnCollector returns a collector that exposes the number of sockets of
+// the network namespace of the process by protocol and state as the gauge
+// "net_connections" with the labels "proto" ("tcp", "tcp6", "udp", or "udp6")
+// and "state" (e.g. "established" or "listen", see the Linux header
+// include/net/tcp_states.h for all state names, or "unknown" for states not
+// known to this collector). For each protocol, all TCP states are exposed,
+// including those without any socket, with the exception of UDP, for which
+// only the states "established" (connected sockets) and "close" (unconnected
+// sockets) are exposed. This is similar to the output of "ss -s", without
+// having to run it.
+//
+// The socket tables are read from /proc/net/tcp, /proc/net/tcp6,
+// /proc/net/udp, and /proc/net/udp6 upon each collection. Thus, the
+// collector only works on Linux. On other operating systems, or if the tables
+// don't exist (e.g. /proc/net/tcp6 with IPv6 disabled), no metrics are
+// collected for the respective protocols. Note that reading a socket table
+// takes time proportional to the number of sockets, which is a consideration
+// for servers with a huge number of connections.
+func NewNetConnCollector() prometheus.Collector {
+	return newNetConnCollector("/proc")
+}
+
+func newNetConnCollector(procPath string) *netConnCollector {
+	return &netConnCollector{
+		procPath: procPath,
+		conns: prometheus.NewDesc(
+			"net_connections",
+			"Number of sockets by protocol and state.",
+			[]string{"proto", "state"}, nil,
+		),
+	}
+}
+
+// Describe implements Collector.
+func (c *netConnCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.conns
+}
+
+// Collect implements Collector.
+func (c *netConnCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, proto := range netConnProtos {
+		counts, unknown, err := readNetConnStates(filepath.Join(c.procPath, "net", proto))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.conns, err)
+			continue
+		}
+		isUDP := proto == "udp" || proto == "udp6"
+		for st, name := range netConnStates {
+			if name == "" || (isUDP && name != "established" && name != "close") {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(counts[st]), proto, name)
+		}
+		if unknown > 0 {
+			ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(unknown), proto, netConnUnknownState)
+		}
+	}
+}
+
+// readNetConnStates reads the socket table in the provided file and returns
+// the number of sockets by state (indexed as netConnStates) and the number of
+// sockets in states not contained in netConnStates.
+func readNetConnStates(path string) (counts [len(netConnStates)]int, unknown int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return counts, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip the header line.
+	for scanner.Scan() {
+		st, ok := netConnState(scanner.Bytes())
+		switch {
+		case !ok:
+			return counts, 0, fmt.Errorf("invalid line in %s: %q", path, scanner.Text())
+		case st > 0 && st < len(netConnStates) && netConnStates[st] != "":
+			counts[st]++
+		default:
+			unknown++
+		}
+	}
+	return counts, unknown, scanner.Err()
+}
+
+// netConnState returns the hexadecimal value of the fourth whitespace-separated
+// field of the provided line of a socket table, which is the socket state.
+func netConnState(line []byte) (int, bool) {
+	var field []byte
+	for i := 0; i < 4; i++ {
+		line = bytes.TrimLeft(line, " ")
+		end := bytes.IndexByte(line, ' ')
+		if end < 0 {
+			end = len(line)
+		}
+		field, line = line[:end], line[end:]
+	}
+	if len(field) == 0 || len(field) > 2 {
+		return 0, false
+	}
+	var st int
+	for _, b := range field {
+		switch {
+		case b >= '0' && b <= '9':
+			st = st<<4 | int(b-'0')
+		case b >= 'A' && b <= 'F':
+			st = st<<4 | int(b-'A'+10)
+		case b >= 'a' && b <= 'f':
+			st = st<<4 | int(b-'a'+10)
+		default:
+			return 0, false
+		}
+	}
+	return st, true
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestNetConnCollector(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "net"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:07E8 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 662 1 000000005b506984 100 0 0 10 0
+   1: 0100007F:BC8F 0100007F:07E8 01 00000000:00000000 00:00000000 00000000 65534        0 860 1 00000000e4230f94 100 0 0 10 0
+   2: 0100007F:BC90 0100007F:07E8 01 00000000:00000000 00:00000000 00000000 65534        0 861 1 00000000e4230f95 100 0 0 10 0
+   3: 0100007F:BC91 0100007F:07E8 06 00000000:00000000 00:00000000 00000000     0        0 0 3 0000000000000000
+   4: 0100007F:BC92 0100007F:07E8 1F 00000000:00000000 00:00000000 00000000     0        0 0 3 0000000000000000
+`
+	udp := `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 00000000:0044 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1234 2 0000000000000000 0
+`
+	for name, content := range map[string]string{"tcp": tcp, "udp": udp} {
+		if err := os.WriteFile(filepath.Join(dir, "net", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `
+		# HELP net_connections Number of sockets by protocol and state.
+		# TYPE net_connections gauge
+		net_connections{proto="tcp",state="close"} 0
+		net_connections{proto="tcp",state="close_wait"} 0
+		net_connections{proto="tcp",state="closing"} 0
+		net_connections{proto="tcp",state="established"} 2
+		net_connections{proto="tcp",state="fin_wait1"} 0
+		net_connections{proto="tcp",state="fin_wait2"} 0
+		net_connections{proto="tcp",state="last_ack"} 0
+		net_connections{proto="tcp",state="listen"} 1
+		net_connections{proto="tcp",state="new_syn_recv"} 0
+		net_connections{proto="tcp",state="syn_recv"} 0
+		net_connections{proto="tcp",state="syn_sent"} 0
+		net_connections{proto="tcp",state="time_wait"} 1
+		net_connections{proto="tcp",state="unknown"} 1
+		net_connections{proto="udp",state="close"} 1
+		net_connections{proto="udp",state="established"} 0
+	`
+	if err := testutil.CollectAndCompare(newNetConnCollector(dir), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// A malformed table is reported as an error.
+	if err := os.WriteFile(filepath.Join(dir, "net", "tcp6"), []byte("header\n   0: garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(newNetConnCollector(dir))
+	if _, err := reg.Gather(); err == nil || !strings.Contains(err.Error(), "invalid line") {
+		t.Errorf("got error %v, want error about invalid line", err)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package opencensus provides a collector bridging OpenCensus views to
+// Prometheus metrics. It is a separate Go module so that the (deprecated)
+// OpenCensus dependency is only pulled in by users of the bridge.
+package opencensus
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opencensus.io/stats/view"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+type bridge struct {
+	views []*view.View
+	descs []*prometheus.Desc
+}
+
+// NewBridge returns a collector that exposes the data aggregated by
+// the provided OpenCensus views as Prometheus metrics. It is meant to ease an
+// incremental migration from OpenCensus instrumentation without having to
+// instrument the same code twice.
+//
+// The views have to be registered with OpenCensus (see view.Register) for
+// data to be collected. The metric name is derived from the view name, and the
+// view's tag keys are used as label names. Characters that are invalid in
+// metric and label names are replaced by underscores. The aggregations are
+// mapped as follows:
+//   - Count aggregations are exposed as counters, with the suffix "_total"
+//     added to the metric name unless it ends with "_total" already.
+//   - Sum aggregations are exposed as untyped metrics, as OpenCensus sums are
+//     not guaranteed to only ever increase.
+//   - Distribution aggregations are exposed as histograms with the buckets
+//     of the aggregation.
+//   - LastValue aggregations are exposed as gauges.
+//
+// Note that the collected data is retrieved with view.RetrieveData upon each
+// collection, which is synchronized with the recording of measurements in
+// OpenCensus.
+func NewBridge(views ...*view.View) prometheus.Collector {
+	c := &bridge{
+		views: views,
+		descs: make([]*prometheus.Desc, len(views)),
+	}
+	for i, v := range views {
+		labels := make([]string, len(v.TagKeys))
+		for j, k := range v.TagKeys {
+			labels[j] = sanitizeOpenCensusName(k.Name())
+		}
+		help := v.Description
+		if help == "" && v.Measure != nil {
+			help = v.Measure.Description()
+		}
+		name := sanitizeOpenCensusName(openCensusViewName(v))
+		if v.Aggregation != nil && v.Aggregation.Type == view.AggTypeCount && !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+		c.descs[i] = prometheus.NewDesc(name, help, labels, nil)
+	}
+	return c
+}
+
+// Describe implements Collector.
+func (c *bridge) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descs {
+		ch <- d
+	}
+}
+
+// Collect implements Collector.
+func (c *bridge) Collect(ch chan<- prometheus.Metric) {
+	for i, v := range c.views {
+		desc := c.descs[i]
+		rows, err := view.RetrieveData(openCensusViewName(v))
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		for _, row := range rows {
+			ch <- openCensusRowToMetric(desc, v, row)
+		}
+	}
+}
+
+func openCensusRowToMetric(desc *prometheus.Desc, v *view.View, row *view.Row) prometheus.Metric {
+	labelValues := make([]string, len(v.TagKeys))
+	for i, k := range v.TagKeys {
+		for _, t := range row.Tags {
+			if t.Key == k {
+				labelValues[i] = t.Value
+				break
+			}
+		}
+	}
+
+	var (
+		m   prometheus.Metric
+		err error
+	)
+	switch data := row.Data.(type) {
+	case *view.CountData:
+		m, err = prometheus.NewConstMetricWithCreatedTimestamp(
+			desc, prometheus.CounterValue, float64(data.Value), data.Start, labelValues...,
+		)
+	case *view.SumData:
+		m, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, data.Value, labelValues...)
+	case *view.LastValueData:
+		m, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, data.Value, labelValues...)
+	case *view.DistributionData:
+		var (
+			bounds  = v.Aggregation.Buckets
+			buckets = make(map[float64]uint64, len(bounds))
+			cum     uint64
+		)
+		// OpenCensus has one more bucket than bounds, which is the
+		// implicit +Inf bucket in Prometheus.
+		for i, b := range bounds {
+			if i < len(data.CountPerBucket) {
+				cum += uint64(data.CountPerBucket[i])
+			}
+			buckets[b] = cum
+		}
+		m, err = prometheus.NewConstHistogram(
+			desc, uint64(data.Count), data.Sum(), buckets, labelValues...,
+		)
+	default:
+		err = fmt.Errorf("unsupported OpenCensus aggregation data %T for view %q", row.Data, v.Name)
+	}
+	if err != nil {
+		return prometheus.NewInvalidMetric(desc, err)
+	}
+	return m
+}
+
+// openCensusViewName returns the name of the view with the same fallback to
+// the measure name as view.Register applies.
+func openCensusViewName(v *view.View) string {
+	if v.Name == "" && v.Measure != nil {
+		return v.Measure.Name()
+	}
+	return v.Name
+}
+
+// sanitizeOpenCensusName replaces all characters that are invalid in
+// Prometheus metric and label names by underscores.
+func sanitizeOpenCensusName(name string) string {
+	if name == "" {
+		return name
+	}
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
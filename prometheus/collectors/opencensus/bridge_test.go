@@ -0,0 +1,125 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensus
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestBridge(t *testing.T) {
+	var (
+		latency = stats.Float64("test.io/latency", "Request latency in seconds.", stats.UnitSeconds)
+		method  = tag.MustNewKey("http.method")
+	)
+	views := []*view.View{
+		{
+			Name:        "test.io/requests",
+			Description: "Number of requests.",
+			Measure:     latency,
+			TagKeys:     []tag.Key{method},
+			Aggregation: view.Count(),
+		},
+		{
+			Name:        "test.io/latency_accumulated",
+			Description: "Sum of request latencies.",
+			Measure:     latency,
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        "test.io/latency_last",
+			Description: "Last request latency.",
+			Measure:     latency,
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        "test.io/latency",
+			Description: "Distribution of request latencies.",
+			Measure:     latency,
+			TagKeys:     []tag.Key{method},
+			Aggregation: view.Distribution(0.1, 1),
+		},
+	}
+	if err := view.Register(views...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(views...)
+
+	for _, r := range []struct {
+		method string
+		value  float64
+	}{
+		{"GET", 0.05},
+		{"GET", 0.5},
+		{"POST", 2},
+	} {
+		ctx, err := tag.New(context.Background(), tag.Insert(method, r.method))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats.Record(ctx, latency.M(r.value))
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewBridge(views...))
+
+	const expected = `
+# HELP test_io_latency Distribution of request latencies.
+# TYPE test_io_latency histogram
+test_io_latency_bucket{http_method="GET",le="0.1"} 1
+test_io_latency_bucket{http_method="GET",le="1"} 2
+test_io_latency_bucket{http_method="GET",le="+Inf"} 2
+test_io_latency_sum{http_method="GET"} 0.55
+test_io_latency_count{http_method="GET"} 2
+test_io_latency_bucket{http_method="POST",le="0.1"} 0
+test_io_latency_bucket{http_method="POST",le="1"} 0
+test_io_latency_bucket{http_method="POST",le="+Inf"} 1
+test_io_latency_sum{http_method="POST"} 2
+test_io_latency_count{http_method="POST"} 1
+# HELP test_io_latency_last Last request latency.
+# TYPE test_io_latency_last gauge
+test_io_latency_last 2
+# HELP test_io_latency_accumulated Sum of request latencies.
+# TYPE test_io_latency_accumulated untyped
+test_io_latency_accumulated 2.55
+# HELP test_io_requests_total Number of requests.
+# TYPE test_io_requests_total counter
+test_io_requests_total{http_method="GET"} 2
+test_io_requests_total{http_method="POST"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBridgeUnregisteredView(t *testing.T) {
+	v := &view.View{
+		Name:        "test.io/unregistered",
+		Measure:     stats.Int64("test.io/unregistered", "Unregistered.", stats.UnitDimensionless),
+		Aggregation: view.Count(),
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewBridge(v))
+	if _, err := reg.Gather(); err == nil {
+		t.Error("expected error for unregistered view")
+	}
+}
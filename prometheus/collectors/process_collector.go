@@ -45,7 +45,12 @@ type ProcessCollectorOpts struct {
 //
 // The collector only works on operating systems with a Linux-style proc
 // filesystem and on Microsoft Windows. On other operating systems, it will not
-// collect any metrics.
+// collect any metrics, except for the process start time, which is also
+// supported on Darwin (via sysctl). Where the start time of the current process
+// can't be read from the operating system, it is approximated by the time the
+// process initialized the prometheus package, and the gauge
+// "process_start_time_approximated" is collected with a value of 1 to flag
+// that.
 func NewProcessCollector(opts ProcessCollectorOpts) prometheus.Collector {
 	//nolint:staticcheck // Ignore SA1019 until v2.
 	return prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{
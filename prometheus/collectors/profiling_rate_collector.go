@@ -0,0 +1,191 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// blockProfileRate is the rate last set with SetBlockProfileRate. Accessed
+// atomically.
+var blockProfileRate int64
+
+// SetBlockProfileRate calls runtime.SetBlockProfileRate with the provided rate
+// and records the rate so that it can be exposed by a collector created with
+// NewProfilingRateCollector. The runtime offers no way to read the block
+// profile rate, so a rate set by calling runtime.SetBlockProfileRate directly
+// is not visible to the collector.
+func SetBlockProfileRate(rate int) {
+	runtime.SetBlockProfileRate(rate)
+	atomic.StoreInt64(&blockProfileRate, int64(rate))
+}
+
+// ProfilingRateOption is an option for NewProfilingRateCollector.
+type ProfilingRateOption func(*profilingRateCollector)
+
+// WithMutexContentionLocations makes the collector created with
+// NewProfilingRateCollector expose the counter
+// "go_mutex_profile_contentions_total" with the label "location", which is the
+// number of contention events recorded in the mutex profile for the limit most
+// contended locations (by total delay).
+// The location is the function that released the contended lock, which is
+// typically the function holding it for too long. Note that only a fraction of
+// the contention events are recorded, according to the mutex profile fraction,
+// and that nothing is recorded while mutex profiling is disabled.
+func WithMutexContentionLocations(limit int) ProfilingRateOption {
+	return func(c *profilingRateCollector) {
+		c.maxLocations = limit
+	}
+}
+
+type profilingRateCollector struct {
+	maxLocations int
+
+	mutexFraction *prometheus.Desc
+	blockRate     *prometheus.Desc
+	contentions   *prometheus.Desc
+}
+
+// NewProfilingRateCollector returns a collector that exposes the configured
+// rates of the mutex and block profiles of the Go runtime as the gauges
+// "go_mutex_profile_fraction" (as set with runtime.SetMutexProfileFraction) and
+// "go_block_profile_rate" (as set with SetBlockProfileRate of this package,
+// see there). A value of 0 means that the profile is disabled. This helps
+// verifying that profiling has actually been enabled (or disabled again) when
+// debugging contention. Use WithMutexContentionLocations to also expose the
+// most contended mutex locations.
+func NewProfilingRateCollector(opts ...ProfilingRateOption) prometheus.Collector {
+	c := &profilingRateCollector{
+		mutexFraction: prometheus.NewDesc(
+			"go_mutex_profile_fraction",
+			"Rate of mutex contention events reported in the mutex profile as set by runtime.SetMutexProfileFraction.",
+			nil, nil,
+		),
+		blockRate: prometheus.NewDesc(
+			"go_block_profile_rate",
+			"Rate of blocking events (in nanoseconds spent blocked) reported in the block profile as set by runtime.SetBlockProfileRate.",
+			nil, nil,
+		),
+		contentions: prometheus.NewDesc(
+			"go_mutex_profile_contentions_total",
+			"Number of contention events recorded in the mutex profile for the most contended locations.",
+			[]string{"location"}, nil,
+		),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Describe implements Collector.
+func (c *profilingRateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.mutexFraction
+	ch <- c.blockRate
+	if c.maxLocations > 0 {
+		ch <- c.contentions
+	}
+}
+
+// Collect implements Collector.
+func (c *profilingRateCollector) Collect(ch chan<- prometheus.Metric) {
+	// A negative argument reads the fraction without changing it.
+	ch <- prometheus.MustNewConstMetric(c.mutexFraction, prometheus.GaugeValue, float64(runtime.SetMutexProfileFraction(-1)))
+	ch <- prometheus.MustNewConstMetric(c.blockRate, prometheus.GaugeValue, float64(atomic.LoadInt64(&blockProfileRate)))
+	if c.maxLocations <= 0 {
+		return
+	}
+	for _, l := range topContendedLocations(mutexProfile(), c.maxLocations) {
+		ch <- prometheus.MustNewConstMetric(c.contentions, prometheus.CounterValue, float64(l.count), l.location)
+	}
+}
+
+// mutexProfile returns the current records of the mutex profile.
+func mutexProfile() []runtime.BlockProfileRecord {
+	n, _ := runtime.MutexProfile(nil)
+	for {
+		// Allow for some growth between the calls.
+		records := make([]runtime.BlockProfileRecord, n+16)
+		var ok bool
+		if n, ok = runtime.MutexProfile(records); ok {
+			return records[:n]
+		}
+	}
+}
+
+type contendedLocation struct {
+	location      string
+	count, cycles int64
+}
+
+// topContendedLocations aggregates the provided records by location and returns
+// the limit locations with the most cycles of delay. Ties are broken by
+// location to keep the result stable.
+func topContendedLocations(records []runtime.BlockProfileRecord, limit int) []contendedLocation {
+	byLocation := map[string]*contendedLocation{}
+	for _, r := range records {
+		loc := contentionLocation(r.Stack())
+		l, ok := byLocation[loc]
+		if !ok {
+			l = &contendedLocation{location: loc}
+			byLocation[loc] = l
+		}
+		l.count += r.Count
+		l.cycles += r.Cycles
+	}
+	locations := make([]contendedLocation, 0, len(byLocation))
+	for _, l := range byLocation {
+		locations = append(locations, *l)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		if locations[i].cycles != locations[j].cycles {
+			return locations[i].cycles > locations[j].cycles
+		}
+		return locations[i].location < locations[j].location
+	})
+	if len(locations) > limit {
+		locations = locations[:limit]
+	}
+	return locations
+}
+
+// contentionLocation returns the name of the first function in the stack that
+// is not part of the runtime or the sync package, i.e. the function that
+// released the contended lock.
+func contentionLocation(stack []uintptr) string {
+	frames := runtime.CallersFrames(stack)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !isSyncOrRuntime(f.Function) {
+			return f.Function
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func isSyncOrRuntime(function string) bool {
+	for _, prefix := range []string{"runtime.", "sync.", "internal/sync."} {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestProfilingRateCollector(t *testing.T) {
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(5))
+	defer SetBlockProfileRate(0)
+	SetBlockProfileRate(100)
+
+	want := `
+		# HELP go_block_profile_rate Rate of blocking events (in nanoseconds spent blocked) reported in the block profile as set by runtime.SetBlockProfileRate.
+		# TYPE go_block_profile_rate gauge
+		go_block_profile_rate 100
+		# HELP go_mutex_profile_fraction Rate of mutex contention events reported in the mutex profile as set by runtime.SetMutexProfileFraction.
+		# TYPE go_mutex_profile_fraction gauge
+		go_mutex_profile_fraction 5
+	`
+	if err := testutil.CollectAndCompare(NewProfilingRateCollector(), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProfilingRateCollectorContentionLocations(t *testing.T) {
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(1))
+
+	var (
+		mtx sync.Mutex
+		wg  sync.WaitGroup
+	)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mtx.Lock()
+			time.Sleep(10 * time.Millisecond)
+			mtx.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewProfilingRateCollector(WithMutexContentionLocations(2)))
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, mf := range mfs {
+		if mf.GetName() != "go_mutex_profile_contentions_total" {
+			continue
+		}
+		if mf.GetType() != dto.MetricType_COUNTER {
+			t.Errorf("got type %v, want counter", mf.GetType())
+		}
+		if len(mf.Metric) > 2 {
+			t.Errorf("got %d locations, want at most 2", len(mf.Metric))
+		}
+		for _, m := range mf.Metric {
+			if strings.Contains(m.Label[0].GetValue(), "TestProfilingRateCollectorContentionLocations") {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("contended location not found in %v", mfs)
+	}
+}
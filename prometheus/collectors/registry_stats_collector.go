@@ -0,0 +1,76 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/internal"
+)
+
+type registryStatsCollector struct {
+	g prometheus.Gatherer
+
+	collectors *prometheus.Desc
+	descs      *prometheus.Desc
+}
+
+// NewRegistryStatsCollector returns a collector that exposes the number of
+// Collectors and Descs registered with the provided Gatherer as the gauges
+// "prometheus_registered_collectors" and "prometheus_registered_metric_descs".
+// This is useful to detect runaway dynamic registration.
+//
+// The counts are read directly from the Gatherer if it is a
+// *prometheus.Registry (like prometheus.DefaultGatherer). Otherwise, the
+// Gatherer is gathered upon each collection, and the number of gathered metric
+// families is reported as the number of descs, while the number of
+// collectors, which can't be derived from gathered metrics, is not reported.
+// In that case, the returned collector must not be registered with a Registry
+// that is gathered by the provided Gatherer, as this would cause an infinite
+// recursion.
+func NewRegistryStatsCollector(g prometheus.Gatherer) prometheus.Collector {
+	return &registryStatsCollector{
+		g: g,
+		collectors: prometheus.NewDesc(
+			"prometheus_registered_collectors",
+			"Number of collectors currently registered.",
+			nil, nil,
+		),
+		descs: prometheus.NewDesc(
+			"prometheus_registered_metric_descs",
+			"Number of metric descriptors currently registered.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements Collector.
+func (c *registryStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.collectors
+	ch <- c.descs
+}
+
+// Collect implements Collector.
+func (c *registryStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	if collectors, descs, ok := internal.RegistryStats(c.g); ok {
+		ch <- prometheus.MustNewConstMetric(c.collectors, prometheus.GaugeValue, float64(collectors))
+		ch <- prometheus.MustNewConstMetric(c.descs, prometheus.GaugeValue, float64(descs))
+		return
+	}
+	mfs, err := c.g.Gather()
+	if err != nil && len(mfs) == 0 {
+		ch <- prometheus.NewInvalidMetric(c.descs, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.descs, prometheus.GaugeValue, float64(len(mfs)))
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestRegistryStatsCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewCounter(prometheus.CounterOpts{Name: "a_total", Help: "A."}),
+		prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "b", Help: "B."}, []string{"l"}),
+		NewDBStatsCollector(new(sql.DB), "db"),
+	)
+	stats := NewRegistryStatsCollector(reg)
+	reg.MustRegister(stats)
+
+	// 4 collectors with 1 + 1 + 9 + 2 descs.
+	const expected = `
+# HELP prometheus_registered_collectors Number of collectors currently registered.
+# TYPE prometheus_registered_collectors gauge
+prometheus_registered_collectors 4
+# HELP prometheus_registered_metric_descs Number of metric descriptors currently registered.
+# TYPE prometheus_registered_metric_descs gauge
+prometheus_registered_metric_descs 13
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"prometheus_registered_collectors", "prometheus_registered_metric_descs",
+	); err != nil {
+		t.Error(err)
+	}
+
+	// Any other Gatherer is gathered instead. The GaugeVec without
+	// children doesn't result in a metric family.
+	other := NewRegistryStatsCollector(prometheus.Gatherers{reg})
+	const expectedOther = `
+# HELP prometheus_registered_metric_descs Number of metric descriptors currently registered.
+# TYPE prometheus_registered_metric_descs gauge
+prometheus_registered_metric_descs 12
+`
+	if err := testutil.CollectAndCompare(other, strings.NewReader(expectedOther)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// SummaryToHistogramShim is an Observer that observes into both a Summary and
+// a companion Histogram, and a Collector that collects both. Use
+// NewSummaryToHistogramShim to create instances.
+type SummaryToHistogramShim interface {
+	prometheus.Observer
+	prometheus.Collector
+}
+
+type summaryToHistogramShim struct {
+	summary   prometheus.Summary
+	histogram prometheus.Histogram
+}
+
+// NewSummaryToHistogramShim returns a SummaryToHistogramShim for the provided
+// Summary, easing the migration from a Summary to a Histogram: For a
+// transition period, both are exposed, so that dashboards and alerts based on
+// the Summary keep working while those based on the Histogram are created.
+//
+// The Histogram has the name of the Summary with the suffix "_histogram"
+// (to avoid a collision of the "_sum" and "_count" series), the same help
+// string, and the labels of the Summary as constant labels. It uses the
+// provided buckets, or prometheus.DefBuckets if there are none. The name, help
+// string, and labels are determined by collecting the Summary once, which
+// panics if that fails.
+//
+// A Summary doesn't retain the observed values, so the Histogram can't be
+// derived from it. Instead, the observations have to be made via the Observe
+// method of the shim, which observes into both. Observations made directly on
+// the Summary are not reflected in the Histogram. Register the shim instead of
+// the Summary, as it collects both.
+func NewSummaryToHistogramShim(s prometheus.Summary, buckets []float64) SummaryToHistogramShim {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(s); err != nil {
+		panic(fmt.Errorf("cannot collect summary: %w", err))
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		panic(fmt.Errorf("cannot collect summary: %w", err))
+	}
+	if len(mfs) != 1 || len(mfs[0].Metric) != 1 {
+		panic(fmt.Errorf("expected exactly one summary, got %v", mfs))
+	}
+	mf := mfs[0]
+	constLabels := prometheus.Labels{}
+	for _, lp := range mf.Metric[0].Label {
+		constLabels[lp.GetName()] = lp.GetValue()
+	}
+	return &summaryToHistogramShim{
+		summary: s,
+		histogram: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        mf.GetName() + "_histogram",
+			Help:        mf.GetHelp(),
+			ConstLabels: constLabels,
+			Buckets:     buckets,
+		}),
+	}
+}
+
+// Observe implements Observer.
+func (s *summaryToHistogramShim) Observe(v float64) {
+	s.summary.Observe(v)
+	s.histogram.Observe(v)
+}
+
+// Describe implements Collector.
+func (s *summaryToHistogramShim) Describe(ch chan<- *prometheus.Desc) {
+	s.summary.Describe(ch)
+	s.histogram.Describe(ch)
+}
+
+// Collect implements Collector.
+func (s *summaryToHistogramShim) Collect(ch chan<- prometheus.Metric) {
+	s.summary.Collect(ch)
+	s.histogram.Collect(ch)
+}
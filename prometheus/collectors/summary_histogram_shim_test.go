@@ -0,0 +1,50 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestSummaryToHistogramShim(t *testing.T) {
+	vec := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:        "request_duration_seconds",
+		Help:        "Duration of requests.",
+		ConstLabels: prometheus.Labels{"service": "api"},
+	}, []string{"method"})
+	shim := NewSummaryToHistogramShim(vec.WithLabelValues("GET").(prometheus.Summary), []float64{0.1, 1})
+	shim.Observe(0.5)
+	shim.Observe(2)
+
+	want := `
+		# HELP request_duration_seconds Duration of requests.
+		# TYPE request_duration_seconds summary
+		request_duration_seconds_sum{method="GET",service="api"} 2.5
+		request_duration_seconds_count{method="GET",service="api"} 2
+		# HELP request_duration_seconds_histogram Duration of requests.
+		# TYPE request_duration_seconds_histogram histogram
+		request_duration_seconds_histogram_bucket{method="GET",service="api",le="0.1"} 0
+		request_duration_seconds_histogram_bucket{method="GET",service="api",le="1"} 1
+		request_duration_seconds_histogram_bucket{method="GET",service="api",le="+Inf"} 2
+		request_duration_seconds_histogram_sum{method="GET",service="api"} 2.5
+		request_duration_seconds_histogram_count{method="GET",service="api"} 2
+	`
+	if err := testutil.CollectAndCompare(shim, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
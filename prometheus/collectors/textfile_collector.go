@@ -0,0 +1,149 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+type textfileCollector struct {
+	dir string
+
+	mtime     *prometheus.Desc
+	scrapeErr *prometheus.Desc
+}
+
+// NewTextfileCollector returns a collector that exposes the metrics found in
+// the files matching "*.prom" in the provided directory, in the same way as the
+// textfile collector of the node_exporter. The files have to be in the text
+// exposition format. They are read and parsed upon each collection.
+//
+// For each successfully parsed file, a gauge "node_textfile_mtime_seconds"
+// with the label "file" contains the modification time of the file. A file
+// that cannot be read or parsed is skipped without affecting the other files.
+// In that case, the gauge "node_textfile_scrape_error" is set to 1. It is 0 if
+// all files have been processed successfully.
+//
+// The collector is unchecked, i.e. it doesn't describe the metrics from the
+// files upfront. Note that metrics with the same name in different files must
+// have the same type and help string and must not have the same label set.
+// Otherwise, gathering fails as with any other inconsistent metrics.
+func NewTextfileCollector(dir string) prometheus.Collector {
+	return &textfileCollector{
+		dir: dir,
+		mtime: prometheus.NewDesc(
+			"node_textfile_mtime_seconds",
+			"Unixtime mtime of textfiles successfully read.",
+			[]string{"file"}, nil,
+		),
+		scrapeErr: prometheus.NewDesc(
+			"node_textfile_scrape_error",
+			"1 if there was an error opening or reading a file, 0 otherwise.",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements Collector. It describes nothing, as the metrics read from
+// the files are not known upfront.
+func (c *textfileCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements Collector.
+func (c *textfileCollector) Collect(ch chan<- prometheus.Metric) {
+	var scrapeErr float64
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.prom"))
+	if err != nil {
+		// Only possible with a malformed directory name.
+		scrapeErr = 1
+	}
+	for _, path := range paths {
+		mfs, mtime, err := parseTextfile(path)
+		if err != nil {
+			scrapeErr = 1
+			continue
+		}
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				ch <- newTextfileMetric(mf, m)
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.mtime, prometheus.GaugeValue,
+			float64(mtime.UnixNano())/1e9, filepath.Base(path),
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(c.scrapeErr, prometheus.GaugeValue, scrapeErr)
+}
+
+// parseTextfile parses the file at the provided path completely, so that
+// either all or none of its metrics are exposed.
+func parseTextfile(path string) (map[string]*dto.MetricFamily, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var parser expfmt.TextParser
+	mfs, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return mfs, stat.ModTime(), nil
+}
+
+// textfileMetric is a Metric parsed from a textfile. Its Desc has all labels
+// of the metric as variable labels.
+type textfileMetric struct {
+	desc   *prometheus.Desc
+	metric *dto.Metric
+}
+
+func newTextfileMetric(mf *dto.MetricFamily, m *dto.Metric) prometheus.Metric {
+	labelNames := make([]string, len(m.Label))
+	for i, lp := range m.Label {
+		labelNames[i] = lp.GetName()
+	}
+	sort.Strings(labelNames)
+	return &textfileMetric{
+		desc:   prometheus.NewDesc(mf.GetName(), mf.GetHelp(), labelNames, nil),
+		metric: m,
+	}
+}
+
+func (m *textfileMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *textfileMetric) Write(out *dto.Metric) error {
+	out.Label = m.metric.Label
+	out.Counter = m.metric.Counter
+	out.Gauge = m.metric.Gauge
+	out.Summary = m.metric.Summary
+	out.Untyped = m.metric.Untyped
+	out.Histogram = m.metric.Histogram
+	out.TimestampMs = m.metric.TimestampMs
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestTextfileCollector(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Unix(1700000000, 0)
+	for name, content := range map[string]string{
+		"jobs.prom": `# HELP batch_jobs_total Finished batch jobs.
+# TYPE batch_jobs_total counter
+batch_jobs_total{job="backup",result="success"} 3
+batch_jobs_total{job="backup",result="failure"} 1
+# HELP batch_duration_seconds Duration of batch jobs.
+# TYPE batch_duration_seconds histogram
+batch_duration_seconds_bucket{le="10"} 1
+batch_duration_seconds_bucket{le="+Inf"} 2
+batch_duration_seconds_sum 25
+batch_duration_seconds_count 2
+`,
+		"last_run.prom": `# HELP last_run_timestamp_seconds Last run.
+last_run_timestamp_seconds 1.7e+09
+`,
+		"broken.prom": `# TYPE broken gauge
+broken{ 1
+`,
+		"ignored.txt": `ignored 1
+`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewTextfileCollector(dir))
+
+	const expected = `
+# HELP batch_duration_seconds Duration of batch jobs.
+# TYPE batch_duration_seconds histogram
+batch_duration_seconds_bucket{le="10"} 1
+batch_duration_seconds_bucket{le="+Inf"} 2
+batch_duration_seconds_sum 25
+batch_duration_seconds_count 2
+# HELP batch_jobs_total Finished batch jobs.
+# TYPE batch_jobs_total counter
+batch_jobs_total{job="backup",result="failure"} 1
+batch_jobs_total{job="backup",result="success"} 3
+# HELP last_run_timestamp_seconds Last run.
+# TYPE last_run_timestamp_seconds untyped
+last_run_timestamp_seconds 1.7e+09
+# HELP node_textfile_mtime_seconds Unixtime mtime of textfiles successfully read.
+# TYPE node_textfile_mtime_seconds gauge
+node_textfile_mtime_seconds{file="jobs.prom"} 1.7e+09
+node_textfile_mtime_seconds{file="last_run.prom"} 1.7e+09
+# HELP node_textfile_scrape_error 1 if there was an error opening or reading a file, 0 otherwise.
+# TYPE node_textfile_scrape_error gauge
+node_textfile_scrape_error 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// Without the broken file, there is no error.
+	if err := os.Remove(filepath.Join(dir, "broken.prom")); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP node_textfile_scrape_error 1 if there was an error opening or reading a file, 0 otherwise.
+# TYPE node_textfile_scrape_error gauge
+node_textfile_scrape_error 0
+`), "node_textfile_scrape_error"); err != nil {
+		t.Error(err)
+	}
+}
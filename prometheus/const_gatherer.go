@@ -0,0 +1,62 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+type constGatherer struct {
+	mfs []*dto.MetricFamily
+	err error
+}
+
+// NewConstGatherer returns a Gatherer that yields the provided MetricFamilies on
+// each Gather call. This is useful to re-expose metrics that were received from
+// elsewhere, e.g. by a proxy or aggregator, through the usual means like the
+// promhttp handler.
+//
+// The MetricFamilies are validated and sorted once, in the same way as by
+// Gatherers: inconsistent MetricFamilies and Metrics are dropped, and the
+// resulting errors are returned by every Gather call, together with the
+// remaining MetricFamilies. The MetricFamilies are copied, so that neither
+// later modifications of mfs nor modifications of the gathered MetricFamilies
+// affect the result of subsequent Gather calls.
+func NewConstGatherer(mfs []*dto.MetricFamily) Gatherer {
+	copied := cloneMetricFamilies(mfs)
+	validated, _, err := Gatherers{GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return copied, nil
+	})}.gather(false)
+	return &constGatherer{mfs: validated, err: err}
+}
+
+// Gather implements Gatherer.
+func (g *constGatherer) Gather() ([]*dto.MetricFamily, error) {
+	return cloneMetricFamilies(g.mfs), g.err
+}
+
+func cloneMetricFamilies(mfs []*dto.MetricFamily) []*dto.MetricFamily {
+	if mfs == nil {
+		return nil
+	}
+	cloned := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		if mf == nil {
+			continue
+		}
+		cloned = append(cloned, proto.Clone(mf).(*dto.MetricFamily))
+	}
+	return cloned
+}
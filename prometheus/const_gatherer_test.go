@@ -0,0 +1,73 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestConstGatherer(t *testing.T) {
+	mfs := []*dto.MetricFamily{
+		{
+			Name: proto.String("b_total"),
+			Help: proto.String("B."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{Label: []*dto.LabelPair{{Name: proto.String("x"), Value: proto.String("2")}}, Counter: &dto.Counter{Value: proto.Float64(2)}},
+				{Label: []*dto.LabelPair{{Name: proto.String("x"), Value: proto.String("1")}}, Counter: &dto.Counter{Value: proto.Float64(1)}},
+				// Duplicate, dropped.
+				{Label: []*dto.LabelPair{{Name: proto.String("x"), Value: proto.String("1")}}, Counter: &dto.Counter{Value: proto.Float64(3)}},
+			},
+		},
+		{
+			Name:   proto.String("a"),
+			Help:   proto.String("A."),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(42)}}},
+		},
+	}
+	g := prometheus.NewConstGatherer(mfs)
+	// Modifying the input must not affect the gatherer.
+	mfs[1].Metric[0].Gauge.Value = proto.Float64(0)
+
+	const expected = `
+		# HELP a A.
+		# TYPE a gauge
+		a 42
+		# HELP b_total B.
+		# TYPE b_total counter
+		b_total{x="1"} 1
+		b_total{x="2"} 2
+	`
+	for i := 0; i < 2; i++ {
+		got, err := g.Gather()
+		if err == nil || !strings.Contains(err.Error(), "was collected before with the same name and label values") {
+			t.Errorf("gather #%d: expected duplicate metric error, got %v", i, err)
+		}
+		if err := testutil.GatherAndCompare(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return got, nil
+		}), strings.NewReader(expected)); err != nil {
+			t.Errorf("gather #%d: %v", i, err)
+		}
+		// Modifying the result must not affect later Gather calls.
+		got[0].Metric[0].Gauge.Value = proto.Float64(0)
+	}
+}
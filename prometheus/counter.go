@@ -15,7 +15,9 @@ package prometheus
 
 import (
 	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -40,10 +42,57 @@ type Counter interface {
 	// non-negative values.
 	Inc()
 	// Add adds the given value to the counter. It panics if the value is <
-	// 0.
+	// 0 (unless configured otherwise with CounterDecreaseOpts).
 	Add(float64)
 }
 
+// CounterSetter is implemented by the Counters provided by this package. Its
+// Set method sets the Counter to the provided value, which is meant for bridges
+// importing cumulative counter values from other systems. If the value is below
+// the current value of the Counter, the configured CounterDecreasePolicy is
+// applied (see CounterDecreaseOpts). Set is not atomic with respect to
+// concurrent Inc and Add calls, so those should not be mixed with Set on the
+// same Counter.
+type CounterSetter interface {
+	Set(float64)
+}
+
+// CounterDecreasePolicy defines how Counters deal with updates that would
+// decrease their value.
+type CounterDecreasePolicy int
+
+// These constants define the available CounterDecreasePolicy values.
+const (
+	// Panic upon a decrease. This is the default to stay compatible with
+	// earlier versions of this package.
+	CounterDecreasePanic CounterDecreasePolicy = iota
+	// Ignore the update, i.e. keep the current value.
+	CounterDecreaseIgnore
+	// Treat the decrease as a counter reset, i.e. set the Counter to 0 upon
+	// Add with a negative value, or to the provided value upon Set. Note
+	// that the created timestamp of the Counter is not updated. Concurrent
+	// increments racing with a reset might get lost.
+	CounterDecreaseReset
+)
+
+// CounterDecreaseOpts defines what happens if a Counter is about to decrease,
+// i.e. upon Add with a negative value or Set (see CounterSetter) with a value
+// below the current one. It is meant for bridges importing counters from other
+// systems (like StatsD), which might go backwards upon resets. Use it with
+// NewCounterWithDecreaseOpts or NewCounterVecWithDecreaseOpts.
+//
+// Unless Policy is CounterDecreasePanic, the number of times Policy has been
+// applied is counted by a counter named like the Counter (without a "_total"
+// suffix) followed by "_decrease_events_total", with the same const labels,
+// which is collected together with the Counter. The counter has no variable
+// labels, i.e. all Counters of a CounterVec share one counter.
+type CounterDecreaseOpts struct {
+	// Policy is the CounterDecreasePolicy to apply. The default
+	// CounterDecreasePanic is the behavior of earlier versions of this
+	// package.
+	Policy CounterDecreasePolicy
+}
+
 // ExemplarAdder is implemented by Counters that offer the option of adding a
 // value to the Counter together with an exemplar. Its AddWithExemplar method
 // works like the Add method of the Counter interface but also replaces the
@@ -74,8 +123,9 @@ type CounterVecOpts struct {
 
 // NewCounter creates a new Counter based on the provided CounterOpts.
 //
-// The returned implementation also implements ExemplarAdder. It is safe to
-// perform the corresponding type assertion.
+// The returned implementation also implements ExemplarAdder, ExemplarLister,
+// ExemplarClearer, and CounterSetter. It is safe to perform the corresponding
+// type assertions.
 //
 // The returned implementation tracks the counter value in two separate
 // variables, a float64 and a uint64. The latter is used to track calls of the
@@ -85,21 +135,36 @@ type CounterVecOpts struct {
 // Both internal tracking values are added up in the Write method. This has to
 // be taken into account when it comes to precision and overflow behavior.
 func NewCounter(opts CounterOpts) Counter {
-	desc := NewDesc(
+	desc := newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 		opts.Help,
-		nil,
+		opts.Unit,
+		UnconstrainedLabels(nil),
 		opts.ConstLabels,
 	)
 	if opts.now == nil {
 		opts.now = time.Now
 	}
-	result := &counter{desc: desc, labelPairs: desc.constLabelPairs, now: opts.now}
+	result := &counter{
+		desc:           desc,
+		labelPairs:     desc.constLabelPairs,
+		exemplarPolicy: newExemplarPolicy(opts.ExemplarOpts),
+		now:            opts.now,
+	}
 	result.init(result) // Init self-collection.
 	result.createdTs = timestamppb.New(opts.now())
 	return result
 }
 
+// NewCounterWithDecreaseOpts works like NewCounter but applies the provided
+// CounterDecreaseOpts instead of panicking whenever the Counter is about to
+// decrease.
+func NewCounterWithDecreaseOpts(opts CounterOpts, decreaseOpts CounterDecreaseOpts) Counter {
+	c := NewCounter(opts).(*counter)
+	c.decreasePolicy = newDecreasePolicy(c.desc, opts, decreaseOpts)
+	return c
+}
+
 type counter struct {
 	// valBits contains the bits of the represented float64 value, while
 	// valInt stores values that are exact integers. Both have to go first
@@ -111,9 +176,11 @@ type counter struct {
 	selfCollector
 	desc *Desc
 
-	createdTs  *timestamppb.Timestamp
-	labelPairs []*dto.LabelPair
-	exemplar   atomic.Value // Containing nil or a *dto.Exemplar.
+	createdTs      *timestamppb.Timestamp
+	labelPairs     []*dto.LabelPair
+	exemplar       atomic.Value // Containing nil or a (possibly nil) *dto.Exemplar.
+	exemplarPolicy *exemplarPolicy
+	decreasePolicy *decreasePolicy // nil for CounterDecreasePanic
 
 	// now is for testing purposes, by default it's time.Now.
 	now func() time.Time
@@ -125,7 +192,8 @@ func (c *counter) Desc() *Desc {
 
 func (c *counter) Add(v float64) {
 	if v < 0 {
-		panic(errors.New("counter cannot decrease in value"))
+		c.decrease(0)
+		return
 	}
 
 	ival := uint64(v)
@@ -143,8 +211,37 @@ func (c *counter) Add(v float64) {
 	}
 }
 
+// Set implements CounterSetter.
+func (c *counter) Set(v float64) {
+	current := c.get()
+	if v < current {
+		c.decrease(v)
+		return
+	}
+	if v > current {
+		c.Add(v - current)
+	}
+}
+
+// decrease applies the decreasePolicy, with resetTo being the value to set
+// upon CounterDecreaseReset.
+func (c *counter) decrease(resetTo float64) {
+	if c.decreasePolicy == nil {
+		panic(errCounterDecrease)
+	}
+	c.decreasePolicy.events.Inc()
+	if c.decreasePolicy.policy == CounterDecreaseReset {
+		atomic.StoreUint64(&c.valInt, 0)
+		atomic.StoreUint64(&c.valBits, math.Float64bits(resetTo))
+	}
+}
+
 func (c *counter) AddWithExemplar(v float64, e Labels) {
 	c.Add(v)
+	if v < 0 {
+		// Not an observation worth an exemplar.
+		return
+	}
 	c.updateExemplar(v, e)
 }
 
@@ -161,10 +258,7 @@ func (c *counter) get() float64 {
 func (c *counter) Write(out *dto.Metric) error {
 	// Read the Exemplar first and the value second. This is to avoid a race condition
 	// where users see an exemplar for a not-yet-existing observation.
-	var exemplar *dto.Exemplar
-	if e := c.exemplar.Load(); e != nil {
-		exemplar = e.(*dto.Exemplar)
-	}
+	exemplar, _ := c.exemplar.Load().(*dto.Exemplar)
 	val := c.get()
 	return populateMetric(CounterValue, val, c.labelPairs, exemplar, out, c.createdTs)
 }
@@ -173,13 +267,47 @@ func (c *counter) updateExemplar(v float64, l Labels) {
 	if l == nil {
 		return
 	}
-	e, err := newExemplar(v, c.now(), l)
+	e, err := c.exemplarPolicy.newExemplar(v, c.now(), l)
+	if err == errExemplarDropped {
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 	c.exemplar.Store(e)
 }
 
+// Exemplars implements ExemplarLister.
+func (c *counter) Exemplars() []*dto.Exemplar {
+	if e, _ := c.exemplar.Load().(*dto.Exemplar); e != nil {
+		return []*dto.Exemplar{e}
+	}
+	return nil
+}
+
+// ClearExemplars implements ExemplarClearer.
+func (c *counter) ClearExemplars() {
+	c.exemplar.Store((*dto.Exemplar)(nil))
+}
+
+// Describe implements Collector. In addition to the Desc of the Counter, it
+// sends the Desc of the counter of decrease events, if any.
+func (c *counter) Describe(ch chan<- *Desc) {
+	c.selfCollector.Describe(ch)
+	if c.decreasePolicy != nil {
+		c.decreasePolicy.events.Describe(ch)
+	}
+}
+
+// Collect implements Collector. In addition to the Counter, it sends the
+// counter of decrease events, if any.
+func (c *counter) Collect(ch chan<- Metric) {
+	c.selfCollector.Collect(ch)
+	if c.decreasePolicy != nil {
+		c.decreasePolicy.events.Collect(ch)
+	}
+}
+
 // CounterVec is a Collector that bundles a set of Counters that all share the
 // same Desc, but have different values for their variable labels. This is used
 // if you want to count the same thing partitioned by various dimensions
@@ -187,6 +315,7 @@ func (c *counter) updateExemplar(v float64, l Labels) {
 // method). Create instances with NewCounterVec.
 type CounterVec struct {
 	*MetricVec
+	decreaseEvents Counter // nil for CounterDecreasePanic.
 }
 
 // NewCounterVec creates a new CounterVec based on the provided CounterOpts and
@@ -198,23 +327,45 @@ func NewCounterVec(opts CounterOpts, labelNames []string) *CounterVec {
 	})
 }
 
+// NewCounterVecWithDecreaseOpts works like NewCounterVec but applies the
+// provided CounterDecreaseOpts instead of panicking whenever one of the
+// Counters is about to decrease.
+func NewCounterVecWithDecreaseOpts(opts CounterOpts, labelNames []string, decreaseOpts CounterDecreaseOpts) *CounterVec {
+	return newCounterVec(CounterVecOpts{
+		CounterOpts:    opts,
+		VariableLabels: UnconstrainedLabels(labelNames),
+	}, decreaseOpts)
+}
+
 // NewCounterVec creates a new CounterVec based on the provided CounterVecOpts.
 func (v2) NewCounterVec(opts CounterVecOpts) *CounterVec {
-	desc := V2.NewDesc(
+	return newCounterVec(opts, CounterDecreaseOpts{})
+}
+
+func newCounterVec(opts CounterVecOpts, decreaseOpts CounterDecreaseOpts) *CounterVec {
+	desc := newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 		opts.Help,
+		opts.Unit,
 		opts.VariableLabels,
 		opts.ConstLabels,
 	)
 	if opts.now == nil {
 		opts.now = time.Now
 	}
+	ep := newExemplarPolicy(opts.ExemplarOpts)
+	dp := newDecreasePolicy(desc, opts.CounterOpts, decreaseOpts)
+	var decreaseEvents Counter
+	if dp != nil {
+		decreaseEvents = dp.events
+	}
 	return &CounterVec{
+		decreaseEvents: decreaseEvents,
 		MetricVec: NewMetricVec(desc, func(lvs ...string) Metric {
 			if len(lvs) != len(desc.variableLabels.names) {
 				panic(makeInconsistentCardinalityError(desc.fqName, desc.variableLabels.names, lvs))
 			}
-			result := &counter{desc: desc, labelPairs: MakeLabelPairs(desc, lvs), now: opts.now}
+			result := &counter{desc: desc, labelPairs: MakeLabelPairs(desc, lvs), exemplarPolicy: ep, decreasePolicy: dp, now: opts.now}
 			result.init(result) // Init self-collection.
 			result.createdTs = timestamppb.New(opts.now())
 			return result
@@ -222,6 +373,24 @@ func (v2) NewCounterVec(opts CounterVecOpts) *CounterVec {
 	}
 }
 
+// Describe implements Collector. In addition to the Desc of the Counters, it
+// sends the Desc of the counter of decrease events, if any.
+func (v *CounterVec) Describe(ch chan<- *Desc) {
+	v.MetricVec.Describe(ch)
+	if v.decreaseEvents != nil {
+		v.decreaseEvents.Describe(ch)
+	}
+}
+
+// Collect implements Collector. In addition to the Counters, it sends the
+// counter of decrease events, if any.
+func (v *CounterVec) Collect(ch chan<- Metric) {
+	v.MetricVec.Collect(ch)
+	if v.decreaseEvents != nil {
+		v.decreaseEvents.Collect(ch)
+	}
+}
+
 // GetMetricWithLabelValues returns the Counter for the given slice of label
 // values (same order as the variable labels in Desc). If that combination of
 // label values is accessed for the first time, a new Counter is created.
@@ -237,7 +406,8 @@ func (v2) NewCounterVec(opts CounterVecOpts) *CounterVec {
 // Counter with the same label values is created later.
 //
 // An error is returned if the number of label values is not the same as the
-// number of variable labels in Desc (minus any curried labels).
+// number of variable labels in Desc (minus any curried labels) or if any of the
+// label values is not valid UTF-8.
 //
 // Note that for more than one label value, this method is prone to mistakes
 // caused by an incorrect order of arguments. Consider GetMetricWith(Labels) as
@@ -260,7 +430,8 @@ func (v *CounterVec) GetMetricWithLabelValues(lvs ...string) (Counter, error) {
 // the same as for GetMetricWithLabelValues.
 //
 // An error is returned if the number and names of the Labels are inconsistent
-// with those of the variable labels in Desc (minus any curried labels).
+// with those of the variable labels in Desc (minus any curried labels) or if
+// any of the label values is not valid UTF-8.
 //
 // This method is used for the same purpose as
 // GetMetricWithLabelValues(...string). See there for pros and cons of the two
@@ -314,7 +485,7 @@ func (v *CounterVec) With(labels Labels) Counter {
 func (v *CounterVec) CurryWith(labels Labels) (*CounterVec, error) {
 	vec, err := v.MetricVec.CurryWith(labels)
 	if vec != nil {
-		return &CounterVec{vec}, err
+		return &CounterVec{MetricVec: vec, decreaseEvents: v.decreaseEvents}, err
 	}
 	return nil, err
 }
@@ -349,10 +520,35 @@ type CounterFunc interface {
 //
 // Check out the ExampleGaugeFunc examples for the similar GaugeFunc.
 func NewCounterFunc(opts CounterOpts, function func() float64) CounterFunc {
-	return newValueFunc(NewDesc(
+	return newValueFunc(newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 		opts.Help,
-		nil,
+		opts.Unit,
+		UnconstrainedLabels(nil),
 		opts.ConstLabels,
 	), CounterValue, function)
 }
+
+var errCounterDecrease = errors.New("counter cannot decrease in value")
+
+type decreasePolicy struct {
+	policy CounterDecreasePolicy
+	events Counter
+}
+
+// newDecreasePolicy returns nil for CounterDecreasePanic so that counters with
+// the default behavior don't carry any overhead.
+func newDecreasePolicy(desc *Desc, opts CounterOpts, decreaseOpts CounterDecreaseOpts) *decreasePolicy {
+	if decreaseOpts.Policy == CounterDecreasePanic {
+		return nil
+	}
+	name := strings.TrimSuffix(desc.fqName, "_total")
+	return &decreasePolicy{
+		policy: decreaseOpts.Policy,
+		events: NewCounter(CounterOpts{
+			Name:        name + "_decrease_events_total",
+			Help:        fmt.Sprintf("Number of times the counter %s was about to decrease.", desc.fqName),
+			ConstLabels: opts.ConstLabels,
+		}),
+	}
+}
@@ -259,6 +259,84 @@ func TestCounterAddSmall(t *testing.T) {
 	}
 }
 
+func TestCounterOnDecrease(t *testing.T) {
+	for _, tc := range []struct {
+		policy             CounterDecreasePolicy
+		wantAfterAdd       float64
+		wantAfterSet       float64
+		wantDecreaseEvents float64
+	}{
+		{policy: CounterDecreaseIgnore, wantAfterAdd: 10, wantAfterSet: 10, wantDecreaseEvents: 2},
+		{policy: CounterDecreaseReset, wantAfterAdd: 0, wantAfterSet: 3, wantDecreaseEvents: 2},
+	} {
+		vec := NewCounterVecWithDecreaseOpts(CounterOpts{
+			Name:        "bridged_total",
+			Help:        "test help",
+			ConstLabels: Labels{"source": "statsd"},
+		}, []string{"a"}, CounterDecreaseOpts{Policy: tc.policy})
+		c := vec.WithLabelValues("x")
+
+		c.(CounterSetter).Set(10)
+		c.Add(-1)
+		if got := c.(*counter).get(); got != tc.wantAfterAdd {
+			t.Errorf("policy %d: got %v after Add(-1), want %v", tc.policy, got, tc.wantAfterAdd)
+		}
+		// Decreases of a curried vector are counted by the same counter.
+		vec.MustCurryWith(Labels{"a": "x"}).WithLabelValues().(CounterSetter).Set(10)
+		c.(CounterSetter).Set(3)
+		if got := c.(*counter).get(); got != tc.wantAfterSet {
+			t.Errorf("policy %d: got %v after Set(3), want %v", tc.policy, got, tc.wantAfterSet)
+		}
+		c.(CounterSetter).Set(12.5)
+		if got := c.(*counter).get(); got != 12.5 {
+			t.Errorf("policy %d: got %v after Set(12.5), want 12.5", tc.policy, got)
+		}
+
+		single := NewCounterWithDecreaseOpts(
+			CounterOpts{Name: "single", Help: "test help"},
+			CounterDecreaseOpts{Policy: tc.policy},
+		)
+		single.Add(5)
+		single.Add(-1)
+		if got := single.(*counter).get(); got != tc.wantAfterAdd/2 {
+			t.Errorf("policy %d: got %v after Add(-1), want %v", tc.policy, got, tc.wantAfterAdd/2)
+		}
+
+		reg := NewPedanticRegistry()
+		reg.MustRegister(vec, single)
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]*dto.Metric{}
+		for _, mf := range mfs {
+			got[mf.GetName()] = mf.Metric[0]
+		}
+		if len(got) != 4 {
+			t.Fatalf("policy %d: unexpected metric families %v", tc.policy, mfs)
+		}
+		events := got["bridged_decrease_events_total"]
+		if v := events.GetCounter().GetValue(); v != tc.wantDecreaseEvents {
+			t.Errorf("policy %d: got %v decrease events, want %v", tc.policy, v, tc.wantDecreaseEvents)
+		}
+		if len(events.Label) != 1 || events.Label[0].GetName() != "source" {
+			t.Errorf("policy %d: got labels %v for decrease events, want only the const label", tc.policy, events.Label)
+		}
+		if v := got["single_decrease_events_total"].GetCounter().GetValue(); v != 1 {
+			t.Errorf("policy %d: got %v decrease events of single counter, want 1", tc.policy, v)
+		}
+	}
+
+	c := NewCounter(CounterOpts{Name: "test", Help: "test help"})
+	c.(CounterSetter).Set(5)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic upon decrease with the default policy")
+		}
+	}()
+	c.(CounterSetter).Set(4)
+}
+
 func TestCounterExemplar(t *testing.T) {
 	now := time.Now()
 
@@ -47,6 +47,9 @@ type Desc struct {
 	fqName string
 	// help provides some helpful information about this metric.
 	help string
+	// unit is the unit of this metric as exposed in the OpenMetrics format,
+	// or empty if no unit has been set.
+	unit string
 	// constLabelPairs contains precalculated DTO label pairs based on
 	// the constant labels.
 	constLabelPairs []*dto.LabelPair
@@ -57,8 +60,8 @@ type Desc struct {
 	// must be unique among all registered descriptors and can therefore be
 	// used as an identifier of the descriptor.
 	id uint64
-	// dimHash is a hash of the label names (preset and variable) and the
-	// Help string. Each Desc with the same fqName must have the same
+	// dimHash is a hash of the label names (preset and variable), the
+	// Help string, and the unit. Each Desc with the same fqName must have the same
 	// dimHash.
 	dimHash uint64
 	// err is an error that occurred during construction. It is reported on
@@ -90,15 +93,27 @@ func NewDesc(fqName, help string, variableLabels []string, constLabels Labels) *
 // For constLabels, the label values are constant. Therefore, they are fully
 // specified in the Desc. See the Collector example for a usage pattern.
 func (v2) NewDesc(fqName, help string, variableLabels ConstrainableLabels, constLabels Labels) *Desc {
+	return newDesc(fqName, help, "", variableLabels, constLabels)
+}
+
+// newDesc works like V2.NewDesc but additionally sets the unit of the
+// Desc. The unit must be a suffix of fqName (before a "_total" suffix, if
+// any), separated by "_". Otherwise, an error is recorded in the Desc.
+func newDesc(fqName, help, unit string, variableLabels ConstrainableLabels, constLabels Labels) *Desc {
 	d := &Desc{
 		fqName:         fqName,
 		help:           help,
+		unit:           unit,
 		variableLabels: variableLabels.compile(),
 	}
 	if !model.IsValidMetricName(model.LabelValue(fqName)) {
 		d.err = fmt.Errorf("%q is not a valid metric name", fqName)
 		return d
 	}
+	if unit != "" && !checkUnit(fqName, unit) {
+		d.err = fmt.Errorf("unit %q does not match the name of metric %q", unit, fqName)
+		return d
+	}
 	// labelValues contains the label values of const labels (in order of
 	// their sorted label names) plus the fqName (at position 0).
 	labelValues := make([]string, 1, len(constLabels)+1)
@@ -149,11 +164,15 @@ func (v2) NewDesc(fqName, help string, variableLabels ConstrainableLabels, const
 	d.id = xxh.Sum64()
 	// Sort labelNames so that order doesn't matter for the hash.
 	sort.Strings(labelNames)
-	// Now hash together (in this order) the help string and the sorted
-	// label names.
+	// Now hash together (in this order) the help string, the unit (if
+	// any), and the sorted label names.
 	xxh.Reset()
 	xxh.WriteString(help)
 	xxh.Write(separatorByteSlice)
+	if unit != "" {
+		xxh.WriteString(unit)
+		xxh.Write(separatorByteSlice)
+	}
 	for _, labelName := range labelNames {
 		xxh.WriteString(labelName)
 		xxh.Write(separatorByteSlice)
@@ -171,6 +190,17 @@ func (v2) NewDesc(fqName, help string, variableLabels ConstrainableLabels, const
 	return d
 }
 
+// checkUnit returns whether the provided unit is a suffix of the provided
+// metric name, as required by OpenMetrics. A "_total" suffix of the name is
+// ignored, so that counters like "request_duration_seconds_total" can have
+// the unit "seconds".
+func checkUnit(fqName, unit string) bool {
+	if strings.HasPrefix(unit, "_") || strings.HasSuffix(unit, "_") {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimSuffix(fqName, "_total"), "_"+unit)
+}
+
 // NewInvalidDesc returns an invalid descriptor, i.e. a descriptor with the
 // provided error set. If a collector returning such a descriptor is registered,
 // registration will fail with the provided error. NewInvalidDesc can be used by
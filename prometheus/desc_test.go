@@ -14,6 +14,7 @@
 package prometheus
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -28,3 +29,41 @@ func TestNewDescInvalidLabelValues(t *testing.T) {
 		t.Errorf("NewDesc: expected error because: %s", desc.err)
 	}
 }
+
+func TestNewDescUnit(t *testing.T) {
+	scenarios := []struct {
+		name, unit string
+		valid      bool
+	}{
+		{"request_duration_seconds", "seconds", true},
+		{"processed_bytes_total", "bytes", true},
+		{"disk_io_time_seconds_total", "seconds_total", false},
+		{"response_size_bytes", "kilobytes", false},
+		{"requests_total", "total", false},
+		{"seconds", "seconds", false},
+		{"request_duration_seconds", "_seconds", false},
+		{"request_duration_seconds", "", true},
+	}
+	for _, s := range scenarios {
+		desc := newDesc(s.name, "help", s.unit, UnconstrainedLabels(nil), nil)
+		if got := desc.err == nil; got != s.valid {
+			t.Errorf("name %q, unit %q: got valid %t, want %t (error: %v)", s.name, s.unit, got, s.valid, desc.err)
+		}
+	}
+
+	// Different units for the same name are inconsistent.
+	reg := NewRegistry()
+	reg.MustRegister(NewGauge(GaugeOpts{Name: "size_bytes", Help: "help", Unit: "bytes"}))
+	err := reg.Register(NewGauge(GaugeOpts{Name: "size_bytes", Help: "help", ConstLabels: Labels{"a": "b"}}))
+	if err == nil || !strings.Contains(err.Error(), `has unit "bytes" instead of ""`) {
+		t.Errorf("expected error naming both units registering the same name without unit, got %v", err)
+	}
+
+	// Wrapping Registerers keep the unit.
+	WrapRegistererWith(Labels{"a": "b"}, WrapRegistererWithPrefix("app_", reg)).MustRegister(
+		NewGauge(GaugeOpts{Name: "size_bytes", Help: "help", Unit: "bytes"}),
+	)
+	if got := registryUnits(Gatherers{reg})["app_size_bytes"]; got != "bytes" {
+		t.Errorf("got unit %q for wrapped gauge, want %q", got, "bytes")
+	}
+}
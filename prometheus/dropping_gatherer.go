@@ -0,0 +1,91 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	dto "github.com/prometheus/client_model/go"
+)
+
+type droppingGatherer struct {
+	g    Gatherer
+	drop func(name string, m *dto.Metric) bool
+}
+
+// NewDroppingGatherer returns a Gatherer that removes metrics from the result
+// of the provided Gatherer. The drop function is called for each metric with
+// the name of its metric family and all of its labels. If it returns true, the
+// metric is removed. Metric families without any remaining metrics are
+// removed completely. An error returned by the provided Gatherer is passed on
+// unchanged, together with the remaining metric families.
+//
+// This is a lightweight way to suppress unwanted metrics, e.g. noisy metrics
+// of a third-party Collector. Note that the dropped metrics are still
+// collected, so there are no savings in collection time.
+//
+// The metric families returned by the provided Gatherer are not modified.
+// Where metrics are removed, a copy of the metric family is returned instead.
+func NewDroppingGatherer(g Gatherer, drop func(name string, labels Labels) bool) Gatherer {
+	return &droppingGatherer{
+		g: g,
+		drop: func(name string, m *dto.Metric) bool {
+			labels := make(Labels, len(m.Label))
+			for _, lp := range m.Label {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			return drop(name, labels)
+		},
+	}
+}
+
+// Gather implements Gatherer.
+func (dg *droppingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := dg.g.Gather()
+	result := mfs[:0:0]
+	for _, mf := range mfs {
+		if mf = dg.filter(mf); mf != nil {
+			result = append(result, mf)
+		}
+	}
+	return result, err
+}
+
+// filter returns mf without the dropped metrics, or nil if all metrics have
+// been dropped.
+func (dg *droppingGatherer) filter(mf *dto.MetricFamily) *dto.MetricFamily {
+	var kept []*dto.Metric
+	for i, m := range mf.Metric {
+		if !dg.drop(mf.GetName(), m) {
+			if kept != nil {
+				kept = append(kept, m)
+			}
+			continue
+		}
+		if kept == nil {
+			// First dropped metric, so copy the ones kept so far.
+			kept = append(make([]*dto.Metric, 0, len(mf.Metric)-1), mf.Metric[:i]...)
+		}
+	}
+	switch {
+	case kept == nil:
+		return mf
+	case len(kept) == 0:
+		return nil
+	}
+	return &dto.MetricFamily{
+		Name:   mf.Name,
+		Help:   mf.Help,
+		Type:   mf.Type,
+		Metric: kept,
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestDroppingGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Requests.",
+	}, []string{"path"})
+	requests.WithLabelValues("/").Inc()
+	requests.WithLabelValues("/debug").Inc()
+	noisy := prometheus.NewGauge(prometheus.GaugeOpts{Name: "noisy", Help: "Noisy."})
+	kept := prometheus.NewGauge(prometheus.GaugeOpts{Name: "kept", Help: "Kept."})
+	reg.MustRegister(requests, noisy, kept)
+
+	g := prometheus.NewDroppingGatherer(reg, func(name string, labels prometheus.Labels) bool {
+		return name == "noisy" || labels["path"] == "/debug"
+	})
+
+	const expected = `
+# HELP kept Kept.
+# TYPE kept gauge
+kept 0
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{path="/"} 1
+`
+	if err := testutil.GatherAndCompare(g, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// The result of the wrapped Gatherer is not modified.
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+	if _, err := prometheus.NewDroppingGatherer(original, func(string, prometheus.Labels) bool { return true }).Gather(); err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 3 || len(mfs[2].Metric) != 2 {
+		t.Errorf("wrapped Gatherer result modified: %v", mfs)
+	}
+}
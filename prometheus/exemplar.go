@@ -0,0 +1,334 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/adhimaswaskita/client_golang/prometheus/internal"
+)
+
+// ExemplarLimitPolicy defines how metrics deal with exemplars whose labels
+// exceed the configured maximum length or are invalid otherwise.
+type ExemplarLimitPolicy int
+
+// These constants define the available ExemplarLimitPolicy values.
+const (
+	// Panic upon an invalid exemplar in the ...WithExemplar methods. This
+	// is the default to stay compatible with earlier versions of this
+	// package.
+	ExemplarLimitPanic ExemplarLimitPolicy = iota
+	// Truncate the labels of an exemplar that exceeds the maximum length.
+	// Label values are cut off, and labels that do not fit at all are
+	// removed, both in lexicographical order of the label names. Exemplars
+	// with invalid label names or values are dropped.
+	ExemplarLimitTruncate
+	// Drop invalid exemplars, including those exceeding the maximum
+	// length. The observation itself is still recorded.
+	ExemplarLimitDrop
+)
+
+// ExemplarOpts bundles the options for the handling of exemplars in metrics
+// supporting them. The zero value of ExemplarOpts results in the behavior of
+// earlier versions of this package, i.e. a panic upon invalid exemplars or
+// exemplars with labels exceeding ExemplarMaxRunes.
+type ExemplarOpts struct {
+	// LimitPolicy defines what happens with invalid exemplars, in
+	// particular those exceeding MaxRunes. Use a policy other than
+	// ExemplarLimitPanic if exemplar labels are derived from user input.
+	LimitPolicy ExemplarLimitPolicy
+
+	// MaxRunes is the maximum total number of runes allowed in the label
+	// names and values of an exemplar. If MaxRunes is zero or negative,
+	// ExemplarMaxRunes is used, which is the limit set by the OpenMetrics
+	// specification.
+	MaxRunes int
+
+	// If Registry is not nil, it is used to register a metric
+	// "prometheus_exemplar_dropped_total", partitioned by "action", which
+	// counts exemplars that have been "dropped" or "truncated" according to
+	// the LimitPolicy. The same metric may be shared between metrics
+	// created with the same Registry. A failed registration causes a panic.
+	Registry Registerer
+
+	// If RandSource is not nil, it is used to decide whether an exemplar is
+	// sampled in ObserveWithExemplarSampled, rather than the internal
+	// pseudo-random number generator. Calls of its Int63 method are
+	// serialized with a mutex (shared by the Histograms of a HistogramVec),
+	// so the provided Source doesn't need to be safe for concurrent use, but
+	// also note the additional cost of synchronization. This is mostly
+	// useful for reproducible tests with a seeded Source.
+	RandSource rand.Source
+}
+
+// ExemplarLister is implemented by metrics that store exemplars, in
+// particular the Counters and Histograms provided by this package. Its
+// Exemplars method returns the currently stored exemplars, which must not be
+// modified. It is meant for inspection and debugging.
+type ExemplarLister interface {
+	Exemplars() []*dto.Exemplar
+}
+
+// ExemplarClearer is implemented by metrics that store exemplars, in
+// particular the Counters and Histograms provided by this package. Its
+// ClearExemplars method removes all currently stored exemplars without
+// affecting the value of the metric.
+type ExemplarClearer interface {
+	ClearExemplars()
+}
+
+var errExemplarDropped = errors.New("exemplar dropped")
+
+// ExemplarLabels are exemplar labels that have been validated in advance, see
+// NewExemplarLabels. They are immutable and may be used concurrently.
+type ExemplarLabels struct {
+	pairs []*dto.LabelPair // Sorted by name.
+	runes int
+}
+
+// NewExemplarLabels returns ExemplarLabels for the provided Labels. It returns
+// an error if any of the label names is invalid or any of the label values is
+// not valid UTF-8.
+//
+// ExemplarLabels are meant for hot paths where the same exemplar labels (e.g. a
+// trace ID valid for a whole request) are used for many observations: The
+// Labels are validated and converted only once here rather than upon each
+// observation, as it would happen with ObserveWithExemplar, see
+// ExemplarLabelsObserver. Note that the limit of the total number of runes is
+// still checked upon each observation (which is cheap), as it depends on the
+// ExemplarOpts of the metric.
+func NewExemplarLabels(l Labels) (*ExemplarLabels, error) {
+	el := &ExemplarLabels{pairs: make([]*dto.LabelPair, 0, len(l))}
+	for name, value := range l {
+		if !checkLabelName(name) {
+			return nil, fmt.Errorf("exemplar label name %q is invalid", name)
+		}
+		if !utf8.ValidString(value) {
+			return nil, fmt.Errorf("exemplar label value %q is not valid UTF-8", value)
+		}
+		el.runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+		el.pairs = append(el.pairs, &dto.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(value),
+		})
+	}
+	sort.Sort(internal.LabelPairSorter(el.pairs))
+	return el, nil
+}
+
+// MustNewExemplarLabels is a version of NewExemplarLabels that panics where
+// NewExemplarLabels would have returned an error.
+func MustNewExemplarLabels(l Labels) *ExemplarLabels {
+	el, err := NewExemplarLabels(l)
+	if err != nil {
+		panic(err)
+	}
+	return el
+}
+
+// labels returns el as Labels.
+func (el *ExemplarLabels) labels() Labels {
+	l := make(Labels, len(el.pairs))
+	for _, lp := range el.pairs {
+		l[lp.GetName()] = lp.GetValue()
+	}
+	return l
+}
+
+// exemplarPolicy implements ExemplarOpts. A nil *exemplarPolicy implements the
+// default behavior.
+type exemplarPolicy struct {
+	limitPolicy        ExemplarLimitPolicy
+	maxRunes           int
+	dropped, truncated Counter // nil if not counted
+
+	randMtx    sync.Mutex  // Protects randSource.
+	randSource rand.Source // nil if the internal PRNG is used.
+}
+
+// newExemplarPolicy returns nil for the zero value of ExemplarOpts so that
+// metrics with the default behavior don't carry any overhead.
+func newExemplarPolicy(opts ExemplarOpts) *exemplarPolicy {
+	if opts.LimitPolicy == ExemplarLimitPanic && opts.MaxRunes <= 0 && opts.Registry == nil && opts.RandSource == nil {
+		return nil
+	}
+	p := &exemplarPolicy{
+		limitPolicy: opts.LimitPolicy,
+		maxRunes:    opts.MaxRunes,
+		randSource:  opts.RandSource,
+	}
+	if p.maxRunes <= 0 {
+		p.maxRunes = ExemplarMaxRunes
+	}
+	if opts.Registry != nil {
+		cnt := NewCounterVec(
+			CounterOpts{
+				Name: "prometheus_exemplar_dropped_total",
+				Help: "Total number of exemplars dropped or truncated because they were invalid or exceeded the maximum length.",
+			},
+			[]string{"action"},
+		)
+		if err := opts.Registry.Register(cnt); err != nil {
+			are := &AlreadyRegisteredError{}
+			if !errors.As(err, are) {
+				panic(err)
+			}
+			cnt = are.ExistingCollector.(*CounterVec)
+		}
+		p.dropped = cnt.WithLabelValues("dropped")
+		p.truncated = cnt.WithLabelValues("truncated")
+	}
+	return p
+}
+
+// newExemplar creates a new dto.Exemplar according to the policy. It returns
+// errExemplarDropped if the exemplar has been dropped according to the policy,
+// and it returns the validation error if the policy is ExemplarLimitPanic. In
+// the latter case, the caller is expected to panic.
+func (p *exemplarPolicy) newExemplar(value float64, ts time.Time, l Labels) (*dto.Exemplar, error) {
+	if p == nil {
+		return newExemplar(value, ts, l)
+	}
+	e, err := newExemplarWithMaxRunes(value, ts, l, p.maxRunes)
+	if err == nil || p.limitPolicy == ExemplarLimitPanic {
+		return e, err
+	}
+	if p.limitPolicy == ExemplarLimitTruncate {
+		if e, err := newExemplarWithMaxRunes(value, ts, truncateExemplarLabels(l, p.maxRunes), p.maxRunes); err == nil {
+			if p.truncated != nil {
+				p.truncated.Inc()
+			}
+			return e, nil
+		}
+	}
+	if p.dropped != nil {
+		p.dropped.Inc()
+	}
+	return nil, errExemplarDropped
+}
+
+// newExemplarFromLabels works like newExemplar, but with ExemplarLabels. The
+// label pairs are shared with the returned dto.Exemplar.
+func (p *exemplarPolicy) newExemplarFromLabels(value float64, ts time.Time, l *ExemplarLabels) (*dto.Exemplar, error) {
+	maxRunes := ExemplarMaxRunes
+	if p != nil {
+		maxRunes = p.maxRunes
+	}
+	if l.runes > maxRunes {
+		// Rare case, so let the regular path deal with it according to
+		// the policy.
+		return p.newExemplar(value, ts, l.labels())
+	}
+	tsProto := timestamppb.New(ts)
+	if err := tsProto.CheckValid(); err != nil {
+		return nil, err
+	}
+	return &dto.Exemplar{
+		Value:     proto.Float64(value),
+		Timestamp: tsProto,
+		Label:     l.pairs,
+	}, nil
+}
+
+// truncateExemplarLabels returns a copy of the provided labels that fits into
+// maxRunes. Labels are processed in lexicographical order of their names. The
+// value of the label that exceeds the limit is truncated, and the remaining
+// labels are removed. l is returned unchanged if any label value is not valid
+// UTF-8, as truncation wouldn't make the exemplar valid anyway.
+func truncateExemplarLabels(l Labels, maxRunes int) Labels {
+	names := make([]string, 0, len(l))
+	for name, value := range l {
+		if !utf8.ValidString(value) {
+			return l
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	truncated := make(Labels, len(l))
+	budget := maxRunes
+	for _, name := range names {
+		budget -= utf8.RuneCountInString(name)
+		if budget < 0 {
+			break
+		}
+		value := l[name]
+		if n := utf8.RuneCountInString(value); n > budget {
+			value = string([]rune(value)[:budget])
+		}
+		budget -= utf8.RuneCountInString(value)
+		truncated[name] = value
+	}
+	return truncated
+}
+
+// sampleExemplar returns true with the provided probability, using the
+// RandSource of the policy, if any.
+func (p *exemplarPolicy) sampleExemplar(probability float64) bool {
+	switch {
+	case probability >= 1:
+		return true
+	case !(probability > 0): // Also catches NaN.
+		return false
+	}
+	var f float64
+	if p != nil && p.randSource != nil {
+		p.randMtx.Lock()
+		f = float64(p.randSource.Int63()>>10) / (1 << 53)
+		p.randMtx.Unlock()
+	} else {
+		r := sampleRandPool.Get().(*splitMix64)
+		f = float64(r.next()>>11) / (1 << 53)
+		sampleRandPool.Put(r)
+	}
+	return f < probability
+}
+
+// sampleRandSeed is advanced for each new splitMix64 so that no two of them
+// yield the same sequence.
+var sampleRandSeed = uint64(time.Now().UnixNano())
+
+// sampleRandPool provides splitMix64 PRNGs without any locking in the common
+// case, as sync.Pool keeps a cache per P. This is much cheaper than the
+// global math/rand source, which is protected by a mutex.
+var sampleRandPool = sync.Pool{
+	New: func() interface{} {
+		return &splitMix64{state: atomic.AddUint64(&sampleRandSeed, 0x9e3779b97f4a7c15)}
+	},
+}
+
+// splitMix64 is a minimal, non-cryptographic PRNG, see
+// https://prng.di.unimi.it/splitmix64.c
+type splitMix64 struct {
+	state uint64
+}
+
+func (r *splitMix64) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,306 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestExemplarLimitPolicy(t *testing.T) {
+	oversized := Labels{
+		"a": strings.Repeat("x", 10),
+		"b": strings.Repeat("y", 10),
+		"c": "z",
+	}
+
+	reg := NewRegistry()
+	opts := ExemplarOpts{MaxRunes: 15, Registry: reg}
+
+	opts.LimitPolicy = ExemplarLimitTruncate
+	truncCounter := NewCounter(CounterOpts{
+		Name:         "truncating_counter",
+		Help:         "help",
+		ExemplarOpts: opts,
+	}).(*counter)
+	opts.LimitPolicy = ExemplarLimitDrop
+	dropHisto := NewHistogram(HistogramOpts{
+		Name:         "dropping_histogram",
+		Help:         "help",
+		Buckets:      []float64{1},
+		ExemplarOpts: opts,
+	}).(*histogram)
+
+	truncCounter.AddWithExemplar(1, oversized)
+	got := map[string]string{}
+	for _, lp := range truncCounter.exemplar.Load().(*dto.Exemplar).Label {
+		got[lp.GetName()] = lp.GetValue()
+	}
+	if len(got) != 2 || got["a"] != strings.Repeat("x", 10) || got["b"] != strings.Repeat("y", 3) {
+		t.Errorf("unexpected truncated exemplar labels %v", got)
+	}
+	// Invalid label names can't be fixed by truncation.
+	truncCounter.AddWithExemplar(1, Labels{":o)": "smile"})
+
+	dropHisto.ObserveWithExemplar(0.5, oversized)
+	if e := dropHisto.exemplars[0].Load(); e != nil {
+		t.Errorf("expected dropped exemplar, got %v", e)
+	}
+
+	m := &dto.Metric{}
+	if err := dropHisto.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Histogram.GetSampleCount(); got != 1 {
+		t.Errorf("expected observation to be recorded despite dropped exemplar, got count %d", got)
+	}
+	if err := truncCounter.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Counter.GetValue(); got != 2 {
+		t.Errorf("expected counter value 2, got %v", got)
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || mfs[0].GetName() != "prometheus_exemplar_dropped_total" {
+		t.Fatalf("unexpected metric families %v", mfs)
+	}
+	values := map[string]float64{}
+	for _, m := range mfs[0].Metric {
+		values[m.Label[0].GetValue()] = m.Counter.GetValue()
+	}
+	if values["truncated"] != 1 || values["dropped"] != 2 {
+		t.Errorf("unexpected drop counts %v", values)
+	}
+}
+
+func TestExemplarLimitPolicyDefaultPanics(t *testing.T) {
+	c := NewCounterVec(CounterOpts{
+		Name:         "test",
+		Help:         "help",
+		ExemplarOpts: ExemplarOpts{MaxRunes: 5},
+	}, []string{"l"})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for exemplar exceeding custom MaxRunes")
+		}
+	}()
+	c.WithLabelValues("x").(ExemplarAdder).AddWithExemplar(1, Labels{"foo": "bar"})
+}
+
+func TestClearExemplars(t *testing.T) {
+	c := NewCounter(CounterOpts{Name: "test_total", Help: "help"})
+	h := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help", Buckets: []float64{1, 2}})
+
+	if got := c.(ExemplarLister).Exemplars(); len(got) != 0 {
+		t.Errorf("expected no counter exemplars, got %v", got)
+	}
+	if got := h.(ExemplarLister).Exemplars(); len(got) != 0 {
+		t.Errorf("expected no histogram exemplars, got %v", got)
+	}
+
+	c.(ExemplarAdder).AddWithExemplar(1, Labels{"trace_id": "a"})
+	h.(ExemplarObserver).ObserveWithExemplar(0.5, Labels{"trace_id": "b"})
+	h.(ExemplarObserver).ObserveWithExemplar(5, Labels{"trace_id": "c"})
+
+	if got := c.(ExemplarLister).Exemplars(); len(got) != 1 || got[0].Label[0].GetValue() != "a" {
+		t.Errorf("unexpected counter exemplars %v", got)
+	}
+	got := h.(ExemplarLister).Exemplars()
+	if len(got) != 2 || got[0].Label[0].GetValue() != "b" || got[1].Label[0].GetValue() != "c" {
+		t.Errorf("unexpected histogram exemplars %v", got)
+	}
+
+	c.(ExemplarClearer).ClearExemplars()
+	h.(ExemplarClearer).ClearExemplars()
+
+	if got := c.(ExemplarLister).Exemplars(); len(got) != 0 {
+		t.Errorf("expected no counter exemplars after clearing, got %v", got)
+	}
+	if got := h.(ExemplarLister).Exemplars(); len(got) != 0 {
+		t.Errorf("expected no histogram exemplars after clearing, got %v", got)
+	}
+
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Counter.Exemplar != nil || m.Counter.GetValue() != 1 {
+		t.Errorf("unexpected counter after clearing exemplar: %v", m.Counter)
+	}
+	m = &dto.Metric{}
+	if err := h.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	// No explicit +Inf bucket without exemplar.
+	if len(m.Histogram.Bucket) != 2 || m.Histogram.GetSampleCount() != 2 {
+		t.Errorf("unexpected histogram after clearing exemplars: %v", m.Histogram)
+	}
+	for _, b := range m.Histogram.Bucket {
+		if b.Exemplar != nil {
+			t.Errorf("unexpected exemplar %v after clearing", b.Exemplar)
+		}
+	}
+}
+
+func TestObserveWithExemplarSampled(t *testing.T) {
+	h := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help", Buckets: []float64{1}})
+	o := h.(SampledExemplarObserver)
+
+	var calls int
+	exemplar := func() Labels {
+		calls++
+		return Labels{"trace_id": "a"}
+	}
+
+	for _, p := range []float64{0, -1, math.NaN()} {
+		o.ObserveWithExemplarSampled(0.5, func() Labels {
+			t.Fatalf("exemplar function called with probability %v", p)
+			return nil
+		}, p)
+	}
+	if got := h.(ExemplarLister).Exemplars(); len(got) != 0 {
+		t.Errorf("expected no exemplars, got %v", got)
+	}
+
+	o.ObserveWithExemplarSampled(0.5, exemplar, 1)
+	if calls != 1 || len(h.(ExemplarLister).Exemplars()) != 1 {
+		t.Errorf("expected exemplar with probability 1, got %d calls", calls)
+	}
+
+	const n = 100000
+	calls = 0
+	for i := 0; i < n; i++ {
+		o.ObserveWithExemplarSampled(0.5, exemplar, 0.1)
+	}
+	// The standard deviation is ~95, so this is very unlikely to fail.
+	if calls < 9000 || calls > 11000 {
+		t.Errorf("expected about %d sampled exemplars, got %d", n/10, calls)
+	}
+
+	m := &dto.Metric{}
+	if err := h.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Histogram.GetSampleCount(), uint64(n+4); got != want {
+		t.Errorf("got sample count %d, want %d", got, want)
+	}
+}
+
+func TestObserveWithExemplarSampledRandSource(t *testing.T) {
+	sampled := func(seed int64) []int {
+		h := NewHistogram(HistogramOpts{
+			Name:         "test_histogram",
+			Help:         "help",
+			ExemplarOpts: ExemplarOpts{RandSource: rand.NewSource(seed)},
+		}).(SampledExemplarObserver)
+		var calls []int
+		for i := 0; i < 1000; i++ {
+			i := i
+			h.ObserveWithExemplarSampled(0.5, func() Labels {
+				calls = append(calls, i)
+				return Labels{"trace_id": "a"}
+			}, 0.1)
+		}
+		return calls
+	}
+
+	first, second := sampled(42), sampled(42)
+	if len(first) == 0 || len(first) == 1000 {
+		t.Fatalf("unexpected number of sampled exemplars: %d", len(first))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("sampling with the same seed is not reproducible: %v vs. %v", first, second)
+	}
+	if reflect.DeepEqual(first, sampled(43)) {
+		t.Error("sampling with different seeds yielded the same result")
+	}
+}
+
+func BenchmarkObserveWithExemplarSampled(b *testing.B) {
+	h := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help"}).(SampledExemplarObserver)
+	exemplar := func() Labels { return Labels{"trace_id": "a"} }
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.ObserveWithExemplarSampled(0.5, exemplar, 0.001)
+		}
+	})
+}
+
+func TestObserveWithExemplarLabels(t *testing.T) {
+	if _, err := NewExemplarLabels(Labels{":o)": "smile"}); err == nil {
+		t.Error("expected error for invalid label name")
+	}
+	if _, err := NewExemplarLabels(Labels{"foo": "\xff"}); err == nil {
+		t.Error("expected error for invalid label value")
+	}
+
+	el := MustNewExemplarLabels(Labels{"trace_id": "abc", "span_id": "def"})
+	h := NewHistogram(HistogramOpts{
+		Name:    "test_histogram",
+		Help:    "help",
+		Buckets: []float64{1},
+	}).(*histogram)
+	h.ObserveWithExemplarLabels(0.5, el)
+	h.ObserveWithExemplarLabels(2, nil)
+
+	if e := h.exemplars[1].Load(); e != nil {
+		t.Errorf("unexpected exemplar for nil ExemplarLabels: %v", e)
+	}
+	e := h.exemplars[0].Load().(*dto.Exemplar)
+	if e.GetValue() != 0.5 || len(e.Label) != 2 || e.Label[0].GetName() != "span_id" || e.Label[1].GetValue() != "abc" {
+		t.Errorf("unexpected exemplar %v", e)
+	}
+
+	// The limit of runes depends on the metric's ExemplarOpts.
+	dropHisto := NewHistogram(HistogramOpts{
+		Name:         "dropping_histogram",
+		Help:         "help",
+		Buckets:      []float64{1},
+		ExemplarOpts: ExemplarOpts{MaxRunes: 10, LimitPolicy: ExemplarLimitDrop},
+	}).(*histogram)
+	dropHisto.ObserveWithExemplarLabels(0.5, el)
+	if e := dropHisto.exemplars[0].Load(); e != nil {
+		t.Errorf("expected dropped exemplar, got %v", e)
+	}
+}
+
+func BenchmarkObserveWithExemplar(b *testing.B) {
+	h := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help"})
+	b.Run("Labels", func(b *testing.B) {
+		o := h.(ExemplarObserver)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			o.ObserveWithExemplar(0.5, Labels{"trace_id": "a1b2c3", "span_id": "d4e5"})
+		}
+	})
+	b.Run("ExemplarLabels", func(b *testing.B) {
+		o := h.(ExemplarLabelsObserver)
+		el := MustNewExemplarLabels(Labels{"trace_id": "a1b2c3", "span_id": "d4e5"})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			o.ObserveWithExemplarLabels(0.5, el)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// familiesDroppedName is the name of the counter returned by
+// NewFamilyLimitGatherer.
+const familiesDroppedName = "prometheus_families_dropped_total"
+
+type familyLimitGatherer struct {
+	g           Gatherer
+	maxFamilies int
+	dropped     Counter
+}
+
+// NewFamilyLimitGatherer wraps the provided Gatherer so that at most
+// maxFamilies metric families are returned by its Gather method. Any further
+// metric families are dropped, and the returned Collector counts them as
+// "prometheus_families_dropped_total". This is a coarse safety limit against
+// runaway metric definitions, e.g. a bug that registers thousands of distinct
+// metric names, rather than a limit of the cardinality of individual metrics.
+//
+// The metric families are dropped deterministically from the end of the list
+// returned by the provided Gatherer, which is sorted by name for all Gatherers
+// implemented by this package. The counter is not subject to the limit and
+// not counted towards it, so that it is still visible if the returned
+// Collector is registered with the Registry that is wrapped (which is the
+// typical usage). The counter is collected before the metric families are
+// dropped, so the drops of one scrape show up in the next scrape. An error
+// returned by the provided Gatherer is passed on unchanged, together with the
+// remaining metric families.
+//
+// NewFamilyLimitGatherer panics if maxFamilies is negative.
+func NewFamilyLimitGatherer(g Gatherer, maxFamilies int) (Gatherer, Collector) {
+	if maxFamilies < 0 {
+		panic(fmt.Errorf("negative maximum number of metric families %d", maxFamilies))
+	}
+	flg := &familyLimitGatherer{
+		g:           g,
+		maxFamilies: maxFamilies,
+		dropped: NewCounter(CounterOpts{
+			Name: familiesDroppedName,
+			Help: "Total number of metric families dropped because the maximum number of metric families was exceeded.",
+		}),
+	}
+	return flg, flg.dropped
+}
+
+// Gather implements Gatherer.
+func (flg *familyLimitGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := flg.g.Gather()
+	if len(mfs) <= flg.maxFamilies {
+		return mfs, err
+	}
+	result := make([]*dto.MetricFamily, 0, flg.maxFamilies+1)
+	var kept, dropped int
+	for _, mf := range mfs {
+		switch {
+		case mf.GetName() == familiesDroppedName:
+			result = append(result, mf)
+		case kept < flg.maxFamilies:
+			result = append(result, mf)
+			kept++
+		default:
+			dropped++
+		}
+	}
+	flg.dropped.Add(float64(dropped))
+	return result, err
+}
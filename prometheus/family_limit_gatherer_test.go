@@ -0,0 +1,63 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestFamilyLimitGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"d", "a", "c", "b"} {
+		reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "helpless"}))
+	}
+	g, c := prometheus.NewFamilyLimitGatherer(reg, 2)
+	reg.MustRegister(c)
+
+	for i, wantDropped := range []float64{0, 2} {
+		mfs, err := g.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, mf := range mfs {
+			names = append(names, mf.GetName())
+			if mf.GetName() == "prometheus_families_dropped_total" {
+				if got := mf.Metric[0].GetCounter().GetValue(); got != wantDropped {
+					t.Errorf("gather %d: got %v dropped families, want %v", i, got, wantDropped)
+				}
+			}
+		}
+		// The counter is not subject to the limit.
+		if got, want := len(names), 3; got != want || names[0] != "a" || names[1] != "b" {
+			t.Errorf("gather %d: got families %v, want a, b, and the counter", i, names)
+		}
+	}
+	if got := testutil.ToFloat64(c); got != 4 {
+		t.Errorf("got %v dropped families in total, want 4", got)
+	}
+
+	// No limit is applied if there are few enough families.
+	g, _ = prometheus.NewFamilyLimitGatherer(reg, 100)
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(mfs), 5; got != want {
+		t.Errorf("got %d families, want %d", got, want)
+	}
+}
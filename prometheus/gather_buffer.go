@@ -0,0 +1,186 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"sort"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus/internal"
+)
+
+// GatherBufferPool is a pool of buffers for the MetricFamilies and Metrics
+// created during gathering. See Registry.PooledGatherer for details. A
+// GatherBufferPool can be shared between Registries. Use NewGatherBufferPool
+// to create one.
+type GatherBufferPool struct {
+	pool sync.Pool
+}
+
+// NewGatherBufferPool returns a new, empty GatherBufferPool.
+func NewGatherBufferPool() *GatherBufferPool {
+	return &GatherBufferPool{pool: sync.Pool{
+		New: func() interface{} { return &gatherBuffer{} },
+	}}
+}
+
+// PooledGatherer returns a TransactionalGatherer that gathers from the
+// Registry in the same way as Registry.Gather, but takes the returned
+// MetricFamilies and Metrics (together with some internal bookkeeping objects)
+// from the provided GatherBufferPool. Once the done function returned by its
+// Gather method is called, all of them are reset and put back into the pool,
+// to be reused by a later Gather call. This reduces the pressure on the garbage
+// collector if a Registry is gathered frequently, e.g. every second by a local
+// agent.
+// The returned TransactionalGatherer can be used with
+// promhttp.HandlerForTransactional.
+//
+// Thus, the returned MetricFamilies, Metrics, and anything referenced by them
+// must not be used after calling done. Note that objects written by the Write
+// methods of Metrics (e.g. the dto.Counter within a dto.Metric) are still
+// allocated upon each Gather call.
+func (r *Registry) PooledGatherer(p *GatherBufferPool) TransactionalGatherer {
+	return &pooledGatherer{r: r, p: p}
+}
+
+type pooledGatherer struct {
+	r *Registry
+	p *GatherBufferPool
+}
+
+// Gather implements TransactionalGatherer.
+func (pg *pooledGatherer) Gather() (_ []*dto.MetricFamily, done func(), err error) {
+	buf := pg.p.pool.Get().(*gatherBuffer)
+	mfs, err := pg.r.gather(buf)
+	var once sync.Once
+	return mfs, func() {
+		once.Do(func() {
+			buf.reset()
+			pg.p.pool.Put(buf)
+		})
+	}, err
+}
+
+// gatherBuffer holds the objects allocated for a single gathering. All its
+// methods can be called on a nil gatherBuffer, in which case the objects are
+// allocated normally. A non-nil gatherBuffer must only be used by one
+// goroutine at a time.
+type gatherBuffer struct {
+	metrics     []*dto.Metric
+	usedMetrics int
+
+	families     []*dto.MetricFamily
+	usedFamilies int
+
+	familiesByName map[string]*dto.MetricFamily
+	hashes         map[uint64]struct{}
+	names          []string
+	result         []*dto.MetricFamily
+}
+
+func (b *gatherBuffer) newMetric() *dto.Metric {
+	if b == nil {
+		return &dto.Metric{}
+	}
+	if b.usedMetrics == len(b.metrics) {
+		b.metrics = append(b.metrics, &dto.Metric{})
+	}
+	m := b.metrics[b.usedMetrics]
+	b.usedMetrics++
+	return m
+}
+
+func (b *gatherBuffer) newFamily() *dto.MetricFamily {
+	if b == nil {
+		return &dto.MetricFamily{}
+	}
+	if b.usedFamilies == len(b.families) {
+		b.families = append(b.families, &dto.MetricFamily{})
+	}
+	mf := b.families[b.usedFamilies]
+	b.usedFamilies++
+	return mf
+}
+
+func (b *gatherBuffer) newFamiliesByName(size int) map[string]*dto.MetricFamily {
+	if b == nil {
+		return make(map[string]*dto.MetricFamily, size)
+	}
+	if b.familiesByName == nil {
+		b.familiesByName = make(map[string]*dto.MetricFamily, size)
+	}
+	return b.familiesByName
+}
+
+func (b *gatherBuffer) newHashes() map[uint64]struct{} {
+	if b == nil {
+		return map[uint64]struct{}{}
+	}
+	if b.hashes == nil {
+		b.hashes = map[uint64]struct{}{}
+	}
+	return b.hashes
+}
+
+// normalize works like internal.NormalizeMetricFamilies but reuses the
+// slices of the buffer.
+func (b *gatherBuffer) normalize(metricFamiliesByName map[string]*dto.MetricFamily) []*dto.MetricFamily {
+	for _, mf := range metricFamiliesByName {
+		sort.Sort(internal.MetricSorter(mf.Metric))
+	}
+	names := b.names[:0]
+	for name, mf := range metricFamiliesByName {
+		if len(mf.Metric) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	result := b.result[:0]
+	for _, name := range names {
+		result = append(result, metricFamiliesByName[name])
+	}
+	b.names, b.result = names, result
+	return result
+}
+
+// reset resets all used objects so that the buffer can be used again. The
+// slices of Metrics within the MetricFamilies keep their capacity.
+func (b *gatherBuffer) reset() {
+	for _, m := range b.metrics[:b.usedMetrics] {
+		m.Reset()
+	}
+	b.usedMetrics = 0
+	for _, mf := range b.families[:b.usedFamilies] {
+		ms := mf.Metric
+		for i := range ms {
+			ms[i] = nil
+		}
+		mf.Reset()
+		mf.Metric = ms[:0]
+	}
+	b.usedFamilies = 0
+	for name := range b.familiesByName {
+		delete(b.familiesByName, name)
+	}
+	for h := range b.hashes {
+		delete(b.hashes, h)
+	}
+	for i := range b.result {
+		b.result[i] = nil
+	}
+	b.result = b.result[:0]
+	b.names = b.names[:0]
+}
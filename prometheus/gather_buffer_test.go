@@ -0,0 +1,89 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func newGatherBufferTestRegistry() *Registry {
+	reg := NewRegistry()
+	cv := NewCounterVec(CounterOpts{Name: "requests_total", Help: "help"}, []string{"code", "method"})
+	gv := NewGaugeVec(GaugeOpts{Name: "in_flight", Help: "help"}, []string{"handler"})
+	h := NewHistogram(HistogramOpts{Name: "latency_seconds", Help: "help"})
+	for i := 0; i < 100; i++ {
+		cv.WithLabelValues(fmt.Sprint(200+i), "GET").Add(float64(i))
+		gv.WithLabelValues(fmt.Sprint("/handler", i)).Set(float64(i))
+	}
+	h.Observe(0.1)
+	reg.MustRegister(cv, gv, h)
+	return reg
+}
+
+func TestPooledGatherer(t *testing.T) {
+	reg := newGatherBufferTestRegistry()
+	want, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := reg.PooledGatherer(NewGatherBufferPool())
+	for i := 0; i < 3; i++ {
+		got, done, err := g.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d metric families, want %d", len(got), len(want))
+		}
+		for j := range got {
+			if !proto.Equal(got[j], want[j]) {
+				t.Errorf("gather %d: got %v, want %v", i, got[j], want[j])
+			}
+		}
+		first := got[0]
+		done()
+		done() // Calling done twice must be harmless.
+		// The returned objects are reset after done.
+		if first.Name != nil || len(first.Metric) != 0 {
+			t.Errorf("expected metric family to be reset after done, got %v", first)
+		}
+	}
+}
+
+func BenchmarkGather(b *testing.B) {
+	reg := newGatherBufferTestRegistry()
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := reg.Gather(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		g := reg.PooledGatherer(NewGatherBufferPool())
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, done, err := g.Gather()
+			if err != nil {
+				b.Fatal(err)
+			}
+			done()
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// These are the values of the "error_type" label of the counter returned by
+// NewErrorTrackingGatherer.
+const (
+	// A collected Metric reported an error, e.g. a Collector returned a
+	// Metric created with NewInvalidMetric.
+	gathererErrorTypeCollect = "collect"
+	// Any other error, e.g. inconsistent or duplicate metrics, or an
+	// error of a Gatherer not implemented by this package.
+	gathererErrorTypeOther = "other"
+)
+
+type errorTrackingGatherer struct {
+	g      Gatherer
+	errors *CounterVec
+}
+
+// NewErrorTrackingGatherer wraps the provided Gatherer so that each error
+// returned by its Gather method is counted. The returned Collector exposes the
+// counts as "prometheus_gatherer_errors_total", partitioned by "error_type",
+// which is "collect" for errors reported by collected Metrics (e.g. via
+// NewInvalidMetric) and "other" for all other errors (e.g. inconsistent
+// metrics). Errors contained in a MultiError are counted individually.
+//
+// The returned Gatherer still returns the MetricFamilies and the error of the
+// wrapped Gatherer unchanged. The returned Collector is typically registered
+// with the Registry that is wrapped, so that the errors of one scrape show up
+// in the next scrape. As the Collector never fails, it doesn't contribute any
+// errors itself.
+func NewErrorTrackingGatherer(g Gatherer) (Gatherer, Collector) {
+	etg := &errorTrackingGatherer{
+		g: g,
+		errors: NewCounterVec(
+			CounterOpts{
+				Name: "prometheus_gatherer_errors_total",
+				Help: "Total number of errors encountered while gathering metrics, partitioned by error type.",
+			},
+			[]string{"error_type"},
+		),
+	}
+	// Initialize so that the counters are visible before the first error.
+	etg.errors.WithLabelValues(gathererErrorTypeCollect)
+	etg.errors.WithLabelValues(gathererErrorTypeOther)
+	return etg, etg.errors
+}
+
+// Gather implements Gatherer.
+func (etg *errorTrackingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := etg.g.Gather()
+	if err == nil {
+		return mfs, nil
+	}
+	multiErr := MultiError{}
+	if errors.As(err, &multiErr) {
+		for _, err := range multiErr {
+			etg.count(err)
+		}
+	} else {
+		etg.count(err)
+	}
+	return mfs, err
+}
+
+func (etg *errorTrackingGatherer) count(err error) {
+	errorType := gathererErrorTypeOther
+	var ce *collectError
+	if errors.As(err, &ce) {
+		errorType = gathererErrorTypeCollect
+	}
+	etg.errors.WithLabelValues(errorType).Inc()
+}
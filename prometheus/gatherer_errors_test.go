@@ -0,0 +1,79 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+type failingCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c failingCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c failingCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.NewInvalidMetric(c.desc, errors.New("collect error"))
+}
+
+func TestErrorTrackingGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(failingCollector{
+		desc: prometheus.NewDesc("failing_metric", "Fails.", nil, nil),
+	})
+	g, c := prometheus.NewErrorTrackingGatherer(reg)
+	reg.MustRegister(c)
+
+	// The errors of the first gather show up in the second one.
+	if _, err := g.Gather(); err == nil {
+		t.Fatal("expected error from wrapped gatherer")
+	}
+	_, err := g.Gather()
+	if err == nil || !strings.Contains(err.Error(), "collect error") {
+		t.Fatalf("expected underlying error, got %v", err)
+	}
+
+	const expected = `
+# HELP prometheus_gatherer_errors_total Total number of errors encountered while gathering metrics, partitioned by error type.
+# TYPE prometheus_gatherer_errors_total counter
+prometheus_gatherer_errors_total{error_type="collect"} 2
+prometheus_gatherer_errors_total{error_type="other"} 0
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// Errors of other gatherers are counted as "other".
+	g, c = prometheus.NewErrorTrackingGatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, prometheus.MultiError{errors.New("one"), errors.New("two")}
+	}))
+	if _, err := g.Gather(); err == nil {
+		t.Fatal("expected error from wrapped gatherer")
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP prometheus_gatherer_errors_total Total number of errors encountered while gathering metrics, partitioned by error type.
+# TYPE prometheus_gatherer_errors_total counter
+prometheus_gatherer_errors_total{error_type="collect"} 0
+prometheus_gatherer_errors_total{error_type="other"} 2
+`)); err != nil {
+		t.Error(err)
+	}
+}
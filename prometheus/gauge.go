@@ -14,6 +14,7 @@
 package prometheus
 
 import (
+	"fmt"
 	"math"
 	"sync/atomic"
 	"time"
@@ -76,10 +77,11 @@ type GaugeVecOpts struct {
 // scenarios for Gauges and Counters, where the former tends to be Set-heavy and
 // the latter Inc-heavy.
 func NewGauge(opts GaugeOpts) Gauge {
-	desc := NewDesc(
+	desc := newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 		opts.Help,
-		nil,
+		opts.Unit,
+		UnconstrainedLabels(nil),
 		opts.ConstLabels,
 	)
 	result := &gauge{desc: desc, labelPairs: desc.constLabelPairs}
@@ -158,9 +160,10 @@ func NewGaugeVec(opts GaugeOpts, labelNames []string) *GaugeVec {
 
 // NewGaugeVec creates a new GaugeVec based on the provided GaugeVecOpts.
 func (v2) NewGaugeVec(opts GaugeVecOpts) *GaugeVec {
-	desc := V2.NewDesc(
+	desc := newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 		opts.Help,
+		opts.Unit,
 		opts.VariableLabels,
 		opts.ConstLabels,
 	)
@@ -192,7 +195,8 @@ func (v2) NewGaugeVec(opts GaugeVecOpts) *GaugeVec {
 // example.
 //
 // An error is returned if the number of label values is not the same as the
-// number of variable labels in Desc (minus any curried labels).
+// number of variable labels in Desc (minus any curried labels) or if any of the
+// label values is not valid UTF-8.
 //
 // Note that for more than one label value, this method is prone to mistakes
 // caused by an incorrect order of arguments. Consider GetMetricWith(Labels) as
@@ -214,7 +218,8 @@ func (v *GaugeVec) GetMetricWithLabelValues(lvs ...string) (Gauge, error) {
 // the same as for GetMetricWithLabelValues.
 //
 // An error is returned if the number and names of the Labels are inconsistent
-// with those of the variable labels in Desc (minus any curried labels).
+// with those of the variable labels in Desc (minus any curried labels) or if
+// any of the label values is not valid UTF-8.
 //
 // This method is used for the same purpose as
 // GetMetricWithLabelValues(...string). See there for pros and cons of the two
@@ -302,10 +307,63 @@ type GaugeFunc interface {
 // value of 1. Example:
 // https://github.com/prometheus/common/blob/8558a5b7db3c84fa38b4766966059a7bd5bfa2ee/version/info.go#L36-L56
 func NewGaugeFunc(opts GaugeOpts, function func() float64) GaugeFunc {
-	return newValueFunc(NewDesc(
+	return newValueFunc(newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 		opts.Help,
-		nil,
+		opts.Unit,
+		UnconstrainedLabels(nil),
 		opts.ConstLabels,
 	), GaugeValue, function)
 }
+
+type multiValueFunc struct {
+	descs    []*Desc
+	function func() []float64
+}
+
+// NewMultiValueFunc returns a Collector that reports one gauge for each of the
+// provided Descs, with the values returned by a single call of the provided
+// function at collect time. The i-th value is reported for the i-th Desc. This
+// is useful for related values computed together, e.g. the minimum, average,
+// and maximum of the same snapshot, without implementing a full Collector.
+// Take into account that metric collection may happen concurrently. Therefore,
+// it must be safe to call the provided function concurrently.
+//
+// If the function returns a different number of values than there are Descs,
+// an invalid metric is reported for each Desc, so that gathering fails with a
+// corresponding error. NewMultiValueFunc panics if a Desc has variable labels,
+// as the function provides no label values.
+func NewMultiValueFunc(descs []*Desc, function func() []float64) Collector {
+	for _, desc := range descs {
+		if len(desc.variableLabels.names) > 0 {
+			panic(fmt.Errorf("%s has variable labels, which NewMultiValueFunc does not support", desc))
+		}
+	}
+	return &multiValueFunc{descs: descs, function: function}
+}
+
+// Describe implements Collector.
+func (m *multiValueFunc) Describe(ch chan<- *Desc) {
+	for _, desc := range m.descs {
+		ch <- desc
+	}
+}
+
+// Collect implements Collector.
+func (m *multiValueFunc) Collect(ch chan<- Metric) {
+	values := m.function()
+	if len(values) != len(m.descs) {
+		err := fmt.Errorf("function returned %d values for %d descs", len(values), len(m.descs))
+		for _, desc := range m.descs {
+			ch <- NewInvalidMetric(desc, err)
+		}
+		return
+	}
+	for i, desc := range m.descs {
+		metric, err := NewConstMetric(desc, GaugeValue, values[i])
+		if err != nil {
+			metric = NewInvalidMetric(desc, err)
+		}
+		ch <- metric
+	}
+}
@@ -193,6 +193,42 @@ func TestGaugeFunc(t *testing.T) {
 	}
 }
 
+func TestMultiValueFunc(t *testing.T) {
+	var (
+		minDesc = NewDesc("latency_min_seconds", "Minimum latency.", nil, nil)
+		maxDesc = NewDesc("latency_max_seconds", "Maximum latency.", nil, Labels{"a": "1"})
+		values  = []float64{0.5, 2}
+	)
+	reg := NewPedanticRegistry()
+	reg.MustRegister(NewMultiValueFunc([]*Desc{minDesc, maxDesc}, func() []float64 { return values }))
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 2 {
+		t.Fatalf("got %d metric families, want 2", len(mfs))
+	}
+	if got := mfs[0].Metric[0].GetGauge().GetValue(); mfs[0].GetName() != "latency_max_seconds" || got != 2 {
+		t.Errorf("got %s %v, want latency_max_seconds 2", mfs[0].GetName(), got)
+	}
+	if got := mfs[1].Metric[0].GetGauge().GetValue(); mfs[1].GetName() != "latency_min_seconds" || got != 0.5 {
+		t.Errorf("got %s %v, want latency_min_seconds 0.5", mfs[1].GetName(), got)
+	}
+
+	values = values[:1]
+	if _, err := reg.Gather(); err == nil {
+		t.Error("expected error for wrong number of values")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for desc with variable labels")
+		}
+	}()
+	NewMultiValueFunc([]*Desc{NewDesc("labeled", "Labeled.", []string{"l"}, nil)}, func() []float64 { return nil })
+}
+
 func TestGaugeSetCurrentTime(t *testing.T) {
 	g := NewGauge(GaugeOpts{
 		Name: "test_name",
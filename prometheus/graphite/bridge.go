@@ -24,6 +24,7 @@ import (
 	"net"
 	"sort"
 	"time"
+	"unicode"
 
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
@@ -54,7 +55,12 @@ const (
 
 // Config defines the Graphite bridge config.
 type Config struct {
-	// Whether to use Graphite tags or not. Defaults to false.
+	// Whether to use Graphite tags or not. Defaults to false, i.e. label
+	// names and values are appended to the metric name as dot-separated
+	// path components. If true, labels become Graphite tags instead, e.g.
+	// "name;label1=value1;label2=value2", with the tags sorted by name.
+	// Labels with an empty value are omitted in that case, and characters
+	// not allowed in tag values are replaced by '_'.
 	UseTags bool
 
 	// The url to push data to. Required.
@@ -79,6 +85,17 @@ type Config struct {
 	// logged regardless of the configured ErrorHandling provided Logger
 	// is not nil.
 	ErrorHandling HandlerErrorHandling
+
+	// The label name to use for the upper bound of Histogram buckets
+	// instead of "le", e.g. if the Graphite backend reserves the latter.
+	// Defaults to "le". If a Histogram already has a label with that name,
+	// Push returns an error.
+	HistogramBucketLabelName string
+
+	// The label name to use for the quantile of Summaries instead of
+	// "quantile". Defaults to "quantile". If a Summary already has a label
+	// with that name, Push returns an error.
+	SummaryQuantileLabelName string
 }
 
 // Bridge pushes metrics to the configured Graphite server.
@@ -91,10 +108,22 @@ type Bridge struct {
 
 	errorHandling HandlerErrorHandling
 	logger        Logger
+	labelNames    reservedLabelNames
 
 	g prometheus.Gatherer
 }
 
+// reservedLabelNames are the names of the labels that expfmt.ExtractSamples
+// adds for Histogram buckets and Summary quantiles.
+type reservedLabelNames struct {
+	bucket, quantile model.LabelName
+}
+
+var defaultReservedLabelNames = reservedLabelNames{
+	bucket:   model.BucketLabel,
+	quantile: model.QuantileLabel,
+}
+
 // Logger is the minimal interface Bridge needs for logging. Note that
 // log.Logger from the standard library implements this interface, and it is
 // easy to implement by custom loggers, if they don't do so already anyway.
@@ -142,6 +171,20 @@ func NewBridge(c *Config) (*Bridge, error) {
 
 	b.errorHandling = c.ErrorHandling
 
+	b.labelNames = defaultReservedLabelNames
+	if c.HistogramBucketLabelName != "" {
+		b.labelNames.bucket = model.LabelName(c.HistogramBucketLabelName)
+		if !b.labelNames.bucket.IsValid() {
+			return nil, fmt.Errorf("invalid histogram bucket label name %q", c.HistogramBucketLabelName)
+		}
+	}
+	if c.SummaryQuantileLabelName != "" {
+		b.labelNames.quantile = model.LabelName(c.SummaryQuantileLabelName)
+		if !b.labelNames.quantile.IsValid() {
+			return nil, fmt.Errorf("invalid summary quantile label name %q", c.SummaryQuantileLabelName)
+		}
+	}
+
 	return b, nil
 }
 
@@ -184,15 +227,28 @@ func (b *Bridge) Push() error {
 	}
 	defer conn.Close()
 
-	return writeMetrics(conn, mfs, b.useTags, b.prefix, model.Now())
+	return writeMetrics(conn, mfs, b.useTags, b.prefix, b.labelNames, model.Now())
 }
 
-func writeMetrics(w io.Writer, mfs []*dto.MetricFamily, useTags bool, prefix string, now model.Time) error {
-	vec, err := expfmt.ExtractSamples(&expfmt.DecodeOptions{
-		Timestamp: now,
-	}, mfs...)
-	if err != nil {
-		return err
+func writeMetrics(w io.Writer, mfs []*dto.MetricFamily, useTags bool, prefix string, labelNames reservedLabelNames, now model.Time) error {
+	var vec model.Vector
+	for _, mf := range mfs {
+		samples, err := expfmt.ExtractSamples(&expfmt.DecodeOptions{
+			Timestamp: now,
+		}, mf)
+		if err != nil {
+			return err
+		}
+		switch mf.GetType() {
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			err = renameLabel(samples, model.BucketLabel, labelNames.bucket)
+		case dto.MetricType_SUMMARY:
+			err = renameLabel(samples, model.QuantileLabel, labelNames.quantile)
+		}
+		if err != nil {
+			return err
+		}
+		vec = append(vec, samples...)
 	}
 
 	buf := bufio.NewWriter(w)
@@ -221,6 +277,26 @@ func writeMetrics(w io.Writer, mfs []*dto.MetricFamily, useTags bool, prefix str
 	return nil
 }
 
+// renameLabel renames the label from to the label to in all samples that have
+// it. It returns an error if a sample already has a label named to.
+func renameLabel(samples model.Vector, from, to model.LabelName) error {
+	if from == to {
+		return nil
+	}
+	for _, s := range samples {
+		value, ok := s.Metric[from]
+		if !ok {
+			continue
+		}
+		if _, ok := s.Metric[to]; ok {
+			return fmt.Errorf("cannot rename label %q to %q in metric %s: label already exists", from, to, s.Metric)
+		}
+		delete(s.Metric, from)
+		s.Metric[to] = value
+	}
+	return nil
+}
+
 func writeMetric(buf *bufio.Writer, m model.Metric, useTags bool) error {
 	metricName, hasName := m[model.MetricNameLabel]
 	numLabels := len(m) - 1
@@ -247,16 +323,42 @@ func writeMetric(buf *bufio.Writer, m model.Metric, useTags bool) error {
 }
 
 func writeTags(buf *bufio.Writer, m model.Metric) error {
+	labels := make(model.LabelNames, 0, len(m))
 	for label, value := range m {
-		if label != model.MetricNameLabel {
-			buf.WriteRune(';')
-			if _, err := buf.WriteString(string(label)); err != nil {
-				return err
-			}
-			buf.WriteRune('=')
-			if _, err := buf.WriteString(string(value)); err != nil {
-				return err
-			}
+		// Graphite doesn't accept empty tag values. An empty label value is
+		// the same as a missing label in Prometheus anyway.
+		if label != model.MetricNameLabel && value != "" {
+			labels = append(labels, label)
+		}
+	}
+	sort.Sort(labels)
+	for _, label := range labels {
+		if err := buf.WriteByte(';'); err != nil {
+			return err
+		}
+		if _, err := buf.WriteString(string(label)); err != nil {
+			return err
+		}
+		if err := buf.WriteByte('='); err != nil {
+			return err
+		}
+		if err := writeTagValue(buf, string(m[label])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTagValue writes the provided tag value with ';' and whitespace (which
+// would break the plaintext protocol) and a leading '~' (which is not allowed
+// by Graphite) replaced by '_'.
+func writeTagValue(buf *bufio.Writer, s string) error {
+	for i, c := range s {
+		if c == ';' || unicode.IsSpace(c) || (i == 0 && c == '~') {
+			c = '_'
+		}
+		if _, err := buf.WriteRune(c); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -29,7 +29,9 @@ import (
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/adhimaswaskita/client_golang/prometheus"
 )
@@ -64,6 +66,48 @@ func TestSanitize(t *testing.T) {
 	}
 }
 
+func TestWriteTags(t *testing.T) {
+	testCases := []struct {
+		in  model.Metric
+		out string
+	}{
+		{
+			in:  model.Metric{model.MetricNameLabel: "name"},
+			out: "name",
+		},
+		{
+			in:  model.Metric{model.MetricNameLabel: "name", "b": "2", "a": "1", "c": "3"},
+			out: "name;a=1;b=2;c=3",
+		},
+		{
+			in:  model.Metric{model.MetricNameLabel: "name", "empty": "", "a": "1"},
+			out: "name;a=1",
+		},
+		{
+			in:  model.Metric{model.MetricNameLabel: "name", "a": "~with space;and~semicolon", "b": "a.b/c=d"},
+			out: "name;a=_with_space_and~semicolon;b=a.b/c=d",
+		},
+	}
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	for i, tc := range testCases {
+		if err := writeMetric(w, tc.in, true); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("flush failed: %v", err)
+		}
+
+		if want, got := tc.out, buf.String(); want != got {
+			t.Errorf("test case index %d: got tagged metric %s, want %s", i, got, want)
+		}
+
+		buf.Reset()
+	}
+}
+
 func TestWriteSummary(t *testing.T) {
 	testWriteSummary(t, false)
 	testWriteSummary(t, true)
@@ -137,7 +181,7 @@ func testWriteSummary(t *testing.T, useTags bool) {
 
 		now := model.Time(1477043083)
 		var buf bytes.Buffer
-		err = writeMetrics(&buf, mfs, useTags, tc.prefix, now)
+		err = writeMetrics(&buf, mfs, useTags, tc.prefix, defaultReservedLabelNames, now)
 		if err != nil {
 			t.Fatalf("error: %v", err)
 		}
@@ -193,7 +237,7 @@ func testWriteHistogram(t *testing.T, useTags bool) {
 
 	now := model.Time(1477043083)
 	var buf bytes.Buffer
-	err = writeMetrics(&buf, mfs, useTags, "prefix", now)
+	err = writeMetrics(&buf, mfs, useTags, "prefix", defaultReservedLabelNames, now)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -242,6 +286,58 @@ prefix.name_bucket;constname=constvalue;labelname=val2;le=+Inf 3 1477043
 	}
 }
 
+func TestRenameReservedLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	his := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "his",
+		Help:    "docstring",
+		Buckets: []float64{1},
+	})
+	sum := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "sum",
+		Help:       "docstring",
+		Objectives: map[float64]float64{0.5: 0.05},
+	})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "gauge",
+		Help:        "docstring",
+		ConstLabels: prometheus.Labels{"le": "untouched"},
+	})
+	reg.MustRegister(his, sum, gauge)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	labelNames := reservedLabelNames{bucket: "bound", quantile: "q"}
+	if err := writeMetrics(&buf, mfs, false, "", labelNames, model.Time(1477043083)); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := `gauge.le.untouched 0 1477043
+his_bucket.bound.1 0 1477043
+his_sum 0 1477043
+his_count 0 1477043
+his_bucket.bound._Inf 0 1477043
+sum.q.0_5 NaN 1477043
+sum_sum 0 1477043
+sum_count 0 1477043
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Renaming to an existing label fails.
+	labelNames = reservedLabelNames{bucket: "le", quantile: "le"}
+	mfs[2].Metric[0].Label = append(mfs[2].Metric[0].Label, &dto.LabelPair{
+		Name:  proto.String("le"),
+		Value: proto.String("conflict"),
+	})
+	if err := writeMetrics(&buf, mfs, false, "", labelNames, model.Time(1477043083)); err == nil {
+		t.Error("expected error for conflicting label name")
+	}
+}
+
 func TestToReader(t *testing.T) {
 	testToReader(t, false)
 	testToReader(t, true)
@@ -282,7 +378,7 @@ prefix.name;constname=constvalue;labelname=val2 1 1477043
 
 	now := model.Time(1477043083)
 	var buf bytes.Buffer
-	err = writeMetrics(&buf, mfs, useTags, "prefix", now)
+	err = writeMetrics(&buf, mfs, useTags, "prefix", defaultReservedLabelNames, now)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
@@ -14,10 +14,13 @@
 package prometheus
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -324,6 +327,42 @@ func ExponentialBuckets(start, factor float64, count int) []float64 {
 	return buckets
 }
 
+// HybridBuckets creates 'linearCount' linear buckets followed by 'expCount'
+// exponential buckets. The linear buckets are the same as created by
+// LinearBuckets(linearStart, linearWidth, linearCount). Each of the following
+// exponential buckets has an upper bound that is 'expFactor' times the previous
+// bucket's upper bound, starting with the upper bound of the highest linear
+// bucket, which is not repeated. This provides fine resolution for low values
+// and coverage of a long tail with few buckets. The final +Inf bucket is not
+// counted and not included in the returned slice. The returned slice is meant to
+// be used for the Buckets field of HistogramOpts.
+//
+// The function panics if 'linearCount' or 'expCount' is 0 or negative, if
+// 'linearWidth' is 0 or negative, if the upper bound of the highest linear
+// bucket is 0 or negative (as the exponential buckets wouldn't grow), or if
+// 'expFactor' is less than or equal 1.
+func HybridBuckets(linearStart, linearWidth float64, linearCount int, expFactor float64, expCount int) []float64 {
+	if linearCount < 1 {
+		panic("HybridBuckets needs a positive linearCount")
+	}
+	if expCount < 1 {
+		panic("HybridBuckets needs a positive expCount")
+	}
+	if linearWidth <= 0 {
+		panic("HybridBuckets needs a positive linearWidth")
+	}
+	if expFactor <= 1 {
+		panic("HybridBuckets needs an expFactor greater than 1")
+	}
+	buckets := LinearBuckets(linearStart, linearWidth, linearCount)
+	junction := buckets[len(buckets)-1]
+	if junction <= 0 {
+		panic("HybridBuckets needs a positive upper bound of the highest linear bucket")
+	}
+	exp := ExponentialBuckets(junction, expFactor, expCount+1)
+	return append(buckets, exp[1:]...)
+}
+
 // ExponentialBucketsRange creates 'count' buckets, where the lowest bucket is
 // 'min' and the highest bucket is 'max'. The final +Inf bucket is not counted
 // and not included in the returned slice. The returned slice is meant to be
@@ -352,6 +391,135 @@ func ExponentialBucketsRange(min, max float64, count int) []float64 {
 	return buckets
 }
 
+// SLOBuckets creates buckets suitable for measuring service level objectives.
+// Each of the provided 'thresholds' (e.g. the latency thresholds of the SLOs)
+// is guaranteed to be an exact bucket boundary, so that the fraction of
+// observations below a threshold can be calculated precisely. The range from
+// one decade below the lowest threshold to one decade above the highest
+// threshold is filled with buckets of approximately equal geometric width,
+// with 'resolutionPerDecade' buckets per factor of ten. Between two adjacent
+// thresholds, the width of the buckets is adjusted to meet both thresholds
+// exactly. The final +Inf bucket is not counted and not included in the
+// returned slice. The returned slice is meant to be used for the Buckets field
+// of HistogramOpts.
+//
+// The function panics if 'thresholds' is empty, if any threshold is 0 or
+// negative, if 'thresholds' is not sorted in strictly increasing order, or if
+// 'resolutionPerDecade' is 0 or negative.
+func SLOBuckets(thresholds []float64, resolutionPerDecade int) []float64 {
+	if len(thresholds) == 0 {
+		panic("SLOBuckets needs at least one threshold")
+	}
+	if resolutionPerDecade < 1 {
+		panic("SLOBuckets needs a positive resolutionPerDecade")
+	}
+	for i, t := range thresholds {
+		if t <= 0 || math.IsInf(t, 0) || math.IsNaN(t) {
+			panic("SLOBuckets needs positive finite thresholds")
+		}
+		if i > 0 && t <= thresholds[i-1] {
+			panic("SLOBuckets needs thresholds sorted in strictly increasing order")
+		}
+	}
+
+	// fill appends the buckets geometrically spaced between lower
+	// (exclusive) and upper (inclusive), using the smallest number of
+	// buckets that still results in the requested resolution.
+	var buckets []float64
+	fill := func(lower, upper float64) {
+		n := int(math.Ceil(math.Log10(upper/lower)*float64(resolutionPerDecade) - 1e-9))
+		if n < 1 {
+			n = 1
+		}
+		factor := math.Pow(upper/lower, 1/float64(n))
+		for i := 1; i < n; i++ {
+			buckets = append(buckets, lower*math.Pow(factor, float64(i)))
+		}
+		buckets = append(buckets, upper)
+	}
+
+	lowest, highest := thresholds[0], thresholds[len(thresholds)-1]
+	buckets = append(buckets, lowest/10)
+	fill(lowest/10, lowest)
+	for i := 1; i < len(thresholds); i++ {
+		fill(thresholds[i-1], thresholds[i])
+	}
+	fill(highest, highest*10)
+	return buckets
+}
+
+// Thresholds used by ValidateBuckets.
+const (
+	// validateBucketsMaxCount is the number of buckets above which
+	// ValidateBuckets warns about the cost.
+	validateBucketsMaxCount = 30
+	// validateBucketsMaxFactor is the factor between adjacent positive bucket
+	// boundaries above which ValidateBuckets warns about a resolution gap.
+	validateBucketsMaxFactor = 10
+)
+
+// ValidateBuckets checks the provided buckets, as they would be used for the
+// Buckets field of HistogramOpts, for common pitfalls. It returns a
+// human-readable warning for each problem found, or nil if there is none. In
+// contrast to NewHistogram, which only panics for buckets that cannot work at
+// all, ValidateBuckets also reports questionable but valid choices:
+//   - Buckets not in strictly increasing order (including NaN and duplicate
+//     boundaries), for which NewHistogram panics.
+//   - An explicit +Inf bucket, which is redundant as it is always added
+//     implicitly.
+//   - A -Inf bucket, which only ever counts observations of -Inf.
+//   - More than 30 buckets. Each bucket is a separate series (multiplied by
+//     the number of label combinations in a HistogramVec), so that many
+//     buckets are costly. Consider native histograms in that case.
+//   - A factor of more than 10 between adjacent positive bucket boundaries,
+//     i.e. a gap in the resolution where quantiles can only be estimated
+//     coarsely.
+//
+// ValidateBuckets is meant to be called in tests or upon startup to catch
+// problematic bucket configurations early. It never panics.
+func ValidateBuckets(buckets []float64) []string {
+	if len(buckets) == 0 {
+		return []string{"no buckets provided, DefBuckets will be used"}
+	}
+	var warnings []string
+	for i, b := range buckets {
+		switch {
+		case math.IsNaN(b):
+			warnings = append(warnings, fmt.Sprintf("bucket %d is NaN", i))
+			continue
+		case math.IsInf(b, -1):
+			warnings = append(warnings, fmt.Sprintf("bucket %d is -Inf, it only counts observations of -Inf", i))
+		case math.IsInf(b, +1) && i == len(buckets)-1:
+			warnings = append(warnings, "the +Inf bucket is always added implicitly and doesn't need to be provided")
+		}
+		if i == 0 || math.IsNaN(buckets[i-1]) {
+			continue
+		}
+		prev := buckets[i-1]
+		switch {
+		case b <= prev:
+			warnings = append(warnings, fmt.Sprintf(
+				"buckets are not in strictly increasing order: %g at index %d follows %g", b, i, prev,
+			))
+		case prev > 0 && !math.IsInf(b, +1) && b/prev > validateBucketsMaxFactor:
+			warnings = append(warnings, fmt.Sprintf(
+				"resolution gap between %g and %g (factor %.3g)", prev, b, b/prev,
+			))
+		}
+	}
+	n := len(buckets)
+	if math.IsInf(buckets[n-1], +1) {
+		n--
+	}
+	if n > validateBucketsMaxCount {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d buckets result in %d series per label combination, consider fewer buckets or native histograms",
+			n, n+3, // Plus +Inf bucket, sum, and count.
+		))
+	}
+	return warnings
+}
+
 // HistogramOpts bundles the options for creating a Histogram metric. It is
 // mandatory to set Name to a non-empty string. All other fields are optional
 // and can safely be left at their zero value, although it is strongly
@@ -384,6 +552,16 @@ type HistogramOpts struct {
 	// https://prometheus.io/docs/instrumenting/writing_exporters/#target-labels-not-static-scraped-labels
 	ConstLabels Labels
 
+	// Unit is the unit of this metric, e.g. "seconds" or "bytes". If set,
+	// it is exposed in "# UNIT" lines in the OpenMetrics format. As
+	// required by OpenMetrics, it must be a suffix of the fully-qualified
+	// name (ignoring a "_total" suffix), e.g. the unit of
+	// "http_request_duration_seconds" can only be "seconds". Otherwise,
+	// registration fails.
+	//
+	// Metrics with the same fully-qualified name must have the same Unit.
+	Unit string
+
 	// Buckets defines the buckets into which observations are counted. Each
 	// element in the slice is the upper inclusive bound of a bucket. The
 	// values must be sorted in strictly increasing order. There is no need
@@ -394,6 +572,17 @@ type HistogramOpts struct {
 	// otherwise the default is no buckets. (In other words, if you want to
 	// use both regular buckets and buckets for a native histogram, you have
 	// to define the regular buckets here explicitly.)
+	//
+	// Using both kinds of buckets is useful while migrating to native
+	// histograms: Each observation is counted in both, and a Histogram is
+	// exposed with both the regular and the sparse buckets in the protobuf
+	// exposition format, while the text exposition formats only contain the
+	// regular buckets (as they cannot represent sparse buckets). Thus, a
+	// scraper using the text format sees a classic histogram, and a scraper
+	// negotiating the protobuf format can ingest a native histogram (and,
+	// if configured to do so, the classic histogram, too). Note that the
+	// memory cost and the cost of each observation are the sum of both
+	// kinds of buckets, and the protobuf exposition is larger accordingly.
 	Buckets []float64
 
 	// If NativeHistogramBucketFactor is greater than one, so-called sparse
@@ -428,6 +617,15 @@ type HistogramOpts struct {
 	// change their behavior or name (or might completely disappear) without
 	// a major version bump.
 	NativeHistogramBucketFactor float64
+	// NativeHistogramMaxRelativeError is an alternative to
+	// NativeHistogramBucketFactor to configure the resolution of the sparse
+	// buckets. If it is greater than zero, sparse buckets are used with the
+	// schema returned by NativeHistogramSchemaForError, i.e. the lowest
+	// resolution whose buckets have a relative error of at most the
+	// provided value (e.g. 0.05 for “within 5% accuracy”). It is an error
+	// to set both NativeHistogramBucketFactor and
+	// NativeHistogramMaxRelativeError.
+	NativeHistogramMaxRelativeError float64
 	// All observations with an absolute value of less or equal
 	// NativeHistogramZeroThreshold are accumulated into a “zero” bucket.
 	// For best results, this should be close to a bucket boundary. This is
@@ -437,7 +635,8 @@ type HistogramOpts struct {
 	// configure a zero bucket with an actual threshold of zero (i.e. only
 	// observations of precisely zero will go into the zero bucket), set
 	// NativeHistogramZeroThreshold to the NativeHistogramZeroThresholdZero
-	// constant (or any negative float value).
+	// constant (or any negative float value). NewHistogram panics if
+	// NativeHistogramZeroThreshold is NaN or +Inf.
 	NativeHistogramZeroThreshold float64
 
 	// The remaining fields define a strategy to limit the number of
@@ -473,6 +672,101 @@ type HistogramOpts struct {
 	NativeHistogramMinResetDuration time.Duration
 	NativeHistogramMaxZeroThreshold float64
 
+	// If NativeHistogramBucketCountGauge is true and sparse buckets are
+	// used, a gauge with the name of the Histogram plus the suffix
+	// "_native_histogram_buckets" is collected together with the
+	// Histogram, set to its current number of populated sparse buckets.
+	// For a HistogramVec, there is one such gauge per Histogram, with the
+	// same labels. This helps watching the growth of sparse buckets and
+	// tuning NativeHistogramMaxBucketNumber accordingly.
+	NativeHistogramBucketCountGauge bool
+	// If NativeHistogramOnBucketCreated is not nil, it is called whenever
+	// an observation creates a new sparse bucket (including the
+	// re-creation of a bucket after a reset or a reduction of the
+	// resolution of the Histogram). It is called synchronously from within
+	// the Observe call, so it has to be cheap (e.g. incrementing a
+	// Counter) and safe for concurrent use.
+	NativeHistogramOnBucketCreated func()
+
+	// If AnnotateHelpWithBuckets is true, the bucket configuration is
+	// appended to Help in the exposed HELP string, e.g. "Request
+	// latencies. (buckets: 0.1, 0.5, 1; native histogram schema: 3)". The
+	// classic bucket boundaries are listed without the implicit +Inf
+	// bucket, or with the default buckets if the defaults are used. The
+	// native histogram schema is listed if sparse buckets are used. This
+	// helps debugging bucket choices without reading the instrumented
+	// code. Note that a Histogram with the same name but a different
+	// bucket configuration then has a different HELP string, which a
+	// Registry rejects as inconsistent.
+	AnnotateHelpWithBuckets bool
+
+	// ExemplarOpts defines how invalid exemplars are handled. See
+	// ExemplarOpts for details.
+	ExemplarOpts ExemplarOpts
+
+	// If ClampMin is less than ClampMax, observed values are clamped into
+	// the range from ClampMin to ClampMax before they are recorded, i.e. a
+	// value below ClampMin is recorded as ClampMin (and thus ends up in the
+	// lowest bucket containing ClampMin), and a value above ClampMax is
+	// recorded as ClampMax. This guards the sum of observations against
+	// pathological outliers, e.g. from a stuck timer. The default of both
+	// being zero means no clamping. To only clamp one side of the range,
+	// set the other to -Inf or +Inf, respectively. NaN observations are not
+	// clamped. The clamped observations are counted by a counter with the
+	// name of the Histogram plus the suffix "_clamped_observations_total"
+	// and its const labels, which is collected together with the
+	// Histogram. The counter has no variable labels, i.e. all Histograms
+	// of a HistogramVec share one counter. NewHistogram panics if ClampMin
+	// is greater than ClampMax or if either of them is NaN.
+	ClampMin, ClampMax float64
+
+	// If KahanSum is true, the sum of observations is calculated with
+	// compensated summation (Neumaier's variant of the Kahan summation
+	// algorithm), which keeps track of the low-order bits lost when adding
+	// a small value to a large sum. This vastly improves the accuracy of
+	// the sum of many observations, in particular if the sum is much larger
+	// than the individual observations, at the price of a little CPU time
+	// and a mutex protecting the sum, i.e. concurrent observations contend
+	// on the mutex. The sum is exposed as the compensated sum. The default
+	// is plain floating-point addition.
+	KahanSum bool
+
+	// If TrackBucketActivity is true, a gauge with the name of the
+	// Histogram plus the suffix "_bucket_last_seen_timestamp_seconds" is
+	// collected together with the Histogram, with one series per classic
+	// bucket (including the +Inf bucket), partitioned by "le" like the
+	// "_bucket" series. Its value is the time of the last observation
+	// counted in the respective bucket (but not in the buckets with a
+	// higher upper bound, i.e. the time the cumulative count of the bucket
+	// last increased without the one of the next lower bucket increasing,
+	// too), or 0 if the bucket has not received any observation yet. For
+	// a HistogramVec, there is one such series per bucket and Histogram,
+	// with the same additional labels. This helps identifying buckets
+	// that are never hit and thus only waste series, as a tuning aid.
+	// Since it doubles the number of series of the classic buckets and
+	// every observation has to read the clock, it should only be enabled
+	// temporarily.
+	TrackBucketActivity bool
+
+	// exemplarPolicy is shared between the Histograms of a HistogramVec.
+	// If nil, it is created from ExemplarOpts.
+	exemplarPolicy *exemplarPolicy
+
+	// clampedObservations is shared between the Histograms of a
+	// HistogramVec. If nil, it is created from the other options if
+	// clamping is enabled.
+	clampedObservations Counter
+
+	// bucketCountDesc is the Desc of the gauge for
+	// NativeHistogramBucketCountGauge. It is only set for Histograms
+	// collected by themselves, not for those in a HistogramVec.
+	bucketCountDesc *Desc
+
+	// bucketActivityDesc is the Desc of the gauge for TrackBucketActivity.
+	// It is only set for Histograms collected by themselves, not for those
+	// in a HistogramVec.
+	bucketActivityDesc *Desc
+
 	// now is for testing purposes, by default it's time.Now.
 	now func() time.Time
 
@@ -495,21 +789,102 @@ type HistogramVecOpts struct {
 // NewHistogram creates a new Histogram based on the provided HistogramOpts. It
 // panics if the buckets in HistogramOpts are not in strictly increasing order.
 //
-// The returned implementation also implements ExemplarObserver. It is safe to
-// perform the corresponding type assertion. Exemplars are tracked separately
-// for each bucket.
+// The returned implementation also implements ExemplarObserver,
+// SampledExemplarObserver, DurationObserver, LazyObserver, ExemplarLister, and
+// ExemplarClearer. It is safe to perform the corresponding type assertions.
+// Exemplars are tracked separately for each bucket.
 func NewHistogram(opts HistogramOpts) Histogram {
+	if opts.NativeHistogramBucketCountGauge {
+		opts.bucketCountDesc = newBucketCountDesc(opts, nil)
+	}
+	if opts.TrackBucketActivity {
+		opts.bucketActivityDesc = newBucketActivityDesc(opts, nil)
+	}
 	return newHistogram(
-		NewDesc(
+		newDesc(
 			BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
-			opts.Help,
-			nil,
+			histogramHelp(opts),
+			opts.Unit,
+			UnconstrainedLabels(nil),
 			opts.ConstLabels,
 		),
 		opts,
 	)
 }
 
+// newBucketCountDesc returns the Desc of the gauge for
+// HistogramOpts.NativeHistogramBucketCountGauge.
+func newBucketCountDesc(opts HistogramOpts, variableLabels []string) *Desc {
+	name := BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	return NewDesc(
+		name+"_native_histogram_buckets",
+		fmt.Sprintf("Current number of populated sparse buckets of the native histogram %s.", name),
+		variableLabels, opts.ConstLabels,
+	)
+}
+
+// newBucketActivityDesc returns the Desc of the gauge for
+// HistogramOpts.TrackBucketActivity.
+func newBucketActivityDesc(opts HistogramOpts, variableLabels []string) *Desc {
+	name := BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	return NewDesc(
+		name+"_bucket_last_seen_timestamp_seconds",
+		fmt.Sprintf("Time of the last observation in each bucket of the histogram %s since unix epoch in seconds, or 0 if there was none.", name),
+		append(append([]string{}, variableLabels...), bucketLabel), opts.ConstLabels,
+	)
+}
+
+// histogramHelp returns the help string of the Histogram, annotated with the
+// bucket configuration if HistogramOpts.AnnotateHelpWithBuckets is set.
+func histogramHelp(opts HistogramOpts) string {
+	if !opts.AnnotateHelpWithBuckets {
+		return opts.Help
+	}
+	schema, buckets := histogramSchemaAndBuckets(opts)
+
+	var parts []string
+	if len(buckets) > 0 {
+		bounds := make([]string, 0, len(buckets))
+		for _, b := range buckets {
+			if math.IsInf(b, +1) {
+				continue
+			}
+			bounds = append(bounds, strconv.FormatFloat(b, 'g', -1, 64))
+		}
+		parts = append(parts, "buckets: "+strings.Join(bounds, ", "))
+	}
+	if schema != math.MinInt32 {
+		parts = append(parts, "native histogram schema: "+strconv.Itoa(int(schema)))
+	}
+	annotation := "(" + strings.Join(parts, "; ") + ")"
+	if opts.Help == "" {
+		return annotation
+	}
+	return opts.Help + " " + annotation
+}
+
+// histogramSchemaAndBuckets returns the native histogram schema (math.MinInt32
+// if there are no native buckets) and the upper bounds of the classic buckets
+// configured by opts, defaulting to DefBuckets if neither are configured. It
+// panics if both NativeHistogramBucketFactor and
+// NativeHistogramMaxRelativeError are set.
+func histogramSchemaAndBuckets(opts HistogramOpts) (schema int32, buckets []float64) {
+	schema = math.MinInt32
+	switch {
+	case opts.NativeHistogramBucketFactor > 1 && opts.NativeHistogramMaxRelativeError > 0:
+		panic(errors.New("only one of NativeHistogramBucketFactor and NativeHistogramMaxRelativeError may be set"))
+	case opts.NativeHistogramBucketFactor > 1:
+		schema = pickSchema(opts.NativeHistogramBucketFactor)
+	case opts.NativeHistogramMaxRelativeError > 0:
+		schema = NativeHistogramSchemaForError(opts.NativeHistogramMaxRelativeError)
+	}
+	buckets = opts.Buckets
+	if len(buckets) == 0 && schema == math.MinInt32 {
+		buckets = DefBuckets
+	}
+	return schema, buckets
+}
+
 func newHistogram(desc *Desc, opts HistogramOpts, labelValues ...string) Histogram {
 	if len(desc.variableLabels.names) != len(labelValues) {
 		panic(makeInconsistentCardinalityError(desc.fqName, desc.variableLabels.names, labelValues))
@@ -532,30 +907,43 @@ func newHistogram(desc *Desc, opts HistogramOpts, labelValues ...string) Histogr
 	if opts.afterFunc == nil {
 		opts.afterFunc = time.AfterFunc
 	}
+	if opts.exemplarPolicy == nil {
+		opts.exemplarPolicy = newExemplarPolicy(opts.ExemplarOpts)
+	}
+	if opts.clampedObservations == nil {
+		opts.clampedObservations = newClampedObservationsCounter(opts)
+	}
 	h := &histogram{
 		desc:                            desc,
-		upperBounds:                     opts.Buckets,
 		labelPairs:                      MakeLabelPairs(desc, labelValues),
 		nativeHistogramMaxBuckets:       opts.NativeHistogramMaxBucketNumber,
 		nativeHistogramMaxZeroThreshold: opts.NativeHistogramMaxZeroThreshold,
 		nativeHistogramMinResetDuration: opts.NativeHistogramMinResetDuration,
 		lastResetTime:                   opts.now(),
+		exemplarPolicy:                  opts.exemplarPolicy,
+		clampMin:                        opts.ClampMin,
+		clampMax:                        opts.ClampMax,
+		clampedObservations:             opts.clampedObservations,
+		onBucketCreated:                 opts.NativeHistogramOnBucketCreated,
 		now:                             opts.now,
 		afterFunc:                       opts.afterFunc,
 	}
-	if len(h.upperBounds) == 0 && opts.NativeHistogramBucketFactor <= 1 {
-		h.upperBounds = DefBuckets
-	}
-	if opts.NativeHistogramBucketFactor <= 1 {
-		h.nativeHistogramSchema = math.MinInt32 // To mark that there are no sparse buckets.
-	} else {
+	// A schema of math.MinInt32 marks that there are no sparse buckets.
+	h.nativeHistogramSchema, h.upperBounds = histogramSchemaAndBuckets(opts)
+	if h.nativeHistogramSchema != math.MinInt32 {
+		if math.IsNaN(opts.NativeHistogramZeroThreshold) || math.IsInf(opts.NativeHistogramZeroThreshold, +1) {
+			panic(fmt.Errorf(
+				"native histogram zero threshold must be a finite number, got %f",
+				opts.NativeHistogramZeroThreshold,
+			))
+		}
 		switch {
 		case opts.NativeHistogramZeroThreshold > 0:
 			h.nativeHistogramZeroThreshold = opts.NativeHistogramZeroThreshold
 		case opts.NativeHistogramZeroThreshold == 0:
 			h.nativeHistogramZeroThreshold = DefNativeHistogramZeroThreshold
 		} // Leave h.nativeHistogramZeroThreshold at 0 otherwise.
-		h.nativeHistogramSchema = pickSchema(opts.NativeHistogramBucketFactor)
+		h.bucketCountDesc = opts.bucketCountDesc
 	}
 	for i, upperBound := range h.upperBounds {
 		if i < len(h.upperBounds)-1 {
@@ -574,13 +962,17 @@ func newHistogram(desc *Desc, opts HistogramOpts, labelValues ...string) Histogr
 	}
 	// Finally we know the final length of h.upperBounds and can make buckets
 	// for both counts as well as exemplars:
-	h.counts[0] = &histogramCounts{buckets: make([]uint64, len(h.upperBounds))}
+	h.counts[0] = &histogramCounts{buckets: make([]uint64, len(h.upperBounds)), compensated: opts.KahanSum}
 	atomic.StoreUint64(&h.counts[0].nativeHistogramZeroThresholdBits, math.Float64bits(h.nativeHistogramZeroThreshold))
 	atomic.StoreInt32(&h.counts[0].nativeHistogramSchema, h.nativeHistogramSchema)
-	h.counts[1] = &histogramCounts{buckets: make([]uint64, len(h.upperBounds))}
+	h.counts[1] = &histogramCounts{buckets: make([]uint64, len(h.upperBounds)), compensated: opts.KahanSum}
 	atomic.StoreUint64(&h.counts[1].nativeHistogramZeroThresholdBits, math.Float64bits(h.nativeHistogramZeroThreshold))
 	atomic.StoreInt32(&h.counts[1].nativeHistogramSchema, h.nativeHistogramSchema)
 	h.exemplars = make([]atomic.Value, len(h.upperBounds)+1)
+	if opts.TrackBucketActivity {
+		h.bucketLastSeen = make([]int64, len(h.upperBounds)+1)
+		h.bucketActivityDesc = opts.bucketActivityDesc
+	}
 
 	h.init(h) // Init self-collection.
 	return h
@@ -613,6 +1005,14 @@ type histogramCounts struct {
 	// Regular buckets.
 	buckets []uint64
 
+	// If compensated is true, the sum is calculated with compensated
+	// summation, see HistogramOpts.KahanSum. Updates of sumBits are then
+	// protected by sumMtx, which also protects compensation, the
+	// accumulated low-order bits lost in the additions to sumBits.
+	compensated  bool
+	sumMtx       sync.Mutex
+	compensation float64
+
 	// The sparse buckets for native histograms are implemented with a
 	// sync.Map for now. A dedicated data structure will likely be more
 	// efficient. There are separate maps for negative and positive
@@ -625,20 +1025,66 @@ type histogramCounts struct {
 	nativeHistogramBucketsPositive, nativeHistogramBucketsNegative sync.Map
 }
 
+// addSum adds v to the sum of observations.
+func (hc *histogramCounts) addSum(v float64) {
+	if !hc.compensated {
+		atomicAddFloat(&hc.sumBits, v)
+		return
+	}
+	hc.sumMtx.Lock()
+	defer hc.sumMtx.Unlock()
+
+	sum := math.Float64frombits(atomic.LoadUint64(&hc.sumBits))
+	t := sum + v
+	// Once the sum is not finite anymore, the compensation is meaningless.
+	if !math.IsInf(t, 0) && !math.IsNaN(t) {
+		if math.Abs(sum) >= math.Abs(v) {
+			hc.compensation += (sum - t) + v
+		} else {
+			hc.compensation += (v - t) + sum
+		}
+	}
+	atomic.StoreUint64(&hc.sumBits, math.Float64bits(t))
+}
+
+// sum returns the sum of observations.
+func (hc *histogramCounts) sum() float64 {
+	if !hc.compensated {
+		return math.Float64frombits(atomic.LoadUint64(&hc.sumBits))
+	}
+	hc.sumMtx.Lock()
+	defer hc.sumMtx.Unlock()
+
+	return math.Float64frombits(atomic.LoadUint64(&hc.sumBits)) + hc.compensation
+}
+
+// resetSum sets the sum of observations to 0.
+func (hc *histogramCounts) resetSum() {
+	if !hc.compensated {
+		atomic.StoreUint64(&hc.sumBits, 0)
+		return
+	}
+	hc.sumMtx.Lock()
+	defer hc.sumMtx.Unlock()
+
+	atomic.StoreUint64(&hc.sumBits, 0)
+	hc.compensation = 0
+}
+
 // observe manages the parts of observe that only affects
 // histogramCounts. doSparse is true if sparse buckets should be done,
-// too.
-func (hc *histogramCounts) observe(v float64, bucket int, doSparse bool) {
+// too. It returns true if a new sparse bucket has been created.
+func (hc *histogramCounts) observe(v float64, bucket int, doSparse bool) (bucketCreated bool) {
 	if bucket < len(hc.buckets) {
 		atomic.AddUint64(&hc.buckets[bucket], 1)
 	}
-	atomicAddFloat(&hc.sumBits, v)
+	hc.addSum(v)
 	if doSparse && !math.IsNaN(v) {
 		var (
-			key                  int
-			schema               = atomic.LoadInt32(&hc.nativeHistogramSchema)
-			zeroThreshold        = math.Float64frombits(atomic.LoadUint64(&hc.nativeHistogramZeroThresholdBits))
-			bucketCreated, isInf bool
+			key           int
+			schema        = atomic.LoadInt32(&hc.nativeHistogramSchema)
+			zeroThreshold = math.Float64frombits(atomic.LoadUint64(&hc.nativeHistogramZeroThresholdBits))
+			isInf         bool
 		)
 		if math.IsInf(v, 0) {
 			// Pretend v is MaxFloat64 but later increment key by one.
@@ -679,6 +1125,7 @@ func (hc *histogramCounts) observe(v float64, bucket int, doSparse bool) {
 	// Increment count last as we take it as a signal that the observation
 	// is complete.
 	atomic.AddUint64(&hc.count, 1)
+	return bucketCreated
 }
 
 type histogram struct {
@@ -714,7 +1161,7 @@ type histogram struct {
 
 	upperBounds                     []float64
 	labelPairs                      []*dto.LabelPair
-	exemplars                       []atomic.Value // One more than buckets (to include +Inf), each a *dto.Exemplar.
+	exemplars                       []atomic.Value // One more than buckets (to include +Inf), each a (possibly nil) *dto.Exemplar.
 	nativeHistogramSchema           int32          // The initial schema. Set to math.MinInt32 if no sparse buckets are used.
 	nativeHistogramZeroThreshold    float64        // The initial zero threshold.
 	nativeHistogramMaxZeroThreshold float64
@@ -727,6 +1174,20 @@ type histogram struct {
 	// passed).
 	resetScheduled bool
 
+	exemplarPolicy *exemplarPolicy
+
+	clampMin, clampMax  float64
+	clampedObservations Counter // nil if observations are not clamped.
+
+	bucketCountDesc *Desc  // nil if the bucket count is not collected.
+	onBucketCreated func() // nil if there is no hook.
+
+	// bucketLastSeen contains the time of the last observation of each
+	// bucket (including +Inf) in nanoseconds since the Unix epoch, accessed
+	// atomically. It is nil if bucket activity is not tracked.
+	bucketLastSeen     []int64
+	bucketActivityDesc *Desc // nil if bucket activity is not collected by the histogram itself.
+
 	// now is for testing purposes, by default it's time.Now.
 	now func() time.Time
 
@@ -739,15 +1200,151 @@ func (h *histogram) Desc() *Desc {
 }
 
 func (h *histogram) Observe(v float64) {
+	v = h.clamp(v)
 	h.observe(v, h.findBucket(v))
 }
 
+// ObserveDuration implements DurationObserver.
+func (h *histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+// MaybeObserve implements LazyObserver.
+func (h *histogram) MaybeObserve(f func() float64) {
+	h.Observe(f())
+}
+
 func (h *histogram) ObserveWithExemplar(v float64, e Labels) {
+	v = h.clamp(v)
 	i := h.findBucket(v)
 	h.observe(v, i)
 	h.updateExemplar(v, i, e)
 }
 
+// ObserveWithExemplarLabels implements ExemplarLabelsObserver.
+func (h *histogram) ObserveWithExemplarLabels(v float64, l *ExemplarLabels) {
+	v = h.clamp(v)
+	i := h.findBucket(v)
+	h.observe(v, i)
+	if l == nil {
+		return
+	}
+	e, err := h.exemplarPolicy.newExemplarFromLabels(v, h.now(), l)
+	h.storeExemplar(i, e, err)
+}
+
+func (h *histogram) ObserveWithExemplarSampled(v float64, e func() Labels, probability float64) {
+	if !h.exemplarPolicy.sampleExemplar(probability) {
+		h.Observe(v)
+		return
+	}
+	h.ObserveWithExemplar(v, e())
+}
+
+// Describe implements Collector. In addition to the Desc of the Histogram, it
+// sends the Descs of the counter of clamped observations, of the gauge of
+// sparse buckets, and of the gauge of bucket activity, if any.
+func (h *histogram) Describe(ch chan<- *Desc) {
+	h.selfCollector.Describe(ch)
+	if h.clampedObservations != nil {
+		h.clampedObservations.Describe(ch)
+	}
+	if h.bucketCountDesc != nil {
+		ch <- h.bucketCountDesc
+	}
+	if h.bucketActivityDesc != nil {
+		ch <- h.bucketActivityDesc
+	}
+}
+
+// Collect implements Collector. In addition to the Histogram, it sends the
+// counter of clamped observations, the gauge of sparse buckets, and the gauge
+// of bucket activity, if any.
+func (h *histogram) Collect(ch chan<- Metric) {
+	h.selfCollector.Collect(ch)
+	if h.clampedObservations != nil {
+		h.clampedObservations.Collect(ch)
+	}
+	if h.bucketCountDesc != nil {
+		ch <- MustNewConstMetric(h.bucketCountDesc, GaugeValue, float64(h.nativeHistogramBuckets()))
+	}
+	if h.bucketActivityDesc != nil {
+		h.collectBucketActivity(ch, h.bucketActivityDesc)
+	}
+}
+
+// collectBucketActivity sends the gauge of bucket activity for each bucket,
+// with the provided label values followed by the upper bound of the bucket.
+func (h *histogram) collectBucketActivity(ch chan<- Metric, desc *Desc, labelValues ...string) {
+	lvs := append(append(make([]string, 0, len(labelValues)+1), labelValues...), "")
+	for i := range h.bucketLastSeen {
+		upperBound := math.Inf(+1)
+		if i < len(h.upperBounds) {
+			upperBound = h.upperBounds[i]
+		}
+		lvs[len(lvs)-1] = formatUpperBound(upperBound)
+		var lastSeen float64
+		if ns := atomic.LoadInt64(&h.bucketLastSeen[i]); ns != 0 {
+			lastSeen = float64(ns) / 1e9
+		}
+		ch <- MustNewConstMetric(desc, GaugeValue, lastSeen, lvs...)
+	}
+}
+
+// formatUpperBound formats the upper bound of a bucket like the "le" label of
+// the text format.
+func formatUpperBound(upperBound float64) string {
+	if math.IsInf(upperBound, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(upperBound, 'g', -1, 64)
+}
+
+// nativeHistogramBuckets returns the current number of sparse buckets, as
+// counted in the hot counts.
+func (h *histogram) nativeHistogramBuckets() uint32 {
+	n := atomic.LoadUint64(&h.countAndHotIdx)
+	return atomic.LoadUint32(&h.counts[n>>63].nativeHistogramBucketsNumber)
+}
+
+// clamp returns v clamped into the configured range and counts the observation
+// if it had to be clamped.
+func (h *histogram) clamp(v float64) float64 {
+	if h.clampedObservations == nil {
+		return v
+	}
+	switch {
+	case v < h.clampMin:
+		h.clampedObservations.Inc()
+		return h.clampMin
+	case v > h.clampMax:
+		h.clampedObservations.Inc()
+		return h.clampMax
+	}
+	return v
+}
+
+// newClampedObservationsCounter returns the counter of clamped observations
+// for the provided HistogramOpts or nil if clamping is disabled. It panics if
+// the clamping range is invalid.
+func newClampedObservationsCounter(opts HistogramOpts) Counter {
+	if math.IsNaN(opts.ClampMin) || math.IsNaN(opts.ClampMax) || opts.ClampMin > opts.ClampMax {
+		panic(fmt.Errorf(
+			"invalid clamping range for histogram observations: %f to %f",
+			opts.ClampMin, opts.ClampMax,
+		))
+	}
+	if opts.ClampMin == opts.ClampMax {
+		return nil
+	}
+	name := BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	return NewCounter(CounterOpts{
+		Name:        name + "_clamped_observations_total",
+		Help:        fmt.Sprintf("Number of observations of %s clamped into the range from %g to %g.", name, opts.ClampMin, opts.ClampMax),
+		ConstLabels: opts.ConstLabels,
+	})
+}
+
 func (h *histogram) Write(out *dto.Metric) error {
 	// For simplicity, we protect this whole method by a mutex. It is not in
 	// the hot path, i.e. Observe is called much more often than Write. The
@@ -772,7 +1369,7 @@ func (h *histogram) Write(out *dto.Metric) error {
 	his := &dto.Histogram{
 		Bucket:           make([]*dto.Bucket, len(h.upperBounds)),
 		SampleCount:      proto.Uint64(count),
-		SampleSum:        proto.Float64(math.Float64frombits(atomic.LoadUint64(&coldCounts.sumBits))),
+		SampleSum:        proto.Float64(coldCounts.sum()),
 		CreatedTimestamp: timestamppb.New(h.lastResetTime),
 	}
 	out.Histogram = his
@@ -785,16 +1382,16 @@ func (h *histogram) Write(out *dto.Metric) error {
 			CumulativeCount: proto.Uint64(cumCount),
 			UpperBound:      proto.Float64(upperBound),
 		}
-		if e := h.exemplars[i].Load(); e != nil {
-			his.Bucket[i].Exemplar = e.(*dto.Exemplar)
+		if e, _ := h.exemplars[i].Load().(*dto.Exemplar); e != nil {
+			his.Bucket[i].Exemplar = e
 		}
 	}
 	// If there is an exemplar for the +Inf bucket, we have to add that bucket explicitly.
-	if e := h.exemplars[len(h.upperBounds)].Load(); e != nil {
+	if e, _ := h.exemplars[len(h.upperBounds)].Load().(*dto.Exemplar); e != nil {
 		b := &dto.Bucket{
 			CumulativeCount: proto.Uint64(count),
 			UpperBound:      proto.Float64(math.Inf(1)),
-			Exemplar:        e.(*dto.Exemplar),
+			Exemplar:        e,
 		}
 		his.Bucket = append(his.Bucket, b)
 	}
@@ -850,7 +1447,12 @@ func (h *histogram) observe(v float64, bucket int) {
 	// back, which we can use to find the currently-hot counts.
 	n := atomic.AddUint64(&h.countAndHotIdx, 1)
 	hotCounts := h.counts[n>>63]
-	hotCounts.observe(v, bucket, doSparse)
+	if hotCounts.observe(v, bucket, doSparse) && h.onBucketCreated != nil {
+		h.onBucketCreated()
+	}
+	if h.bucketLastSeen != nil {
+		atomic.StoreInt64(&h.bucketLastSeen[bucket], h.now().UnixNano())
+	}
 	if doSparse {
 		h.limitBuckets(hotCounts, v, bucket)
 	}
@@ -1078,7 +1680,7 @@ func (h *histogram) doubleBucketWidth(hot, cold *histogramCounts) {
 }
 
 func (h *histogram) resetCounts(counts *histogramCounts) {
-	atomic.StoreUint64(&counts.sumBits, 0)
+	counts.resetSum()
 	atomic.StoreUint64(&counts.count, 0)
 	atomic.StoreUint64(&counts.nativeHistogramZeroBucket, 0)
 	atomic.StoreUint64(&counts.nativeHistogramZeroThresholdBits, math.Float64bits(h.nativeHistogramZeroThreshold))
@@ -1097,13 +1699,42 @@ func (h *histogram) updateExemplar(v float64, bucket int, l Labels) {
 	if l == nil {
 		return
 	}
-	e, err := newExemplar(v, h.now(), l)
+	e, err := h.exemplarPolicy.newExemplar(v, h.now(), l)
+	h.storeExemplar(bucket, e, err)
+}
+
+// storeExemplar stores the provided exemplar for the provided bucket, unless
+// it has been dropped according to the exemplar policy. It panics upon any
+// other error.
+func (h *histogram) storeExemplar(bucket int, e *dto.Exemplar, err error) {
+	if err == errExemplarDropped {
+		return
+	}
 	if err != nil {
 		panic(err)
 	}
 	h.exemplars[bucket].Store(e)
 }
 
+// Exemplars implements ExemplarLister. The exemplars are returned in the order
+// of the buckets they belong to.
+func (h *histogram) Exemplars() []*dto.Exemplar {
+	var exemplars []*dto.Exemplar
+	for i := range h.exemplars {
+		if e, _ := h.exemplars[i].Load().(*dto.Exemplar); e != nil {
+			exemplars = append(exemplars, e)
+		}
+	}
+	return exemplars
+}
+
+// ClearExemplars implements ExemplarClearer.
+func (h *histogram) ClearExemplars() {
+	for i := range h.exemplars {
+		h.exemplars[i].Store((*dto.Exemplar)(nil))
+	}
+}
+
 // HistogramVec is a Collector that bundles a set of Histograms that all share the
 // same Desc, but have different values for their variable labels. This is used
 // if you want to count the same thing partitioned by various dimensions
@@ -1111,6 +1742,9 @@ func (h *histogram) updateExemplar(v float64, bucket int, l Labels) {
 // instances with NewHistogramVec.
 type HistogramVec struct {
 	*MetricVec
+	clampedObservations Counter // nil if observations are not clamped.
+	bucketCountDesc     *Desc   // nil if the bucket counts are not collected.
+	bucketActivityDesc  *Desc   // nil if bucket activity is not tracked.
 }
 
 // NewHistogramVec creates a new HistogramVec based on the provided HistogramOpts and
@@ -1124,16 +1758,96 @@ func NewHistogramVec(opts HistogramOpts, labelNames []string) *HistogramVec {
 
 // NewHistogramVec creates a new HistogramVec based on the provided HistogramVecOpts.
 func (v2) NewHistogramVec(opts HistogramVecOpts) *HistogramVec {
-	desc := V2.NewDesc(
+	desc := newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
-		opts.Help,
+		histogramHelp(opts.HistogramOpts),
+		opts.Unit,
 		opts.VariableLabels,
 		opts.ConstLabels,
 	)
+	if opts.exemplarPolicy == nil {
+		opts.exemplarPolicy = newExemplarPolicy(opts.ExemplarOpts)
+	}
+	if opts.clampedObservations == nil {
+		opts.clampedObservations = newClampedObservationsCounter(opts.HistogramOpts)
+	}
+	var bucketCountDesc *Desc
+	if opts.NativeHistogramBucketCountGauge {
+		bucketCountDesc = newBucketCountDesc(opts.HistogramOpts, desc.variableLabels.names)
+	}
+	var bucketActivityDesc *Desc
+	if opts.TrackBucketActivity {
+		bucketActivityDesc = newBucketActivityDesc(opts.HistogramOpts, desc.variableLabels.names)
+	}
 	return &HistogramVec{
 		MetricVec: NewMetricVec(desc, func(lvs ...string) Metric {
 			return newHistogram(desc, opts.HistogramOpts, lvs...)
 		}),
+		clampedObservations: opts.clampedObservations,
+		bucketCountDesc:     bucketCountDesc,
+		bucketActivityDesc:  bucketActivityDesc,
+	}
+}
+
+// Describe implements Collector. In addition to the Desc of the Histograms, it
+// sends the Descs of the counter of clamped observations, of the gauges of
+// sparse buckets, and of the gauges of bucket activity, if any.
+func (v *HistogramVec) Describe(ch chan<- *Desc) {
+	v.MetricVec.Describe(ch)
+	if v.clampedObservations != nil {
+		v.clampedObservations.Describe(ch)
+	}
+	if v.bucketCountDesc != nil {
+		ch <- v.bucketCountDesc
+	}
+	if v.bucketActivityDesc != nil {
+		ch <- v.bucketActivityDesc
+	}
+}
+
+// Collect implements Collector. In addition to the Histograms, it sends the
+// counter of clamped observations, the gauges of sparse buckets, and the
+// gauges of bucket activity, if any.
+func (v *HistogramVec) Collect(ch chan<- Metric) {
+	v.MetricVec.Collect(ch)
+	if v.clampedObservations != nil {
+		v.clampedObservations.Collect(ch)
+	}
+	if v.bucketCountDesc != nil {
+		v.collectBucketCounts(ch)
+	}
+	if v.bucketActivityDesc != nil {
+		v.collectBucketActivity(ch)
+	}
+}
+
+// collectBucketActivity sends the gauges of bucket activity for all
+// Histograms in the HistogramVec.
+func (v *HistogramVec) collectBucketActivity(ch chan<- Metric) {
+	v.metricMap.mtx.RLock()
+	defer v.metricMap.mtx.RUnlock()
+	for _, metrics := range v.metricMap.metrics {
+		for _, m := range metrics {
+			if h, ok := m.metric.(*histogram); ok {
+				h.collectBucketActivity(ch, v.bucketActivityDesc, m.values...)
+			}
+		}
+	}
+}
+
+// collectBucketCounts sends the gauges of sparse buckets for all Histograms in
+// the HistogramVec.
+func (v *HistogramVec) collectBucketCounts(ch chan<- Metric) {
+	v.metricMap.mtx.RLock()
+	defer v.metricMap.mtx.RUnlock()
+	for _, metrics := range v.metricMap.metrics {
+		for _, m := range metrics {
+			h, ok := m.metric.(*histogram)
+			if !ok || h.nativeHistogramSchema == math.MinInt32 {
+				continue
+			}
+			ch <- MustNewConstMetric(v.bucketCountDesc, GaugeValue, float64(h.nativeHistogramBuckets()), m.values...)
+		}
 	}
 }
 
@@ -1153,7 +1867,8 @@ func (v2) NewHistogramVec(opts HistogramVecOpts) *HistogramVec {
 // example.
 //
 // An error is returned if the number of label values is not the same as the
-// number of variable labels in Desc (minus any curried labels).
+// number of variable labels in Desc (minus any curried labels) or if any of the
+// label values is not valid UTF-8.
 //
 // Note that for more than one label value, this method is prone to mistakes
 // caused by an incorrect order of arguments. Consider GetMetricWith(Labels) as
@@ -1176,7 +1891,8 @@ func (v *HistogramVec) GetMetricWithLabelValues(lvs ...string) (Observer, error)
 // are the same as for GetMetricWithLabelValues.
 //
 // An error is returned if the number and names of the Labels are inconsistent
-// with those of the variable labels in Desc (minus any curried labels).
+// with those of the variable labels in Desc (minus any curried labels) or if
+// any of the label values is not valid UTF-8.
 //
 // This method is used for the same purpose as
 // GetMetricWithLabelValues(...string). See there for pros and cons of the two
@@ -1230,7 +1946,12 @@ func (v *HistogramVec) With(labels Labels) Observer {
 func (v *HistogramVec) CurryWith(labels Labels) (ObserverVec, error) {
 	vec, err := v.MetricVec.CurryWith(labels)
 	if vec != nil {
-		return &HistogramVec{vec}, err
+		return &HistogramVec{
+			MetricVec:           vec,
+			clampedObservations: v.clampedObservations,
+			bucketCountDesc:     v.bucketCountDesc,
+			bucketActivityDesc:  v.bucketActivityDesc,
+		}, err
 	}
 	return nil, err
 }
@@ -1336,6 +2057,69 @@ func MustNewConstHistogram(
 	return m
 }
 
+// NewConstHistogramFromSamples returns a const histogram like
+// NewConstHistogram, but calculates the count, sum, and bucket counts from the
+// provided raw sample values. The buckets are the upper bounds of the buckets
+// as in HistogramOpts. A trailing +Inf bucket is allowed but not required.
+// This is useful to construct expected histograms in tests or to expose the
+// observations of batch jobs that are only available as a list of values.
+//
+// NewConstHistogramFromSamples returns an error if the buckets are not in
+// strictly increasing order, if the length of labelValues is not consistent
+// with the variable labels in Desc, or if Desc is invalid.
+func NewConstHistogramFromSamples(
+	desc *Desc,
+	buckets []float64,
+	samples []float64,
+	labelValues ...string,
+) (Metric, error) {
+	if len(buckets) > 0 && math.IsInf(buckets[len(buckets)-1], +1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return nil, fmt.Errorf(
+				"histogram buckets must be in increasing order: %f >= %f",
+				buckets[i-1], buckets[i],
+			)
+		}
+	}
+	var (
+		counts = make([]uint64, len(buckets))
+		sum    float64
+	)
+	for _, v := range samples {
+		// Same bucket selection as in histogram.findBucket.
+		if i := sort.SearchFloat64s(buckets, v); i < len(buckets) {
+			counts[i]++
+		}
+		sum += v
+	}
+	cumCounts := make(map[float64]uint64, len(buckets))
+	var cumCount uint64
+	for i, upperBound := range buckets {
+		cumCount += counts[i]
+		cumCounts[upperBound] = cumCount
+	}
+	return NewConstHistogram(desc, uint64(len(samples)), sum, cumCounts, labelValues...)
+}
+
+// MustNewConstHistogramFromSamples is a version of
+// NewConstHistogramFromSamples that panics where NewConstHistogramFromSamples
+// would have returned an error.
+func MustNewConstHistogramFromSamples(
+	desc *Desc,
+	buckets []float64,
+	samples []float64,
+	labelValues ...string,
+) Metric {
+	m, err := NewConstHistogramFromSamples(desc, buckets, samples, labelValues...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
 type buckSort []*dto.Bucket
 
 func (s buckSort) Len() int {
@@ -1350,6 +2134,33 @@ func (s buckSort) Less(i, j int) bool {
 	return s[i].GetUpperBound() < s[j].GetUpperBound()
 }
 
+// NativeHistogramSchemaForError returns the smallest schema (i.e. the lowest
+// resolution) of native histograms whose buckets have a relative error of at
+// most relErr. With a schema s, the width of the buckets grows by a factor of
+// f = 2^(2^-s) from bucket to bucket, and the relative error of a bucket is
+// (f-1)/(f+1), i.e. the maximum relative deviation of any value in the bucket
+// from the value in the middle of the bucket. For example, a relErr of 0.05
+// results in schema 3 with a relative error of approx. 4.3%.
+//
+// The returned schema is between (and including) -4 and 8. If no schema in
+// that range is fine-grained enough, 8 is returned. The function panics if
+// relErr is not positive.
+//
+// The result can be used via NativeHistogramMaxRelativeError in the
+// HistogramOpts, which calls this function.
+func NativeHistogramSchemaForError(relErr float64) int32 {
+	if !(relErr > 0) {
+		panic(fmt.Errorf("relative error %f is not positive", relErr))
+	}
+	for schema := int32(-4); schema < 8; schema++ {
+		f := math.Exp2(math.Exp2(-float64(schema)))
+		if (f-1)/(f+1) <= relErr {
+			return schema
+		}
+	}
+	return 8
+}
+
 // pickSchema returns the largest number n between -4 and 8 such that
 // 2^(2^-n) is less or equal the provided bucketFactor.
 //
@@ -1565,9 +2376,8 @@ func atomicDecUint32(p *uint32) {
 func addAndResetCounts(hot, cold *histogramCounts) {
 	atomic.AddUint64(&hot.count, atomic.LoadUint64(&cold.count))
 	atomic.StoreUint64(&cold.count, 0)
-	coldSum := math.Float64frombits(atomic.LoadUint64(&cold.sumBits))
-	atomicAddFloat(&hot.sumBits, coldSum)
-	atomic.StoreUint64(&cold.sumBits, 0)
+	hot.addSum(cold.sum())
+	cold.resetSum()
 	for i := range hot.buckets {
 		atomic.AddUint64(&hot.buckets[i], atomic.LoadUint64(&cold.buckets[i]))
 		atomic.StoreUint64(&cold.buckets[i], 0)
@@ -0,0 +1,122 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// HistogramsCompatible returns an error describing the first incompatibility
+// found between the histograms in the provided metric families (including
+// those within the same family), or nil if all of them can be aggregated, e.g.
+// added up by a recording rule. This is meant for tests (e.g. in CI) asserting
+// that histograms of different services (or different versions of the same
+// service) intended for aggregation share the same bucket layout.
+//
+// Histograms with regular buckets are compatible if they have the same bucket
+// boundaries (ignoring the implicit +Inf bucket), as the sum of classic
+// histograms with different boundaries is meaningless. Native histograms are
+// always compatible with each other, as a Prometheus server reduces native
+// histograms of different resolutions to the lowest one when adding them up.
+// The regular buckets of histograms that have both kinds of buckets are
+// compared nevertheless. Finally, histograms with only sparse buckets are
+// incompatible with those with only regular buckets.
+//
+// An error is also returned if a metric family is not a histogram family or
+// contains no histograms.
+func HistogramsCompatible(a, b *dto.MetricFamily) error {
+	var (
+		ref                         []float64 // Boundaries of refName.
+		refName                     string
+		nativeOnlyName, classicName string
+	)
+	for _, mf := range []*dto.MetricFamily{a, b} {
+		if t := mf.GetType(); t != dto.MetricType_HISTOGRAM && t != dto.MetricType_GAUGE_HISTOGRAM {
+			return fmt.Errorf("metric family %q is of type %s, not a histogram", mf.GetName(), t)
+		}
+		if len(mf.GetMetric()) == 0 {
+			return fmt.Errorf("metric family %q contains no histograms", mf.GetName())
+		}
+		for _, m := range mf.GetMetric() {
+			h := m.GetHistogram()
+			if h == nil {
+				return fmt.Errorf("metric family %q contains a metric without a histogram", mf.GetName())
+			}
+			name := histogramName(mf.GetName(), m)
+			native := isNativeHistogram(h)
+			switch {
+			case native && len(h.GetBucket()) == 0:
+				if nativeOnlyName == "" {
+					nativeOnlyName = name
+				}
+				continue
+			case !native && classicName == "":
+				classicName = name
+			}
+
+			bounds := make([]float64, 0, len(h.GetBucket()))
+			for _, b := range h.GetBucket() {
+				if !math.IsInf(b.GetUpperBound(), +1) {
+					bounds = append(bounds, b.GetUpperBound())
+				}
+			}
+			if ref == nil {
+				ref, refName = bounds, name
+				continue
+			}
+			if !equalBounds(bounds, ref) {
+				return fmt.Errorf(
+					"bucket boundaries %v of %s differ from bucket boundaries %v of %s",
+					bounds, name, ref, refName,
+				)
+			}
+		}
+	}
+	if nativeOnlyName != "" && classicName != "" {
+		return fmt.Errorf(
+			"native histogram %s without regular buckets is incompatible with classic histogram %s",
+			nativeOnlyName, classicName,
+		)
+	}
+	return nil
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// histogramName returns the name of the metric family with the labels of m in
+// the usual text representation, e.g. `name{label="value"}`.
+func histogramName(name string, m *dto.Metric) string {
+	if len(m.GetLabel()) == 0 {
+		return name
+	}
+	pairs := make([]string, 0, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", lp.GetName(), lp.GetValue()))
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
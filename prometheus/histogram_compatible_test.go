@@ -0,0 +1,82 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistogramsCompatible(t *testing.T) {
+	classic := func(name string, buckets ...float64) *HistogramVec {
+		vec := NewHistogramVec(HistogramOpts{Name: name, Help: "helpless", Buckets: buckets}, []string{"service"})
+		vec.WithLabelValues("a").Observe(1)
+		vec.WithLabelValues("b").Observe(2)
+		return vec
+	}
+	native := func(name string, factor float64, buckets ...float64) Histogram {
+		h := NewHistogram(HistogramOpts{
+			Name: name, Help: "helpless", Buckets: buckets,
+			NativeHistogramBucketFactor: factor,
+		})
+		h.Observe(1)
+		return h
+	}
+	gauge := NewGauge(GaugeOpts{Name: "gauge", Help: "helpless"})
+
+	scenarios := map[string]struct {
+		a, b    Collector
+		wantErr string
+	}{
+		"same buckets": {
+			a: classic("a", 1, 2, 5),
+			b: classic("b", 1, 2, 5),
+		},
+		"different buckets": {
+			a:       classic("a", 1, 2, 5),
+			b:       classic("b", 1, 2, 10),
+			wantErr: `bucket boundaries [1 2 10] of b{service="a"} differ from bucket boundaries [1 2 5] of a{service="a"}`,
+		},
+		"native with different schemas": {
+			a: native("a", 1.1),
+			b: native("b", 2),
+		},
+		"native with classic buckets": {
+			a:       native("a", 1.1, 1, 2),
+			b:       native("b", 1.1, 1, 3),
+			wantErr: "bucket boundaries [1 3] of b differ",
+		},
+		"native and classic": {
+			a:       native("a", 1.1),
+			b:       classic("b", 1),
+			wantErr: `native histogram a without regular buckets is incompatible with classic histogram b{service="a"}`,
+		},
+		"not a histogram": {
+			a:       classic("a", 1),
+			b:       gauge,
+			wantErr: `metric family "gauge" is of type GAUGE, not a histogram`,
+		},
+	}
+	for name, s := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			err := HistogramsCompatible(gatherFamily(t, s.a), gatherFamily(t, s.b))
+			switch {
+			case s.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case s.wantErr != "" && (err == nil || !strings.Contains(err.Error(), s.wantErr)):
+				t.Errorf("got error %v, want error containing %q", err, s.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// HistogramQuantile calculates the φ-quantile (0 ≤ φ ≤ 1) of the histograms in
+// the provided metric family in the same way as the PromQL function
+// histogram_quantile does. If the family contains more than one histogram
+// (i.e. more than one combination of label values), the histograms are added
+// up first, like "sum by (le)" would do for classic histograms or "sum" for
+// native histograms. Note that the counts of a gathered histogram accumulate
+// over the lifetime of the process, i.e. the result is the quantile of all
+// observations so far, not of a recent time window (as it would be the case in
+// PromQL with a rate applied).
+//
+// If all histograms in the family have sparse buckets (as created with
+// HistogramOpts.NativeHistogramBucketFactor), they are treated as native
+// histograms, i.e. the sparse buckets are used (after reducing all histograms
+// to the lowest resolution and the widest zero bucket among them), and the
+// value is interpolated exponentially within the bucket the quantile falls into
+// (or linearly within the zero bucket). Otherwise, the regular buckets are used
+// with linear interpolation, including the same treatment of the +Inf bucket
+// (the upper bound of the second highest bucket is returned if the quantile
+// falls into the +Inf bucket) and of the lowest bucket (with an assumed lower
+// bound of 0, unless its upper bound is not positive).
+//
+// As in PromQL, the result is NaN if φ is NaN or there are no observations,
+// -Inf for φ < 0, and +Inf for φ > 1. An error is returned if the metric family
+// is not a histogram family, contains no histograms, or mixes histograms with
+// only sparse buckets and histograms with only regular buckets.
+func HistogramQuantile(q float64, mf *dto.MetricFamily) (float64, error) {
+	if t := mf.GetType(); t != dto.MetricType_HISTOGRAM && t != dto.MetricType_GAUGE_HISTOGRAM {
+		return 0, fmt.Errorf("metric family %q is of type %s, not a histogram", mf.GetName(), t)
+	}
+	if len(mf.GetMetric()) == 0 {
+		return 0, fmt.Errorf("metric family %q contains no histograms", mf.GetName())
+	}
+	allNative, anyNativeOnly := true, false
+	for _, m := range mf.GetMetric() {
+		h := m.GetHistogram()
+		if h == nil {
+			return 0, fmt.Errorf("metric family %q contains a metric without a histogram", mf.GetName())
+		}
+		native := isNativeHistogram(h)
+		allNative = allNative && native
+		anyNativeOnly = anyNativeOnly || (native && len(h.GetBucket()) == 0)
+	}
+
+	switch {
+	case math.IsNaN(q):
+		return math.NaN(), nil
+	case q < 0:
+		return math.Inf(-1), nil
+	case q > 1:
+		return math.Inf(+1), nil
+	}
+	if allNative {
+		nh, err := mergeNativeHistograms(mf.GetMetric())
+		if err != nil {
+			return 0, fmt.Errorf("metric family %q: %w", mf.GetName(), err)
+		}
+		return nh.quantile(q), nil
+	}
+	if anyNativeOnly {
+		return 0, fmt.Errorf("metric family %q mixes native histograms without regular buckets and classic histograms", mf.GetName())
+	}
+	return classicQuantile(q, mergeClassicHistograms(mf.GetMetric())), nil
+}
+
+// isNativeHistogram returns true if h has sparse buckets, following the same
+// logic a Prometheus server uses to detect a native histogram.
+func isNativeHistogram(h *dto.Histogram) bool {
+	return h.GetSampleCountFloat() > 0 ||
+		len(h.GetPositiveSpan()) > 0 || len(h.GetNegativeSpan()) > 0 ||
+		h.GetZeroThreshold() > 0 || h.GetZeroCount() > 0 || h.GetZeroCountFloat() > 0
+}
+
+type classicBucket struct {
+	upperBound, count float64
+}
+
+// mergeClassicHistograms adds up the cumulative counts of the regular buckets
+// of the provided histograms by upper bound, including the implicit +Inf
+// bucket, and returns the buckets sorted by upper bound.
+func mergeClassicHistograms(metrics []*dto.Metric) []classicBucket {
+	counts := map[float64]float64{}
+	for _, m := range metrics {
+		h := m.GetHistogram()
+		hasInf := false
+		for _, b := range h.GetBucket() {
+			count := float64(b.GetCumulativeCount())
+			if b.CumulativeCountFloat != nil {
+				count = b.GetCumulativeCountFloat()
+			}
+			counts[b.GetUpperBound()] += count
+			hasInf = hasInf || math.IsInf(b.GetUpperBound(), +1)
+		}
+		if !hasInf {
+			count := float64(h.GetSampleCount())
+			if h.SampleCountFloat != nil {
+				count = h.GetSampleCountFloat()
+			}
+			counts[math.Inf(+1)] += count
+		}
+	}
+	buckets := make([]classicBucket, 0, len(counts))
+	for ub, count := range counts {
+		buckets = append(buckets, classicBucket{upperBound: ub, count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].upperBound < buckets[j].upperBound })
+	return buckets
+}
+
+// classicQuantile implements the quantile calculation of histogram_quantile
+// for classic histograms. The buckets must be sorted and the last bucket must
+// be the +Inf bucket.
+func classicQuantile(q float64, buckets []classicBucket) float64 {
+	if len(buckets) < 2 {
+		return math.NaN()
+	}
+	// Like PromQL, fix non-monotonic counts (e.g. caused by a race
+	// between the count of the higher and the lower bucket).
+	maxCount := math.Inf(-1)
+	for i := range buckets {
+		if buckets[i].count > maxCount {
+			maxCount = buckets[i].count
+		} else {
+			buckets[i].count = maxCount
+		}
+	}
+	observations := buckets[len(buckets)-1].count
+	if observations == 0 {
+		return math.NaN()
+	}
+	rank := q * observations
+	b := sort.Search(len(buckets)-1, func(i int) bool { return buckets[i].count >= rank })
+
+	if b == len(buckets)-1 {
+		return buckets[len(buckets)-2].upperBound
+	}
+	if b == 0 && buckets[0].upperBound <= 0 {
+		return buckets[0].upperBound
+	}
+	var (
+		bucketStart float64
+		bucketEnd   = buckets[b].upperBound
+		count       = buckets[b].count
+	)
+	if b > 0 {
+		bucketStart = buckets[b-1].upperBound
+		count -= buckets[b-1].count
+		rank -= buckets[b-1].count
+	}
+	return bucketStart + (bucketEnd-bucketStart)*(rank/count)
+}
+
+// nativeHistogram is a native histogram with sparse buckets as a map from
+// bucket index to (absolute, non-cumulative) count.
+type nativeHistogram struct {
+	schema             int32
+	zeroThreshold      float64
+	zeroCount, count   float64
+	positive, negative map[int]float64
+}
+
+// mergeNativeHistograms adds up the provided native histograms.
+func mergeNativeHistograms(metrics []*dto.Metric) (*nativeHistogram, error) {
+	nh := &nativeHistogram{
+		schema:   math.MaxInt32,
+		positive: map[int]float64{},
+		negative: map[int]float64{},
+	}
+	for _, m := range metrics {
+		h := m.GetHistogram()
+		s := h.GetSchema()
+		if s < -4 || s > 8 {
+			return nil, fmt.Errorf("invalid native histogram schema %d", s)
+		}
+		if s < nh.schema {
+			nh.schema = s
+		}
+		if zt := h.GetZeroThreshold(); zt > nh.zeroThreshold {
+			nh.zeroThreshold = zt
+		}
+	}
+	for _, m := range metrics {
+		h := m.GetHistogram()
+		if h.SampleCountFloat != nil {
+			nh.count += h.GetSampleCountFloat()
+		} else {
+			nh.count += float64(h.GetSampleCount())
+		}
+		if h.ZeroCountFloat != nil {
+			nh.zeroCount += h.GetZeroCountFloat()
+		} else {
+			nh.zeroCount += float64(h.GetZeroCount())
+		}
+		if err := nh.addBuckets(nh.positive, h.GetSchema(), h.GetPositiveSpan(), h.GetPositiveDelta(), h.GetPositiveCount()); err != nil {
+			return nil, err
+		}
+		if err := nh.addBuckets(nh.negative, h.GetSchema(), h.GetNegativeSpan(), h.GetNegativeDelta(), h.GetNegativeCount()); err != nil {
+			return nil, err
+		}
+	}
+	return nh, nil
+}
+
+// addBuckets adds the buckets described by the provided spans and deltas (for
+// integer counts) or counts (for float counts) to the provided buckets map,
+// reducing them from the provided schema to the schema of nh. Buckets within
+// the zero bucket of nh are added to the zero bucket instead.
+func (nh *nativeHistogram) addBuckets(buckets map[int]float64, schema int32, spans []*dto.BucketSpan, deltas []int64, counts []float64) error {
+	var (
+		idx      int
+		i        int
+		absCount int64
+	)
+	for _, span := range spans {
+		// The offset of the first span is relative to index 0, the
+		// offset of any following span is relative to the end of the
+		// previous one.
+		idx += int(span.GetOffset())
+		for j := uint32(0); j < span.GetLength(); j++ {
+			var count float64
+			switch {
+			case len(counts) > 0:
+				if i >= len(counts) {
+					return errors.New("native histogram has fewer bucket counts than spans require")
+				}
+				count = counts[i]
+			default:
+				if i >= len(deltas) {
+					return errors.New("native histogram has fewer bucket deltas than spans require")
+				}
+				absCount += deltas[i]
+				count = float64(absCount)
+			}
+			i++
+			// Each bucket at the target schema contains
+			// 2^(schema-nh.schema) buckets at the original schema.
+			target := ((idx - 1) >> (schema - nh.schema)) + 1
+			if getLe(target, nh.schema) <= nh.zeroThreshold {
+				nh.zeroCount += count
+			} else if count != 0 {
+				buckets[target] += count
+			}
+			idx++
+		}
+	}
+	return nil
+}
+
+type nativeBucket struct {
+	lower, upper, count float64
+}
+
+// buckets returns all non-empty buckets of nh in ascending order.
+func (nh *nativeHistogram) buckets() []nativeBucket {
+	var result []nativeBucket
+	neg := make([]int, 0, len(nh.negative))
+	for idx := range nh.negative {
+		neg = append(neg, idx)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(neg)))
+	for _, idx := range neg {
+		result = append(result, nativeBucket{
+			lower: -getLe(idx, nh.schema),
+			upper: -getLe(idx-1, nh.schema),
+			count: nh.negative[idx],
+		})
+	}
+	if nh.zeroCount > 0 {
+		result = append(result, nativeBucket{
+			lower: -nh.zeroThreshold,
+			upper: nh.zeroThreshold,
+			count: nh.zeroCount,
+		})
+	}
+	pos := make([]int, 0, len(nh.positive))
+	for idx := range nh.positive {
+		pos = append(pos, idx)
+	}
+	sort.Ints(pos)
+	for _, idx := range pos {
+		result = append(result, nativeBucket{
+			lower: getLe(idx-1, nh.schema),
+			upper: getLe(idx, nh.schema),
+			count: nh.positive[idx],
+		})
+	}
+	return result
+}
+
+// quantile implements the quantile calculation of histogram_quantile for
+// native histograms.
+func (nh *nativeHistogram) quantile(q float64) float64 {
+	if nh.count == 0 {
+		return math.NaN()
+	}
+	buckets := nh.buckets()
+	if len(buckets) == 0 {
+		return math.NaN()
+	}
+	var (
+		bucket nativeBucket
+		count  float64
+		rank   = q * nh.count
+	)
+	for _, bucket = range buckets {
+		count += bucket.count
+		if count >= rank {
+			break
+		}
+	}
+	if bucket.lower < 0 && bucket.upper > 0 {
+		switch {
+		case len(nh.negative) == 0 && len(nh.positive) > 0:
+			// The result is in the zero bucket and the histogram has
+			// only positive buckets. So we consider 0 to be the lower
+			// bound.
+			bucket.lower = 0
+		case len(nh.positive) == 0 && len(nh.negative) > 0:
+			// As above, but for negative buckets only.
+			bucket.upper = 0
+		}
+	}
+	// Due to numerical inaccuracies, the bucket counts might not add up to
+	// rank. In that case, return the upper bound of the last bucket.
+	if count < rank {
+		return bucket.upper
+	}
+	rank -= count - bucket.count
+	fraction := rank / bucket.count
+
+	// Interpolate linearly within the zero bucket.
+	if bucket.lower <= 0 && bucket.upper >= 0 {
+		return bucket.lower + (bucket.upper-bucket.lower)*fraction
+	}
+	// Otherwise, interpolate on a logarithmic scale, on which the
+	// exponential bucket boundaries are linear.
+	logLower := math.Log2(math.Abs(bucket.lower))
+	logUpper := math.Log2(math.Abs(bucket.upper))
+	if bucket.lower > 0 {
+		return math.Exp2(logLower + (logUpper-logLower)*fraction)
+	}
+	// A negative bucket, so things have to be mirrored.
+	return -math.Exp2(logUpper + (logLower-logUpper)*(1-fraction))
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"math"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gatherFamily(t *testing.T, cs ...Collector) *dto.MetricFamily {
+	t.Helper()
+	reg := NewRegistry()
+	reg.MustRegister(cs...)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 {
+		t.Fatalf("got %d metric families, want 1", len(mfs))
+	}
+	return mfs[0]
+}
+
+func checkQuantiles(t *testing.T, mf *dto.MetricFamily, want map[float64]float64) {
+	t.Helper()
+	for q, w := range want {
+		got, err := HistogramQuantile(q, mf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w && !(math.IsNaN(got) && math.IsNaN(w)) && !(math.Abs(got-w) < 1e-9) {
+			t.Errorf("quantile %v: got %v, want %v", q, got, w)
+		}
+	}
+}
+
+func TestHistogramQuantileClassic(t *testing.T) {
+	his := NewHistogramVec(HistogramOpts{
+		Name:    "test_histogram",
+		Help:    "help",
+		Buckets: []float64{1, 2},
+	}, []string{"l"})
+	empty := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help"})
+	checkQuantiles(t, gatherFamily(t, empty), map[float64]float64{0.5: math.NaN()})
+
+	// Two histograms are added up to 10 observations in each bucket.
+	for i := 0; i < 5; i++ {
+		his.WithLabelValues("a").Observe(0.5)
+		his.WithLabelValues("b").Observe(0.5)
+		his.WithLabelValues("a").Observe(1.5)
+		his.WithLabelValues("b").Observe(1.5)
+		his.WithLabelValues("a").Observe(5)
+		his.WithLabelValues("b").Observe(5)
+	}
+	checkQuantiles(t, gatherFamily(t, his), map[float64]float64{
+		0:           0,
+		0.1:         0.3,
+		0.5:         1.5,
+		0.9:         2, // In the +Inf bucket.
+		-1:          math.Inf(-1),
+		2:           math.Inf(+1),
+		math.NaN():  math.NaN(),
+		2.0 / 3.0:   2,
+		1.0 / 3.0:   1,
+		1.0 / 30.0:  0.1,
+		29.0 / 30.0: 2,
+	})
+}
+
+func TestHistogramQuantileNative(t *testing.T) {
+	his := NewHistogram(HistogramOpts{
+		Name:                        "test_histogram",
+		Help:                        "help",
+		NativeHistogramBucketFactor: 2,
+	})
+	for _, v := range []float64{1.5, 1.5, 3, 3} {
+		his.Observe(v)
+	}
+	checkQuantiles(t, gatherFamily(t, his), map[float64]float64{
+		0.25: math.Sqrt2,
+		0.5:  2,
+		0.75: 2 * math.Sqrt2,
+	})
+
+	// With only positive buckets, the zero bucket starts at 0.
+	zero := NewHistogram(HistogramOpts{
+		Name:                         "test_histogram",
+		Help:                         "help",
+		NativeHistogramBucketFactor:  2,
+		NativeHistogramZeroThreshold: 0.5,
+	})
+	zero.Observe(0)
+	zero.Observe(3)
+	checkQuantiles(t, gatherFamily(t, zero), map[float64]float64{0.25: 0.25})
+
+	neg := NewHistogram(HistogramOpts{
+		Name:                        "test_histogram",
+		Help:                        "help",
+		NativeHistogramBucketFactor: 2,
+	})
+	neg.Observe(-3)
+	checkQuantiles(t, gatherFamily(t, neg), map[float64]float64{0.5: -2 * math.Sqrt2})
+
+	// Histograms with different schemas are reduced to the lower one.
+	vec := NewHistogramVec(HistogramOpts{
+		Name:                        "test_histogram",
+		Help:                        "help",
+		NativeHistogramBucketFactor: 1.5, // Schema 1.
+	}, []string{"l"})
+	vec.WithLabelValues("a").Observe(1.5)
+	mf := gatherFamily(t, vec)
+	mf.Metric = append(mf.Metric, gatherFamily(t, his).Metric...)
+	checkQuantiles(t, mf, map[float64]float64{
+		0.3: math.Sqrt2,
+		0.6: 2,
+	})
+}
+
+func TestHistogramQuantileErrors(t *testing.T) {
+	cnt := NewCounter(CounterOpts{Name: "test_counter", Help: "help"})
+	if _, err := HistogramQuantile(0.5, gatherFamily(t, cnt)); err == nil {
+		t.Error("expected error for counter")
+	}
+
+	classic := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help"})
+	native := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help", NativeHistogramBucketFactor: 2})
+	mf := gatherFamily(t, classic)
+	mf.Metric = append(mf.Metric, gatherFamily(t, native).Metric...)
+	if _, err := HistogramQuantile(0.5, mf); err == nil {
+		t.Error("expected error for mixed histograms")
+	}
+}
@@ -14,6 +14,7 @@
 package prometheus
 
 import (
+	"bytes"
 	"math"
 	"math/rand"
 	"reflect"
@@ -28,6 +29,7 @@ import (
 	"github.com/adhimaswaskita/client_golang/prometheus/internal"
 
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -354,6 +356,12 @@ func TestBuckets(t *testing.T) {
 		t.Errorf("exponential buckets: got %v, want %v", got, want)
 	}
 
+	got = HybridBuckets(0.01, 0.01, 5, 2, 3)
+	want = []float64{0.01, 0.02, 0.03, 0.04, 0.05, 0.1, 0.2, 0.4}
+	if !internal.AlmostEqualFloat64s(got, want, 1e-9) {
+		t.Errorf("hybrid buckets: got %v, want %v", got, want)
+	}
+
 	got = ExponentialBucketsRange(1, 100, 10)
 	want = []float64{
 		1.0, 1.6681, 2.7825, 4.6415, 7.7426, 12.9154, 21.5443,
@@ -363,6 +371,542 @@ func TestBuckets(t *testing.T) {
 	if !internal.AlmostEqualFloat64s(got, want, epsilon) {
 		t.Errorf("exponential buckets range: got %v, want %v (epsilon %f)", got, want, epsilon)
 	}
+
+	got = SLOBuckets([]float64{0.3, 1}, 2)
+	want = []float64{
+		0.03, 0.094868, 0.3, 0.547723, 1, 3.162278, 10,
+	}
+	if !internal.AlmostEqualFloat64s(got, want, epsilon) {
+		t.Errorf("SLO buckets: got %v, want %v (epsilon %f)", got, want, epsilon)
+	}
+}
+
+func TestHybridBucketsPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"linearCount":   func() { HybridBuckets(1, 1, 0, 2, 1) },
+		"expCount":      func() { HybridBuckets(1, 1, 1, 2, 0) },
+		"linearWidth":   func() { HybridBuckets(1, 0, 2, 2, 1) },
+		"expFactor":     func() { HybridBuckets(1, 1, 2, 1, 1) },
+		"junction zero": func() { HybridBuckets(-1, 1, 2, 2, 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestValidateBuckets(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		buckets  []float64
+		warnings []string
+	}{
+		{
+			name:    "default buckets",
+			buckets: DefBuckets,
+		},
+		{
+			name:     "no buckets",
+			warnings: []string{"no buckets provided, DefBuckets will be used"},
+		},
+		{
+			name:    "not increasing",
+			buckets: []float64{1, 2, 2, 1.5, 3},
+			warnings: []string{
+				"buckets are not in strictly increasing order: 2 at index 2 follows 2",
+				"buckets are not in strictly increasing order: 1.5 at index 3 follows 2",
+			},
+		},
+		{
+			name:     "NaN",
+			buckets:  []float64{1, math.NaN(), 2},
+			warnings: []string{"bucket 1 is NaN"},
+		},
+		{
+			name:    "infinities",
+			buckets: []float64{math.Inf(-1), 0, 1, math.Inf(+1)},
+			warnings: []string{
+				"bucket 0 is -Inf, it only counts observations of -Inf",
+				"the +Inf bucket is always added implicitly and doesn't need to be provided",
+			},
+		},
+		{
+			name:     "resolution gap",
+			buckets:  []float64{0, 0.1, 0.2, 5, 10},
+			warnings: []string{"resolution gap between 0.2 and 5 (factor 25)"},
+		},
+		{
+			name:     "too many buckets",
+			buckets:  LinearBuckets(1, 1, 40),
+			warnings: []string{"40 buckets result in 43 series per label combination, consider fewer buckets or native histograms"},
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			if got := ValidateBuckets(s.buckets); !reflect.DeepEqual(got, s.warnings) {
+				t.Errorf("got warnings %q, want %q", got, s.warnings)
+			}
+		})
+	}
+}
+
+func TestNativeHistogramSchemaForError(t *testing.T) {
+	relErr := func(schema int32) float64 {
+		f := math.Exp2(math.Exp2(-float64(schema)))
+		return (f - 1) / (f + 1)
+	}
+	scenarios := []struct {
+		relErr float64
+		schema int32
+	}{
+		{1, -4},
+		{0.5, 0},
+		{1.0 / 3, 0},
+		{0.3, 1},
+		{0.05, 3},
+		{relErr(3), 3},
+		{0.043, 4},
+		{0.01, 6},
+		{relErr(8), 8},
+		{1e-9, 8},
+	}
+	for _, s := range scenarios {
+		if got := NativeHistogramSchemaForError(s.relErr); got != s.schema {
+			t.Errorf("relative error %v: got schema %d, want %d", s.relErr, got, s.schema)
+		}
+	}
+
+	for _, relErr := range []float64{0, -0.1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for relative error %v", relErr)
+				}
+			}()
+			NativeHistogramSchemaForError(relErr)
+		}()
+	}
+
+	h := NewHistogram(HistogramOpts{
+		Name:                            "test",
+		Help:                            "help",
+		NativeHistogramMaxRelativeError: 0.05,
+	}).(*histogram)
+	if h.nativeHistogramSchema != 3 {
+		t.Errorf("got schema %d, want 3", h.nativeHistogramSchema)
+	}
+	if len(h.upperBounds) != 0 {
+		t.Errorf("expected no regular buckets, got %v", h.upperBounds)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic if bucket factor and relative error are both set")
+		}
+	}()
+	NewHistogram(HistogramOpts{
+		Name:                            "test",
+		Help:                            "help",
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxRelativeError: 0.05,
+	})
+}
+
+func TestHistogramClamping(t *testing.T) {
+	reg := NewPedanticRegistry()
+	his := NewHistogramVec(HistogramOpts{
+		Name:     "test_histogram",
+		Help:     "help",
+		Buckets:  []float64{1, 10},
+		ClampMin: 0,
+		ClampMax: 100,
+	}, []string{"l"})
+	reg.MustRegister(his)
+
+	his.WithLabelValues("a").Observe(5)
+	his.WithLabelValues("a").Observe(-3)
+	his.WithLabelValues("b").Observe(1e9)
+	his.WithLabelValues("b").(ExemplarObserver).ObserveWithExemplar(200, Labels{"id": "1"})
+	his.WithLabelValues("b").Observe(math.NaN())
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 2 || mfs[0].GetName() != "test_histogram" || mfs[1].GetName() != "test_histogram_clamped_observations_total" {
+		t.Fatalf("unexpected metric families %v", mfs)
+	}
+	a, b := mfs[0].Metric[0].Histogram, mfs[0].Metric[1].Histogram
+	if got, want := a.GetSampleSum(), 5.0; got != want {
+		t.Errorf("got sum %f for a, want %f", got, want)
+	}
+	if got := a.Bucket[0].GetCumulativeCount(); got != 1 {
+		t.Errorf("got %d observations <= 1 for a, want 1", got)
+	}
+	if got := b.GetSampleCount(); got != 3 {
+		t.Errorf("got count %d for b, want 3", got)
+	}
+	if got := b.GetSampleSum(); !math.IsNaN(got) {
+		t.Errorf("got sum %f for b, want NaN", got)
+	}
+	if got := b.Bucket[1].GetCumulativeCount(); got != 0 {
+		t.Errorf("got %d observations <= 10 for b, want 0", got)
+	}
+	if got := mfs[1].Metric[0].Counter.GetValue(); got != 3 {
+		t.Errorf("got %f clamped observations, want 3", got)
+	}
+
+	// Without clamping, no counter is collected.
+	plain := NewHistogram(HistogramOpts{Name: "test_plain", Help: "help"})
+	plain.Observe(-1e9)
+	if got := plain.(*histogram).clampedObservations; got != nil {
+		t.Error("unexpected counter of clamped observations without clamping")
+	}
+
+	for _, opts := range []HistogramOpts{
+		{Name: "test", Help: "help", ClampMin: 1, ClampMax: 0},
+		{Name: "test", Help: "help", ClampMin: math.NaN(), ClampMax: 1},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for clamping range %f to %f", opts.ClampMin, opts.ClampMax)
+				}
+			}()
+			NewHistogram(opts)
+		}()
+	}
+}
+
+func TestHistogramAnnotateHelpWithBuckets(t *testing.T) {
+	scenarios := map[string]struct {
+		opts HistogramOpts
+		want string
+	}{
+		"disabled": {
+			opts: HistogramOpts{Help: "helpless", Buckets: []float64{1, 2}},
+			want: "helpless",
+		},
+		"classic": {
+			opts: HistogramOpts{Help: "helpless", Buckets: []float64{0.5, 1, math.Inf(+1)}, AnnotateHelpWithBuckets: true},
+			want: "helpless (buckets: 0.5, 1)",
+		},
+		"default buckets": {
+			opts: HistogramOpts{AnnotateHelpWithBuckets: true},
+			want: "(buckets: 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10)",
+		},
+		"native": {
+			opts: HistogramOpts{Help: "helpless", NativeHistogramBucketFactor: 1.1, AnnotateHelpWithBuckets: true},
+			want: "helpless (native histogram schema: 3)",
+		},
+		"classic and native": {
+			opts: HistogramOpts{Help: "helpless", Buckets: []float64{1}, NativeHistogramMaxRelativeError: 0.05, AnnotateHelpWithBuckets: true},
+			want: "helpless (buckets: 1; native histogram schema: 3)",
+		},
+	}
+	for name, s := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			s.opts.Name = "test_histogram"
+			if got := NewHistogram(s.opts).Desc().help; got != s.want {
+				t.Errorf("got help %q, want %q", got, s.want)
+			}
+			vec := NewHistogramVec(s.opts, []string{"l"})
+			if got := vec.WithLabelValues("v").(Histogram).Desc().help; got != s.want {
+				t.Errorf("got vector help %q, want %q", got, s.want)
+			}
+		})
+	}
+
+	// Conflicting native histogram options are rejected whether the help is
+	// annotated or not.
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for both NativeHistogramBucketFactor and NativeHistogramMaxRelativeError")
+		}
+	}()
+	NewHistogramVec(HistogramOpts{
+		Name:                            "test_histogram",
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxRelativeError: 0.05,
+		AnnotateHelpWithBuckets:         true,
+	}, []string{"l"})
+}
+
+func TestHistogramKahanSum(t *testing.T) {
+	for _, kahan := range []bool{false, true} {
+		his := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "helpless", KahanSum: kahan})
+		his.Observe(1)
+		for i := 0; i < 10000; i++ {
+			his.Observe(1e-16)
+		}
+		m := &dto.Metric{}
+		if err := his.Write(m); err != nil {
+			t.Fatal(err)
+		}
+		got := m.GetHistogram().GetSampleSum()
+		want := 1.0 // The small observations get lost without compensation.
+		if kahan {
+			want = 1 + 1e-12
+		}
+		if math.Abs(got-want) > 1e-15 {
+			t.Errorf("KahanSum %t: got sum %.17g, want %.17g", kahan, got, want)
+		}
+	}
+
+	// Infinite observations result in an infinite sum, not in NaN.
+	his := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "helpless", KahanSum: true, NativeHistogramBucketFactor: 1.1})
+	his.Observe(1)
+	his.Observe(math.Inf(+1))
+	his.Observe(1)
+	m := &dto.Metric{}
+	if err := his.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHistogram().GetSampleSum(); !math.IsInf(got, +1) {
+		t.Errorf("got sum %v, want +Inf", got)
+	}
+}
+
+func TestNativeHistogramBucketCountGauge(t *testing.T) {
+	var created uint32
+	reg := NewPedanticRegistry()
+	his := NewHistogramVec(HistogramOpts{
+		Name:                            "test_histogram",
+		Help:                            "help",
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramBucketCountGauge: true,
+		NativeHistogramOnBucketCreated:  func() { atomic.AddUint32(&created, 1) },
+	}, []string{"l"})
+	reg.MustRegister(his)
+
+	for _, v := range []float64{1, 1, 2, 3, 0} {
+		his.WithLabelValues("a").Observe(v)
+	}
+	his.WithLabelValues("b").Observe(-5)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 2 || mfs[1].GetName() != "test_histogram_native_histogram_buckets" {
+		t.Fatalf("unexpected metric families %v", mfs)
+	}
+	for i, want := range []float64{3, 1} {
+		if got := mfs[1].Metric[i].Gauge.GetValue(); got != want {
+			t.Errorf("got %f buckets for %s, want %f", got, mfs[1].Metric[i].Label[0].GetValue(), want)
+		}
+	}
+	if got := atomic.LoadUint32(&created); got != 4 {
+		t.Errorf("got %d created buckets, want 4", got)
+	}
+
+	// Curried vectors share the gauge.
+	curried := his.MustCurryWith(Labels{"l": "c"})
+	curried.WithLabelValues().Observe(7)
+	mfs, err = reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(mfs[1].Metric); got != 3 {
+		t.Errorf("got %d bucket count gauges, want 3", got)
+	}
+
+	// Without native histograms, no gauge is collected.
+	classic := NewHistogram(HistogramOpts{
+		Name:                            "test_classic",
+		Help:                            "help",
+		NativeHistogramBucketCountGauge: true,
+	})
+	if got := classic.(*histogram).bucketCountDesc; got != nil {
+		t.Error("unexpected bucket count gauge without native histograms")
+	}
+}
+
+func TestHistogramTrackBucketActivity(t *testing.T) {
+	now := time.Unix(1700000000, 500000000)
+	reg := NewPedanticRegistry()
+	his := NewHistogram(HistogramOpts{
+		Name:                "test_histogram",
+		Help:                "help",
+		Buckets:             []float64{0.5, 1},
+		TrackBucketActivity: true,
+		now:                 func() time.Time { return now },
+	})
+	vec := NewHistogramVec(HistogramOpts{
+		Name:                "test_histogram_vec",
+		Help:                "help",
+		Buckets:             []float64{1},
+		TrackBucketActivity: true,
+		now:                 func() time.Time { return now },
+	}, []string{"l"})
+	reg.MustRegister(his, vec)
+
+	his.Observe(0.7)
+	now = now.Add(time.Second)
+	his.Observe(5)
+	vec.WithLabelValues("a").Observe(0.1)
+	vec.MustCurryWith(Labels{"l": "b"}).WithLabelValues().Observe(2)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, mf := range mfs {
+		if mf.GetType() != dto.MetricType_GAUGE {
+			continue
+		}
+		for _, m := range mf.Metric {
+			key := mf.GetName()
+			for _, lp := range m.Label {
+				key += "," + lp.GetName() + "=" + lp.GetValue()
+			}
+			got[key] = m.Gauge.GetValue()
+		}
+	}
+	want := map[string]float64{
+		"test_histogram_bucket_last_seen_timestamp_seconds,le=0.5":          0,
+		"test_histogram_bucket_last_seen_timestamp_seconds,le=1":            1700000000.5,
+		"test_histogram_bucket_last_seen_timestamp_seconds,le=+Inf":         1700000001.5,
+		"test_histogram_vec_bucket_last_seen_timestamp_seconds,l=a,le=1":    1700000001.5,
+		"test_histogram_vec_bucket_last_seen_timestamp_seconds,l=a,le=+Inf": 0,
+		"test_histogram_vec_bucket_last_seen_timestamp_seconds,l=b,le=1":    0,
+		"test_histogram_vec_bucket_last_seen_timestamp_seconds,l=b,le=+Inf": 1700000001.5,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if untracked := NewHistogram(HistogramOpts{Name: "test", Help: "help"}).(*histogram); untracked.bucketLastSeen != nil || untracked.bucketActivityDesc != nil {
+		t.Error("unexpected bucket activity tracking by default")
+	}
+}
+
+func TestObserveDuration(t *testing.T) {
+	observers := map[string]Observer{
+		"histogram":              NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help", Buckets: []float64{0.1, 1}}),
+		"histogram vec":          NewHistogramVec(HistogramOpts{Name: "test_histogram", Help: "help"}, []string{"l"}).WithLabelValues("v"),
+		"summary":                NewSummary(SummaryOpts{Name: "test_summary", Help: "help", Objectives: map[float64]float64{0.5: 0.05}}),
+		"summary w/o objectives": NewSummary(SummaryOpts{Name: "test_summary", Help: "help"}),
+	}
+	for name, o := range observers {
+		do, ok := o.(DurationObserver)
+		if !ok {
+			t.Errorf("%s doesn't implement DurationObserver", name)
+			continue
+		}
+		do.ObserveDuration(250 * time.Millisecond)
+		do.ObserveDuration(2 * time.Second)
+
+		m := &dto.Metric{}
+		if err := o.(Metric).Write(m); err != nil {
+			t.Fatal(err)
+		}
+		var count uint64
+		var sum float64
+		if m.Histogram != nil {
+			count, sum = m.Histogram.GetSampleCount(), m.Histogram.GetSampleSum()
+			if got := m.Histogram.Bucket[0].GetCumulativeCount(); name == "histogram" && got != 0 {
+				t.Errorf("%s: got %d observations <= 0.1s, want 0", name, got)
+			}
+		} else {
+			count, sum = m.Summary.GetSampleCount(), m.Summary.GetSampleSum()
+		}
+		if count != 2 || sum != 2.25 {
+			t.Errorf("%s: got count %d and sum %v, want 2 and 2.25", name, count, sum)
+		}
+	}
+}
+
+func TestMaybeObserve(t *testing.T) {
+	var calls int
+	value := func() float64 {
+		calls++
+		return 42
+	}
+
+	h := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help"})
+	s := NewSummary(SummaryOpts{Name: "test_summary", Help: "help"})
+	var observed float64
+	f := ObserverFunc(func(v float64) { observed = v })
+	for _, o := range []Observer{h, s, f} {
+		MaybeObserve(o, value)
+	}
+	if calls != 3 || observed != 42 {
+		t.Errorf("got %d calls and observed %v, want 3 calls and 42", calls, observed)
+	}
+
+	m := &dto.Metric{}
+	if err := h.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Histogram.GetSampleSum(); got != 42 {
+		t.Errorf("got histogram sum %v, want 42", got)
+	}
+
+	calls = 0
+	MaybeObserve(DiscardObserver, value)
+	DiscardObserver.(DurationObserver).ObserveDuration(time.Second)
+	if calls != 0 {
+		t.Errorf("DiscardObserver called the function %d times", calls)
+	}
+}
+
+func TestSLOBuckets(t *testing.T) {
+	thresholds := []float64{0.1, 0.25, 0.3, 1, 5}
+	buckets := SLOBuckets(thresholds, 5)
+
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			t.Fatalf("buckets not strictly increasing: %v", buckets)
+		}
+		// The geometric width of each bucket must not exceed the
+		// requested resolution.
+		if got, max := buckets[i]/buckets[i-1], math.Pow(10, 1.0/5)*(1+1e-9); got > max {
+			t.Errorf("bucket %v is %v times wider than its predecessor, want at most %v", buckets[i], got, max)
+		}
+	}
+	for _, th := range thresholds {
+		found := false
+		for _, b := range buckets {
+			if b == th {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("threshold %v is not a bucket boundary in %v", th, buckets)
+		}
+	}
+	if got, want := buckets[0], 0.01; math.Abs(got-want) > 1e-12 {
+		t.Errorf("got lowest bucket %v, want %v", got, want)
+	}
+	if got, want := buckets[len(buckets)-1], 50.0; got != want {
+		t.Errorf("got highest bucket %v, want %v", got, want)
+	}
+
+	for name, f := range map[string]func(){
+		"empty":          func() { SLOBuckets(nil, 5) },
+		"zero threshold": func() { SLOBuckets([]float64{0, 1}, 5) },
+		"unsorted":       func() { SLOBuckets([]float64{1, 0.5}, 5) },
+		"duplicate":      func() { SLOBuckets([]float64{1, 1}, 5) },
+		"zero resolution": func() {
+			SLOBuckets([]float64{1}, 0)
+		},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			f()
+		}()
+	}
 }
 
 func TestHistogramAtomicObserve(t *testing.T) {
@@ -468,6 +1012,62 @@ func TestHistogramExemplar(t *testing.T) {
 	}
 }
 
+func TestHistogramClassicAndNativeExposition(t *testing.T) {
+	reg := NewPedanticRegistry()
+	his := NewHistogram(HistogramOpts{
+		Name:                        "test_histogram",
+		Help:                        "help",
+		Buckets:                     []float64{1, 10},
+		NativeHistogramBucketFactor: 1.1,
+	})
+	reg.MustRegister(his)
+	for _, v := range []float64{0.5, 2, 20} {
+		his.Observe(v)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The text format only contains the classic buckets.
+	var text bytes.Buffer
+	if err := expfmt.NewEncoder(&text, expfmt.FmtText).Encode(mfs[0]); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_histogram help
+# TYPE test_histogram histogram
+test_histogram_bucket{le="1"} 1
+test_histogram_bucket{le="10"} 2
+test_histogram_bucket{le="+Inf"} 3
+test_histogram_sum 22.5
+test_histogram_count 3
+`
+	if got := text.String(); got != want {
+		t.Errorf("got text exposition\n%s\nwant\n%s", got, want)
+	}
+
+	// The protobuf format contains both kinds of buckets from the same
+	// observations.
+	var buf bytes.Buffer
+	if err := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim).Encode(mfs[0]); err != nil {
+		t.Fatal(err)
+	}
+	var mf dto.MetricFamily
+	if err := expfmt.NewDecoder(&buf, expfmt.FmtProtoDelim).Decode(&mf); err != nil {
+		t.Fatal(err)
+	}
+	h := mf.Metric[0].Histogram
+	if got := len(h.Bucket); got != 2 {
+		t.Errorf("got %d classic buckets, want 2", got)
+	}
+	if h.GetSchema() != 3 || len(h.PositiveSpan) != 3 || len(h.PositiveDelta) != 3 {
+		t.Errorf("unexpected sparse buckets: schema %d, spans %v, deltas %v", h.GetSchema(), h.PositiveSpan, h.PositiveDelta)
+	}
+	if got := h.GetSampleCount(); got != 3 {
+		t.Errorf("got count %d, want 3", got)
+	}
+}
+
 func TestNativeHistogram(t *testing.T) {
 	now := time.Now()
 
@@ -532,6 +1132,50 @@ func TestNativeHistogram(t *testing.T) {
 				CreatedTimestamp: timestamppb.New(now),
 			},
 		},
+		{
+			name:          "observations straddling the zero threshold",
+			observations:  []float64{-1, -0.5, -0.25, 0, 0.25, 0.5, 1},
+			factor:        1.1,
+			zeroThreshold: 0.5,
+			want: &dto.Histogram{
+				SampleCount:   proto.Uint64(7),
+				SampleSum:     proto.Float64(0),
+				Schema:        proto.Int32(3),
+				ZeroThreshold: proto.Float64(0.5),
+				ZeroCount:     proto.Uint64(5),
+				NegativeSpan: []*dto.BucketSpan{
+					{Offset: proto.Int32(0), Length: proto.Uint32(1)},
+				},
+				NegativeDelta: []int64{1},
+				PositiveSpan: []*dto.BucketSpan{
+					{Offset: proto.Int32(0), Length: proto.Uint32(1)},
+				},
+				PositiveDelta:    []int64{1},
+				CreatedTimestamp: timestamppb.New(now),
+			},
+		},
+		{
+			name:          "observations just outside of the zero threshold",
+			observations:  []float64{-0.5000001, 0.5000001, -0.4999999, 0.4999999},
+			factor:        1.1,
+			zeroThreshold: 0.5,
+			want: &dto.Histogram{
+				SampleCount:   proto.Uint64(4),
+				SampleSum:     proto.Float64(0),
+				Schema:        proto.Int32(3),
+				ZeroThreshold: proto.Float64(0.5),
+				ZeroCount:     proto.Uint64(2),
+				NegativeSpan: []*dto.BucketSpan{
+					{Offset: proto.Int32(-7), Length: proto.Uint32(1)},
+				},
+				NegativeDelta: []int64{1},
+				PositiveSpan: []*dto.BucketSpan{
+					{Offset: proto.Int32(-7), Length: proto.Uint32(1)},
+				},
+				PositiveDelta:    []int64{1},
+				CreatedTimestamp: timestamppb.New(now),
+			},
+		},
 		{
 			name:         "factor 1.1 results in schema 3",
 			observations: []float64{0, 1, 2, 3},
@@ -804,6 +1448,27 @@ func TestNativeHistogram(t *testing.T) {
 				CreatedTimestamp: timestamppb.New(now),
 			},
 		},
+		{
+			name:             "widened zero bucket restored to configured threshold after reset",
+			observations:     []float64{1, 1.1, 1.2, 1.8, 3, 0.8, 0.9, 2, 4, 0.25, -0.75},
+			factor:           1.2,
+			zeroThreshold:    0.5,
+			maxBuckets:       4,
+			minResetDuration: 10 * time.Minute,
+			maxZeroThreshold: 1.2,
+			want: &dto.Histogram{
+				SampleCount:   proto.Uint64(2),
+				SampleSum:     proto.Float64(-0.5),
+				Schema:        proto.Int32(2),
+				ZeroThreshold: proto.Float64(0.5),
+				ZeroCount:     proto.Uint64(1),
+				NegativeSpan: []*dto.BucketSpan{
+					{Offset: proto.Int32(-1), Length: proto.Uint32(1)},
+				},
+				NegativeDelta:    []int64{1},
+				CreatedTimestamp: timestamppb.New(now.Add(10 * time.Minute)), // The zero bucket gets widened after 5 observations, and the scheduled reset happens after 9.
+			},
+		},
 		{
 			name:             "buckets limited by reset",
 			observations:     []float64{0, 1, 1.1, 1.2, 1.4, 1.8, 2, 3, 4},
@@ -1005,6 +1670,24 @@ func TestNativeHistogram(t *testing.T) {
 	}
 }
 
+func TestNativeHistogramInvalidZeroThreshold(t *testing.T) {
+	for _, threshold := range []float64{math.NaN(), math.Inf(+1)} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic for zero threshold %v", threshold)
+				}
+			}()
+			NewHistogram(HistogramOpts{
+				Name:                         "name",
+				Help:                         "help",
+				NativeHistogramBucketFactor:  1.1,
+				NativeHistogramZeroThreshold: threshold,
+			})
+		}()
+	}
+}
+
 func TestNativeHistogramConcurrency(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping test in short mode.")
@@ -1271,3 +1954,41 @@ func TestHistogramVecCreatedTimestampWithDeletes(t *testing.T) {
 	now = now.Add(1 * time.Hour)
 	expectCTsForMetricVecValues(t, histogramVec.MetricVec, dto.MetricType_HISTOGRAM, expected)
 }
+
+func TestNewConstHistogramFromSamples(t *testing.T) {
+	buckets := []float64{0.1, 1, 10}
+	samples := []float64{0.05, 0.1, 0.5, 3, 10, 42, math.Inf(-1)}
+	desc := NewDesc("test_histogram", "helpless", []string{"l"}, nil)
+
+	// The result has to be the same as observing the samples.
+	h := newHistogram(desc, HistogramOpts{Buckets: buckets}, "x")
+	for _, v := range samples {
+		h.Observe(v)
+	}
+	want := &dto.Metric{}
+	if err := h.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	want.Histogram.CreatedTimestamp = nil
+
+	for _, b := range [][]float64{buckets, append(buckets, math.Inf(+1))} {
+		m, err := NewConstHistogramFromSamples(desc, b, samples, "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := &dto.Metric{}
+		if err := m.Write(got); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("buckets %v: got %v, want %v", b, got, want)
+		}
+	}
+
+	if _, err := NewConstHistogramFromSamples(desc, []float64{1, 1}, samples, "x"); err == nil {
+		t.Error("expected error for buckets not in increasing order")
+	}
+	if _, err := NewConstHistogramFromSamples(desc, buckets, samples); err == nil {
+		t.Error("expected error for inconsistent label values")
+	}
+}
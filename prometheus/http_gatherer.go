@@ -0,0 +1,105 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/adhimaswaskita/client_golang/prometheus/internal"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// httpGathererAcceptHeader prefers the delimited protobuf format and falls back
+// to the text format, mirroring what the Prometheus server sends.
+const httpGathererAcceptHeader = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3`
+
+// defaultHTTPGathererTimeout is the timeout of the http.Client used by
+// NewHTTPGatherer if no client is provided.
+const defaultHTTPGathererTimeout = 10 * time.Second
+
+type httpGatherer struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPGatherer returns a Gatherer that retrieves metrics from the provided
+// URL, e.g. the /metrics endpoint of a neighboring process, each time Gather is
+// called. The request negotiates the protobuf exposition format and falls back
+// to the text format. The response is decoded according to its Content-Type
+// header.
+//
+// If client is nil, an http.Client with a timeout of 10s is used. Otherwise,
+// the timeout configured in the provided client applies.
+//
+// The returned Gatherer is most useful in combination with Gatherers to fold
+// the metrics of other targets into the exposition of the current process.
+// Note that the remote metrics are not checked for consistency with locally
+// registered Collectors beyond the checks performed by Gatherers.
+func NewHTTPGatherer(url string, client *http.Client) Gatherer {
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPGathererTimeout}
+	}
+	return &httpGatherer{url: url, client: client}
+}
+
+// Gather implements Gatherer.
+func (g *httpGatherer) Gather() ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequest(http.MethodGet, g.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", httpGathererAcceptHeader)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d while gathering metrics from %s", resp.StatusCode, g.url)
+	}
+
+	format := expfmt.ResponseFormat(resp.Header)
+	if format == expfmt.FmtUnknown {
+		// Assume the text format, as the Prometheus server does.
+		format = expfmt.FmtText
+	}
+	dec := expfmt.NewDecoder(resp.Body, format)
+
+	metricFamiliesByName := map[string]*dto.MetricFamily{}
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("error decoding metrics from %s: %w", g.url, err)
+		}
+		if existing, ok := metricFamiliesByName[mf.GetName()]; ok {
+			existing.Metric = append(existing.Metric, mf.Metric...)
+			continue
+		}
+		metricFamiliesByName[mf.GetName()] = mf
+	}
+	return internal.NormalizeMetricFamilies(metricFamiliesByName), nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/promhttp"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestHTTPGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cnt := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "remote_requests_total",
+		Help: "Remote requests.",
+	}, []string{"code"})
+	cnt.WithLabelValues("200").Add(3)
+	cnt.WithLabelValues("500").Inc()
+	reg.MustRegister(cnt)
+
+	const expected = `
+# HELP remote_requests_total Remote requests.
+# TYPE remote_requests_total counter
+remote_requests_total{code="200"} 3
+remote_requests_total{code="500"} 1
+`
+
+	for _, tc := range []struct {
+		name   string
+		accept string
+	}{
+		{name: "negotiated"},
+		{name: "text", accept: "text/plain"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.accept != "" {
+					r.Header.Set("Accept", tc.accept)
+				}
+				handler.ServeHTTP(w, r)
+			}))
+			defer ts.Close()
+
+			g := prometheus.NewHTTPGatherer(ts.URL, nil)
+			if err := testutil.GatherAndCompare(g, strings.NewReader(expected)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestHTTPGathererErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	if _, err := prometheus.NewHTTPGatherer(ts.URL, nil).Gather(); err == nil {
+		t.Error("expected error for non-200 status code")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	if _, err := prometheus.NewHTTPGatherer(ts.URL+"/slow", client).Gather(); err == nil {
+		t.Error("expected timeout error")
+	}
+}
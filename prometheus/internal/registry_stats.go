@@ -0,0 +1,22 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+// RegistryStats should not be used directly by anything, except the
+// `collectors` package. It is set by the prometheus package, which is the only
+// one with access to the internals of a prometheus.Registry.
+//
+// RegistryStats returns the number of registered collectors and descriptors if
+// the provided Gatherer is a *prometheus.Registry. Otherwise, ok is false.
+var RegistryStats func(g interface{}) (collectors, descs int, ok bool)
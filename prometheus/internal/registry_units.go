@@ -0,0 +1,26 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+// RegistryUnits should not be used directly by anything, except the `promhttp`
+// package. It is set by the prometheus package, which is the only one with
+// access to the internals of a prometheus.Registry.
+//
+// RegistryUnits returns the units of the metrics registered with the provided
+// Gatherer by their fully-qualified name, as far as the Gatherer is one of
+// the Gatherers of the prometheus package that know them (like a
+// *prometheus.Registry or a prometheus.Gatherers slice containing such
+// registries). Metrics without units are not included. The returned map may be
+// nil.
+var RegistryUnits func(g interface{}) map[string]string
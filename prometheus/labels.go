@@ -16,6 +16,7 @@ package prometheus
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
@@ -32,15 +33,55 @@ import (
 // create a Desc.
 type Labels map[string]string
 
+// LabelsHash returns a hash of the provided label set, calculated with the same
+// FNV-1a based algorithm the metric vectors (like CounterVec) use internally to
+// look up their children: The label values are hashed in the order of their
+// label names, each followed by model.SeparatorByte. The label names themselves
+// are not part of the hash. Thus, for a vector whose variable labels are sorted
+// lexicographically, the hash of the labels passed to its With method equals
+// the internal key of the resulting child. This allows external caches that
+// shadow the children of a vector to key consistently with the library.
+//
+// Like any 64-bit hash, it is subject to collisions, which callers have to
+// handle (as the metric vectors do). The algorithm is stable within a major
+// version of this library.
+func LabelsHash(labels Labels) uint64 {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := hashNew()
+	for _, name := range names {
+		h = hashAdd(h, labels[name])
+		h = hashAddByte(h, model.SeparatorByte)
+	}
+	return h
+}
+
 // LabelConstraint normalizes label values.
 type LabelConstraint func(string) string
 
+// OtherLabelValue is the label value that values not contained in the Values
+// of a ConstrainedLabel are replaced with.
+const OtherLabelValue = "other"
+
 // ConstrainedLabels represents a label name and its constrain function
 // to normalize label values. This type is commonly used when constructing
 // metric vector Collectors.
+//
+// If Values is not empty, it is the set of allowed values for the label. Any
+// other value (after applying the Constraint, if any) is replaced by
+// OtherLabelValue, i.e. it ends up in a single "other" series instead of
+// creating a new one. This puts an upper bound on the cardinality caused by
+// the label, e.g. for a label populated from user input. Note that this
+// applies to all methods of a vector that take label values, e.g.
+// DeleteLabelValues with a value not in Values deletes the "other" series.
 type ConstrainedLabel struct {
 	Name       string
 	Constraint LabelConstraint
+	Values     []string
 }
 
 // ConstrainableLabels is an interface that allows creating of labels that can
@@ -51,8 +92,13 @@ type ConstrainedLabel struct {
 //	  VariableLabels: []ConstrainedLabels{
 //	    {Name: "A"},
 //	    {Name: "B", Constraint: func(v string) string { ... }},
+//	    {Name: "C", Values: []string{"GET", "POST"}},
 //	  },
 //	})
+//
+// ConstrainableLabels are accepted by the constructors of all vector types in
+// V2, i.e. V2.NewCounterVec, V2.NewGaugeVec, V2.NewHistogramVec, and
+// V2.NewSummaryVec.
 type ConstrainableLabels interface {
 	compile() *compiledLabels
 	labelNames() []string
@@ -71,14 +117,36 @@ func (cls ConstrainedLabels) compile() *compiledLabels {
 
 	for i, label := range cls {
 		compiled.names[i] = label.Name
-		if label.Constraint != nil {
-			compiled.labelConstraints[label.Name] = label.Constraint
+		if fn := label.constraint(); fn != nil {
+			compiled.labelConstraints[label.Name] = fn
 		}
 	}
 
 	return compiled
 }
 
+// constraint returns the LabelConstraint combining the Constraint and the
+// allowed Values of the label, or nil if there is none.
+func (cl ConstrainedLabel) constraint() LabelConstraint {
+	if len(cl.Values) == 0 {
+		return cl.Constraint
+	}
+	allowed := make(map[string]struct{}, len(cl.Values))
+	for _, v := range cl.Values {
+		allowed[v] = struct{}{}
+	}
+	fn := cl.Constraint
+	return func(v string) string {
+		if fn != nil {
+			v = fn(v)
+		}
+		if _, ok := allowed[v]; !ok {
+			return OtherLabelValue
+		}
+		return v
+	}
+}
+
 func (cls ConstrainedLabels) labelNames() []string {
 	names := make([]string, len(cls))
 	for i, label := range cls {
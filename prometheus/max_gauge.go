@@ -0,0 +1,187 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// maxGaugeBuckets is the number of buckets the window of a MaxGauge is divided
+// into. It determines the granularity with which old values expire.
+const maxGaugeBuckets = 5
+
+// MaxGauge is a Metric that tracks a value like a Gauge, but exposes the
+// maximum the value had during a sliding time window rather than its current
+// value. This is useful to capture short peaks that would otherwise be missed
+// between two scrapes, e.g. the maximum number of concurrent connections in the
+// last minute.
+//
+// To create MaxGauge instances, use NewMaxGauge.
+type MaxGauge interface {
+	Metric
+	Collector
+
+	// Set sets the current value of the MaxGauge to an arbitrary value.
+	Set(float64)
+	// Observe is the same as Set. It allows using a MaxGauge as an
+	// Observer, e.g. with a Timer.
+	Observe(float64)
+	// Inc increments the current value of the MaxGauge by 1.
+	Inc()
+	// Dec decrements the current value of the MaxGauge by 1.
+	Dec()
+	// Add adds the given value to the current value of the MaxGauge. (The
+	// value can be negative, resulting in a decrease.)
+	Add(float64)
+	// Sub subtracts the given value from the current value of the MaxGauge.
+	// (The value can be negative, resulting in an increase.)
+	Sub(float64)
+}
+
+// NewMaxGauge creates a new MaxGauge based on the provided GaugeOpts, exposing
+// the maximum value during the provided window. The window is divided into 5
+// buckets, and the bucket with the oldest values is reset whenever a fifth of
+// the window has passed. Thus, the exposed maximum covers the values of the
+// last window plus up to a fifth of it. A value persists until it is changed,
+// i.e. the maximum is never exposed as lower than the current value, even if
+// the last change happened before the window. The metric is exposed with the
+// gauge type, and the initial value is 0.
+//
+// NewMaxGauge panics if window is not positive.
+func NewMaxGauge(opts GaugeOpts, window time.Duration) MaxGauge {
+	if window <= 0 {
+		panic(fmt.Errorf("illegal max gauge window %v", window))
+	}
+	desc := newDesc(
+		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		opts.Help,
+		opts.Unit,
+		UnconstrainedLabels(nil),
+		opts.ConstLabels,
+	)
+	result := newMaxGauge(desc, window, time.Now)
+	result.init(result) // Init self-collection.
+	return result
+}
+
+func newMaxGauge(desc *Desc, window time.Duration, now func() time.Time) *maxGauge {
+	bucketDuration := window / maxGaugeBuckets
+	if bucketDuration <= 0 {
+		bucketDuration = 1
+	}
+	return &maxGauge{
+		desc:           desc,
+		labelPairs:     desc.constLabelPairs,
+		bucketDuration: bucketDuration,
+		headExpTime:    now().Add(bucketDuration),
+		now:            now,
+	}
+}
+
+type maxGauge struct {
+	selfCollector
+
+	desc       *Desc
+	labelPairs []*dto.LabelPair
+
+	mtx sync.Mutex // Protects all fields below.
+	// current is the current value.
+	current float64
+	// buckets contains the maximum value during each bucket's time span,
+	// with buckets[head] being the most recent one.
+	buckets        [maxGaugeBuckets]float64
+	head           int
+	headExpTime    time.Time
+	bucketDuration time.Duration
+	now            func() time.Time
+}
+
+func (g *maxGauge) Desc() *Desc {
+	return g.desc
+}
+
+func (g *maxGauge) Set(val float64) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	g.set(val)
+}
+
+func (g *maxGauge) Observe(val float64) {
+	g.Set(val)
+}
+
+func (g *maxGauge) Inc() {
+	g.Add(1)
+}
+
+func (g *maxGauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *maxGauge) Add(val float64) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	g.set(g.current + val)
+}
+
+func (g *maxGauge) Sub(val float64) {
+	g.Add(val * -1)
+}
+
+func (g *maxGauge) Write(out *dto.Metric) error {
+	g.mtx.Lock()
+	g.rotate()
+	val := g.buckets[0]
+	for _, b := range g.buckets[1:] {
+		val = math.Max(val, b)
+	}
+	g.mtx.Unlock()
+
+	return populateMetric(GaugeValue, val, g.labelPairs, nil, out, nil)
+}
+
+// set sets the current value and updates the maximum of the head bucket. It
+// must be called with mtx locked.
+func (g *maxGauge) set(val float64) {
+	g.rotate()
+	g.current = val
+	g.buckets[g.head] = math.Max(g.buckets[g.head], val)
+}
+
+// rotate moves the head to the next bucket for each bucket duration that has
+// passed, resetting the maximum of the new head bucket to the current value.
+// It must be called with mtx locked.
+func (g *maxGauge) rotate() {
+	now := g.now()
+	if now.Sub(g.headExpTime) >= maxGaugeBuckets*g.bucketDuration {
+		// All buckets have expired.
+		for i := range g.buckets {
+			g.buckets[i] = g.current
+		}
+		g.headExpTime = now.Add(g.bucketDuration)
+		return
+	}
+	for !now.Before(g.headExpTime) {
+		g.head = (g.head + 1) % maxGaugeBuckets
+		g.buckets[g.head] = g.current
+		g.headExpTime = g.headExpTime.Add(g.bucketDuration)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMaxGauge(t *testing.T) {
+	now := time.Unix(0, 0)
+	desc := NewDesc("test_max", "helpless", nil, nil)
+	g := newMaxGauge(desc, 50*time.Second, func() time.Time { return now })
+	g.init(g)
+
+	expect := func(want float64) {
+		t.Helper()
+		m := &dto.Metric{}
+		if err := g.Write(m); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.GetGauge().GetValue(); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	expect(0)
+	g.Set(3)
+	g.Inc()
+	g.Set(1)
+	expect(4)
+
+	// Within the window, the peak is retained.
+	now = now.Add(45 * time.Second)
+	g.Observe(2)
+	expect(4)
+
+	// The bucket with the peak has expired, the remaining ones contain
+	// the later values.
+	now = now.Add(10 * time.Second)
+	expect(2)
+
+	// Without changes, the maximum decays to the current value.
+	now = now.Add(60 * time.Second)
+	expect(2)
+	g.Sub(2)
+	expect(2)
+	now = now.Add(time.Hour)
+	expect(0)
+}
+
+func TestMaxGaugeSelfCollection(t *testing.T) {
+	g := NewMaxGauge(GaugeOpts{Name: "test_max", Help: "helpless"}, time.Minute)
+	g.Add(5)
+	g.Dec()
+
+	reg := NewPedanticRegistry()
+	reg.MustRegister(g)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || mfs[0].GetType() != dto.MetricType_GAUGE {
+		t.Fatalf("unexpected metric families %v", mfs)
+	}
+	if got := mfs[0].Metric[0].GetGauge().GetValue(); got != 5 {
+		t.Errorf("got %v, want 5", got)
+	}
+}
+
+func TestNewMaxGaugePanicsOnInvalidWindow(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	NewMaxGauge(GaugeOpts{Name: "test_max", Help: "helpless"}, 0)
+}
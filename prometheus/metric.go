@@ -15,6 +15,7 @@ package prometheus
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"sort"
 	"strings"
@@ -93,6 +94,22 @@ type Opts struct {
 	// https://prometheus.io/docs/instrumenting/writing_exporters/#target-labels-not-static-scraped-labels
 	ConstLabels Labels
 
+	// Unit is the unit of this metric, e.g. "seconds" or "bytes". If set,
+	// it is exposed in "# UNIT" lines in the OpenMetrics format. As
+	// required by OpenMetrics, it must be a suffix of the fully-qualified
+	// name (ignoring a "_total" suffix), e.g. the unit of
+	// "http_request_duration_seconds" can only be "seconds". Otherwise,
+	// registration fails.
+	//
+	// Metrics with the same fully-qualified name must have the same Unit.
+	Unit string
+
+	// ExemplarOpts defines how invalid exemplars are handled. It is only
+	// used by metric types supporting exemplars, i.e. currently only by
+	// Counters. Gauges don't support exemplars, so GaugeOpts.ExemplarOpts
+	// is ignored. See ExemplarOpts for details.
+	ExemplarOpts ExemplarOpts
+
 	// now is for testing purposes, by default it's time.Now.
 	now func() time.Time
 }
@@ -119,6 +136,64 @@ func BuildFQName(namespace, subsystem, name string) string {
 	return name
 }
 
+// SanitizeMetricName returns a valid metric name derived from the provided
+// string, which may contain arbitrary UTF-8, e.g. the name of a metric from
+// another instrumentation system. It follows the underscore escaping scheme of
+// Prometheus: Every character that isn't allowed in a legacy metric name (i.e.
+// anything but ASCII letters, digits, '_', and ':', and a digit as the first
+// character) is replaced by '_'. A valid metric name is returned unchanged. An
+// empty string results in "_".
+//
+// The result is deterministic, but the mapping is not injective: Different
+// inputs may result in the same metric name, e.g. "http.requests" and
+// "http-requests" both result in "http_requests". Callers bridging metrics
+// from other systems have to deal with such collisions themselves, e.g. by
+// rejecting or merging the colliding metrics, as they would otherwise be
+// reported as duplicates when gathering.
+func SanitizeMetricName(s string) string {
+	return sanitizeName(s, true)
+}
+
+// SanitizeLabelName returns a valid label name derived from the provided
+// string in the same way as SanitizeMetricName does for metric names, with
+// the difference that ':' is replaced by '_', too, as it isn't allowed in
+// label names. The same caveat about collisions applies.
+//
+// Note that label names starting with "__" are syntactically valid but
+// reserved for internal use. They are returned unchanged and thus are still
+// rejected when used as label names of a metric.
+func SanitizeLabelName(s string) string {
+	return sanitizeName(s, false)
+}
+
+func sanitizeName(s string, allowColons bool) string {
+	if s == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_',
+			r >= '0' && r <= '9' && i > 0,
+			r == ':' && allowColons:
+			if b.Len() > 0 {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		if b.Len() == 0 {
+			// First invalid character, copy everything before it.
+			b.Grow(len(s))
+			b.WriteString(s[:i])
+		}
+		b.WriteByte('_')
+	}
+	if b.Len() == 0 {
+		return s
+	}
+	return b.String()
+}
+
 type invalidMetric struct {
 	desc *Desc
 	err  error
@@ -141,24 +216,56 @@ type timestampedMetric struct {
 }
 
 func (m timestampedMetric) Write(pb *dto.Metric) error {
+	if err := validateTimestamp(m.t); err != nil {
+		return err
+	}
 	e := m.Metric.Write(pb)
 	pb.TimestampMs = proto.Int64(m.t.Unix()*1000 + int64(m.t.Nanosecond()/1000000))
 	return e
 }
 
+// minTimestamp and maxTimestamp are the earliest and latest Times that can be
+// represented in milliseconds since the Unix epoch as an int64.
+var (
+	minTimestamp = time.UnixMilli(math.MinInt64)
+	maxTimestamp = time.UnixMilli(math.MaxInt64)
+)
+
+func validateTimestamp(ts time.Time) error {
+	if ts.IsZero() {
+		return errors.New("timestamp must not be the zero time")
+	}
+	if ts.Before(minTimestamp) || ts.After(maxTimestamp) {
+		return fmt.Errorf("timestamp %v out of range", ts)
+	}
+	return nil
+}
+
 // NewMetricWithTimestamp returns a new Metric wrapping the provided Metric in a
 // way that it has an explicit timestamp set to the provided Time. This is only
 // useful in rare cases as the timestamp of a Prometheus metric should usually
 // be set by the Prometheus server during scraping. Exceptions include mirroring
-// metrics with given timestamps from other metric
-// sources.
+// metrics with given timestamps from other metric sources, e.g. in proxies and
+// aggregators forwarding samples with their original sample time (see also
+// NewConstGatherer for forwarding whole MetricFamilies, whose timestamps are
+// kept as they are).
 //
 // NewMetricWithTimestamp works best with MustNewConstMetric,
 // MustNewConstHistogram, and MustNewConstSummary, see example.
 //
 // Currently, the exposition formats used by Prometheus are limited to
 // millisecond resolution. Thus, the provided time will be rounded down to the
-// next full millisecond value.
+// next full millisecond value. Writing the returned Metric fails if the
+// provided time is the zero Time, which is almost certainly a mistake, or if it
+// cannot be represented in milliseconds since the Unix epoch.
+//
+// Note that a Prometheus server handles samples with explicit timestamps
+// differently: it does not create staleness markers for them, so a series that
+// disappears from the exposition only stops being returned by queries after
+// the lookback delta (5m by default), and samples older than the time range
+// the server accepts (or out of order with the samples already ingested) are
+// rejected. Thus, only set explicit timestamps if the sample time is known to
+// differ from the scrape time in a meaningful way.
 func NewMetricWithTimestamp(t time.Time, m Metric) Metric {
 	return timestampedMetric{Metric: m, t: t}
 }
@@ -221,7 +328,11 @@ type Exemplar struct {
 // For Histogram, it means last applicable exemplar for each bucket is injected.
 //
 // NewMetricWithExemplars works best with MustNewConstMetric and
-// MustNewConstHistogram, see example.
+// MustNewConstHistogram, see example. This is also the way for Collectors that
+// forward pre-aggregated counters together with their exemplars (e.g. trace
+// links) to expose them: wrap the result of MustNewConstMetric with CounterValue
+// and pass the exemplar. As a counter carries at most one exemplar, only the last
+// one passed ends up in the exposition.
 func NewMetricWithExemplars(m Metric, exemplars ...Exemplar) (Metric, error) {
 	if len(exemplars) == 0 {
 		return nil, errors.New("no exemplar was passed for NewMetricWithExemplars")
@@ -16,8 +16,10 @@ package prometheus
 import (
 	"math"
 	"testing"
+	"time"
 
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -41,6 +43,38 @@ func TestBuildFQName(t *testing.T) {
 	}
 }
 
+func TestSanitizeName(t *testing.T) {
+	scenarios := []struct{ in, metricName, labelName string }{
+		{"valid_name", "valid_name", "valid_name"},
+		{"", "_", "_"},
+		{"http.server.requests", "http_server_requests", "http_server_requests"},
+		{"with-dash and space", "with_dash_and_space", "with_dash_and_space"},
+		{"job:rate5m", "job:rate5m", "job_rate5m"},
+		{"0leading_digit", "_leading_digit", "_leading_digit"},
+		{"trailing_digit9", "trailing_digit9", "trailing_digit9"},
+		{"ünicode€", "_nicode_", "_nicode_"},
+		{"invalid\xffutf8", "invalid_utf8", "invalid_utf8"},
+		{"__reserved", "__reserved", "__reserved"},
+	}
+
+	for _, s := range scenarios {
+		got := SanitizeMetricName(s.in)
+		if got != s.metricName {
+			t.Errorf("SanitizeMetricName(%q): want %q, got %q", s.in, s.metricName, got)
+		}
+		if !model.IsValidMetricName(model.LabelValue(got)) {
+			t.Errorf("SanitizeMetricName(%q): %q is not a valid metric name", s.in, got)
+		}
+		got = SanitizeLabelName(s.in)
+		if got != s.labelName {
+			t.Errorf("SanitizeLabelName(%q): want %q, got %q", s.in, s.labelName, got)
+		}
+		if !model.LabelName(got).IsValid() {
+			t.Errorf("SanitizeLabelName(%q): %q is not a valid label name", s.in, got)
+		}
+	}
+}
+
 func TestWithExemplarsMetric(t *testing.T) {
 	t.Run("histogram", func(t *testing.T) {
 		// Create a constant histogram from values we got from a 3rd party telemetry system.
@@ -89,4 +123,64 @@ func TestWithExemplarsMetric(t *testing.T) {
 			t.Errorf("want %v, got %v", want, got)
 		}
 	})
+
+	t.Run("counter", func(t *testing.T) {
+		// Forward a counter together with its exemplars from a 3rd party telemetry system.
+		c := MustNewConstMetric(
+			NewDesc("http_requests_total", "The number of HTTP requests.", nil, nil),
+			CounterValue, 4711,
+		)
+		ts := time.Unix(1700000000, 0)
+		m, err := NewMetricWithExemplars(c,
+			Exemplar{Value: 1, Labels: Labels{"trace_id": "abc"}, Timestamp: ts},
+			Exemplar{Value: 2, Labels: Labels{"trace_id": "def"}, Timestamp: ts},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		metric := dto.Metric{}
+		if err := m.Write(&metric); err != nil {
+			t.Fatal(err)
+		}
+		if want, got := 4711.0, metric.GetCounter().GetValue(); want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+		e := metric.GetCounter().GetExemplar()
+		if want, got := 2.0, e.GetValue(); want != got {
+			t.Errorf("want exemplar value %v, got %v", want, got)
+		}
+		if len(e.Label) != 1 || e.Label[0].GetValue() != "def" {
+			t.Errorf("want exemplar labels trace_id=\"def\", got %v", e.Label)
+		}
+		if want, got := ts, e.GetTimestamp().AsTime(); !want.Equal(got) {
+			t.Errorf("want exemplar timestamp %v, got %v", want, got)
+		}
+
+		if _, err := NewMetricWithExemplars(c, Exemplar{Value: 1, Labels: Labels{"in-valid": "abc"}}); err == nil {
+			t.Error("want error for invalid exemplar label name")
+		}
+	})
+}
+
+func TestNewMetricWithTimestamp(t *testing.T) {
+	desc := NewDesc("metric_with_timestamp", "help", []string{"a"}, nil)
+	ts := time.Unix(1700000000, 123456789)
+	m := NewMetricWithTimestamp(ts, MustNewConstMetric(desc, GaugeValue, 42, "x"))
+	pb := &dto.Metric{}
+	if err := m.Write(pb); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pb.GetTimestampMs(), int64(1700000000123); got != want {
+		t.Errorf("got timestamp %d, want %d", got, want)
+	}
+	if got := pb.GetGauge().GetValue(); got != 42 {
+		t.Errorf("got value %v, want 42", got)
+	}
+
+	for _, invalid := range []time.Time{{}, time.Unix(math.MaxInt64/1000+1, 0)} {
+		m := NewMetricWithTimestamp(invalid, MustNewConstMetric(desc, GaugeValue, 42, "x"))
+		if err := m.Write(&dto.Metric{}); err == nil {
+			t.Errorf("expected error for timestamp %v", invalid)
+		}
+	}
 }
@@ -13,6 +13,11 @@
 
 package prometheus
 
+import (
+	"context"
+	"time"
+)
+
 // Observer is the interface that wraps the Observe method, which is used by
 // Histogram and Summary to add observations.
 type Observer interface {
@@ -39,6 +44,81 @@ func (f ObserverFunc) Observe(value float64) {
 	f(value)
 }
 
+// DurationObserver is implemented by Observers that offer the option of
+// observing a time.Duration directly, in particular the Histograms and
+// Summaries provided by this package. Its ObserveDuration method works like
+// Observe with the duration in seconds (the base unit for durations in
+// Prometheus), i.e. ObserveDuration(d) is equivalent to Observe(d.Seconds()).
+// It helps avoiding the accidental observation of durations in other units.
+type DurationObserver interface {
+	ObserveDuration(time.Duration)
+}
+
+// LazyObserver is implemented by Observers that offer the option of observing
+// a value that is only computed if it is actually needed. Its MaybeObserve
+// method calls the provided function and observes the returned value, unless
+// the Observer discards all observations anyway (like DiscardObserver), in
+// which case the function is not called at all. The Histograms and Summaries
+// provided by this package always call the function.
+//
+// LazyObserver is usually not used directly but through the MaybeObserve
+// function, which works with any Observer.
+type LazyObserver interface {
+	MaybeObserve(f func() float64)
+}
+
+// MaybeObserve observes the value returned by f with the provided Observer. If
+// the Observer implements LazyObserver, its MaybeObserve method is used, so
+// that f is not called for an Observer that discards observations. Otherwise, f
+// is called, and its result is passed to Observe.
+//
+// This allows skipping an expensive computation of the observed value (e.g.
+// the size of a large response) if instrumentation is disabled by providing a
+// DiscardObserver. Note that calling the closure has a small overhead in itself,
+// so for cheap values, calling Observe directly is preferable.
+func MaybeObserve(o Observer, f func() float64) {
+	if lo, ok := o.(LazyObserver); ok {
+		lo.MaybeObserve(f)
+		return
+	}
+	o.Observe(f())
+}
+
+// ObserveStream observes each value received from ch with the provided
+// Observer until ch is closed or ctx is canceled, whichever happens first. It
+// blocks until then, so it is usually run in its own goroutine, e.g.
+//
+//	go prometheus.ObserveStream(ctx, myHistogram, latencies)
+//
+// ObserveStream returns nil if ch has been closed, and ctx.Err() if ctx has
+// been canceled. In the latter case, values still buffered in ch are not
+// observed.
+func ObserveStream(ctx context.Context, o Observer, ch <-chan float64) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case v, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			o.Observe(v)
+		}
+	}
+}
+
+// DiscardObserver is an Observer that discards all observations. It can be used
+// wherever an Observer is required but instrumentation is disabled. It
+// implements LazyObserver and DurationObserver, and its MaybeObserve method
+// never calls the provided function.
+var DiscardObserver Observer = discardObserver{}
+
+type discardObserver struct{}
+
+func (discardObserver) Observe(float64)               {}
+func (discardObserver) ObserveDuration(time.Duration) {}
+func (discardObserver) MaybeObserve(f func() float64) {}
+
 // ObserverVec is an interface implemented by `HistogramVec` and `SummaryVec`.
 type ObserverVec interface {
 	GetMetricWith(Labels) (Observer, error)
@@ -62,3 +142,79 @@ type ObserverVec interface {
 type ExemplarObserver interface {
 	ObserveWithExemplar(value float64, exemplar Labels)
 }
+
+// ExemplarLabelsObserver is implemented by Observers that offer the option of
+// observing a value together with an exemplar whose labels have been validated
+// in advance, in particular the Histograms provided by this package. Its
+// ObserveWithExemplarLabels method works like ObserveWithExemplar, but it
+// avoids creating and validating the exemplar labels upon each call, which
+// matters in hot paths with high rates of exemplars. If the provided
+// ExemplarLabels are nil, the current exemplar is left in place. See
+// NewExemplarLabels for how to create ExemplarLabels.
+type ExemplarLabelsObserver interface {
+	ObserveWithExemplarLabels(value float64, exemplar *ExemplarLabels)
+}
+
+// SampledExemplarObserver is implemented by Observers that offer the option of
+// observing a value together with a randomly sampled exemplar. Its
+// ObserveWithExemplarSampled method works like ObserveWithExemplar with the
+// Labels returned by the provided function, but only with the provided
+// probability. Otherwise, it works like Observe, and the function is not
+// called at all. Thus, the cost of creating the Labels is only incurred for the
+// sampled observations. A probability of 1 or more samples every observation,
+// a probability of 0 or less (or NaN) none. See ExemplarOpts.RandSource for
+// how to make the sampling reproducible.
+type SampledExemplarObserver interface {
+	ObserveWithExemplarSampled(value float64, exemplar func() Labels, probability float64)
+}
+
+// ObserverWithContext is an Observer that can derive the labels of an exemplar
+// from a context, e.g. the trace ID of a request-scoped span. Its ObserveCtx
+// method works like ObserveWithExemplar with the Labels derived from the
+// provided context, or like Observe if no Labels can be derived. Use
+// NewObserverWithContext to create instances.
+type ObserverWithContext interface {
+	Observer
+	ObserveCtx(ctx context.Context, value float64)
+}
+
+type observerWithContext struct {
+	Observer
+	exemplarFromContext func(context.Context) Labels
+}
+
+// NewObserverWithContext returns an ObserverWithContext that observes with the
+// provided Observer. Its ObserveCtx method calls the provided
+// exemplarFromContext function with the context. If the function returns
+// non-nil Labels and the Observer implements ExemplarObserver (like the
+// Histograms of this package), the value is observed with an exemplar with
+// those Labels. Otherwise, it is observed without exemplar. If
+// exemplarFromContext is nil, a function returning nil is used, i.e. no
+// exemplars are observed. The exemplarFromContext function is called on
+// every observation, so it has to be cheap, and it has to be safe for
+// concurrent use if the returned ObserverWithContext is used concurrently.
+//
+// A typical usage is to wrap each Histogram of an instrumented code path once
+// with the same function extracting the trace ID, so that the call sites only
+// have to pass on the context:
+//
+//	latency := prometheus.NewObserverWithContext(myHistogram, traceIDFromContext)
+//	...
+//	latency.ObserveCtx(ctx, time.Since(start).Seconds())
+func NewObserverWithContext(o Observer, exemplarFromContext func(context.Context) Labels) ObserverWithContext {
+	if exemplarFromContext == nil {
+		exemplarFromContext = func(context.Context) Labels { return nil }
+	}
+	return &observerWithContext{Observer: o, exemplarFromContext: exemplarFromContext}
+}
+
+// ObserveCtx implements ObserverWithContext.
+func (o *observerWithContext) ObserveCtx(ctx context.Context, value float64) {
+	if eo, ok := o.Observer.(ExemplarObserver); ok {
+		if exemplar := o.exemplarFromContext(ctx); exemplar != nil {
+			eo.ObserveWithExemplar(value, exemplar)
+			return
+		}
+	}
+	o.Observe(value)
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObserveStream(t *testing.T) {
+	var observed []float64
+	o := ObserverFunc(func(v float64) { observed = append(observed, v) })
+
+	ch := make(chan float64, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+	if err := ObserveStream(context.Background(), o, ch); err != nil {
+		t.Errorf("unexpected error after closing the channel: %v", err)
+	}
+	if len(observed) != 3 || observed[0] != 1 || observed[1] != 2 || observed[2] != 3 {
+		t.Errorf("got observations %v, want [1 2 3]", observed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch = make(chan float64)
+	done := make(chan error)
+	go func() { done <- ObserveStream(ctx, o, ch) }()
+	ch <- 4
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if len(observed) != 4 || observed[3] != 4 {
+		t.Errorf("got observations %v, want [1 2 3 4]", observed)
+	}
+}
+
+type traceIDKey struct{}
+
+func TestObserverWithContext(t *testing.T) {
+	his := NewHistogram(HistogramOpts{Name: "test_histogram", Help: "helpless", Buckets: []float64{1}})
+	o := NewObserverWithContext(his, func(ctx context.Context) Labels {
+		if id, ok := ctx.Value(traceIDKey{}).(string); ok {
+			return Labels{"trace_id": id}
+		}
+		return nil
+	})
+
+	o.ObserveCtx(context.Background(), 0.5)
+	o.ObserveCtx(context.WithValue(context.Background(), traceIDKey{}, "abc"), 2)
+	o.Observe(0.7)
+
+	m := &dto.Metric{}
+	if err := his.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	h := m.GetHistogram()
+	if got := h.GetSampleCount(); got != 3 {
+		t.Errorf("got %d observations, want 3", got)
+	}
+	if e := h.Bucket[0].GetExemplar(); e != nil {
+		t.Errorf("got exemplar %v in first bucket, want none", e)
+	}
+	if len(h.Bucket) < 2 {
+		t.Fatalf("missing +Inf bucket in %v", h)
+	}
+	e := h.Bucket[1].GetExemplar()
+	if e.GetValue() != 2 || len(e.Label) != 1 || e.Label[0].GetValue() != "abc" {
+		t.Errorf("got exemplar %v in +Inf bucket, want trace_id abc with value 2", e)
+	}
+
+	// Without an extractor or ExemplarObserver, values are observed as is.
+	var observed float64
+	NewObserverWithContext(ObserverFunc(func(v float64) { observed = v }), nil).ObserveCtx(context.Background(), 3)
+	if observed != 3 {
+		t.Errorf("got observed value %v, want 3", observed)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// PeriodicCollector is a Collector that collects another Collector
+// periodically in the background rather than upon each collection.
+//
+// To create PeriodicCollector instances, use NewPeriodicCollector.
+type PeriodicCollector interface {
+	Collector
+	// Stop stops the periodic collection. It waits for an ongoing
+	// collection of the inner Collector to finish. Afterwards, the
+	// metrics of the last collection are still served. Calling Stop more
+	// than once has no effect.
+	Stop()
+}
+
+type periodicCollector struct {
+	inner    Collector
+	interval time.Duration
+	age      *Desc
+	now      func() time.Time
+
+	mtx       sync.RWMutex // Protects the fields below.
+	cached    []Metric
+	collected time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewPeriodicCollector returns a PeriodicCollector that collects the provided
+// Collector every interval in a background goroutine and serves the metrics of
+// the last collection upon each collection of its own. This decouples
+// expensive Collectors (e.g. scanning a disk) from the scrape interval. The
+// values of the metrics are captured at the time of the periodic collection.
+//
+// The first collection happens synchronously before NewPeriodicCollector
+// returns, so that metrics are available right away. The background goroutine
+// runs until Stop is called.
+//
+// In addition to the metrics of the inner Collector, the gauge
+// "prometheus_periodic_collector_cache_age_seconds" contains the time since the
+// last periodic collection has finished. To register more than one
+// PeriodicCollector with the same Registry, use WrapRegistererWith to
+// distinguish them by labels.
+//
+// The descriptors of the inner Collector are passed on unchanged. Errors
+// reported by the inner Collector (e.g. via NewInvalidMetric) are reported again
+// upon each collection until the next periodic collection.
+//
+// NewPeriodicCollector panics if interval is not positive.
+func NewPeriodicCollector(inner Collector, interval time.Duration) PeriodicCollector {
+	if interval <= 0 {
+		panic("NewPeriodicCollector needs a positive interval")
+	}
+	c := &periodicCollector{
+		inner:    inner,
+		interval: interval,
+		age: NewDesc(
+			"prometheus_periodic_collector_cache_age_seconds",
+			"Time since the last periodic collection of the cached metrics has finished.",
+			nil, nil,
+		),
+		now:     time.Now,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	c.update()
+	go c.run()
+	return c
+}
+
+func (c *periodicCollector) run() {
+	defer close(c.stopped)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.update()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// update collects the inner Collector and replaces the cached metrics.
+func (c *periodicCollector) update() {
+	ch := make(chan Metric, capMetricChan)
+	go func() {
+		c.inner.Collect(ch)
+		close(ch)
+	}()
+	var cached []Metric
+	for m := range ch {
+		cached = append(cached, snapshotMetric(m))
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.cached = cached
+	c.collected = c.now()
+}
+
+// Describe implements Collector.
+func (c *periodicCollector) Describe(ch chan<- *Desc) {
+	c.inner.Describe(ch)
+	ch <- c.age
+}
+
+// Collect implements Collector.
+func (c *periodicCollector) Collect(ch chan<- Metric) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	for _, m := range c.cached {
+		ch <- m
+	}
+	ch <- MustNewConstMetric(c.age, GaugeValue, c.now().Sub(c.collected).Seconds())
+}
+
+// Stop implements PeriodicCollector.
+func (c *periodicCollector) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.stopped
+}
+
+// snapshotMetric returns a Metric with the values that the provided Metric
+// has right now, or an invalid Metric if writing the provided Metric fails.
+func snapshotMetric(m Metric) Metric {
+	out := &dto.Metric{}
+	if err := m.Write(out); err != nil {
+		return NewInvalidMetric(m.Desc(), err)
+	}
+	return &snapshottedMetric{desc: m.Desc(), metric: out}
+}
+
+type snapshottedMetric struct {
+	desc   *Desc
+	metric *dto.Metric
+}
+
+func (m *snapshottedMetric) Desc() *Desc {
+	return m.desc
+}
+
+func (m *snapshottedMetric) Write(out *dto.Metric) error {
+	out.Label = m.metric.Label
+	out.Counter = m.metric.Counter
+	out.Gauge = m.metric.Gauge
+	out.Summary = m.metric.Summary
+	out.Untyped = m.metric.Untyped
+	out.Histogram = m.metric.Histogram
+	out.TimestampMs = m.metric.TimestampMs
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCollector counts its collections in a gauge.
+type countingCollector struct {
+	collections int64
+	desc        *Desc
+}
+
+func (c *countingCollector) Describe(ch chan<- *Desc) { ch <- c.desc }
+
+func (c *countingCollector) Collect(ch chan<- Metric) {
+	n := atomic.AddInt64(&c.collections, 1)
+	ch <- MustNewConstMetric(c.desc, GaugeValue, float64(n))
+}
+
+func TestPeriodicCollector(t *testing.T) {
+	inner := &countingCollector{desc: NewDesc("collections", "help", nil, nil)}
+	c := NewPeriodicCollector(inner, time.Hour).(*periodicCollector)
+	defer c.Stop()
+	if got := atomic.LoadInt64(&inner.collections); got != 1 {
+		t.Fatalf("expected one synchronous collection, got %d", got)
+	}
+
+	now := c.collected
+	c.now = func() time.Time { return now.Add(90 * time.Second) }
+
+	reg := NewPedanticRegistry()
+	reg.MustRegister(c)
+	for i := 0; i < 3; i++ {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mfs) != 2 {
+			t.Fatalf("expected 2 metric families, got %v", mfs)
+		}
+		if got := mfs[0].Metric[0].Gauge.GetValue(); got != 1 {
+			t.Errorf("expected cached value 1, got %v", got)
+		}
+		if got := mfs[1].Metric[0].Gauge.GetValue(); got != 90 {
+			t.Errorf("expected cache age 90, got %v", got)
+		}
+	}
+	if got := atomic.LoadInt64(&inner.collections); got != 1 {
+		t.Errorf("expected scrapes to not collect the inner collector, got %d collections", got)
+	}
+}
+
+func TestPeriodicCollectorInterval(t *testing.T) {
+	inner := &countingCollector{desc: NewDesc("collections", "help", nil, nil)}
+	c := NewPeriodicCollector(inner, time.Millisecond)
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt64(&inner.collections) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("no periodic collections")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.Stop()
+	c.Stop() // Stopping twice is harmless.
+	n := atomic.LoadInt64(&inner.collections)
+	time.Sleep(10 * time.Millisecond)
+	if got := atomic.LoadInt64(&inner.collections); got != n {
+		t.Errorf("collections continued after Stop: %d -> %d", n, got)
+	}
+}
@@ -19,8 +19,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// processInitTime approximates the start time of the process for
+// process_start_time_seconds where the operating system does not provide it.
+var processInitTime = time.Now()
+
 type processCollector struct {
 	collectFn       func(chan<- Metric)
 	pidFn           func() (int, error)
@@ -30,6 +35,9 @@ type processCollector struct {
 	vsize, maxVsize *Desc
 	rss             *Desc
 	startTime       *Desc
+	// startTimeApproximated is only collected if startTime is
+	// approximated by processInitTime.
+	startTimeApproximated *Desc
 }
 
 // ProcessCollectorOpts defines the behavior of a process metrics collector
@@ -100,6 +108,11 @@ func NewProcessCollector(opts ProcessCollectorOpts) Collector {
 			"Start time of the process since unix epoch in seconds.",
 			nil, nil,
 		),
+		startTimeApproximated: NewDesc(
+			ns+"process_start_time_approximated",
+			"Set to 1 if the start time of the process is approximated by the time the process initialized its instrumentation, as the operating system did not provide it.",
+			nil, nil,
+		),
 	}
 
 	if opts.PidFn == nil {
@@ -113,6 +126,12 @@ func NewProcessCollector(opts ProcessCollectorOpts) Collector {
 		c.collectFn = c.processCollect
 	} else {
 		c.collectFn = func(ch chan<- Metric) {
+			if pid, err := c.pidFn(); err == nil {
+				startTime, err := processStartTime(pid)
+				c.collectStartTime(ch, pid, startTime, err)
+			} else {
+				c.reportError(ch, nil, err)
+			}
 			c.reportError(ch, nil, errors.New("process metrics not supported on this platform"))
 		}
 	}
@@ -129,6 +148,7 @@ func (c *processCollector) Describe(ch chan<- *Desc) {
 	ch <- c.maxVsize
 	ch <- c.rss
 	ch <- c.startTime
+	ch <- c.startTimeApproximated
 }
 
 // Collect returns the current state of all metrics of the collector.
@@ -136,6 +156,22 @@ func (c *processCollector) Collect(ch chan<- Metric) {
 	c.collectFn(ch)
 }
 
+// collectStartTime collects the provided start time of the process with the
+// provided PID, or reports err if it is not nil. In the latter case, if the
+// PID is the one of the current process, the start time is approximated by
+// processInitTime instead, which is flagged by startTimeApproximated.
+func (c *processCollector) collectStartTime(ch chan<- Metric, pid int, startTime float64, err error) {
+	switch {
+	case err == nil:
+		ch <- MustNewConstMetric(c.startTime, GaugeValue, startTime)
+	case pid == os.Getpid():
+		ch <- MustNewConstMetric(c.startTime, GaugeValue, float64(processInitTime.UnixNano())/1e9)
+		ch <- MustNewConstMetric(c.startTimeApproximated, GaugeValue, 1)
+	default:
+		c.reportError(ch, c.startTime, err)
+	}
+}
+
 func (c *processCollector) reportError(ch chan<- Metric, desc *Desc, err error) {
 	if !c.reportErrors {
 		return
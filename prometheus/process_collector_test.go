@@ -166,3 +166,41 @@ func TestNewPidFileFn(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessCollectorStartTimeFallback(t *testing.T) {
+	c := NewProcessCollector(ProcessCollectorOpts{ReportErrors: true}).(*processCollector)
+	errStartTime := errors.New("no start time")
+
+	ch := make(chan Metric, 2)
+	c.collectStartTime(ch, os.Getpid(), 0, errStartTime)
+	close(ch)
+	var got []*dto.Metric
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, pb)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d metrics, want start time and approximation flag", len(got))
+	}
+	if v, want := got[0].GetGauge().GetValue(), float64(processInitTime.UnixNano())/1e9; v != want {
+		t.Errorf("got start time %v, want %v", v, want)
+	}
+	if v := got[1].GetGauge().GetValue(); v != 1 {
+		t.Errorf("got approximation flag %v, want 1", v)
+	}
+
+	// The start time of other processes is not approximated.
+	ch = make(chan Metric, 2)
+	c.collectStartTime(ch, os.Getpid()+1, 0, errStartTime)
+	close(ch)
+	m := <-ch
+	if err := m.Write(&dto.Metric{}); !errors.Is(err, errStartTime) {
+		t.Errorf("got error %v, want %v", err, errStartTime)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("unexpected additional metric")
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin
+// +build darwin
+
+package prometheus
+
+import "golang.org/x/sys/unix"
+
+// processStartTime returns the start time of the process with the provided PID
+// in seconds since the Unix epoch, as reported by the kern.proc.pid sysctl.
+func processStartTime(pid int) (float64, error) {
+	kp, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil {
+		return 0, err
+	}
+	return float64(kp.Proc.P_starttime.Nano()) / 1e9, nil
+}
@@ -0,0 +1,26 @@
+// Copyright 2014 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !darwin
+// +build !darwin
+
+package prometheus
+
+import "errors"
+
+// processStartTime returns the start time of the process with the provided PID
+// on platforms where the other process metrics can't be collected. It is
+// only supported on Darwin.
+func processStartTime(int) (float64, error) {
+	return 0, errors.New("process start time not supported on this platform")
+}
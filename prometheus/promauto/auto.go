@@ -159,7 +159,11 @@
 // Enjoy promauto responsibly!
 package promauto
 
-import "github.com/adhimaswaskita/client_golang/prometheus"
+import (
+	"errors"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
 
 // NewCounter works like the function of the same name in the prometheus package
 // but it automatically registers the Counter with the
@@ -250,20 +254,65 @@ func NewUntypedFunc(opts prometheus.UntypedOpts, function func() float64) promet
 // Registerer. All methods of the Factory panic if the registration fails.
 type Factory struct {
 	r prometheus.Registerer
+
+	registrationErrors prometheus.Counter
 }
 
 // With creates a Factory using the provided Registerer for registration of the
 // created Collectors. If the provided Registerer is nil, the returned Factory
 // creates Collectors that are not registered with any Registerer.
-func With(r prometheus.Registerer) Factory { return Factory{r} }
+func With(r prometheus.Registerer) Factory { return Factory{r: r} }
+
+// WithRegistrationErrorCounter returns a copy of the Factory that counts failed
+// registrations in the counter "promauto_registration_errors_total", which is
+// registered with the provided Registerer. The counter is incremented before
+// the Factory panics. This provides insight into how often registrations fail
+// in code that recovers from those panics, e.g. in systems that load plugins
+// dynamically.
+//
+// The counter is shared by all Factories created with the same Registerer. It
+// is usually registered with the Registerer of the Factory itself, but it can
+// be registered elsewhere, too. WithRegistrationErrorCounter panics if the
+// counter cannot be registered (for other reasons than having been registered
+// before). If a Collector with the same descriptor but not being a Counter
+// has been registered before, the counter is used without being registered.
+func (f Factory) WithRegistrationErrorCounter(r prometheus.Registerer) Factory {
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "promauto_registration_errors_total",
+		Help: "Total number of failed registrations of Collectors created by promauto.",
+	})
+	if err := r.Register(c); err != nil {
+		are := prometheus.AlreadyRegisteredError{}
+		if !errors.As(err, &are) {
+			panic(err)
+		}
+		if existing, ok := are.ExistingCollector.(prometheus.Counter); ok {
+			c = existing
+		}
+	}
+	f.registrationErrors = c
+	return f
+}
+
+// mustRegister registers c with the Registerer of the Factory, if any. If the
+// registration fails, it counts the error (if configured) and panics.
+func (f Factory) mustRegister(c prometheus.Collector) {
+	if f.r == nil {
+		return
+	}
+	if err := f.r.Register(c); err != nil {
+		if f.registrationErrors != nil {
+			f.registrationErrors.Inc()
+		}
+		panic(err)
+	}
+}
 
 // NewCounter works like the function of the same name in the prometheus package
 // but it automatically registers the Counter with the Factory's Registerer.
 func (f Factory) NewCounter(opts prometheus.CounterOpts) prometheus.Counter {
 	c := prometheus.NewCounter(opts)
-	if f.r != nil {
-		f.r.MustRegister(c)
-	}
+	f.mustRegister(c)
 	return c
 }
 
@@ -272,9 +321,7 @@ func (f Factory) NewCounter(opts prometheus.CounterOpts) prometheus.Counter {
 // Registerer.
 func (f Factory) NewCounterVec(opts prometheus.CounterOpts, labelNames []string) *prometheus.CounterVec {
 	c := prometheus.NewCounterVec(opts, labelNames)
-	if f.r != nil {
-		f.r.MustRegister(c)
-	}
+	f.mustRegister(c)
 	return c
 }
 
@@ -283,9 +330,7 @@ func (f Factory) NewCounterVec(opts prometheus.CounterOpts, labelNames []string)
 // Registerer.
 func (f Factory) NewCounterFunc(opts prometheus.CounterOpts, function func() float64) prometheus.CounterFunc {
 	c := prometheus.NewCounterFunc(opts, function)
-	if f.r != nil {
-		f.r.MustRegister(c)
-	}
+	f.mustRegister(c)
 	return c
 }
 
@@ -293,9 +338,7 @@ func (f Factory) NewCounterFunc(opts prometheus.CounterOpts, function func() flo
 // but it automatically registers the Gauge with the Factory's Registerer.
 func (f Factory) NewGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
 	g := prometheus.NewGauge(opts)
-	if f.r != nil {
-		f.r.MustRegister(g)
-	}
+	f.mustRegister(g)
 	return g
 }
 
@@ -304,9 +347,7 @@ func (f Factory) NewGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
 // Registerer.
 func (f Factory) NewGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
 	g := prometheus.NewGaugeVec(opts, labelNames)
-	if f.r != nil {
-		f.r.MustRegister(g)
-	}
+	f.mustRegister(g)
 	return g
 }
 
@@ -315,9 +356,7 @@ func (f Factory) NewGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *pr
 // Registerer.
 func (f Factory) NewGaugeFunc(opts prometheus.GaugeOpts, function func() float64) prometheus.GaugeFunc {
 	g := prometheus.NewGaugeFunc(opts, function)
-	if f.r != nil {
-		f.r.MustRegister(g)
-	}
+	f.mustRegister(g)
 	return g
 }
 
@@ -325,9 +364,7 @@ func (f Factory) NewGaugeFunc(opts prometheus.GaugeOpts, function func() float64
 // but it automatically registers the Summary with the Factory's Registerer.
 func (f Factory) NewSummary(opts prometheus.SummaryOpts) prometheus.Summary {
 	s := prometheus.NewSummary(opts)
-	if f.r != nil {
-		f.r.MustRegister(s)
-	}
+	f.mustRegister(s)
 	return s
 }
 
@@ -336,9 +373,7 @@ func (f Factory) NewSummary(opts prometheus.SummaryOpts) prometheus.Summary {
 // Registerer.
 func (f Factory) NewSummaryVec(opts prometheus.SummaryOpts, labelNames []string) *prometheus.SummaryVec {
 	s := prometheus.NewSummaryVec(opts, labelNames)
-	if f.r != nil {
-		f.r.MustRegister(s)
-	}
+	f.mustRegister(s)
 	return s
 }
 
@@ -347,9 +382,7 @@ func (f Factory) NewSummaryVec(opts prometheus.SummaryOpts, labelNames []string)
 // Registerer.
 func (f Factory) NewHistogram(opts prometheus.HistogramOpts) prometheus.Histogram {
 	h := prometheus.NewHistogram(opts)
-	if f.r != nil {
-		f.r.MustRegister(h)
-	}
+	f.mustRegister(h)
 	return h
 }
 
@@ -358,9 +391,7 @@ func (f Factory) NewHistogram(opts prometheus.HistogramOpts) prometheus.Histogra
 // Registerer.
 func (f Factory) NewHistogramVec(opts prometheus.HistogramOpts, labelNames []string) *prometheus.HistogramVec {
 	h := prometheus.NewHistogramVec(opts, labelNames)
-	if f.r != nil {
-		f.r.MustRegister(h)
-	}
+	f.mustRegister(h)
 	return h
 }
 
@@ -369,8 +400,6 @@ func (f Factory) NewHistogramVec(opts prometheus.HistogramOpts, labelNames []str
 // Registerer.
 func (f Factory) NewUntypedFunc(opts prometheus.UntypedOpts, function func() float64) prometheus.UntypedFunc {
 	u := prometheus.NewUntypedFunc(opts, function)
-	if f.r != nil {
-		f.r.MustRegister(u)
-	}
+	f.mustRegister(u)
 	return u
 }
@@ -17,9 +17,70 @@ import (
 	"testing"
 
 	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
 )
 
 func TestNil(t *testing.T) {
 	// A nil registerer should be treated as a no-op by promauto.
 	With(nil).NewCounter(prometheus.CounterOpts{Name: "test"}).Inc()
 }
+
+func TestRegistrationErrorCounter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	f := With(reg).WithRegistrationErrorCounter(reg)
+	// The counter is shared between Factories.
+	g := With(reg).WithRegistrationErrorCounter(reg)
+
+	opts := prometheus.CounterOpts{Name: "test_total", Help: "help"}
+	f.NewCounter(opts)
+	for _, factory := range []Factory{f, g} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic for duplicate registration")
+				}
+			}()
+			factory.NewCounter(opts)
+		}()
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "promauto_registration_errors_total" {
+			continue
+		}
+		if got := mf.Metric[0].Counter.GetValue(); got != 2 {
+			t.Errorf("got %v registration errors, want 2", got)
+		}
+		return
+	}
+	t.Error("registration error counter not found")
+}
+
+func TestRegistrationErrorCounterCollision(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	// A CounterVec without labels has the same descriptor as the counter.
+	reg.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "promauto_registration_errors_total",
+		Help: "Total number of failed registrations of Collectors created by promauto.",
+	}, nil))
+	f := With(reg).WithRegistrationErrorCounter(reg)
+
+	opts := prometheus.CounterOpts{Name: "test_total", Help: "help"}
+	f.NewCounter(opts)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for duplicate registration")
+			}
+		}()
+		f.NewCounter(opts)
+	}()
+
+	if got := testutil.ToFloat64(f.registrationErrors); got != 1 {
+		t.Errorf("got %v registration errors, want 1", got)
+	}
+}
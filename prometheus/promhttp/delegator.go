@@ -33,6 +33,7 @@ type delegator interface {
 
 	Status() int
 	Written() int64
+	Hijacked() bool
 }
 
 type responseWriterDelegator struct {
@@ -41,6 +42,7 @@ type responseWriterDelegator struct {
 	status             int
 	written            int64
 	wroteHeader        bool
+	hijacked           bool
 	observeWriteHeader func(int)
 }
 
@@ -52,6 +54,12 @@ func (r *responseWriterDelegator) Written() int64 {
 	return r.written
 }
 
+// Hijacked returns whether the connection has been hijacked successfully. The
+// status code is meaningless in that case.
+func (r *responseWriterDelegator) Hijacked() bool {
+	return r.hijacked
+}
+
 func (r *responseWriterDelegator) WriteHeader(code int) {
 	if r.observeWriteHeader != nil && !r.wroteHeader {
 		// Only call observeWriteHeader for the 1st time. It's a bug if
@@ -99,7 +107,11 @@ func (d flusherDelegator) Flush() {
 }
 
 func (d hijackerDelegator) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return d.ResponseWriter.(http.Hijacker).Hijack()
+	conn, rw, err := d.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		d.hijacked = true
+	}
+	return conn, rw, err
 }
 
 func (d readerFromDelegator) ReadFrom(re io.Reader) (int64, error) {
@@ -32,26 +32,41 @@
 package promhttp
 
 import (
+	"bytes"
 	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/cespare/xxhash/v2"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/internal"
 )
 
 const (
-	contentTypeHeader      = "Content-Type"
-	contentEncodingHeader  = "Content-Encoding"
-	acceptEncodingHeader   = "Accept-Encoding"
-	processStartTimeHeader = "Process-Start-Time-Unix"
+	contentTypeHeader           = "Content-Type"
+	contentEncodingHeader       = "Content-Encoding"
+	acceptEncodingHeader        = "Accept-Encoding"
+	processStartTimeHeader      = "Process-Start-Time-Unix"
+	scrapeDurationTrailer       = "X-Prometheus-Scrape-Duration-Seconds"
+	scrapeTimeoutExceededHeader = "X-Prometheus-Scrape-Timeout-Exceeded"
+	scrapeFromCacheMetric       = "scrape_from_cache"
+	scrapedSamplesMetric        = "prometheus_handler_scraped_samples"
+	etagHeader                  = "ETag"
+	trailerHeader               = "Trailer"
+	ifNoneMatchHeader           = "If-None-Match"
 )
 
 var gzipPool = sync.Pool{
@@ -87,13 +102,20 @@ func Handler() http.Handler {
 // instrumentation. Use the InstrumentMetricHandler function to apply the same
 // kind of instrumentation as it is used by the Handler function.
 func HandlerFor(reg prometheus.Gatherer, opts HandlerOpts) http.Handler {
-	return HandlerForTransactional(prometheus.ToTransactionalGatherer(reg), opts)
+	return handlerFor(prometheus.ToTransactionalGatherer(reg), opts, false)
 }
 
 // HandlerForTransactional is like HandlerFor, but it uses transactional gather, which
 // can safely change in-place returned *dto.MetricFamily before call to `Gather` and after
 // call to `done` of that `Gather`.
 func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerOpts) http.Handler {
+	return handlerFor(reg, opts, true)
+}
+
+// handlerFor implements HandlerForTransactional. If transactional is false,
+// the MetricFamilies returned by reg are not modified after calling done, so
+// that they can be cached for ServeStaleOnError without copying them.
+func handlerFor(reg prometheus.TransactionalGatherer, opts HandlerOpts, transactional bool) http.Handler {
 	var (
 		inFlightSem chan struct{}
 		errCnt      = prometheus.NewCounterVec(
@@ -103,11 +125,20 @@ func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerO
 			},
 			[]string{"cause"},
 		)
+		truncCnt = prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_label_values_truncated_total",
+			Help: "Total number of label values truncated by the promhttp metric handler because they exceeded the maximum length.",
+		})
+		lastScrape prometheus.Gauge // Only set if opts.EmitLastScrapeTimestamp is true.
 	)
 
 	if opts.MaxRequestsInFlight > 0 {
 		inFlightSem = make(chan struct{}, opts.MaxRequestsInFlight)
 	}
+	var cache *staleCache
+	if opts.ServeStaleOnError {
+		cache = &staleCache{copy: transactional}
+	}
 	if opts.Registry != nil {
 		// Initialize all possibilities that can occur below.
 		errCnt.WithLabelValues("gathering")
@@ -120,16 +151,44 @@ func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerO
 				panic(err)
 			}
 		}
+		if opts.MaxLabelValueLength > 0 {
+			if err := opts.Registry.Register(truncCnt); err != nil {
+				are := &prometheus.AlreadyRegisteredError{}
+				if errors.As(err, are) {
+					truncCnt = are.ExistingCollector.(prometheus.Counter)
+				} else {
+					panic(err)
+				}
+			}
+		}
+		if opts.EmitLastScrapeTimestamp {
+			lastScrape = prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "prometheus_handler_last_scrape_timestamp_seconds",
+				Help: "Unix timestamp of the last scrape completely served by the promhttp metric handler.",
+			})
+			if err := opts.Registry.Register(lastScrape); err != nil {
+				are := &prometheus.AlreadyRegisteredError{}
+				if errors.As(err, are) {
+					lastScrape = are.ExistingCollector.(prometheus.Gauge)
+				} else {
+					panic(err)
+				}
+			}
+		}
 	}
 
 	h := http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
 		if !opts.ProcessStartTime.IsZero() {
 			rsp.Header().Set(processStartTimeHeader, strconv.FormatInt(opts.ProcessStartTime.Unix(), 10))
 		}
+		// release frees the slot in inFlightSem (if any). It is handed
+		// over to a gathering that exceeded the timeout.
+		release := func() {}
+		defer func() { release() }()
 		if inFlightSem != nil {
 			select {
 			case inFlightSem <- struct{}{}: // All good, carry on.
-				defer func() { <-inFlightSem }()
+				release = func() { <-inFlightSem }
 			default:
 				http.Error(rsp, fmt.Sprintf(
 					"Limit of concurrent requests reached (%d), try again later.", opts.MaxRequestsInFlight,
@@ -137,8 +196,43 @@ func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerO
 				return
 			}
 		}
-		mfs, done, err := reg.Gather()
+		var selectors []seriesSelector
+		if opts.EnableMatchSelectors {
+			for _, param := range req.URL.Query()[matchParam] {
+				sel, err := parseSelector(param)
+				if err != nil {
+					http.Error(rsp, err.Error(), http.StatusBadRequest)
+					return
+				}
+				selectors = append(selectors, sel)
+			}
+		}
+		start := time.Now()
+		mfs, done, timedOut, err := gatherWithTimeout(reg, opts.Timeout, release)
 		defer done()
+		if timedOut {
+			release = func() {}
+			if opts.ErrorLog != nil {
+				opts.ErrorLog.Println("gathering metrics exceeded configured timeout of", opts.Timeout)
+			}
+			errCnt.WithLabelValues("gathering").Inc()
+			rsp.Header().Set(scrapeTimeoutExceededHeader, opts.Timeout.String())
+			http.Error(rsp, fmt.Sprintf(
+				"Exceeded configured timeout of %v.", opts.Timeout,
+			), http.StatusServiceUnavailable)
+			return
+		}
+		if cache != nil {
+			if err == nil {
+				cache.store(mfs)
+			} else if cached := cache.load(); cached != nil {
+				if opts.ErrorLog != nil {
+					opts.ErrorLog.Println("error gathering metrics, serving cached metrics instead:", err)
+				}
+				errCnt.WithLabelValues("gathering").Inc()
+				mfs, err = cached, nil
+			}
+		}
 		if err != nil {
 			if opts.ErrorLog != nil {
 				opts.ErrorLog.Println("error gathering metrics:", err)
@@ -158,6 +252,30 @@ func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerO
 				return
 			}
 		}
+		if len(selectors) > 0 {
+			mfs = filterBySelectors(mfs, selectors)
+		}
+		if opts.MaxLabelValueLength > 0 {
+			var truncated int
+			mfs, truncated = truncateLabelValues(mfs, opts.MaxLabelValueLength)
+			truncCnt.Add(float64(truncated))
+		}
+		if opts.SampleInterceptor != nil {
+			mfs = interceptFamilies(mfs, opts.SampleInterceptor)
+		}
+		if opts.EmitSampleCountMetric {
+			var collision bool
+			mfs, collision = appendSampleCount(mfs)
+			if collision && opts.ErrorLog != nil {
+				opts.ErrorLog.Println("not adding the sample count, as a metric family named", scrapedSamplesMetric, "has been gathered already")
+			}
+		}
+		if opts.HelpTranslator != nil {
+			mfs = translateHelp(mfs, opts.HelpTranslator)
+		}
+		if len(opts.FamilyOrder) > 0 {
+			mfs = orderFamilies(mfs, opts.FamilyOrder)
+		}
 
 		var contentType expfmt.Format
 		if opts.EnableOpenMetrics {
@@ -167,9 +285,28 @@ func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerO
 		}
 		header := rsp.Header()
 		header.Set(contentTypeHeader, string(contentType))
+		// Declaring the trailer before anything is written makes net/http
+		// use chunked transfer encoding. It is only declared once it is
+		// certain that a body will be sent, i.e. by serveWithETag if
+		// EnableETag is true.
+		var trailer string
+		if opts.EmitScrapeDurationTrailer {
+			trailer = scrapeDurationTrailer
+			if !opts.EnableETag {
+				header.Set(trailerHeader, trailer)
+			}
+		}
 
+		compress := !opts.DisableCompression && gzipAccepted(req.Header)
 		w := io.Writer(rsp)
-		if !opts.DisableCompression && gzipAccepted(req.Header) {
+		var etagBuf *bytes.Buffer
+		if opts.EnableETag {
+			// Encode into a buffer first to compute the ETag before
+			// anything is sent. Compression happens once the ETag is
+			// known, in serveWithETag.
+			etagBuf = &bytes.Buffer{}
+			w = etagBuf
+		} else if compress {
 			header.Set(contentEncodingHeader, "gzip")
 			gz := gzipPool.Get().(*gzip.Writer)
 			defer gzipPool.Put(gz)
@@ -181,6 +318,18 @@ func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerO
 		}
 
 		enc := expfmt.NewEncoder(w, contentType)
+		if strings.HasPrefix(string(contentType), expfmt.OpenMetricsType) {
+			units := internal.RegistryUnits(reg)
+			newEncoder := func(w io.Writer) expfmt.Encoder { return expfmt.NewEncoder(w, contentType) }
+			if len(units) > 0 {
+				newEncoder = func(w io.Writer) expfmt.Encoder { return &unitEncoder{w: w, units: units} }
+			}
+			if opts.EnableNativeHistogramsText {
+				enc = newNativeHistogramEncoder(w, newEncoder)
+			} else {
+				enc = newEncoder(w)
+			}
+		}
 
 		// handleError handles the error according to opts.ErrorHandling
 		// and returns true if we have to abort after the handling.
@@ -217,15 +366,67 @@ func HandlerForTransactional(reg prometheus.TransactionalGatherer, opts HandlerO
 				return
 			}
 		}
+		if etagBuf != nil {
+			if handleError(serveWithETag(rsp, req, etagBuf.Bytes(), contentType, compress, trailer)) {
+				return
+			}
+		}
+		duration := time.Since(start)
+		if opts.EmitScrapeDurationTrailer {
+			rsp.Header().Set(scrapeDurationTrailer, strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+		}
+		if opts.SlowScrapeThreshold > 0 && duration > opts.SlowScrapeThreshold && opts.ErrorLog != nil {
+			samples := 0
+			for _, mf := range mfs {
+				samples += countSamples(mf)
+			}
+			opts.ErrorLog.Println(fmt.Sprintf(
+				"slow scrape: duration=%v threshold=%v families=%d samples=%d",
+				duration, opts.SlowScrapeThreshold, len(mfs), samples,
+			))
+		}
+		if lastScrape != nil {
+			lastScrape.SetToCurrentTime()
+		}
 	})
 
-	if opts.Timeout <= 0 {
-		return h
+	return h
+}
+
+// gatherWithTimeout calls reg.Gather and returns its result. If timeout is
+// positive and the call takes longer than that, it returns with timedOut set to
+// true instead, while the call keeps running in the background so that its
+// done function is still called once it returns, followed by lateDone. The
+// caller of a timed out gatherWithTimeout must not call lateDone itself.
+func gatherWithTimeout(reg prometheus.TransactionalGatherer, timeout time.Duration, lateDone func()) (mfs []*dto.MetricFamily, done func(), timedOut bool, err error) {
+	if timeout <= 0 {
+		mfs, done, err = reg.Gather()
+		return mfs, done, false, err
+	}
+	type result struct {
+		mfs  []*dto.MetricFamily
+		done func()
+		err  error
+	}
+	// Buffered so that a late result doesn't block the goroutine forever.
+	ch := make(chan result, 1)
+	go func() {
+		mfs, done, err := reg.Gather()
+		ch <- result{mfs: mfs, done: done, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-ch:
+		return res.mfs, res.done, false, res.err
+	case <-timer.C:
+		go func() {
+			(<-ch).done()
+			lateDone()
+		}()
+		return nil, func() {}, true, nil
 	}
-	return http.TimeoutHandler(h, opts.Timeout, fmt.Sprintf(
-		"Exceeded configured timeout of %v.\n",
-		opts.Timeout,
-	))
 }
 
 // InstrumentMetricHandler is usually used with an http.Handler returned by the
@@ -351,15 +552,27 @@ type HandlerOpts struct {
 	// Service Unavailable and a suitable message in the body. If
 	// MaxRequestsInFlight is 0 or negative, no limit is applied.
 	MaxRequestsInFlight int
-	// If handling a request takes longer than Timeout, it is responded to
-	// with 503 ServiceUnavailable and a suitable Message. No timeout is
-	// applied if Timeout is 0 or negative. Note that with the current
-	// implementation, reaching the timeout simply ends the HTTP requests as
-	// described above (and even that only if sending of the body hasn't
-	// started yet), while the bulk work of gathering all the metrics keeps
-	// running in the background (with the eventual result to be thrown
-	// away). Until the implementation is improved, it is recommended to
-	// implement a separate timeout in potentially slow Collectors.
+	// If gathering the metrics for a request takes longer than Timeout, it
+	// is responded to with 503 ServiceUnavailable, a suitable message, and
+	// the header "X-Prometheus-Scrape-Timeout-Exceeded" set to the
+	// configured timeout, similar to how a Prometheus server reports a
+	// scrape that exceeded its scrape timeout. This is counted as a
+	// gathering error. No timeout is applied if Timeout is 0 or negative.
+	//
+	// Only gathering is covered by Timeout, not encoding and sending the
+	// response: unlike with http.TimeoutHandler, the response is not
+	// buffered, so once gathering has completed in time, the encoded
+	// metrics are streamed to the client as usual, and a 503 can't be sent
+	// anymore. Use the WriteTimeout of the http.Server to limit the
+	// duration of the latter.
+	//
+	// Note that reaching the timeout does not stop the bulk work of
+	// gathering the metrics, which keeps running in the background (with
+	// the eventual result to be thrown away). Such a gathering still
+	// occupies its slot of MaxRequestsInFlight until it has returned, so
+	// that hanging gatherings can't pile up beyond that limit. Therefore,
+	// it is still recommended to implement a separate timeout in
+	// potentially slow Collectors, and to set MaxRequestsInFlight.
 	Timeout time.Duration
 	// If true, the experimental OpenMetrics encoding is added to the
 	// possible options during content negotiation. Note that Prometheus
@@ -370,7 +583,34 @@ type HandlerOpts struct {
 	// a trailing ".0" if they would otherwise look like integer numbers
 	// (which changes the identity of the resulting series on the Prometheus
 	// server).
+	//
+	// With OpenMetrics, the units of metrics created with a Unit in their
+	// Opts are exposed in "# UNIT" lines. This only works for metrics
+	// registered with a prometheus.Registry (or a prometheus.Gatherers
+	// slice of such registries) passed to HandlerFor, and not for metrics
+	// of unchecked Collectors.
 	EnableOpenMetrics bool
+	// If EnableNativeHistogramsText is true (and OpenMetrics has been
+	// negotiated, see EnableOpenMetrics), the sparse buckets of native
+	// histograms are included in the OpenMetrics exposition, using the
+	// text representation proposed for OpenMetrics 2.0, e.g.:
+	//
+	//	request_duration_seconds {count:3,sum:4.5,schema:0,zero_threshold:1e-128,zero_count:1,positive_spans:[1:2],positive_buckets:[1,1]}
+	//
+	// The line for the native histogram precedes the lines for the
+	// regular buckets of the same histogram, which are omitted if there
+	// are none. Bucket counts are absolute, and exemplars of native
+	// histograms are not included.
+	//
+	// EXPERIMENTAL: OpenMetrics 2.0 is not finalized yet, and this
+	// representation might change with the specification (or be removed)
+	// without a major version bump. The exposition is still served with
+	// the OpenMetrics 1.0 content type, so parsers that don't know about
+	// the representation will fail. This is meant for debugging native
+	// histograms with tools that cannot negotiate the protobuf format
+	// (e.g. curl), not for ingestion by a Prometheus server, which should
+	// use the protobuf format.
+	EnableNativeHistogramsText bool
 	// ProcessStartTime allows setting process start timevalue that will be exposed
 	// with "Process-Start-Time-Unix" response header along with the metrics
 	// payload. This allow callers to have efficient transformations to cumulative
@@ -379,6 +619,426 @@ type HandlerOpts struct {
 	// NOTE: This feature is experimental and not covered by OpenMetrics or Prometheus
 	// exposition format.
 	ProcessStartTime time.Time
+	// If EmitScrapeDurationTrailer is true, the handler sends the time it
+	// took to gather and encode the metrics as HTTP trailer
+	// "X-Prometheus-Scrape-Duration-Seconds" after the response body. The
+	// response is sent with chunked transfer encoding, as trailers are
+	// not supported otherwise. Note that the trailer is not sent if an
+	// error (including an exceeded Timeout) aborts serving the response.
+	EmitScrapeDurationTrailer bool
+	// If SlowScrapeThreshold is positive and gathering and encoding the
+	// metrics for a request takes longer than that, a line is logged to
+	// ErrorLog (if set) with the duration, the threshold, and the number
+	// of metric families and samples served, in the form
+	// "slow scrape: duration=1.2s threshold=1s families=42 samples=1234".
+	// Requests aborted by an error are not logged this way.
+	SlowScrapeThreshold time.Duration
+	// If EnableMatchSelectors is true, the handler accepts series selectors
+	// in the PromQL syntax as (possibly repeated) "match[]" URL query
+	// parameters, like the federation endpoint of a Prometheus server,
+	// e.g. `match[]=http_requests_total{code=~"5.."}`. Only series
+	// matching at least one of the selectors are served. Without a
+	// "match[]" parameter, all series are served as usual. An invalid
+	// selector is responded to with 400 Bad Request. Note that the
+	// metric name (and thus the "__name__" label) is matched against the
+	// name of the metric family, i.e. selecting a histogram by the name
+	// of one of its "_bucket", "_sum", or "_count" series doesn't work.
+	// Metric families are still gathered completely before filtering.
+	EnableMatchSelectors bool
+	// If ServeStaleOnError is true, the handler keeps the metrics of the
+	// last gather that returned no error. If a later gather returns an
+	// error, those are served instead, with an additional gauge
+	// "scrape_from_cache" set to 1, regardless of ErrorHandling. The
+	// error is still logged and counted as described above. ErrorHandling
+	// only takes effect if no gather has been successful yet. This smooths
+	// over transient collection errors at the price of keeping the metrics
+	// in memory and serving stale values. Use the "scrape_from_cache" gauge
+	// to detect persistent errors. With HandlerForTransactional, the
+	// metrics might be modified by the TransactionalGatherer once a scrape
+	// is done, so the copy is a deep copy, made upon every successful
+	// scrape. That roughly doubles the CPU time and triples the
+	// allocations of a scrape.
+	ServeStaleOnError bool
+	// If MaxLabelValueLength is positive, label values with more runes
+	// than MaxLabelValueLength are truncated before encoding so that
+	// they have exactly MaxLabelValueLength runes, the last one being the
+	// ellipsis "…". If Registry is not nil, the truncated label values are
+	// counted by a metric "prometheus_label_values_truncated_total". This
+	// is a guard against accidentally exposing very long label values
+	// (e.g. full URLs). Note that series whose label values become
+	// identical by truncation are exposed as duplicates, which the
+	// Prometheus server will reject. Thus, it is still necessary to fix
+	// the instrumentation. The default of 0 means no limit.
+	MaxLabelValueLength int
+	// If EmitSampleCountMetric is true, the handler adds a gauge
+	// "prometheus_handler_scraped_samples" to each response, set to the
+	// number of samples in that response, including the gauge itself.
+	// Samples are counted as in the text format, i.e. a Summary results in
+	// one sample per quantile plus the "_sum" and "_count" samples, and a
+	// Histogram in one sample per bucket (including the "+Inf" bucket) plus
+	// the "_sum" and "_count" samples. This allows detecting cardinality
+	// growth from the side of the exporter, independent of the
+	// "scrape_samples_scraped" metric of the Prometheus server. If the
+	// gathered metrics already contain a metric family with the name of
+	// the gauge, the gauge is not added, and a warning is logged (if
+	// ErrorLog is set).
+	EmitSampleCountMetric bool
+	// If EnableETag is true, the handler supports conditional GET
+	// requests: It sets an "ETag" header derived from a hash of the
+	// encoded metrics (and their format), and it responds with 304 Not
+	// Modified and an empty body if the "If-None-Match" header of the
+	// request matches that ETag. To compute the hash, the handler encodes
+	// the metrics into a buffer before sending them, which costs memory
+	// proportional to the size of the response. As most metrics (counters
+	// in particular) change between scrapes, this mainly helps with
+	// mostly static metrics, e.g. an endpoint that only exposes build
+	// info, polled by a scraper that honors ETags.
+	EnableETag bool
+	// If EmitLastScrapeTimestamp is true and Registry is not nil, a gauge
+	// "prometheus_handler_last_scrape_timestamp_seconds" is registered
+	// with Registry. It is set to the current time whenever the handler has
+	// completely served a response (i.e. not if the response was an HTTP
+	// error or sending was aborted due to an encoding error). Thus, each
+	// scrape sees the time of the previous one. Combined with a push (e.g.
+	// to a Pushgateway) or a blackbox probe, this allows alerting if the
+	// process isn't scraped anymore. A failed registration causes a panic.
+	EmitLastScrapeTimestamp bool
+	// FamilyOrder is a list of metric name prefixes. If not empty, the
+	// handler sends the metric families whose names start with the first
+	// prefix first, followed by those starting with the second prefix,
+	// and so on, and finally all remaining metric families. Within each of
+	// those groups, the order of the Gatherer (i.e. sorted by name for a
+	// Registry) is kept. A metric family matching more than one prefix
+	// belongs to the group of the first matching prefix. This is purely
+	// cosmetic (e.g. to show "build_info" metrics first to a human
+	// reading the exposition) and doesn't change the meaning of the
+	// exposition in any way.
+	FamilyOrder []string
+	// If SampleInterceptor is not nil, it is called for each gathered
+	// metric family before encoding, e.g. to redact sensitive label values
+	// by replacing them with a hash. It may modify the metric family in
+	// place, including the removal of metrics. Metric families left
+	// without any metric are not sent at all. The handler calls
+	// SampleInterceptor with a deep copy of each metric family, so that
+	// the metric families of the Gatherer (which might be cached, e.g. by
+	// a TransactionalGatherer) and those cached for ServeStaleOnError are
+	// never modified. SampleInterceptor is called on every scrape, so it
+	// has to be fast, and as the copy is made on every scrape, too, it
+	// considerably increases the cost of a scrape. It is called after
+	// MaxLabelValueLength has been applied, but before the metrics for
+	// EmitSampleCountMetric are added. It must be safe for concurrent
+	// use, as concurrent scrapes might call it concurrently. Note that the
+	// interceptor might render the exposition invalid, e.g. by creating
+	// metrics with identical label sets.
+	SampleInterceptor func(mf *dto.MetricFamily)
+	// If HelpTranslator is not nil, the HELP string of each metric family
+	// is replaced by the string it returns when called with the name and
+	// the original HELP string of the metric family, e.g. to localize the
+	// HELP strings or to centralize their wording without changing the
+	// Collectors. The default is to send the HELP strings unchanged. The
+	// metric families of the Gatherer are never modified, a shallow copy is
+	// sent instead where the HELP string changes. HelpTranslator is
+	// applied after SampleInterceptor and to the metrics for
+	// EmitSampleCountMetric, too. It is called for each metric family on
+	// every scrape and concurrently for concurrent scrapes, so it has to
+	// be fast and safe for concurrent use. It has to be deterministic,
+	// i.e. return the same string for the same arguments, as a Prometheus
+	// server treats a metric family whose HELP changes between scrapes as
+	// changed metadata.
+	HelpTranslator func(name, help string) string
+}
+
+// staleCache holds a copy of the result of the last successful gather for
+// HandlerOpts.ServeStaleOnError.
+type staleCache struct {
+	copy bool // Whether to store deep copies.
+
+	mtx sync.RWMutex
+	mfs []*dto.MetricFamily
+}
+
+// store saves the provided MetricFamilies. If c.copy is true, it saves a deep
+// copy, as a TransactionalGatherer may modify them once done is called. The
+// handler never modifies gathered MetricFamilies in place, so that otherwise
+// the slice can be kept as is.
+func (c *staleCache) store(mfs []*dto.MetricFamily) {
+	cp := mfs
+	if c.copy {
+		cp = make([]*dto.MetricFamily, len(mfs))
+		for i, mf := range mfs {
+			cp[i] = proto.Clone(mf).(*dto.MetricFamily)
+		}
+	}
+	c.mtx.Lock()
+	c.mfs = cp
+	c.mtx.Unlock()
+}
+
+// load returns the cached MetricFamilies with the "scrape_from_cache" gauge
+// added, or nil if nothing has been cached yet. The cached MetricFamilies are
+// never modified, so that they can be encoded concurrently.
+func (c *staleCache) load() []*dto.MetricFamily {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.mfs == nil {
+		return nil
+	}
+	cached := make([]*dto.MetricFamily, 0, len(c.mfs)+1)
+	cached = append(cached, c.mfs...)
+	return append(cached, &dto.MetricFamily{
+		Name: proto.String(scrapeFromCacheMetric),
+		Help: proto.String("Whether the metrics have been served from the cache of the last successful gather."),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{
+			Gauge: &dto.Gauge{Value: proto.Float64(1)},
+		}},
+	})
+}
+
+// appendSampleCount returns a copy of the provided MetricFamilies with the
+// "prometheus_handler_scraped_samples" gauge for
+// HandlerOpts.EmitSampleCountMetric appended. The provided slice is not
+// modified, as it might be cached. If the provided MetricFamilies contain one
+// with the name of the gauge already, they are returned unchanged, with
+// collision set to true.
+func appendSampleCount(mfs []*dto.MetricFamily) (_ []*dto.MetricFamily, collision bool) {
+	samples := 1 // The gauge itself.
+	for _, mf := range mfs {
+		if mf.GetName() == scrapedSamplesMetric {
+			return mfs, true
+		}
+		samples += countSamples(mf)
+	}
+	result := make([]*dto.MetricFamily, 0, len(mfs)+1)
+	result = append(result, mfs...)
+	return append(result, &dto.MetricFamily{
+		Name: proto.String(scrapedSamplesMetric),
+		Help: proto.String("Number of samples in the current scrape, including this one."),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{
+			Gauge: &dto.Gauge{Value: proto.Float64(float64(samples))},
+		}},
+	}), false
+}
+
+// interceptFamilies returns deep copies of the provided MetricFamilies after
+// calling the interceptor for each of them, omitting those left without
+// metrics. The provided MetricFamilies are not modified.
+func interceptFamilies(mfs []*dto.MetricFamily, interceptor func(*dto.MetricFamily)) []*dto.MetricFamily {
+	result := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		cp := proto.Clone(mf).(*dto.MetricFamily)
+		interceptor(cp)
+		if len(cp.Metric) > 0 {
+			result = append(result, cp)
+		}
+	}
+	return result
+}
+
+// translateHelp returns a copy of the provided MetricFamilies with the HELP
+// strings translated by the provided function. Metric families whose HELP
+// string changes are replaced by a shallow copy, all others are kept. The
+// provided slice is not modified, as it might be cached.
+func translateHelp(mfs []*dto.MetricFamily, translate func(name, help string) string) []*dto.MetricFamily {
+	result := make([]*dto.MetricFamily, len(mfs))
+	for i, mf := range mfs {
+		help := translate(mf.GetName(), mf.GetHelp())
+		if help == mf.GetHelp() {
+			result[i] = mf
+			continue
+		}
+		result[i] = &dto.MetricFamily{
+			Name:   mf.Name,
+			Help:   proto.String(help),
+			Type:   mf.Type,
+			Metric: mf.Metric,
+		}
+	}
+	return result
+}
+
+// orderFamilies returns a copy of the provided MetricFamilies, ordered as
+// described for HandlerOpts.FamilyOrder. The provided slice is not modified, as
+// it might be cached.
+func orderFamilies(mfs []*dto.MetricFamily, prefixes []string) []*dto.MetricFamily {
+	rank := func(mf *dto.MetricFamily) int {
+		for i, prefix := range prefixes {
+			if strings.HasPrefix(mf.GetName(), prefix) {
+				return i
+			}
+		}
+		return len(prefixes)
+	}
+	result := make([]*dto.MetricFamily, len(mfs))
+	copy(result, mfs)
+	sort.SliceStable(result, func(i, j int) bool { return rank(result[i]) < rank(result[j]) })
+	return result
+}
+
+// countSamples returns the number of samples mf results in when encoded in the
+// text format.
+func countSamples(mf *dto.MetricFamily) int {
+	var samples int
+	for _, m := range mf.Metric {
+		switch {
+		case m.Summary != nil:
+			samples += len(m.Summary.Quantile) + 2
+		case m.Histogram != nil:
+			buckets := m.Histogram.Bucket
+			samples += len(buckets) + 2
+			if len(buckets) == 0 || !math.IsInf(buckets[len(buckets)-1].GetUpperBound(), +1) {
+				samples++ // The implicit "+Inf" bucket.
+			}
+		default:
+			samples++
+		}
+	}
+	return samples
+}
+
+// serveWithETag sets the ETag header for body and then either responds with 304
+// Not Modified (if the If-None-Match request header matches the ETag) or sends
+// body, compressed with gzip if compress is true, declaring trailer (if not
+// empty) as the Trailer header.
+func serveWithETag(rsp http.ResponseWriter, req *http.Request, body []byte, contentType expfmt.Format, compress bool, trailer string) error {
+	h := xxhash.New()
+	h.WriteString(string(contentType))
+	h.Write([]byte{0}) // Separator, never contained in a content type.
+	h.Write(body)
+	var suffix string
+	if compress {
+		// Different content encodings need different ETags.
+		suffix = "-gzip"
+	}
+	etag := fmt.Sprintf(`"%016x%s"`, h.Sum64(), suffix)
+	header := rsp.Header()
+	header.Set(etagHeader, etag)
+	if etagMatches(req.Header.Get(ifNoneMatchHeader), etag) {
+		header.Del(contentTypeHeader)
+		rsp.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	if trailer != "" {
+		header.Set(trailerHeader, trailer)
+	}
+
+	if !compress {
+		_, err := rsp.Write(body)
+		return err
+	}
+	header.Set(contentEncodingHeader, "gzip")
+	gz := gzipPool.Get().(*gzip.Writer)
+	defer gzipPool.Put(gz)
+	gz.Reset(rsp)
+	if _, err := gz.Write(body); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// etagMatches returns whether the provided If-None-Match header value matches
+// etag, using the weak comparison mandated by RFC 9110.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateLabelValues truncates all label values with more than maxLen runes
+// to maxLen runes, including a trailing ellipsis. It returns the resulting
+// MetricFamilies and the number of truncated label values. The provided
+// MetricFamilies are not modified, as their label pairs are usually shared with
+// the collected metrics. Instead, affected MetricFamilies are cloned.
+func truncateLabelValues(mfs []*dto.MetricFamily, maxLen int) ([]*dto.MetricFamily, int) {
+	var (
+		truncated int
+		result    []*dto.MetricFamily
+	)
+	for i, mf := range mfs {
+		if !hasLongLabelValue(mf, maxLen) {
+			continue
+		}
+		if result == nil {
+			result = make([]*dto.MetricFamily, len(mfs))
+			copy(result, mfs)
+		}
+		mf = proto.Clone(mf).(*dto.MetricFamily)
+		for _, m := range mf.Metric {
+			for _, lp := range m.Label {
+				if v := lp.GetValue(); utf8.RuneCountInString(v) > maxLen {
+					lp.Value = proto.String(string([]rune(v)[:maxLen-1]) + "…")
+					truncated++
+				}
+			}
+		}
+		result[i] = mf
+	}
+	if result == nil {
+		return mfs, 0
+	}
+	return result, truncated
+}
+
+func hasLongLabelValue(mf *dto.MetricFamily, maxLen int) bool {
+	for _, m := range mf.Metric {
+		for _, lp := range m.Label {
+			// The byte length is an upper bound of the rune count.
+			if v := lp.GetValue(); len(v) > maxLen && utf8.RuneCountInString(v) > maxLen {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unitEncoder is an expfmt.Encoder for the OpenMetrics format that adds
+// "# UNIT" lines for the metric families with a known unit, which the encoder
+// in expfmt doesn't support.
+type unitEncoder struct {
+	w     io.Writer
+	units map[string]string
+	buf   bytes.Buffer
+}
+
+var typeLinePrefix = []byte("# TYPE ")
+
+// Encode implements expfmt.Encoder.
+func (e *unitEncoder) Encode(mf *dto.MetricFamily) error {
+	unit, ok := e.units[mf.GetName()]
+	if !ok {
+		_, err := expfmt.MetricFamilyToOpenMetrics(e.w, mf)
+		return err
+	}
+	e.buf.Reset()
+	if _, err := expfmt.MetricFamilyToOpenMetrics(&e.buf, mf); err != nil {
+		return err
+	}
+	b := e.buf.Bytes()
+	// The TYPE line is always present. It contains the family name as
+	// required for the UNIT line, i.e. without the "_total" suffix of
+	// counters.
+	start := bytes.Index(b, typeLinePrefix)
+	end := start + bytes.IndexByte(b[start:], '\n') + 1
+	name := b[start+len(typeLinePrefix) : start+bytes.LastIndexByte(b[start:end], ' ')]
+	if _, err := e.w.Write(b[:end]); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(e.w, "# UNIT %s %s\n", name, unit); err != nil {
+		return err
+	}
+	_, err := e.w.Write(b[end:])
+	return err
+}
+
+// Close implements expfmt.Closer.
+func (e *unitEncoder) Close() error {
+	_, err := expfmt.FinalizeOpenMetrics(e.w)
+	return err
 }
 
 // gzipAccepted returns whether the client will accept gzip-encoded content.
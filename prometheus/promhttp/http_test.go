@@ -15,16 +15,21 @@ package promhttp
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/adhimaswaskita/client_golang/prometheus"
 )
@@ -71,6 +76,20 @@ func (g *mockTransactionGatherer) Gather() (_ []*dto.MetricFamily, done func(),
 	return mfs, func() { g.doneInvoked++ }, err
 }
 
+// flakyTransactionGatherer returns an error instead of gathering from g while
+// *fail is true.
+type flakyTransactionGatherer struct {
+	g    prometheus.TransactionalGatherer
+	fail *bool
+}
+
+func (g flakyTransactionGatherer) Gather() (_ []*dto.MetricFamily, done func(), err error) {
+	if *g.fail {
+		return nil, func() {}, errors.New("transient error")
+	}
+	return g.g.Gather()
+}
+
 func TestHandlerErrorHandling(t *testing.T) {
 	// Create a registry that collects a MetricFamily with two elements,
 	// another with one, and reports an error. Further down, we'll use the
@@ -328,6 +347,679 @@ func TestHandlerTimeout(t *testing.T) {
 	if got, want := w.Body.String(), "Exceeded configured timeout of 1ms.\n"; got != want {
 		t.Errorf("got body %q, want %q", got, want)
 	}
+	if got, want := w.Header().Get("X-Prometheus-Scrape-Timeout-Exceeded"), "1ms"; got != want {
+		t.Errorf("got timeout header %q, want %q", got, want)
+	}
 
 	close(c.Block) // To not leak a goroutine.
 }
+
+func TestHandlerTimeoutKeepsInFlightSlot(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := blockingCollector{Block: make(chan struct{}), CollectStarted: make(chan struct{}, 1)}
+	reg.MustRegister(c)
+	handler := HandlerFor(reg, HandlerOpts{Timeout: time.Millisecond, MaxRequestsInFlight: 1})
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, request)
+		return w.Body.String()
+	}
+	const limitBody = "Limit of concurrent requests reached (1), try again later.\n"
+
+	if got, want := scrape(), "Exceeded configured timeout of 1ms.\n"; got != want {
+		t.Fatalf("got body %q, want %q", got, want)
+	}
+	// The timed out gathering still occupies the only slot.
+	if got := scrape(); got != limitBody {
+		t.Errorf("got body %q, want %q", got, limitBody)
+	}
+
+	close(c.Block)
+	// Once the gathering has returned, the slot is free again.
+	deadline := time.Now().Add(10 * time.Second)
+	for scrape() == limitBody {
+		if time.Now().After(deadline) {
+			t.Fatal("slot not released after the timed out gathering returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandlerTimeoutNotExceeded(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cnt := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "the_count",
+		Help: "Ah-ah-ah! Thunder and lightning!",
+	})
+	reg.MustRegister(cnt)
+	handler := HandlerFor(reg, HandlerOpts{Timeout: time.Minute, EmitScrapeDurationTrailer: true})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("got HTTP status code %d, want %d", got, want)
+	}
+	if !strings.Contains(string(body), "the_count 0") {
+		t.Errorf("got body %q, want it to contain the counter", body)
+	}
+	if got := resp.Header.Get("X-Prometheus-Scrape-Timeout-Exceeded"); got != "" {
+		t.Errorf("got unexpected timeout header %q", got)
+	}
+	// The response is streamed rather than buffered, so the scrape
+	// duration arrives as a proper trailer.
+	if got := resp.Trailer.Get("X-Prometheus-Scrape-Duration-Seconds"); got == "" {
+		t.Error("scrape duration trailer missing")
+	}
+}
+
+func TestHandlerScrapeDurationTrailer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cnt := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "the_count",
+		Help: "Ah-ah-ah! Thunder and lightning!",
+	})
+	reg.MustRegister(cnt)
+
+	for _, tc := range []struct {
+		name        string
+		opts        HandlerOpts
+		wantTrailer bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", opts: HandlerOpts{EmitScrapeDurationTrailer: true}, wantTrailer: true},
+		{name: "enabled without compression", opts: HandlerOpts{EmitScrapeDurationTrailer: true, DisableCompression: true}, wantTrailer: true},
+		{name: "enabled with ETag", opts: HandlerOpts{EmitScrapeDurationTrailer: true, EnableETag: true}, wantTrailer: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(HandlerFor(reg, tc.opts))
+			defer ts.Close()
+
+			resp, err := http.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if _, err := io.ReadAll(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+
+			got := resp.Trailer.Get(scrapeDurationTrailer)
+			if !tc.wantTrailer {
+				if got != "" {
+					t.Errorf("got unexpected trailer %q", got)
+				}
+				return
+			}
+			if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+				t.Errorf("got transfer encoding %v, want chunked", resp.TransferEncoding)
+			}
+			d, err := strconv.ParseFloat(got, 64)
+			if err != nil {
+				t.Fatalf("could not parse trailer %q: %v", got, err)
+			}
+			if d <= 0 {
+				t.Errorf("got scrape duration %v, want positive value", d)
+			}
+		})
+	}
+}
+
+func TestHandlerSlowScrapeThreshold(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cnt := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "the_count",
+		Help: "Ah-ah-ah! Thunder and lightning!",
+	}, []string{"foo"})
+	cnt.WithLabelValues("a").Inc()
+	cnt.WithLabelValues("b").Inc()
+	reg.MustRegister(cnt)
+
+	for _, tc := range []struct {
+		threshold time.Duration
+		wantLog   bool
+	}{
+		{threshold: 0, wantLog: false},
+		{threshold: time.Hour, wantLog: false},
+		{threshold: time.Nanosecond, wantLog: true},
+	} {
+		logBuf := &bytes.Buffer{}
+		handler := HandlerFor(reg, HandlerOpts{
+			ErrorLog:            log.New(logBuf, "", 0),
+			SlowScrapeThreshold: tc.threshold,
+		})
+		request, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		got := logBuf.String()
+		if !tc.wantLog {
+			if got != "" {
+				t.Errorf("threshold %v: unexpected log output %q", tc.threshold, got)
+			}
+			continue
+		}
+		if !strings.HasPrefix(got, "slow scrape: duration=") || !strings.HasSuffix(got, " threshold=1ns families=1 samples=2\n") {
+			t.Errorf("threshold %v: unexpected log output %q", tc.threshold, got)
+		}
+	}
+}
+
+func TestHandlerServeStaleOnError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cnt := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "the_count",
+		Help: "Ah-ah-ah! Thunder and lightning!",
+	})
+	reg.MustRegister(cnt)
+
+	var (
+		fail  bool
+		count int
+	)
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		if fail {
+			return nil, errors.New("transient error")
+		}
+		return reg.Gather()
+	})
+	// The pooled gatherer resets the gathered MetricFamilies once done is
+	// called, so the handler has to cache a copy of them.
+	tg := flakyTransactionGatherer{
+		g:    reg.PooledGatherer(prometheus.NewGatherBufferPool()),
+		fail: &fail,
+	}
+
+	for name, handler := range map[string]http.Handler{
+		"HandlerFor":              HandlerFor(g, HandlerOpts{ServeStaleOnError: true}),
+		"HandlerForTransactional": HandlerForTransactional(tg, HandlerOpts{ServeStaleOnError: true}),
+	} {
+		scrape := func() (int, string) {
+			writer := httptest.NewRecorder()
+			request, _ := http.NewRequest("GET", "/", nil)
+			request.Header.Add("Accept", "text/plain")
+			handler.ServeHTTP(writer, request)
+			return writer.Code, writer.Body.String()
+		}
+
+		// Nothing cached yet, so the error is reported.
+		fail = true
+		if code, _ := scrape(); code != http.StatusInternalServerError {
+			t.Errorf("%s: got HTTP status code %d, want %d", name, code, http.StatusInternalServerError)
+		}
+
+		fail = false
+		cnt.Inc()
+		count++
+		wantFresh := fmt.Sprintf(`# HELP the_count Ah-ah-ah! Thunder and lightning!
+# TYPE the_count counter
+the_count %d
+`, count)
+		if code, body := scrape(); code != http.StatusOK || body != wantFresh {
+			t.Errorf("%s: got HTTP status code %d and body %q, want %d and %q", name, code, body, http.StatusOK, wantFresh)
+		}
+
+		fail = true
+		cnt.Inc()
+		count++
+		wantStale := wantFresh + `# HELP scrape_from_cache Whether the metrics have been served from the cache of the last successful gather.
+# TYPE scrape_from_cache gauge
+scrape_from_cache 1
+`
+		for i := 0; i < 2; i++ {
+			if code, body := scrape(); code != http.StatusOK || body != wantStale {
+				t.Errorf("%s: got HTTP status code %d and body %q, want %d and %q", name, code, body, http.StatusOK, wantStale)
+			}
+		}
+	}
+}
+
+func TestHandlerMaxLabelValueLength(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cnt := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Requests.",
+	}, []string{"url"})
+	cnt.WithLabelValues("/short").Inc()
+	cnt.WithLabelValues("/a/very/long/url").Inc()
+	cnt.WithLabelValues("/ünïcödé/path").Inc()
+	reg.MustRegister(cnt)
+
+	handler := HandlerFor(reg, HandlerOpts{MaxLabelValueLength: 10, Registry: reg})
+	scrape := func() string {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Add("Accept", "text/plain")
+		handler.ServeHTTP(writer, request)
+		return writer.Body.String()
+	}
+
+	want := `# HELP prometheus_label_values_truncated_total Total number of label values truncated by the promhttp metric handler because they exceeded the maximum length.
+# TYPE prometheus_label_values_truncated_total counter
+prometheus_label_values_truncated_total 0
+# HELP promhttp_metric_handler_errors_total Total number of internal errors encountered by the promhttp metric handler.
+# TYPE promhttp_metric_handler_errors_total counter
+promhttp_metric_handler_errors_total{cause="encoding"} 0
+promhttp_metric_handler_errors_total{cause="gathering"} 0
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{url="/a/very/l…"} 1
+requests_total{url="/short"} 1
+requests_total{url="/ünïcödé/…"} 1
+`
+	if got := scrape(); got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	// The truncation of the first scrape is counted, and the collected
+	// metrics are not modified.
+	want = strings.Replace(want, "prometheus_label_values_truncated_total 0", "prometheus_label_values_truncated_total 2", 1)
+	if got := scrape(); got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestHandlerEnableETag(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "Build info.",
+	})
+	reg.MustRegister(g)
+	handler := HandlerFor(reg, HandlerOpts{EnableETag: true})
+
+	scrape := func(ifNoneMatch, acceptEncoding string) *httptest.ResponseRecorder {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Add("Accept", "text/plain")
+		if ifNoneMatch != "" {
+			request.Header.Add("If-None-Match", ifNoneMatch)
+		}
+		if acceptEncoding != "" {
+			request.Header.Add("Accept-Encoding", acceptEncoding)
+		}
+		handler.ServeHTTP(writer, request)
+		return writer
+	}
+
+	first := scrape("", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("got HTTP status code %d and ETag %q, want %d and an ETag", first.Code, etag, http.StatusOK)
+	}
+	want := `# HELP build_info Build info.
+# TYPE build_info gauge
+build_info 0
+`
+	if got := first.Body.String(); got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		if rsp := scrape(ifNoneMatch, ""); rsp.Code != http.StatusNotModified || rsp.Body.Len() != 0 {
+			t.Errorf("If-None-Match %q: got HTTP status code %d and body %q, want %d and empty body", ifNoneMatch, rsp.Code, rsp.Body.String(), http.StatusNotModified)
+		}
+	}
+
+	// Compressed responses have a different ETag.
+	gz := scrape(etag, "gzip")
+	if gz.Code != http.StatusOK || gz.Header().Get("ETag") == etag || gz.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("got HTTP status code %d, ETag %q, and Content-Encoding %q for compressed response", gz.Code, gz.Header().Get("ETag"), gz.Header().Get("Content-Encoding"))
+	}
+	r, err := gzip.NewReader(gz.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(r); err != nil || string(got) != want {
+		t.Errorf("got compressed body %q (error %v), want %q", got, err, want)
+	}
+
+	// A changed metric changes the ETag.
+	g.Set(1)
+	if rsp := scrape(etag, ""); rsp.Code != http.StatusOK || rsp.Header().Get("ETag") == etag {
+		t.Errorf("got HTTP status code %d and ETag %q after change, want %d and a new ETag", rsp.Code, rsp.Header().Get("ETag"), http.StatusOK)
+	}
+
+	// The trailer is only declared if a body is sent.
+	handler = HandlerFor(reg, HandlerOpts{EnableETag: true, EmitScrapeDurationTrailer: true})
+	first = scrape("", "")
+	if got := first.Header().Get("Trailer"); got != scrapeDurationTrailer {
+		t.Errorf("got Trailer header %q, want %q", got, scrapeDurationTrailer)
+	}
+	if rsp := scrape(first.Header().Get("ETag"), ""); rsp.Code != http.StatusNotModified || rsp.Header().Get("Trailer") != "" {
+		t.Errorf("got HTTP status code %d and Trailer header %q, want %d and no Trailer header", rsp.Code, rsp.Header().Get("Trailer"), http.StatusNotModified)
+	}
+}
+
+func TestHandlerEmitLastScrapeTimestamp(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	fail := true
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		if fail {
+			return nil, errors.New("collection failed")
+		}
+		return reg.Gather()
+	})
+	handler := HandlerFor(g, HandlerOpts{Registry: reg, EmitLastScrapeTimestamp: true})
+
+	lastScrape := func() float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mf := range mfs {
+			if mf.GetName() == "prometheus_handler_last_scrape_timestamp_seconds" {
+				return mf.Metric[0].Gauge.GetValue()
+			}
+		}
+		t.Fatal("last scrape timestamp not registered")
+		return 0
+	}
+	scrape := func() int {
+		writer := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(writer, request)
+		return writer.Code
+	}
+
+	if code := scrape(); code != http.StatusInternalServerError {
+		t.Errorf("got HTTP status code %d, want %d", code, http.StatusInternalServerError)
+	}
+	if got := lastScrape(); got != 0 {
+		t.Errorf("got last scrape timestamp %f after failed scrape, want 0", got)
+	}
+
+	fail = false
+	before := float64(time.Now().UnixNano()) / 1e9
+	if code := scrape(); code != http.StatusOK {
+		t.Errorf("got HTTP status code %d, want %d", code, http.StatusOK)
+	}
+	if got := lastScrape(); got < before {
+		t.Errorf("got last scrape timestamp %f, want at least %f", got, before)
+	}
+}
+
+func TestHandlerEmitSampleCountMetric(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cnt := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Requests.",
+	}, []string{"code"})
+	cnt.WithLabelValues("200").Inc()
+	cnt.WithLabelValues("500").Inc()
+	his := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "latency_seconds",
+		Help:    "Latency.",
+		Buckets: []float64{0.1, 1},
+	})
+	sum := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "size_bytes",
+		Help:       "Size.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01},
+	})
+	reg.MustRegister(cnt, his, sum)
+
+	handler := HandlerFor(reg, HandlerOpts{EmitSampleCountMetric: true})
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Add("Accept", "text/plain")
+	handler.ServeHTTP(writer, request)
+
+	// 2 counters, 3 buckets plus _sum and _count, 2 quantiles plus _sum and
+	// _count, and the gauge itself.
+	want := `# HELP prometheus_handler_scraped_samples Number of samples in the current scrape, including this one.
+# TYPE prometheus_handler_scraped_samples gauge
+prometheus_handler_scraped_samples 12
+`
+	if got := writer.Body.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("got body %q, want suffix %q", got, want)
+	}
+	if n := strings.Count(writer.Body.String(), "\n") - strings.Count(writer.Body.String(), "# "); n != 12 {
+		t.Errorf("got %d samples in body, want 12", n)
+	}
+
+	// A gathered metric family with the same name is not duplicated.
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "prometheus_handler_scraped_samples",
+		Help: "Collides with the sample count.",
+	}))
+	var logBuf bytes.Buffer
+	handler = HandlerFor(reg, HandlerOpts{EmitSampleCountMetric: true, ErrorLog: log.New(&logBuf, "", 0)})
+	writer = httptest.NewRecorder()
+	handler.ServeHTTP(writer, request)
+	if n := strings.Count(writer.Body.String(), "# TYPE prometheus_handler_scraped_samples "); n != 1 {
+		t.Errorf("got %d metric families named prometheus_handler_scraped_samples, want 1", n)
+	}
+	if !strings.Contains(writer.Body.String(), "Collides with the sample count.") {
+		t.Errorf("gathered metric family missing in body %q", writer.Body.String())
+	}
+	if got := logBuf.String(); !strings.Contains(got, "not adding the sample count") {
+		t.Errorf("got log output %q, want warning about the name collision", got)
+	}
+}
+
+func TestHandlerFamilyOrder(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"app_requests_total", "app_build_info", "go_goroutines", "zzz", "app_errors_total"} {
+		reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "help"}))
+	}
+
+	handler := HandlerFor(reg, HandlerOpts{FamilyOrder: []string{"app_build", "go_", "app_"}})
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Add("Accept", "text/plain")
+	handler.ServeHTTP(writer, request)
+
+	var got []string
+	for _, line := range strings.Split(writer.Body.String(), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			got = append(got, strings.Fields(line)[2])
+		}
+	}
+	want := []string{"app_build_info", "go_goroutines", "app_errors_total", "app_requests_total", "zzz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got metric families in order %v, want %v", got, want)
+	}
+}
+
+func TestHandlerSampleInterceptor(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	logins := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logins_total",
+		Help: "Logins by user.",
+	}, []string{"user"})
+	logins.WithLabelValues("alice").Inc()
+	debug := prometheus.NewGauge(prometheus.GaugeOpts{Name: "debug_info", Help: "Internal."})
+	reg.MustRegister(logins, debug)
+	// Always return the same metric families to detect modifications.
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+
+	handler := HandlerFor(g, HandlerOpts{
+		SampleInterceptor: func(mf *dto.MetricFamily) {
+			if mf.GetName() == "debug_info" {
+				mf.Metric = nil
+				return
+			}
+			for _, m := range mf.Metric {
+				for _, lp := range m.Label {
+					if lp.GetName() == "user" {
+						lp.Value = proto.String("redacted")
+					}
+				}
+			}
+		},
+	})
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Add("Accept", "text/plain")
+	handler.ServeHTTP(writer, request)
+
+	want := `# HELP logins_total Logins by user.
+# TYPE logins_total counter
+logins_total{user="redacted"} 1
+`
+	if got := writer.Body.String(); got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if len(mfs[0].Metric) != 1 || mfs[1].Metric[0].Label[0].GetValue() != "alice" {
+		t.Errorf("gathered metric families were modified: %v", mfs)
+	}
+}
+
+func TestHandlerHelpTranslator(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_length", Help: "Length of the queue."}),
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "temperature_celsius", Help: "Temperature."}),
+	)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+
+	translations := map[string]string{"queue_length": "Länge der Warteschlange."}
+	handler := HandlerFor(g, HandlerOpts{
+		HelpTranslator: func(name, help string) string {
+			if tr, ok := translations[name]; ok {
+				return tr
+			}
+			return help
+		},
+	})
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Add("Accept", "text/plain")
+	handler.ServeHTTP(writer, request)
+
+	want := `# HELP queue_length Länge der Warteschlange.
+# TYPE queue_length gauge
+queue_length 0
+# HELP temperature_celsius Temperature.
+# TYPE temperature_celsius gauge
+temperature_celsius 0
+`
+	if got := writer.Body.String(); got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if got := mfs[0].GetHelp(); got != "Length of the queue." {
+		t.Errorf("gathered metric family was modified, got help %q", got)
+	}
+}
+
+func TestHandlerEnableNativeHistogramsText(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	native := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:                        "native_seconds",
+		Help:                        "Native only.",
+		NativeHistogramBucketFactor: 2,
+	}, []string{"path"})
+	native.WithLabelValues(`/a"b`).Observe(1.5)
+	native.WithLabelValues(`/a"b`).Observe(3)
+	native.WithLabelValues(`/a"b`).Observe(0)
+	dual := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:                        "dual_seconds",
+		Help:                        "Classic and native.",
+		Buckets:                     []float64{1},
+		NativeHistogramBucketFactor: 2,
+	})
+	dual.Observe(-3)
+	reg.MustRegister(native, dual, prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "Up."}))
+
+	handler := HandlerFor(reg, HandlerOpts{EnableOpenMetrics: true, EnableNativeHistogramsText: true})
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Add("Accept", "application/openmetrics-text")
+	handler.ServeHTTP(writer, request)
+
+	want := `# HELP dual_seconds Classic and native.
+# TYPE dual_seconds histogram
+dual_seconds {count:1,sum:-3.0,schema:0,zero_threshold:2.938735877055719e-39,zero_count:0,negative_spans:[2:1],negative_buckets:[1]}
+dual_seconds_bucket{le="1.0"} 1
+dual_seconds_bucket{le="+Inf"} 1
+dual_seconds_sum -3.0
+dual_seconds_count 1
+# HELP native_seconds Native only.
+# TYPE native_seconds histogram
+native_seconds{path="/a\"b"} {count:3,sum:4.5,schema:0,zero_threshold:2.938735877055719e-39,zero_count:1,positive_spans:[1:2],positive_buckets:[1,1]}
+# HELP up Up.
+# TYPE up gauge
+up 0.0
+# EOF
+`
+	if got := writer.Body.String(); got != want {
+		t.Errorf("got body\n%s\nwant\n%s", got, want)
+	}
+
+	// Without the option, only the classic representation is sent.
+	handler = HandlerFor(reg, HandlerOpts{EnableOpenMetrics: true})
+	writer = httptest.NewRecorder()
+	handler.ServeHTTP(writer, request)
+	if got := writer.Body.String(); strings.Contains(got, "schema:") {
+		t.Errorf("got native histogram text without EnableNativeHistogramsText: %s", got)
+	}
+}
+
+func TestHandlerOpenMetricsUnits(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "processed_bytes_total",
+			Help: "Processed bytes.",
+			Unit: "bytes",
+		}),
+		prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "temperature_celsius",
+			Help: "Temperature.",
+			Unit: "celsius",
+		}),
+		prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "no_unit",
+			Help: "No unit.",
+		}),
+	)
+
+	handler := HandlerFor(prometheus.Gatherers{reg}, HandlerOpts{EnableOpenMetrics: true})
+	writer := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.Header.Add("Accept", "application/openmetrics-text")
+	handler.ServeHTTP(writer, request)
+
+	want := `# HELP no_unit No unit.
+# TYPE no_unit gauge
+no_unit 0.0
+# HELP processed_bytes Processed bytes.
+# TYPE processed_bytes counter
+# UNIT processed_bytes bytes
+processed_bytes_total 0.0
+# HELP temperature_celsius Temperature.
+# TYPE temperature_celsius gauge
+# UNIT temperature_celsius celsius
+temperature_celsius 0.0
+# EOF
+`
+	if got := writer.Body.String(); got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+
+	// No UNIT lines in the text format.
+	writer = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	request.Header.Add("Accept", "text/plain")
+	handler.ServeHTTP(writer, request)
+	if got := writer.Body.String(); strings.Contains(got, "# UNIT") {
+		t.Errorf("unexpected UNIT line in text format: %q", got)
+	}
+}
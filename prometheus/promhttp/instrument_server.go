@@ -75,7 +75,9 @@ func InstrumentHandlerInFlight(g prometheus.Gauge, next http.Handler) http.Handl
 // unpartitioned observations, use an ObserverVec with zero labels. Note that
 // partitioning of Histograms is expensive and should be used judiciously.
 //
-// If the wrapped Handler does not set a status code, a status code of 200 is assumed.
+// If the wrapped Handler does not set a status code, a status code of 200 is
+// assumed. If the wrapped Handler hijacks the connection, "hijacked" is used as
+// the value of the "code" label.
 //
 // If the wrapped Handler panics, no values are reported.
 //
@@ -96,10 +98,8 @@ func InstrumentHandlerDuration(obs prometheus.ObserverVec, next http.Handler, op
 			d := newDelegator(w, nil)
 			next.ServeHTTP(d, r)
 
-			l := labels(code, method, r.Method, d.Status(), hOpts.extraMethods...)
-			for label, resolve := range hOpts.extraLabelsFromCtx {
-				l[label] = resolve(r.Context())
-			}
+			l := labels(code, method, r.Method, delegatorStatus(d), hOpts.extraMethods...)
+			hOpts.resolveDynamicLabels(l, r)
 			observeWithExemplar(obs.With(l), time.Since(now).Seconds(), hOpts.getExemplarFn(r.Context()))
 		}
 	}
@@ -108,9 +108,7 @@ func InstrumentHandlerDuration(obs prometheus.ObserverVec, next http.Handler, op
 		now := time.Now()
 		next.ServeHTTP(w, r)
 		l := labels(code, method, r.Method, 0, hOpts.extraMethods...)
-		for label, resolve := range hOpts.extraLabelsFromCtx {
-			l[label] = resolve(r.Context())
-		}
+		hOpts.resolveDynamicLabels(l, r)
 		observeWithExemplar(obs.With(l), time.Since(now).Seconds(), hOpts.getExemplarFn(r.Context()))
 	}
 }
@@ -127,7 +125,9 @@ func InstrumentHandlerDuration(obs prometheus.ObserverVec, next http.Handler, op
 // instance label names are present in the CounterVec. For unpartitioned
 // counting, use a CounterVec with zero labels.
 //
-// If the wrapped Handler does not set a status code, a status code of 200 is assumed.
+// If the wrapped Handler does not set a status code, a status code of 200 is
+// assumed. If the wrapped Handler hijacks the connection, "hijacked" is used as
+// the value of the "code" label.
 //
 // If the wrapped Handler panics, the Counter is not incremented.
 //
@@ -146,10 +146,8 @@ func InstrumentHandlerCounter(counter *prometheus.CounterVec, next http.Handler,
 			d := newDelegator(w, nil)
 			next.ServeHTTP(d, r)
 
-			l := labels(code, method, r.Method, d.Status(), hOpts.extraMethods...)
-			for label, resolve := range hOpts.extraLabelsFromCtx {
-				l[label] = resolve(r.Context())
-			}
+			l := labels(code, method, r.Method, delegatorStatus(d), hOpts.extraMethods...)
+			hOpts.resolveDynamicLabels(l, r)
 			addWithExemplar(counter.With(l), 1, hOpts.getExemplarFn(r.Context()))
 		}
 	}
@@ -158,9 +156,7 @@ func InstrumentHandlerCounter(counter *prometheus.CounterVec, next http.Handler,
 		next.ServeHTTP(w, r)
 
 		l := labels(code, method, r.Method, 0, hOpts.extraMethods...)
-		for label, resolve := range hOpts.extraLabelsFromCtx {
-			l[label] = resolve(r.Context())
-		}
+		hOpts.resolveDynamicLabels(l, r)
 		addWithExemplar(counter.With(l), 1, hOpts.getExemplarFn(r.Context()))
 	}
 }
@@ -200,9 +196,7 @@ func InstrumentHandlerTimeToWriteHeader(obs prometheus.ObserverVec, next http.Ha
 		now := time.Now()
 		d := newDelegator(w, func(status int) {
 			l := labels(code, method, r.Method, status, hOpts.extraMethods...)
-			for label, resolve := range hOpts.extraLabelsFromCtx {
-				l[label] = resolve(r.Context())
-			}
+			hOpts.resolveDynamicLabels(l, r)
 			observeWithExemplar(obs.With(l), time.Since(now).Seconds(), hOpts.getExemplarFn(r.Context()))
 		})
 		next.ServeHTTP(d, r)
@@ -223,7 +217,9 @@ func InstrumentHandlerTimeToWriteHeader(obs prometheus.ObserverVec, next http.Ha
 // unpartitioned observations, use an ObserverVec with zero labels. Note that
 // partitioning of Histograms is expensive and should be used judiciously.
 //
-// If the wrapped Handler does not set a status code, a status code of 200 is assumed.
+// If the wrapped Handler does not set a status code, a status code of 200 is
+// assumed. If the wrapped Handler hijacks the connection, "hijacked" is used as
+// the value of the "code" label.
 //
 // If the wrapped Handler panics, no values are reported.
 //
@@ -243,10 +239,8 @@ func InstrumentHandlerRequestSize(obs prometheus.ObserverVec, next http.Handler,
 			next.ServeHTTP(d, r)
 			size := computeApproximateRequestSize(r)
 
-			l := labels(code, method, r.Method, d.Status(), hOpts.extraMethods...)
-			for label, resolve := range hOpts.extraLabelsFromCtx {
-				l[label] = resolve(r.Context())
-			}
+			l := labels(code, method, r.Method, delegatorStatus(d), hOpts.extraMethods...)
+			hOpts.resolveDynamicLabels(l, r)
 			observeWithExemplar(obs.With(l), float64(size), hOpts.getExemplarFn(r.Context()))
 		}
 	}
@@ -256,9 +250,7 @@ func InstrumentHandlerRequestSize(obs prometheus.ObserverVec, next http.Handler,
 		size := computeApproximateRequestSize(r)
 
 		l := labels(code, method, r.Method, 0, hOpts.extraMethods...)
-		for label, resolve := range hOpts.extraLabelsFromCtx {
-			l[label] = resolve(r.Context())
-		}
+		hOpts.resolveDynamicLabels(l, r)
 		observeWithExemplar(obs.With(l), float64(size), hOpts.getExemplarFn(r.Context()))
 	}
 }
@@ -277,7 +269,9 @@ func InstrumentHandlerRequestSize(obs prometheus.ObserverVec, next http.Handler,
 // unpartitioned observations, use an ObserverVec with zero labels. Note that
 // partitioning of Histograms is expensive and should be used judiciously.
 //
-// If the wrapped Handler does not set a status code, a status code of 200 is assumed.
+// If the wrapped Handler does not set a status code, a status code of 200 is
+// assumed. If the wrapped Handler hijacks the connection, "hijacked" is used as
+// the value of the "code" label.
 //
 // If the wrapped Handler panics, no values are reported.
 //
@@ -295,10 +289,8 @@ func InstrumentHandlerResponseSize(obs prometheus.ObserverVec, next http.Handler
 		d := newDelegator(w, nil)
 		next.ServeHTTP(d, r)
 
-		l := labels(code, method, r.Method, d.Status(), hOpts.extraMethods...)
-		for label, resolve := range hOpts.extraLabelsFromCtx {
-			l[label] = resolve(r.Context())
-		}
+		l := labels(code, method, r.Method, delegatorStatus(d), hOpts.extraMethods...)
+		hOpts.resolveDynamicLabels(l, r)
 		observeWithExemplar(obs.With(l), float64(d.Written()), hOpts.getExemplarFn(r.Context()))
 	})
 }
@@ -389,6 +381,18 @@ func isLabelCurried(c prometheus.Collector, label string) bool {
 	return true
 }
 
+// statusHijacked is used by delegatorStatus to represent a hijacked connection.
+const statusHijacked = -1
+
+// delegatorStatus returns the status code recorded by the provided delegator, or
+// statusHijacked if the connection has been hijacked.
+func delegatorStatus(d delegator) int {
+	if d.Hijacked() {
+		return statusHijacked
+	}
+	return d.Status()
+}
+
 func labels(code, method bool, reqMethod string, status int, extraMethods ...string) prometheus.Labels {
 	labels := prometheus.Labels{}
 
@@ -473,6 +477,8 @@ func sanitizeMethod(m string, extraMethods ...string) string {
 func sanitizeCode(s int) string {
 	// See for accepted codes https://www.iana.org/assignments/http-status-codes/http-status-codes.xhtml
 	switch s {
+	case statusHijacked:
+		return "hijacked"
 	case 100:
 		return "100"
 	case 101:
@@ -19,9 +19,13 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	dto "github.com/prometheus/client_model/go"
+
 	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
 )
 
 func TestLabelCheck(t *testing.T) {
@@ -439,6 +443,36 @@ func TestMiddlewareAPI_WithExemplars(t *testing.T) {
 	assetMetricAndExemplars(t, reg, 5, labelsToLabelPair(exemplar))
 }
 
+func TestMiddlewareAPI_WithPathNormalizer(t *testing.T) {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "requests_total", Help: "Number of requests."},
+		[]string{"code", "path"},
+	)
+	normalize := func(r *http.Request) string {
+		if strings.HasPrefix(r.URL.Path, "/users/") {
+			return "/users/:id"
+		}
+		return "other"
+	}
+	handler := InstrumentHandlerCounter(counter, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("OK"))
+	}), WithPathNormalizer(normalize))
+
+	for _, path := range []string{"/users/1", "/users/2", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	want := `
+		# HELP requests_total Number of requests.
+		# TYPE requests_total counter
+		requests_total{code="200",path="/users/:id"} 2
+		requests_total{code="200",path="other"} 1
+	`
+	if err := testutil.CollectAndCompare(counter, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestInstrumentTimeToFirstWrite(t *testing.T) {
 	var i int
 	dobs := &responseWriterDelegator{
@@ -523,6 +557,72 @@ func TestInterfaceUpgrade(t *testing.T) {
 	}
 }
 
+func TestInstrumentHijackedConnection(t *testing.T) {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "requests_total",
+			Help: "Requests.",
+		},
+		[]string{"code"},
+	)
+	duration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "request_duration_seconds",
+			Help: "Request duration.",
+		},
+		[]string{"code"},
+	)
+	hijacking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		rw.Flush()
+	})
+	instrumented := InstrumentHandlerCounter(counter, InstrumentHandlerDuration(duration, hijacking))
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		instrumented.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	// The client might see the response before the instrumentation is done.
+	<-done
+
+	m := &dto.Metric{}
+	if err := counter.WithLabelValues("hijacked").Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Counter.GetValue(); got != 1 {
+		t.Errorf("got %v hijacked requests, want 1", got)
+	}
+	if got := testutil.CollectAndCount(duration); got != 1 {
+		t.Errorf("got %d duration series, want 1", got)
+	}
+	m = &dto.Metric{}
+	if err := duration.WithLabelValues("hijacked").(prometheus.Metric).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Histogram.GetSampleCount(); got != 1 {
+		t.Errorf("got %v hijacked observations, want 1", got)
+	}
+}
+
 func ExampleInstrumentHandlerDuration() {
 	inFlightGauge := prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "in_flight_requests",
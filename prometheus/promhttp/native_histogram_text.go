@@ -0,0 +1,230 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promhttp
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// nativeHistogramEncoder is an expfmt.Encoder for the OpenMetrics format that
+// adds the sparse buckets of native histograms in the experimental text
+// representation proposed for OpenMetrics 2.0, see
+// HandlerOpts.EnableNativeHistogramsText. All other metric families, and the
+// parts of native histogram families that the OpenMetrics 1.0 format can
+// represent, are encoded by encoders created with newEncoder.
+type nativeHistogramEncoder struct {
+	w          io.Writer
+	enc        expfmt.Encoder // Writes to w.
+	newEncoder func(io.Writer) expfmt.Encoder
+	buf        bytes.Buffer
+}
+
+var metadataLinePrefix = []byte("# ")
+
+func newNativeHistogramEncoder(w io.Writer, newEncoder func(io.Writer) expfmt.Encoder) *nativeHistogramEncoder {
+	return &nativeHistogramEncoder{w: w, enc: newEncoder(w), newEncoder: newEncoder}
+}
+
+// Encode implements expfmt.Encoder.
+func (e *nativeHistogramEncoder) Encode(mf *dto.MetricFamily) error {
+	if !hasNativeHistograms(mf) {
+		return e.enc.Encode(mf)
+	}
+	for i, m := range mf.Metric {
+		// Encode each metric separately to interleave the native
+		// histogram lines with the classic ones of the same metric.
+		e.buf.Reset()
+		single := &dto.MetricFamily{
+			Name:   mf.Name,
+			Help:   mf.Help,
+			Type:   mf.Type,
+			Metric: []*dto.Metric{m},
+		}
+		if err := e.newEncoder(&e.buf).Encode(single); err != nil {
+			return err
+		}
+		// The metadata lines starting with "# " precede the samples.
+		b := e.buf.Bytes()
+		n := 0
+		for bytes.HasPrefix(b[n:], metadataLinePrefix) {
+			n += bytes.IndexByte(b[n:], '\n') + 1
+		}
+		header, samples := b[:n], b[n:]
+		if i == 0 {
+			if _, err := e.w.Write(header); err != nil {
+				return err
+			}
+		}
+		h := m.GetHistogram()
+		if isNativeHistogram(h) {
+			if _, err := io.WriteString(e.w, nativeHistogramLine(mf.GetName(), m)); err != nil {
+				return err
+			}
+			if len(h.Bucket) == 0 {
+				// No classic buckets, so the classic lines would
+				// only contain a meaningless +Inf bucket.
+				continue
+			}
+		}
+		if _, err := e.w.Write(samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements expfmt.Closer.
+func (e *nativeHistogramEncoder) Close() error {
+	if closer, ok := e.enc.(expfmt.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func hasNativeHistograms(mf *dto.MetricFamily) bool {
+	if t := mf.GetType(); t != dto.MetricType_HISTOGRAM && t != dto.MetricType_GAUGE_HISTOGRAM {
+		return false
+	}
+	for _, m := range mf.Metric {
+		if isNativeHistogram(m.GetHistogram()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNativeHistogram returns true if h has sparse buckets, following the same
+// logic a Prometheus server uses to detect a native histogram.
+func isNativeHistogram(h *dto.Histogram) bool {
+	return h.GetSampleCountFloat() > 0 ||
+		len(h.GetPositiveSpan()) > 0 || len(h.GetNegativeSpan()) > 0 ||
+		h.GetZeroThreshold() > 0 || h.GetZeroCount() > 0 || h.GetZeroCountFloat() > 0
+}
+
+// nativeHistogramLine returns the line (including the trailing newline) for the
+// native histogram of m, e.g.
+//
+//	name{label="value"} {count:3,sum:4.5,schema:0,zero_threshold:1e-128,zero_count:1,positive_spans:[1:2],positive_buckets:[1,1]}
+//
+// The bucket counts are absolute counts (rather than the deltas of the
+// protobuf format). Exemplars are not included.
+func nativeHistogramLine(name string, m *dto.Metric) string {
+	h := m.GetHistogram()
+	var b strings.Builder
+	b.WriteString(name)
+	if len(m.Label) > 0 {
+		b.WriteByte('{')
+		for i, lp := range m.Label {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(lp.GetName())
+			b.WriteString(`="`)
+			b.WriteString(labelValueEscaper.Replace(lp.GetValue()))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteString(" {count:")
+	if h.SampleCountFloat != nil {
+		b.WriteString(formatOpenMetricsFloat(h.GetSampleCountFloat()))
+	} else {
+		b.WriteString(strconv.FormatUint(h.GetSampleCount(), 10))
+	}
+	b.WriteString(",sum:")
+	b.WriteString(formatOpenMetricsFloat(h.GetSampleSum()))
+	b.WriteString(",schema:")
+	b.WriteString(strconv.Itoa(int(h.GetSchema())))
+	b.WriteString(",zero_threshold:")
+	b.WriteString(formatOpenMetricsFloat(h.GetZeroThreshold()))
+	b.WriteString(",zero_count:")
+	if h.ZeroCountFloat != nil {
+		b.WriteString(formatOpenMetricsFloat(h.GetZeroCountFloat()))
+	} else {
+		b.WriteString(strconv.FormatUint(h.GetZeroCount(), 10))
+	}
+	writeSparseBuckets(&b, "negative", h.NegativeSpan, h.NegativeDelta, h.NegativeCount)
+	writeSparseBuckets(&b, "positive", h.PositiveSpan, h.PositiveDelta, h.PositiveCount)
+	b.WriteByte('}')
+	if m.TimestampMs != nil {
+		b.WriteByte(' ')
+		b.WriteString(formatOpenMetricsFloat(float64(m.GetTimestampMs()) / 1000))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// writeSparseBuckets writes the spans and absolute bucket counts with the
+// provided prefix ("positive" or "negative"), or nothing if there are no
+// spans.
+func writeSparseBuckets(b *strings.Builder, prefix string, spans []*dto.BucketSpan, deltas []int64, counts []float64) {
+	if len(spans) == 0 {
+		return
+	}
+	b.WriteString("," + prefix + "_spans:[")
+	for i, s := range spans {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(int(s.GetOffset())))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatUint(uint64(s.GetLength()), 10))
+	}
+	b.WriteString("]," + prefix + "_buckets:[")
+	if len(counts) > 0 {
+		for i, c := range counts {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(formatOpenMetricsFloat(c))
+		}
+	} else {
+		var count int64
+		for i, d := range deltas {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			count += d
+			b.WriteString(strconv.FormatInt(count, 10))
+		}
+	}
+	b.WriteByte(']')
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// formatOpenMetricsFloat formats f in the same way as the OpenMetrics encoder
+// of the expfmt package.
+func formatOpenMetricsFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, +1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, "e.") {
+		s += ".0"
+	}
+	return s
+}
@@ -15,6 +15,7 @@ package promhttp
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/adhimaswaskita/client_golang/prometheus"
 )
@@ -28,11 +29,15 @@ type Option interface {
 // Context can be filled with values from request through middleware.
 type LabelValueFromCtx func(ctx context.Context) string
 
+// pathLabel is the name of the label set by WithPathNormalizer.
+const pathLabel = "path"
+
 // options store options for both a handler or round tripper.
 type options struct {
 	extraMethods       []string
 	getExemplarFn      func(requestCtx context.Context) prometheus.Labels
 	extraLabelsFromCtx map[string]LabelValueFromCtx
+	pathNormalizer     func(*http.Request) string
 }
 
 func defaultOptions() *options {
@@ -48,10 +53,24 @@ func (o *options) emptyDynamicLabels() prometheus.Labels {
 	for label := range o.extraLabelsFromCtx {
 		labels[label] = ""
 	}
+	if o.pathNormalizer != nil {
+		labels[pathLabel] = ""
+	}
 
 	return labels
 }
 
+// resolveDynamicLabels sets the values of the dynamic labels for the provided
+// request in l.
+func (o *options) resolveDynamicLabels(l prometheus.Labels, r *http.Request) {
+	for label, resolve := range o.extraLabelsFromCtx {
+		l[label] = resolve(r.Context())
+	}
+	if o.pathNormalizer != nil {
+		l[pathLabel] = o.pathNormalizer(r)
+	}
+}
+
 type optionApplyFunc func(*options)
 
 func (o optionApplyFunc) apply(opt *options) { o(opt) }
@@ -82,3 +101,21 @@ func WithLabelFromCtx(name string, valueFn LabelValueFromCtx) Option {
 		o.extraLabelsFromCtx[name] = valueFn
 	})
 }
+
+// WithPathNormalizer registers a function that derives the value of a "path"
+// label from the request, e.g. the templated route "/users/:id" for the request
+// path "/users/42". The instrumented ObserverVec or CounterVec must have a
+// "path" label then. The label name is fixed. For a differently named label,
+// use WithLabelFromCtx instead. Using the raw request path as the label value
+// would create a new series for each distinct path, e.g. for each user ID,
+// which is why such a function has to map the unbounded set of request paths
+// onto a small, bounded set of values (including a catch-all value for
+// unknown paths). It is called once for each observation, whenever the
+// instrumenting middleware observes (e.g. upon the WriteHeader call for
+// InstrumentHandlerTimeToWriteHeader, but after the wrapped Handler or
+// RoundTripper has returned for most others), so it has to be cheap.
+func WithPathNormalizer(normalizer func(*http.Request) string) Option {
+	return optionApplyFunc(func(o *options) {
+		o.pathNormalizer = normalizer
+	})
+}
@@ -0,0 +1,316 @@
+// Copyright 2016 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promhttp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// matchParam is the URL query parameter for series selectors, see
+// HandlerOpts.EnableMatchSelectors.
+const matchParam = "match[]"
+
+type matchType int
+
+const (
+	matchEqual matchType = iota
+	matchNotEqual
+	matchRegexp
+	matchNotRegexp
+)
+
+// labelMatcher matches the value of the label with the given name, following
+// the semantics of PromQL label matchers. A missing label has the empty value.
+type labelMatcher struct {
+	name  string
+	typ   matchType
+	value string
+	re    *regexp.Regexp // Only set for matchRegexp and matchNotRegexp.
+}
+
+func (m labelMatcher) matches(v string) bool {
+	switch m.typ {
+	case matchEqual:
+		return v == m.value
+	case matchNotEqual:
+		return v != m.value
+	case matchRegexp:
+		return m.re.MatchString(v)
+	case matchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	panic(fmt.Errorf("unknown match type %d", m.typ))
+}
+
+// seriesSelector is a parsed PromQL series selector like
+// `http_requests_total{code=~"5..",method!="GET"}`. A series matches if all
+// matchers match.
+type seriesSelector []labelMatcher
+
+func (s seriesSelector) matches(name string, m *dto.Metric) bool {
+	for _, lm := range s {
+		var v string
+		if lm.name == model.MetricNameLabel {
+			v = name
+		} else {
+			for _, lp := range m.Label {
+				if lp.GetName() == lm.name {
+					v = lp.GetValue()
+					break
+				}
+			}
+		}
+		if !lm.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSelector parses a series selector in the PromQL syntax, i.e. an optional
+// metric name followed by an optional list of label matchers in curly braces.
+// Like in PromQL, at least one matcher must not match the empty string.
+func parseSelector(input string) (seriesSelector, error) {
+	p := selectorParser{input: input}
+	sel, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid series selector %q: %w", input, err)
+	}
+	return sel, nil
+}
+
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) parse() (seriesSelector, error) {
+	var sel seriesSelector
+	p.skipSpace()
+	name := p.identifier(true)
+	if name != "" {
+		sel = append(sel, labelMatcher{name: model.MetricNameLabel, typ: matchEqual, value: name})
+		p.skipSpace()
+	}
+	if p.consume('{') {
+		for {
+			p.skipSpace()
+			if p.consume('}') {
+				break
+			}
+			m, err := p.matcher()
+			if err != nil {
+				return nil, err
+			}
+			if m.name == model.MetricNameLabel && name != "" {
+				return nil, errors.New("metric name must not be set twice")
+			}
+			sel = append(sel, m)
+			p.skipSpace()
+			if p.consume(',') {
+				continue
+			}
+			if !p.consume('}') {
+				return nil, p.errorf("expected ',' or '}'")
+			}
+			break
+		}
+		p.skipSpace()
+	}
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected character %q", p.input[p.pos])
+	}
+	for _, m := range sel {
+		if !m.matches("") {
+			return sel, nil
+		}
+	}
+	return nil, errors.New("selector must contain at least one matcher that does not match the empty string")
+}
+
+func (p *selectorParser) matcher() (labelMatcher, error) {
+	name := p.identifier(false)
+	if name == "" {
+		return labelMatcher{}, p.errorf("expected label name")
+	}
+	p.skipSpace()
+	var m labelMatcher
+	m.name = name
+	switch {
+	case p.consumeString("=~"):
+		m.typ = matchRegexp
+	case p.consumeString("!~"):
+		m.typ = matchNotRegexp
+	case p.consumeString("!="):
+		m.typ = matchNotEqual
+	case p.consumeString("="):
+		m.typ = matchEqual
+	default:
+		return labelMatcher{}, p.errorf("expected label matching operator")
+	}
+	p.skipSpace()
+	value, err := p.stringLiteral()
+	if err != nil {
+		return labelMatcher{}, err
+	}
+	m.value = value
+	if m.typ == matchRegexp || m.typ == matchNotRegexp {
+		// Regular expressions are fully anchored, as in PromQL.
+		if m.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return labelMatcher{}, err
+		}
+	}
+	return m, nil
+}
+
+// identifier consumes and returns a label name or (if metricName is true) a
+// metric name, or returns "" if there is none at the current position.
+func (p *selectorParser) identifier(metricName bool) string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(metricName && c == ':') || (p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// stringLiteral consumes a string literal in double quotes, single quotes, or
+// backticks, with the same escaping rules as in PromQL (and Go), and returns
+// its value.
+func (p *selectorParser) stringLiteral() (string, error) {
+	if p.pos >= len(p.input) {
+		return "", p.errorf("expected string literal")
+	}
+	quote := p.input[p.pos]
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return "", p.errorf("expected string literal")
+	}
+	start := p.pos
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		switch c := p.input[p.pos]; {
+		case c == '\\' && quote != '`':
+			p.pos++
+		case c == quote:
+			p.pos++
+			lit := p.input[start:p.pos]
+			if quote == '`' {
+				return lit[1 : len(lit)-1], nil
+			}
+			value, err := unquote(lit[1:len(lit)-1], quote)
+			if err != nil {
+				return "", fmt.Errorf("invalid string literal %s: %w", p.input[start:p.pos], err)
+			}
+			return value, nil
+		}
+	}
+	return "", errors.New("unterminated string literal")
+}
+
+// unquote returns the value of the content s of a string literal in double or
+// single quotes, as provided by quote. Like in PromQL, both \" and \' are valid
+// escape sequences within either kind of quotes, which strconv.Unquote doesn't
+// accept.
+func unquote(s string, quote byte) (string, error) {
+	var b strings.Builder
+	for len(s) > 0 {
+		if len(s) >= 2 && s[0] == '\\' && (s[1] == '"' || s[1] == '\'') {
+			b.WriteByte(s[1])
+			s = s[2:]
+			continue
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(s, quote)
+		if err != nil {
+			return "", err
+		}
+		if r < utf8.RuneSelf || !multibyte {
+			b.WriteByte(byte(r))
+		} else {
+			b.WriteRune(r)
+		}
+		s = tail
+	}
+	return b.String(), nil
+}
+
+func (p *selectorParser) skipSpace() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\n\r", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *selectorParser) consume(c byte) bool {
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) consumeString(s string) bool {
+	if strings.HasPrefix(p.input[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// filterBySelectors returns the provided MetricFamilies with only those metrics
+// that match at least one of the provided selectors. MetricFamilies without any
+// matching metrics are dropped. The provided MetricFamilies are not modified,
+// as they might be cached.
+func filterBySelectors(mfs []*dto.MetricFamily, selectors []seriesSelector) []*dto.MetricFamily {
+	result := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		var metrics []*dto.Metric
+		for _, m := range mf.Metric {
+			for _, sel := range selectors {
+				if sel.matches(mf.GetName(), m) {
+					metrics = append(metrics, m)
+					break
+				}
+			}
+		}
+		switch len(metrics) {
+		case 0:
+			continue
+		case len(mf.Metric):
+			result = append(result, mf)
+		default:
+			result = append(result, &dto.MetricFamily{
+				Name:   mf.Name,
+				Help:   mf.Help,
+				Type:   mf.Type,
+				Metric: metrics,
+			})
+		}
+	}
+	return result
+}
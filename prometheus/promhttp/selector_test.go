@@ -0,0 +1,165 @@
+// Copyright 2016 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestParseSelector(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		wantErr bool
+		matches map[string]bool // Label set in text form to expected match.
+	}{
+		{input: `up`, matches: map[string]bool{`up`: true, `down`: false}},
+		{input: ` up { } `, matches: map[string]bool{`up`: true}},
+		{input: `{__name__=~"u.|d.*"}`, matches: map[string]bool{`up`: true, `down`: true, `upper`: false}},
+		{input: `up{job="a",}`, matches: map[string]bool{`up{job="a"}`: true, `up{job="b"}`: false, `up`: false}},
+		{input: `up{job!="a"}`, matches: map[string]bool{`up{job="a"}`: false, `up{job="b"}`: true, `up`: true}},
+		{input: `{job=~'a|b', code!~"5.."}`, matches: map[string]bool{`up{job="a"}`: true, `up{job="a",code="500"}`: false, `up{job="c"}`: false}},
+		{input: "{job=`a\\b`}", matches: map[string]bool{`up{job="a\\b"}`: true}},
+		{input: `{job="a\"b"}`, matches: map[string]bool{`up{job="a\"b"}`: true}},
+		{input: `{job='it\'s'}`, matches: map[string]bool{`up{job="it's"}`: true}},
+		{input: `foo{a='\"'}`, matches: map[string]bool{`foo{a="\""}`: true}},
+		{input: `{job="it\'s"}`, matches: map[string]bool{`up{job="it's"}`: true}},
+		{input: `{job='a"b\tc\u00e4'}`, matches: map[string]bool{`up{job="a\"b\tcä"}`: true}},
+		{input: `{job='a\qb'}`, wantErr: true},
+		{input: ``, wantErr: true},
+		{input: `{}`, wantErr: true},
+		{input: `{job=""}`, wantErr: true},
+		{input: `{job=~".*"}`, wantErr: true},
+		{input: `up{__name__="up"}`, wantErr: true},
+		{input: `up{job="a"`, wantErr: true},
+		{input: `up{job="a}`, wantErr: true},
+		{input: `up{job=a}`, wantErr: true},
+		{input: `up{job=~"("}`, wantErr: true},
+		{input: `up{job~"a"}`, wantErr: true},
+		{input: `up{1job="a"}`, wantErr: true},
+		{input: `up foo`, wantErr: true},
+	} {
+		sel, err := parseSelector(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.input, err)
+			continue
+		}
+		for series, want := range tc.matches {
+			name, m := parseSeries(t, series)
+			if got := sel.matches(name, m); got != want {
+				t.Errorf("%s: got match %t for %s, want %t", tc.input, got, series, want)
+			}
+		}
+	}
+}
+
+// parseSeries parses series like `up{job="a"}` by (ab)using parseSelector.
+func parseSeries(t *testing.T, series string) (string, *dto.Metric) {
+	t.Helper()
+	sel, err := parseSelector(series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &dto.Metric{}
+	for _, lm := range sel[1:] {
+		m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(lm.name), Value: proto.String(lm.value)})
+	}
+	return sel[0].value, m
+}
+
+func TestHandlerMatchSelectors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Requests.",
+	}, []string{"code"})
+	requests.WithLabelValues("200").Add(2)
+	requests.WithLabelValues("500").Add(1)
+	up := prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "Up."})
+	reg.MustRegister(requests, up)
+
+	for _, tc := range []struct {
+		opts     HandlerOpts
+		matches  []string
+		wantCode int
+		want     []string
+		dontWant []string
+	}{
+		{
+			opts:     HandlerOpts{EnableMatchSelectors: true},
+			wantCode: http.StatusOK,
+			want:     []string{`requests_total{code="200"} 2`, `requests_total{code="500"} 1`, `up 0`},
+		},
+		{
+			opts:     HandlerOpts{EnableMatchSelectors: true},
+			matches:  []string{`requests_total{code=~"5.."}`},
+			wantCode: http.StatusOK,
+			want:     []string{`requests_total{code="500"} 1`},
+			dontWant: []string{`code="200"`, `up`},
+		},
+		{
+			opts:     HandlerOpts{EnableMatchSelectors: true},
+			matches:  []string{`requests_total{code="200"}`, `up`},
+			wantCode: http.StatusOK,
+			want:     []string{`requests_total{code="200"} 2`, `up 0`},
+			dontWant: []string{`code="500"`},
+		},
+		{
+			opts:     HandlerOpts{EnableMatchSelectors: true},
+			matches:  []string{`requests_total{`},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			// Without EnableMatchSelectors, the parameter is ignored.
+			matches:  []string{`requests_total{`},
+			wantCode: http.StatusOK,
+			want:     []string{`requests_total{code="200"} 2`, `requests_total{code="500"} 1`, `up 0`},
+		},
+	} {
+		query := url.Values{matchParam: tc.matches}
+		request := httptest.NewRequest("GET", "/metrics?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		HandlerFor(reg, tc.opts).ServeHTTP(w, request)
+
+		if w.Code != tc.wantCode {
+			t.Errorf("match[]=%q: got HTTP status code %d, want %d", tc.matches, w.Code, tc.wantCode)
+			continue
+		}
+		body := w.Body.String()
+		for _, want := range tc.want {
+			if !strings.Contains(body, "\n"+want+"\n") {
+				t.Errorf("match[]=%q: body %q does not contain %q", tc.matches, body, want)
+			}
+		}
+		for _, dontWant := range tc.dontWant {
+			if strings.Contains(body, dontWant) {
+				t.Errorf("match[]=%q: body %q contains %q", tc.matches, body, dontWant)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// WriteProtoDelimited writes the provided MetricFamilies to w in the delimited
+// protobuf exposition format, i.e. each MetricFamily is encoded as a protobuf
+// message prefixed by its varint-encoded length. This is the same format
+// served by promhttp for the Content-Type
+// "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited",
+// but without any HTTP involved, e.g. to transport metrics between processes
+// over a pipe. It returns the number of bytes written and the first error
+// encountered, if any.
+//
+// The result of a Gather call can be passed in directly. The MetricFamilies are
+// written as they are, without any consistency checks.
+func WriteProtoDelimited(w io.Writer, mfs []*dto.MetricFamily) (int, error) {
+	written := 0
+	for _, mf := range mfs {
+		n, err := protodelim.MarshalTo(w, mf)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// ReadProtoDelimited reads MetricFamilies in the delimited protobuf exposition
+// format from r until EOF, as written by WriteProtoDelimited (or served by
+// promhttp). The MetricFamilies are returned in the order they were read. The
+// size of a single encoded MetricFamily is not limited.
+//
+// If r ends in the middle of a MetricFamily or contains anything that can't be
+// decoded, the MetricFamilies read so far are returned together with an error.
+func ReadProtoDelimited(r io.Reader) ([]*dto.MetricFamily, error) {
+	br, ok := r.(protodelim.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	opts := protodelim.UnmarshalOptions{MaxSize: -1}
+	var mfs []*dto.MetricFamily
+	for {
+		mf := &dto.MetricFamily{}
+		if err := opts.UnmarshalFrom(br, mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return mfs, nil
+			}
+			return mfs, fmt.Errorf("error decoding metric family %d: %w", len(mfs), err)
+		}
+		mfs = append(mfs, mf)
+	}
+}
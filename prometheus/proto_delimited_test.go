@@ -0,0 +1,93 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestProtoDelimitedRoundTrip(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cv := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_total",
+		Help: "A test counter.",
+	}, []string{"label"})
+	cv.WithLabelValues("a").Add(1)
+	cv.WithLabelValues("b").Add(2)
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "test_histogram",
+		Help:    "A test histogram.",
+		Buckets: []float64{1, 2},
+	})
+	h.Observe(1.5)
+	reg.MustRegister(cv, h)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := prometheus.WriteProtoDelimited(&buf, mfs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != buf.Len() {
+		t.Errorf("reported %d bytes written, got %d", n, buf.Len())
+	}
+
+	// The output must be identical to what expfmt produces.
+	var want bytes.Buffer
+	enc := expfmt.NewEncoder(&want, expfmt.FmtProtoDelim)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Error("output differs from the expfmt encoding")
+	}
+
+	got, err := prometheus.ReadProtoDelimited(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(mfs) {
+		t.Fatalf("read %d metric families, want %d", len(got), len(mfs))
+	}
+	for i := range mfs {
+		if !proto.Equal(got[i], mfs[i]) {
+			t.Errorf("metric family %d: got %v, want %v", i, got[i], mfs[i])
+		}
+	}
+
+	// A truncated input returns what could be read and an error.
+	got, err = prometheus.ReadProtoDelimited(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	if err == nil {
+		t.Error("expected error for truncated input")
+	}
+	if len(got) != len(mfs)-1 {
+		t.Errorf("read %d metric families from truncated input, want %d", len(got), len(mfs)-1)
+	}
+
+	if got, err := prometheus.ReadProtoDelimited(&bytes.Buffer{}); err != nil || len(got) != 0 {
+		t.Errorf("expected no metric families and no error for empty input, got %v, %v", got, err)
+	}
+}
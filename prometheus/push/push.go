@@ -37,6 +37,7 @@ package push
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -44,6 +45,8 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
@@ -77,6 +80,7 @@ type Pusher struct {
 	registerer prometheus.Registerer
 
 	client             HTTPDoer
+	customClient       bool // Whether the client has been set with Client.
 	header             http.Header
 	useBasicAuth       bool
 	username, password string
@@ -113,6 +117,40 @@ func New(url, job string) *Pusher {
 	}
 }
 
+var (
+	http1TransportOnce sync.Once
+	http1Transport     *http.Transport
+)
+
+// getHTTP1Transport returns the transport used by the default client of all
+// Pushers after UseHTTP2(false). It is a copy of http.DefaultTransport with
+// HTTP/2 disabled, created upon first use. Like http.DefaultTransport for HTTP/2,
+// it is shared so that all Pushers use the same pool of idle connections.
+func getHTTP1Transport() *http.Transport {
+	http1TransportOnce.Do(func() {
+		if t, ok := http.DefaultTransport.(*http.Transport); ok {
+			http1Transport = t.Clone()
+		} else {
+			http1Transport = &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				MaxIdleConns:          100,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: 1 * time.Second,
+			}
+		}
+		http1Transport.ForceAttemptHTTP2 = false
+		if c := http1Transport.TLSClientConfig; c != nil {
+			// http.DefaultTransport adds "h2" to NextProtos once used
+			// for HTTPS, which must not be negotiated anymore.
+			c.NextProtos = nil
+		}
+		// A non-nil, empty map disables HTTP/2.
+		http1Transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	})
+	return http1Transport
+}
+
 // Push collects/gathers all metrics from all Collectors and Gatherers added to
 // this Pusher. Then, it pushes them to the Pushgateway configured while
 // creating this Pusher, using the configured job name and any added grouping
@@ -199,6 +237,30 @@ func (p *Pusher) Grouping(name, value string) *Pusher {
 // Since *http.Client naturally implements that interface, it can still be used normally.
 func (p *Pusher) Client(c HTTPDoer) *Pusher {
 	p.client = c
+	p.customClient = true
+	return p
+}
+
+// UseHTTP2 defines whether the default HTTP client of the Pusher attempts to
+// use HTTP/2, which is the case by default. HTTP/2 is negotiated during the TLS
+// handshake and is therefore only used for HTTPS URLs and only if the
+// Pushgateway (or a proxy in front of it) supports it. With HTTP/1.1 and
+// HTTP/2 alike, connections are kept alive and reused by subsequent pushes,
+// which is beneficial for frequent pushes. The default client uses
+// http.DefaultTransport, and after UseHTTP2(false) a copy of it with HTTP/2
+// disabled, which is shared by all Pushers. UseHTTP2 has no effect if a custom
+// client has been set with the Client method. It must not be called
+// concurrently with pushing. For convenience, this method returns a pointer to
+// the Pusher itself.
+func (p *Pusher) UseHTTP2(enabled bool) *Pusher {
+	if p.customClient {
+		return p
+	}
+	if enabled {
+		p.client = &http.Client{}
+	} else {
+		p.client = &http.Client{Transport: getHTTP1Transport()}
+	}
 	return p
 }
 
@@ -221,9 +283,13 @@ func (p *Pusher) BasicAuth(username, password string) *Pusher {
 
 // Format configures the Pusher to use an encoding format given by the
 // provided expfmt.Format. The default format is expfmt.FmtProtoDelim and
-// should be used with the standard Prometheus Pushgateway. Custom
-// implementations may require different formats. For convenience, this
-// method returns a pointer to the Pusher itself.
+// should be used with the standard Prometheus Pushgateway. It is the only
+// format able to carry native histograms and the exemplars of all metric types.
+// The text format (expfmt.FmtText), which the Pushgateway accepts as well,
+// silently drops them. Custom implementations may require different formats.
+// If the receiving end responds with 415 Unsupported Media Type, pushing
+// returns an error naming the rejected format. For convenience, this method
+// returns a pointer to the Pusher itself.
 func (p *Pusher) Format(format expfmt.Format) *Pusher {
 	p.expfmt = format
 	return p
@@ -254,7 +320,7 @@ func (p *Pusher) Delete() error {
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 	if resp.StatusCode != http.StatusAccepted {
 		body, _ := io.ReadAll(resp.Body) // Ignore any further error as this is for an error message only.
 		return fmt.Errorf("unexpected status code %d while deleting %s: %s", resp.StatusCode, p.fullURL(), body)
@@ -308,7 +374,10 @@ func (p *Pusher) push(ctx context.Context, method string) error {
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
+	if resp.StatusCode == http.StatusUnsupportedMediaType {
+		return fmt.Errorf("pushing to %s failed as the content type %q is not supported, see Pusher.Format", p.fullURL(), p.expfmt)
+	}
 	// Depending on version and configuration of the PGW, StatusOK or StatusAccepted may be returned.
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
 		body, _ := io.ReadAll(resp.Body) // Ignore any further error as this is for an error message only.
@@ -317,6 +386,13 @@ func (p *Pusher) push(ctx context.Context, method string) error {
 	return nil
 }
 
+// closeBody reads the remaining response body before closing it, which is
+// required to reuse the connection for the next request.
+func closeBody(body io.ReadCloser) {
+	io.Copy(io.Discard, body) // Errors only affect connection reuse.
+	body.Close()
+}
+
 // fullURL assembles the URL used to push/delete metrics and returns it as a
 // string. The job name and any grouping label values containing a '/' will
 // trigger a base64 encoding of the affected component and proper suffixing of
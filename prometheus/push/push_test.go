@@ -15,10 +15,15 @@ package push
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/prometheus/common/expfmt"
@@ -307,3 +312,149 @@ func TestPush(t *testing.T) {
 		t.Error("empty Authorization header")
 	}
 }
+
+func TestPushConnectionReuse(t *testing.T) {
+	var newConns int
+	pgw := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		// Per the net/http documentation, the connection can only
+		// be reused if the body is read completely.
+		w.Write(bytes.Repeat([]byte("x"), 64<<10))
+	}))
+	pgw.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns++
+		}
+	}
+	pgw.Start()
+	defer pgw.Close()
+
+	p := New(pgw.URL, "testjob").Collector(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_total",
+		Help: "help",
+	}))
+	for i := 0; i < 5; i++ {
+		if err := p.Add(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if newConns != 1 {
+		t.Errorf("got %d connections for 5 pushes, want 1", newConns)
+	}
+}
+
+func TestPushFormat(t *testing.T) {
+	var lastContentType string
+	pgw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastContentType = r.Header.Get("Content-Type")
+		if lastContentType == string(expfmt.FmtOpenMetrics_1_0_0) {
+			http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer pgw.Close()
+
+	p := New(pgw.URL, "testjob").Collector(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_total",
+		Help: "help",
+	}))
+	for _, format := range []expfmt.Format{expfmt.FmtProtoDelim, expfmt.FmtText} {
+		if err := p.Format(format).Push(); err != nil {
+			t.Errorf("format %s: unexpected error: %v", format, err)
+		}
+		if lastContentType != string(format) {
+			t.Errorf("format %s: got content type %q", format, lastContentType)
+		}
+	}
+	err := p.Format(expfmt.FmtOpenMetrics_1_0_0).Push()
+	if err == nil || !strings.Contains(err.Error(), "is not supported") {
+		t.Errorf("expected error about unsupported content type, got %v", err)
+	}
+}
+
+func TestPushUseHTTP2(t *testing.T) {
+	var lastProto int
+	pgw := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastProto = r.ProtoMajor
+		w.WriteHeader(http.StatusOK)
+	}))
+	pgw.EnableHTTP2 = true
+	pgw.StartTLS()
+	defer pgw.Close()
+
+	// Trust the certificate of the test server by means of a replaced
+	// http.DefaultTransport, which is also the base of the HTTP/1 transport.
+	certs := x509.NewCertPool()
+	certs.AddCert(pgw.Certificate())
+	defaultTransport := http.DefaultTransport
+	trustingTransport := defaultTransport.(*http.Transport).Clone()
+	trustingTransport.TLSClientConfig = &tls.Config{RootCAs: certs}
+	http.DefaultTransport = trustingTransport
+	http1TransportOnce = sync.Once{}
+	defer func() {
+		http.DefaultTransport = defaultTransport
+		http1TransportOnce = sync.Once{}
+	}()
+
+	for _, tc := range []struct {
+		useHTTP2  bool
+		wantProto int
+	}{
+		{useHTTP2: true, wantProto: 2},
+		{useHTTP2: false, wantProto: 1},
+	} {
+		p := New(pgw.URL, "testjob").UseHTTP2(tc.useHTTP2)
+		if err := p.Push(); err != nil {
+			t.Fatal(err)
+		}
+		if lastProto != tc.wantProto {
+			t.Errorf("UseHTTP2(%t): got HTTP/%d, want HTTP/%d", tc.useHTTP2, lastProto, tc.wantProto)
+		}
+	}
+
+	// All Pushers share the transport, and thus the idle connections.
+	t1 := New(pgw.URL, "testjob").UseHTTP2(false).client.(*http.Client).Transport
+	t2 := New(pgw.URL, "testjob").UseHTTP2(false).client.(*http.Client).Transport
+	if t1 != t2 {
+		t.Error("Pushers with HTTP/2 disabled don't share their transport")
+	}
+	if tr := New(pgw.URL, "testjob").client.(*http.Client).Transport; tr != nil {
+		t.Errorf("got transport %v by default, want nil, i.e. http.DefaultTransport", tr)
+	}
+}
+
+func BenchmarkPush(b *testing.B) {
+	pgw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+		// Large enough not to be drained upon closing an unread body.
+		w.Write(bytes.Repeat([]byte("x"), 8<<10))
+	}))
+	defer pgw.Close()
+
+	newPusher := func() *Pusher {
+		return New(pgw.URL, "testjob").Collector(prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_total",
+			Help: "help",
+		}))
+	}
+	b.Run("same Pusher", func(b *testing.B) {
+		p := newPusher()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := p.Add(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("new Pusher", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := newPusher().Add(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
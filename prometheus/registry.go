@@ -60,6 +60,8 @@ var (
 func init() {
 	MustRegister(NewProcessCollector(ProcessCollectorOpts{}))
 	MustRegister(NewGoCollector())
+	internal.RegistryStats = registryStats
+	internal.RegistryUnits = registryUnits
 }
 
 // NewRegistry creates a new vanilla Registry without any Collectors
@@ -69,6 +71,7 @@ func NewRegistry() *Registry {
 		collectorsByID:  map[uint64]Collector{},
 		descIDs:         map[uint64]struct{}{},
 		dimHashesByName: map[string]uint64{},
+		unitsByName:     map[string]string{},
 	}
 }
 
@@ -262,18 +265,18 @@ type Registry struct {
 	collectorsByID        map[uint64]Collector // ID is a hash of the descIDs.
 	descIDs               map[uint64]struct{}
 	dimHashesByName       map[string]uint64
+	unitsByName           map[string]string
 	uncheckedCollectors   []Collector
 	pedanticChecksEnabled bool
+	requiredNamePrefix    string
 }
 
 // Register implements Registerer.
 func (r *Registry) Register(c Collector) error {
 	var (
-		descChan           = make(chan *Desc, capDescChan)
-		newDescIDs         = map[uint64]struct{}{}
-		newDimHashesByName = map[string]uint64{}
-		collectorID        uint64 // All desc IDs XOR'd together.
-		duplicateDescErr   error
+		descChan    = make(chan *Desc, capDescChan)
+		newDescIDs  = map[uint64]struct{}{}
+		collectorID uint64 // All desc IDs XOR'd together.
 	)
 	go func() {
 		c.Describe(descChan)
@@ -286,18 +289,22 @@ func (r *Registry) Register(c Collector) error {
 		}
 		r.mtx.Unlock()
 	}()
+	checker := newDescChecker(
+		r.requiredNamePrefix,
+		func(id uint64) bool {
+			_, exists := r.descIDs[id]
+			return exists
+		},
+		func(fqName string) (uint64, bool) {
+			dimHash, exists := r.dimHashesByName[fqName]
+			return dimHash, exists
+		},
+		func(fqName string) string { return r.unitsByName[fqName] },
+	)
 	// Conduct various tests...
 	for desc := range descChan {
-
-		// Is the descriptor valid at all?
-		if desc.err != nil {
-			return fmt.Errorf("descriptor %s is invalid: %w", desc, desc.err)
-		}
-
-		// Is the descID unique?
-		// (In other words: Is the fqName + constLabel combination unique?)
-		if _, exists := r.descIDs[desc.id]; exists {
-			duplicateDescErr = fmt.Errorf("descriptor %s already exists with the same fully-qualified name and const label values", desc)
+		if err := checker.check(desc); err != nil {
+			return err
 		}
 		// If it is not a duplicate desc in this collector, XOR it to
 		// the collectorID.  (We allow duplicate descs within the same
@@ -306,24 +313,6 @@ func (r *Registry) Register(c Collector) error {
 			newDescIDs[desc.id] = struct{}{}
 			collectorID ^= desc.id
 		}
-
-		// Are all the label names and the help string consistent with
-		// previous descriptors of the same name?
-		// First check existing descriptors...
-		if dimHash, exists := r.dimHashesByName[desc.fqName]; exists {
-			if dimHash != desc.dimHash {
-				return fmt.Errorf("a previously registered descriptor with the same fully-qualified name as %s has different label names or a different help string", desc)
-			}
-		} else {
-			// ...then check the new descriptors already seen.
-			if dimHash, exists := newDimHashesByName[desc.fqName]; exists {
-				if dimHash != desc.dimHash {
-					return fmt.Errorf("descriptors reported by collector have inconsistent label names or help strings for the same fully-qualified name, offender is %s", desc)
-				}
-			} else {
-				newDimHashesByName[desc.fqName] = desc.dimHash
-			}
-		}
 	}
 	// A Collector yielding no Desc at all is considered unchecked.
 	if len(newDescIDs) == 0 {
@@ -346,8 +335,8 @@ func (r *Registry) Register(c Collector) error {
 	}
 	// If the collectorID is new, but at least one of the descs existed
 	// before, we are in trouble.
-	if duplicateDescErr != nil {
-		return duplicateDescErr
+	if checker.duplicateDescErr != nil {
+		return checker.duplicateDescErr
 	}
 
 	// Only after all tests have passed, actually register.
@@ -355,9 +344,96 @@ func (r *Registry) Register(c Collector) error {
 	for hash := range newDescIDs {
 		r.descIDs[hash] = struct{}{}
 	}
-	for name, dimHash := range newDimHashesByName {
+	for name, dimHash := range checker.newDimHashesByName {
 		r.dimHashesByName[name] = dimHash
 	}
+	for name, unit := range checker.newUnitsByName {
+		r.unitsByName[name] = unit
+	}
+	return nil
+}
+
+// descChecker conducts the consistency checks upon registration of the Descs
+// of a Collector. It is shared by Registry and ShardedRegistry, which provide
+// access to their already registered Descs via descIDExists, dimHashByName,
+// and unitByName.
+type descChecker struct {
+	requiredNamePrefix string
+	descIDExists       func(id uint64) bool
+	dimHashByName      func(fqName string) (dimHash uint64, exists bool)
+	unitByName         func(fqName string) string
+
+	// newDimHashesByName and newUnitsByName contain the metric names
+	// seen for the first time, to be added to the registry once the
+	// Collector has passed all tests.
+	newDimHashesByName map[string]uint64
+	newUnitsByName     map[string]string
+	// duplicateDescErr is set if a Desc exists already. That's only an
+	// error if the Collector itself is not registered yet, which the
+	// caller has to find out.
+	duplicateDescErr error
+}
+
+func newDescChecker(
+	requiredNamePrefix string,
+	descIDExists func(id uint64) bool,
+	dimHashByName func(fqName string) (uint64, bool),
+	unitByName func(fqName string) string,
+) *descChecker {
+	return &descChecker{
+		requiredNamePrefix: requiredNamePrefix,
+		descIDExists:       descIDExists,
+		dimHashByName:      dimHashByName,
+		unitByName:         unitByName,
+		newDimHashesByName: map[string]uint64{},
+		newUnitsByName:     map[string]string{},
+	}
+}
+
+// check returns an error if the provided Desc can't be registered.
+func (c *descChecker) check(desc *Desc) error {
+	// Is the descriptor valid at all?
+	if desc.err != nil {
+		return fmt.Errorf("descriptor %s is invalid: %w", desc, desc.err)
+	}
+
+	// Does the name follow the required naming convention?
+	if !strings.HasPrefix(desc.fqName, c.requiredNamePrefix) {
+		return fmt.Errorf("descriptor %s has fully-qualified name %q, which does not start with the required prefix %q", desc, desc.fqName, c.requiredNamePrefix)
+	}
+
+	// Is the descID unique?
+	// (In other words: Is the fqName + constLabel combination unique?)
+	if c.descIDExists(desc.id) {
+		c.duplicateDescErr = fmt.Errorf("descriptor %s already exists with the same fully-qualified name and const label values", desc)
+	}
+
+	// Are all the label names and the help string consistent with
+	// previous descriptors of the same name?
+	// First check existing descriptors...
+	if dimHash, exists := c.dimHashByName(desc.fqName); exists {
+		if dimHash != desc.dimHash {
+			if unit := c.unitByName(desc.fqName); unit != desc.unit {
+				return fmt.Errorf("a previously registered descriptor with the same fully-qualified name as %s has unit %q instead of %q", desc, unit, desc.unit)
+			}
+			return fmt.Errorf("a previously registered descriptor with the same fully-qualified name as %s has different label names or a different help string", desc)
+		}
+		return nil
+	}
+	// ...then check the new descriptors already seen.
+	if dimHash, exists := c.newDimHashesByName[desc.fqName]; exists {
+		if dimHash != desc.dimHash {
+			if unit := c.newUnitsByName[desc.fqName]; unit != desc.unit {
+				return fmt.Errorf("descriptors reported by collector have inconsistent units %q and %q for the same fully-qualified name, offender is %s", unit, desc.unit, desc)
+			}
+			return fmt.Errorf("descriptors reported by collector have inconsistent label names or help strings for the same fully-qualified name, offender is %s", desc)
+		}
+		return nil
+	}
+	c.newDimHashesByName[desc.fqName] = desc.dimHash
+	if desc.unit != "" {
+		c.newUnitsByName[desc.fqName] = desc.unit
+	}
 	return nil
 }
 
@@ -393,11 +469,24 @@ func (r *Registry) Unregister(c Collector) bool {
 	for id := range descIDs {
 		delete(r.descIDs, id)
 	}
-	// dimHashesByName is left untouched as those must be consistent
-	// throughout the lifetime of a program.
+	// dimHashesByName and unitsByName are left untouched as those must be
+	// consistent throughout the lifetime of a program.
 	return true
 }
 
+// RequireNamePrefix makes the Registry reject the registration of Collectors
+// that describe metrics whose fully-qualified name does not start with the
+// provided prefix. This helps to enforce a common namespace for all metrics of
+// a binary. The error returned by Register contains the offending name. The
+// check only applies to Collectors registered after calling RequireNamePrefix
+// and not to unchecked Collectors, as their metrics are not described upfront.
+// An empty prefix disables the check, which is the default.
+func (r *Registry) RequireNamePrefix(prefix string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.requiredNamePrefix = prefix
+}
+
 // MustRegister implements Registerer.
 func (r *Registry) MustRegister(cs ...Collector) {
 	for _, c := range cs {
@@ -407,8 +496,59 @@ func (r *Registry) MustRegister(cs ...Collector) {
 	}
 }
 
+// registryStats implements internal.RegistryStats.
+func registryStats(g interface{}) (collectors, descs int, ok bool) {
+	if sr, isScoped := g.(*ScopedRegistry); isScoped {
+		g = sr.Registry
+	}
+	r, ok := g.(*Registry)
+	if !ok {
+		return 0, 0, false
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return len(r.collectorsByID) + len(r.uncheckedCollectors), len(r.descIDs), true
+}
+
+// unitsGatherer is implemented by the Gatherers of this package that know the
+// units of the metrics registered with them (directly or via a Registerer
+// wrapping them, as wrapping keeps the units).
+type unitsGatherer interface {
+	// units returns the units by fully-qualified metric name, or nil if
+	// there are none.
+	units() map[string]string
+}
+
+// registryUnits implements internal.RegistryUnits.
+func registryUnits(g interface{}) map[string]string {
+	if ug, ok := g.(unitsGatherer); ok {
+		return ug.units()
+	}
+	return nil
+}
+
+// units implements unitsGatherer.
+func (r *Registry) units() map[string]string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if len(r.unitsByName) == 0 {
+		return nil
+	}
+	units := make(map[string]string, len(r.unitsByName))
+	for name, unit := range r.unitsByName {
+		units[name] = unit
+	}
+	return units
+}
+
 // Gather implements Gatherer.
 func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
+	return r.gather(nil)
+}
+
+// gather implements Gather. If buf is not nil, the returned MetricFamilies and
+// Metrics are allocated from it.
+func (r *Registry) gather(buf *gatherBuffer) ([]*dto.MetricFamily, error) {
 	r.mtx.RLock()
 
 	if len(r.collectorsByID) == 0 && len(r.uncheckedCollectors) == 0 {
@@ -420,14 +560,14 @@ func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
 	var (
 		checkedMetricChan   = make(chan Metric, capMetricChan)
 		uncheckedMetricChan = make(chan Metric, capMetricChan)
-		metricHashes        = map[uint64]struct{}{}
+		metricHashes        = buf.newHashes()
 		wg                  sync.WaitGroup
 		errs                MultiError          // The collected errors to return in the end.
 		registeredDescIDs   map[uint64]struct{} // Only used for pedantic checks
 	)
 
 	goroutineBudget := len(r.collectorsByID) + len(r.uncheckedCollectors)
-	metricFamiliesByName := make(map[string]*dto.MetricFamily, len(r.dimHashesByName))
+	metricFamiliesByName := buf.newFamiliesByName(len(r.dimHashesByName))
 	checkedCollectors := make(chan Collector, len(r.collectorsByID))
 	uncheckedCollectors := make(chan Collector, len(r.uncheckedCollectors))
 	for _, collector := range r.collectorsByID {
@@ -502,6 +642,7 @@ func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
 				metric, metricFamiliesByName,
 				metricHashes,
 				registeredDescIDs,
+				buf,
 			))
 		case metric, ok := <-umc:
 			if !ok {
@@ -512,6 +653,7 @@ func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
 				metric, metricFamiliesByName,
 				metricHashes,
 				nil,
+				buf,
 			))
 		default:
 			if goroutineBudget <= 0 || len(checkedCollectors)+len(uncheckedCollectors) == 0 {
@@ -529,6 +671,7 @@ func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
 						metric, metricFamiliesByName,
 						metricHashes,
 						registeredDescIDs,
+						buf,
 					))
 				case metric, ok := <-umc:
 					if !ok {
@@ -539,6 +682,7 @@ func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
 						metric, metricFamiliesByName,
 						metricHashes,
 						nil,
+						buf,
 					))
 				}
 				break
@@ -555,6 +699,9 @@ func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
 			break
 		}
 	}
+	if buf != nil {
+		return buf.normalize(metricFamiliesByName), errs.MaybeUnwrap()
+	}
 	return internal.NormalizeMetricFamilies(metricFamiliesByName), errs.MaybeUnwrap()
 }
 
@@ -615,12 +762,29 @@ func WriteToTextfile(filename string, g Gatherer) error {
 	return os.Rename(tmp.Name(), filename)
 }
 
+// collectError is returned by processMetric if writing a collected Metric
+// failed, typically because the Collector reported an error via
+// NewInvalidMetric.
+type collectError struct {
+	desc *Desc
+	err  error
+}
+
+func (e *collectError) Error() string {
+	return fmt.Sprintf("error collecting metric %v: %v", e.desc, e.err)
+}
+
+func (e *collectError) Unwrap() error {
+	return e.err
+}
+
 // processMetric is an internal helper method only used by the Gather method.
 func processMetric(
 	metric Metric,
 	metricFamiliesByName map[string]*dto.MetricFamily,
 	metricHashes map[uint64]struct{},
 	registeredDescIDs map[uint64]struct{},
+	buf *gatherBuffer,
 ) error {
 	desc := metric.Desc()
 	// Wrapped metrics collected by an unchecked Collector can have an
@@ -628,9 +792,9 @@ func processMetric(
 	if desc.err != nil {
 		return desc.err
 	}
-	dtoMetric := &dto.Metric{}
+	dtoMetric := buf.newMetric()
 	if err := metric.Write(dtoMetric); err != nil {
-		return fmt.Errorf("error collecting metric %v: %w", desc, err)
+		return &collectError{desc: desc, err: err}
 	}
 	metricFamily, ok := metricFamiliesByName[desc.fqName]
 	if ok { // Existing name.
@@ -681,7 +845,7 @@ func processMetric(
 			panic("encountered MetricFamily with invalid type")
 		}
 	} else { // New name.
-		metricFamily = &dto.MetricFamily{}
+		metricFamily = buf.newFamily()
 		metricFamily.Name = proto.String(desc.fqName)
 		metricFamily.Help = proto.String(desc.help)
 		// TODO(beorn7): Simplify switch once Desc has type.
@@ -743,6 +907,30 @@ type Gatherers []Gatherer
 
 // Gather implements Gatherer.
 func (gs Gatherers) Gather() ([]*dto.MetricFamily, error) {
+	mfs, _, err := gs.gather(false)
+	return mfs, err
+}
+
+// units implements unitsGatherer. The units are merged, with later Gatherers
+// taking precedence.
+func (gs Gatherers) units() map[string]string {
+	var units map[string]string
+	for _, g := range gs {
+		for name, unit := range registryUnits(g) {
+			if units == nil {
+				units = map[string]string{}
+			}
+			units[name] = unit
+		}
+	}
+	return units
+}
+
+// gather implements Gather. If normalizeHelp is true, metric families with a
+// help string different from the first occurrence are merged (with the first
+// help string) rather than skipped. The number of such metric families is
+// returned as helpConflicts.
+func (gs Gatherers) gather(normalizeHelp bool) (_ []*dto.MetricFamily, helpConflicts int, _ error) {
 	var (
 		metricFamiliesByName = map[string]*dto.MetricFamily{}
 		metricHashes         = map[uint64]struct{}{}
@@ -765,11 +953,14 @@ func (gs Gatherers) Gather() ([]*dto.MetricFamily, error) {
 			existingMF, exists := metricFamiliesByName[mf.GetName()]
 			if exists {
 				if existingMF.GetHelp() != mf.GetHelp() {
-					errs = append(errs, fmt.Errorf(
-						"gathered metric family %s has help %q but should have %q",
-						mf.GetName(), mf.GetHelp(), existingMF.GetHelp(),
-					))
-					continue
+					if !normalizeHelp {
+						errs = append(errs, fmt.Errorf(
+							"gathered metric family %s has help %q but should have %q",
+							mf.GetName(), mf.GetHelp(), existingMF.GetHelp(),
+						))
+						continue
+					}
+					helpConflicts++
 				}
 				if existingMF.GetType() != mf.GetType() {
 					errs = append(errs, fmt.Errorf(
@@ -798,7 +989,77 @@ func (gs Gatherers) Gather() ([]*dto.MetricFamily, error) {
 			}
 		}
 	}
-	return internal.NormalizeMetricFamilies(metricFamiliesByName), errs.MaybeUnwrap()
+	return internal.NormalizeMetricFamilies(metricFamiliesByName), helpConflicts, errs.MaybeUnwrap()
+}
+
+// GatherersOpts configures a Gatherer created with NewGatherers.
+type GatherersOpts struct {
+	// If NormalizeHelp is true, metric families gathered from different
+	// Gatherers that only differ in their help string are merged, using
+	// the help string of the first occurrence in slice order, instead of
+	// skipping the later ones and reporting an error. Instead, a counter
+	// "prometheus_help_conflicts_total" is added to the gathered metric
+	// families. It counts the number of metric families with a
+	// conflicting help string, summed up over all Gather calls. This is a
+	// pragmatic way of dealing with help strings drifting apart in large
+	// code bases, but it doesn't fix the drift, so keep an eye on the
+	// counter. Inconsistent types are still reported as errors.
+	NormalizeHelp bool
+}
+
+// helpConflictsMetric is the name of the counter for GatherersOpts.NormalizeHelp.
+const helpConflictsMetric = "prometheus_help_conflicts_total"
+
+type optsGatherers struct {
+	gs Gatherers
+
+	mtx           sync.Mutex // Protects helpConflicts.
+	helpConflicts int
+}
+
+// NewGatherers returns a Gatherer that works like Gatherers(gs), but with the
+// behavior modified as configured by opts. See GatherersOpts for details.
+func NewGatherers(opts GatherersOpts, gs ...Gatherer) Gatherer {
+	if !opts.NormalizeHelp {
+		return Gatherers(gs)
+	}
+	return &optsGatherers{gs: gs}
+}
+
+// units implements unitsGatherer.
+func (g *optsGatherers) units() map[string]string {
+	return g.gs.units()
+}
+
+// Gather implements Gatherer.
+func (g *optsGatherers) Gather() ([]*dto.MetricFamily, error) {
+	mfs, conflicts, err := g.gs.gather(true)
+
+	g.mtx.Lock()
+	g.helpConflicts += conflicts
+	total := g.helpConflicts
+	g.mtx.Unlock()
+
+	i := sort.Search(len(mfs), func(i int) bool { return mfs[i].GetName() >= helpConflictsMetric })
+	if i < len(mfs) && mfs[i].GetName() == helpConflictsMetric {
+		errs, isMulti := err.(MultiError)
+		if !isMulti {
+			errs.Append(err)
+		}
+		errs.Append(fmt.Errorf("gathered metric family %s collides with the counter of help conflicts", helpConflictsMetric))
+		return mfs, errs.MaybeUnwrap()
+	}
+	mfs = append(mfs, nil)
+	copy(mfs[i+1:], mfs[i:])
+	mfs[i] = &dto.MetricFamily{
+		Name: proto.String(helpConflictsMetric),
+		Help: proto.String("Total number of gathered metric families with a help string conflicting with an earlier one."),
+		Type: dto.MetricType_COUNTER.Enum(),
+		Metric: []*dto.Metric{{
+			Counter: &dto.Counter{Value: proto.Float64(float64(total))},
+		}},
+	}
+	return mfs, err
 }
 
 // checkSuffixCollisions checks for collisions with the “magic” suffixes the
@@ -1068,6 +1329,11 @@ type noTransactionGatherer struct {
 	g Gatherer
 }
 
+// units implements unitsGatherer.
+func (g *noTransactionGatherer) units() map[string]string {
+	return registryUnits(g.g)
+}
+
 // Gather implements TransactionalGatherer interface.
 func (g *noTransactionGatherer) Gather() (_ []*dto.MetricFamily, done func(), err error) {
 	mfs, err := g.g.Gather()
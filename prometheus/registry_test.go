@@ -27,6 +27,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -887,6 +888,33 @@ func TestRegisterUnregisterCollector(t *testing.T) {
 	}
 }
 
+func TestRequireNamePrefix(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	// Registered before the prefix is required.
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "legacy", Help: "help"}))
+
+	reg.RequireNamePrefix("myapp_")
+	if err := reg.Register(prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "myapp",
+		Name:      "requests_total",
+		Help:      "help",
+	})); err != nil {
+		t.Errorf("unexpected error registering metric with prefix: %v", err)
+	}
+	err := reg.Register(prometheus.NewGoCollector())
+	if err == nil {
+		t.Fatal("expected error registering metrics without prefix")
+	}
+	if !strings.Contains(err.Error(), `"go_`) || !strings.Contains(err.Error(), `"myapp_"`) {
+		t.Errorf("expected offending name and prefix in error, got %v", err)
+	}
+
+	reg.RequireNamePrefix("")
+	if err := reg.Register(prometheus.NewGoCollector()); err != nil {
+		t.Errorf("unexpected error after disabling the prefix check: %v", err)
+	}
+}
+
 // TestHistogramVecRegisterGatherConcurrency is an end-to-end test that
 // concurrently calls Observe on random elements of a HistogramVec while the
 // same HistogramVec is registered concurrently and the Gather method of the
@@ -1338,3 +1366,38 @@ func TestCheckMetricConsistency(t *testing.T) {
 	}
 	reg.Unregister(invalidCollector)
 }
+
+func TestGatherersNormalizeHelp(t *testing.T) {
+	reg1, reg2 := prometheus.NewRegistry(), prometheus.NewRegistry()
+	reg1.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "requests_total",
+		Help:        "Requests.",
+		ConstLabels: prometheus.Labels{"reg": "1"},
+	}))
+	reg2.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "requests_total",
+		Help:        "Total number of requests.",
+		ConstLabels: prometheus.Labels{"reg": "2"},
+	}))
+
+	if _, err := prometheus.NewGatherers(prometheus.GatherersOpts{}, reg1, reg2).Gather(); err == nil {
+		t.Error("expected error for conflicting help without NormalizeHelp")
+	}
+
+	g := prometheus.NewGatherers(prometheus.GatherersOpts{NormalizeHelp: true}, reg1, reg2)
+	for i := 1; i <= 2; i++ {
+		mfs, err := g.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mfs) != 2 {
+			t.Fatalf("got %d metric families, want 2", len(mfs))
+		}
+		if got := mfs[0]; got.GetName() != "prometheus_help_conflicts_total" || got.Metric[0].Counter.GetValue() != float64(i) {
+			t.Errorf("gather #%d: unexpected help conflicts counter %v", i, got)
+		}
+		if got := mfs[1]; got.GetHelp() != "Requests." || len(got.Metric) != 2 {
+			t.Errorf("gather #%d: unexpected merged metric family %v", i, got)
+		}
+	}
+}
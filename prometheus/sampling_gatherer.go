@@ -0,0 +1,71 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cespare/xxhash/v2"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// NewSamplingGatherer returns a Gatherer that only returns a sample of the
+// metrics gathered by the provided Gatherer, namely approximately the provided
+// fraction of the metrics in each metric family. Whether a metric is included
+// is decided by a hash of its metric name and labels, so that the same metrics
+// are included in each Gather call (as long as they exist). Metric families
+// without any sampled metrics are omitted. An error returned by the provided
+// Gatherer is passed on unchanged, together with the sampled metric families.
+//
+// The fraction must be between (and including) 0 and 1. Otherwise,
+// NewSamplingGatherer panics. A fraction of 1 includes all metrics, a fraction
+// of 0 none.
+//
+// The returned Gatherer is meant for debugging, e.g. to spot-check a huge
+// Registry via a separate HTTP endpoint without transferring the full
+// exposition. It is not suitable for monitoring, as the sampled metrics are
+// incomplete by design, e.g. the sum of a sampled counter vector is
+// meaningless. Note that the provided Gatherer still gathers all metrics, so
+// there are no savings in collection time.
+func NewSamplingGatherer(g Gatherer, fraction float64) Gatherer {
+	if !(fraction >= 0 && fraction <= 1) {
+		panic(fmt.Errorf("sampling fraction %f is not between 0 and 1", fraction))
+	}
+	if fraction == 1 {
+		return &droppingGatherer{g: g, drop: func(string, *dto.Metric) bool { return false }}
+	}
+	threshold := uint64(fraction * math.Exp2(64))
+	return &droppingGatherer{
+		g: g,
+		drop: func(name string, m *dto.Metric) bool {
+			return sampleHash(name, m) >= threshold
+		},
+	}
+}
+
+// sampleHash returns a hash of the provided metric name and the labels of m,
+// which are expected to be sorted, as returned by a Gatherer.
+func sampleHash(name string, m *dto.Metric) uint64 {
+	xxh := xxhash.New()
+	xxh.WriteString(name)
+	xxh.Write(separatorByteSlice)
+	for _, lp := range m.Label {
+		xxh.WriteString(lp.GetName())
+		xxh.Write(separatorByteSlice)
+		xxh.WriteString(lp.GetValue())
+		xxh.Write(separatorByteSlice)
+	}
+	return xxh.Sum64()
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestSamplingGatherer(t *testing.T) {
+	const n = 10000
+	reg := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Requests.",
+	}, []string{"id"})
+	for i := 0; i < n; i++ {
+		requests.WithLabelValues(strconv.Itoa(i)).Inc()
+	}
+	reg.MustRegister(requests)
+
+	sampledIDs := func(fraction float64) []string {
+		mfs, err := prometheus.NewSamplingGatherer(reg, fraction).Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				ids = append(ids, m.Label[0].GetValue())
+			}
+		}
+		return ids
+	}
+
+	if got := sampledIDs(1); len(got) != n {
+		t.Errorf("got %d metrics with fraction 1, want %d", len(got), n)
+	}
+	if got := sampledIDs(0); len(got) != 0 {
+		t.Errorf("got %d metrics with fraction 0, want none", len(got))
+	}
+
+	sample := sampledIDs(0.1)
+	// The standard deviation is 30, so this is very unlikely to fail.
+	if len(sample) < 900 || len(sample) > 1100 {
+		t.Errorf("got %d sampled metrics, want about %d", len(sample), n/10)
+	}
+	if again := sampledIDs(0.1); !reflect.DeepEqual(sample, again) {
+		t.Error("sample is not stable across Gather calls")
+	}
+
+	for _, fraction := range []float64{-0.1, 1.1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for fraction %v", fraction)
+				}
+			}()
+			prometheus.NewSamplingGatherer(reg, fraction)
+		}()
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"log"
+	"runtime"
+	"sync"
+)
+
+// errScopedRegistryClosed is returned when registering with a closed
+// ScopedRegistry.
+var errScopedRegistryClosed = errors.New("scoped registry is closed")
+
+// ScopedRegistry is a Registry with a limited lifetime, e.g. the lifetime of a
+// tenant in a multi-tenant exporter. Create instances with NewScopedRegistry,
+// and call Close once the scope ends.
+type ScopedRegistry struct {
+	*Registry
+
+	// closeMtx is held across the check of closed and the registration
+	// with the Registry so that no Collector is registered after Close.
+	closeMtx sync.Mutex
+	closed   bool
+}
+
+// NewScopedRegistry returns a new ScopedRegistry. It works like a Registry
+// created with NewRegistry until Close is called.
+//
+// Note that a Registry that isn't referenced anymore is garbage-collected
+// together with all its Collectors anyway. The problem a ScopedRegistry helps
+// with is a Registry that is still referenced after its scope has ended, e.g.
+// by a handler that is still routed to or by a Gatherers slice, and that
+// keeps its Collectors (and everything they reference) alive. To detect
+// missing Close calls, a warning is logged with the standard library's log
+// package if a ScopedRegistry is garbage-collected without having been closed.
+func NewScopedRegistry() *ScopedRegistry {
+	r := &ScopedRegistry{Registry: NewRegistry()}
+	runtime.SetFinalizer(r, func(r *ScopedRegistry) {
+		r.closeMtx.Lock()
+		closed := r.closed
+		r.closeMtx.Unlock()
+		if closed {
+			return
+		}
+		collectors, _, _ := registryStats(r.Registry)
+		log.Printf("prometheus: ScopedRegistry with %d collector(s) garbage-collected without calling Close", collectors)
+	})
+	return r
+}
+
+// Register implements Registerer. After Close has been called, it always
+// returns an error.
+func (r *ScopedRegistry) Register(c Collector) error {
+	r.closeMtx.Lock()
+	defer r.closeMtx.Unlock()
+	if r.closed {
+		return errScopedRegistryClosed
+	}
+	return r.Registry.Register(c)
+}
+
+// MustRegister implements Registerer. After Close has been called, it always
+// panics.
+func (r *ScopedRegistry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Close unregisters all Collectors and releases the internal data structures
+// of the ScopedRegistry, so that neither the Collectors nor the data
+// structures are kept alive by references to the ScopedRegistry that outlive
+// its scope. Afterwards, Gather returns no metrics, and registering fails.
+// Calling Close more than once has no further effect.
+func (r *ScopedRegistry) Close() {
+	r.closeMtx.Lock()
+	defer r.closeMtx.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	// Use new empty maps rather than nil so that Unregister and Gather
+	// keep working.
+	r.collectorsByID = map[uint64]Collector{}
+	r.descIDs = map[uint64]struct{}{}
+	r.dimHashesByName = map[string]uint64{}
+	r.unitsByName = map[string]string{}
+	r.uncheckedCollectors = nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestScopedRegistry(t *testing.T) {
+	reg := prometheus.NewScopedRegistry()
+	requests := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tenant_requests_total",
+		Help: "Requests of the tenant.",
+	})
+	reg.MustRegister(requests)
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tenant_up",
+		Help: "Whether the tenant is up.",
+	}))
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 2 {
+		t.Fatalf("got %d metric families before Close, want 2", len(mfs))
+	}
+
+	reg.Close()
+	reg.Close() // No-op.
+
+	mfs, err = reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 0 {
+		t.Errorf("got %d metric families after Close, want none", len(mfs))
+	}
+	if reg.Unregister(requests) {
+		t.Error("collector still registered after Close")
+	}
+	if err := reg.Register(requests); err == nil {
+		t.Error("expected error registering after Close")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic from MustRegister after Close")
+			}
+		}()
+		reg.MustRegister(requests)
+	}()
+}
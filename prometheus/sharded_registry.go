@@ -0,0 +1,280 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ShardedRegistry is a Registerer and Gatherer that works like a Registry, but
+// it partitions its internal state into shards, each protected by its own
+// lock. Create instances with NewShardedRegistry.
+type ShardedRegistry struct {
+	shards             []registryShard
+	nextUnchecked      uint32       // Distributes unchecked Collectors. Accessed atomically.
+	requiredNamePrefix atomic.Value // Containing nil or a string.
+}
+
+// registryShard holds the part of the state of a ShardedRegistry assigned to
+// one shard. collectorsByID is sharded by collector ID, descIDs by desc ID,
+// and dimHashesByName and unitsByName by a hash of the metric name.
+type registryShard struct {
+	mtx                 sync.RWMutex
+	collectorsByID      map[uint64]Collector
+	descIDs             map[uint64]struct{}
+	dimHashesByName     map[string]uint64
+	unitsByName         map[string]string
+	uncheckedCollectors []Collector
+}
+
+// NewShardedRegistry returns a ShardedRegistry with the provided number of
+// shards. It panics if shards is less than 1.
+//
+// A ShardedRegistry performs the same consistency checks upon registration as
+// a Registry created with NewRegistry, across all shards. In contrast to a
+// Registry, it calls the Describe method of a registered Collector before
+// acquiring any lock, and then it only locks the shards concerned by the
+// Collector's descriptors. Gather locks one shard after another (and only for
+// reading) while taking a snapshot of the registered Collectors. This reduces
+// lock contention if many Collectors are registered and unregistered
+// concurrently (e.g. per-tenant Collectors in a multi-tenant setup), in
+// particular while gathering. However, a Collector with many Descs locks many
+// shards upon registration, so the benefit is largest for Collectors with few
+// Descs. A good number of shards is in the order of the number of CPU cores.
+// With little concurrency, a plain Registry is cheaper.
+func NewShardedRegistry(shards int) *ShardedRegistry {
+	if shards < 1 {
+		panic(fmt.Errorf("number of registry shards must be at least 1, got %d", shards))
+	}
+	r := &ShardedRegistry{shards: make([]registryShard, shards)}
+	for i := range r.shards {
+		r.shards[i] = registryShard{
+			collectorsByID:  map[uint64]Collector{},
+			descIDs:         map[uint64]struct{}{},
+			dimHashesByName: map[string]uint64{},
+			unitsByName:     map[string]string{},
+		}
+	}
+	return r
+}
+
+func (r *ShardedRegistry) shardByID(id uint64) int {
+	return int(id % uint64(len(r.shards)))
+}
+
+func (r *ShardedRegistry) shardByName(name string) int {
+	return r.shardByID(xxhash.Sum64String(name))
+}
+
+// describe returns the deduplicated Descs of c and their collector ID. It
+// returns an error for the first invalid Desc.
+func describe(c Collector) (descs []*Desc, collectorID uint64, err error) {
+	descChan := make(chan *Desc, capDescChan)
+	go func() {
+		c.Describe(descChan)
+		close(descChan)
+	}()
+	// Drain channel in case of premature return to not leak a goroutine.
+	defer func() {
+		for range descChan {
+		}
+	}()
+	seen := map[uint64]struct{}{}
+	for desc := range descChan {
+		if desc.err != nil {
+			return nil, 0, fmt.Errorf("descriptor %s is invalid: %w", desc, desc.err)
+		}
+		// We allow duplicate descs within the same collector, but their
+		// existence must be a no-op.
+		if _, exists := seen[desc.id]; exists {
+			continue
+		}
+		seen[desc.id] = struct{}{}
+		collectorID ^= desc.id
+		descs = append(descs, desc)
+	}
+	return descs, collectorID, nil
+}
+
+// lockShards write-locks the provided shards in ascending order (to avoid
+// deadlocks) and returns a function to unlock them again. Duplicates are
+// allowed.
+func (r *ShardedRegistry) lockShards(shards []int) (unlock func()) {
+	sort.Ints(shards)
+	var locked []int
+	for _, s := range shards {
+		if len(locked) > 0 && s == locked[len(locked)-1] {
+			continue
+		}
+		r.shards[s].mtx.Lock()
+		locked = append(locked, s)
+	}
+	return func() {
+		for _, s := range locked {
+			r.shards[s].mtx.Unlock()
+		}
+	}
+}
+
+// Register implements Registerer.
+func (r *ShardedRegistry) Register(c Collector) error {
+	descs, collectorID, err := describe(c)
+	if err != nil {
+		return err
+	}
+	// A Collector yielding no Desc at all is considered unchecked.
+	if len(descs) == 0 {
+		s := &r.shards[int(atomic.AddUint32(&r.nextUnchecked, 1)%uint32(len(r.shards)))]
+		s.mtx.Lock()
+		s.uncheckedCollectors = append(s.uncheckedCollectors, c)
+		s.mtx.Unlock()
+		return nil
+	}
+
+	shards := make([]int, 0, 2*len(descs)+1)
+	shards = append(shards, r.shardByID(collectorID))
+	for _, desc := range descs {
+		shards = append(shards, r.shardByID(desc.id), r.shardByName(desc.fqName))
+	}
+	defer r.lockShards(shards)()
+
+	requiredNamePrefix, _ := r.requiredNamePrefix.Load().(string)
+	checker := newDescChecker(
+		requiredNamePrefix,
+		func(id uint64) bool {
+			_, exists := r.shards[r.shardByID(id)].descIDs[id]
+			return exists
+		},
+		func(fqName string) (uint64, bool) {
+			dimHash, exists := r.shards[r.shardByName(fqName)].dimHashesByName[fqName]
+			return dimHash, exists
+		},
+		func(fqName string) string { return r.shards[r.shardByName(fqName)].unitsByName[fqName] },
+	)
+	for _, desc := range descs {
+		if err := checker.check(desc); err != nil {
+			return err
+		}
+	}
+	if existing, exists := r.shards[r.shardByID(collectorID)].collectorsByID[collectorID]; exists {
+		if wc, ok := existing.(*wrappingCollector); ok {
+			existing = wc.unwrapRecursively()
+		}
+		return AlreadyRegisteredError{
+			ExistingCollector: existing,
+			NewCollector:      c,
+		}
+	}
+	// If the collectorID is new, but at least one of the descs existed
+	// before, we are in trouble.
+	if checker.duplicateDescErr != nil {
+		return checker.duplicateDescErr
+	}
+
+	// Only after all tests have passed, actually register.
+	r.shards[r.shardByID(collectorID)].collectorsByID[collectorID] = c
+	for _, desc := range descs {
+		r.shards[r.shardByID(desc.id)].descIDs[desc.id] = struct{}{}
+	}
+	for name, dimHash := range checker.newDimHashesByName {
+		r.shards[r.shardByName(name)].dimHashesByName[name] = dimHash
+	}
+	for name, unit := range checker.newUnitsByName {
+		r.shards[r.shardByName(name)].unitsByName[name] = unit
+	}
+	return nil
+}
+
+// RequireNamePrefix works like Registry.RequireNamePrefix.
+func (r *ShardedRegistry) RequireNamePrefix(prefix string) {
+	r.requiredNamePrefix.Store(prefix)
+}
+
+// MustRegister implements Registerer.
+func (r *ShardedRegistry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister implements Registerer.
+func (r *ShardedRegistry) Unregister(c Collector) bool {
+	descs, collectorID, err := describe(c)
+	if err != nil || len(descs) == 0 {
+		// Neither invalid nor unchecked Collectors can be registered.
+		return false
+	}
+
+	shards := make([]int, 0, len(descs)+1)
+	shards = append(shards, r.shardByID(collectorID))
+	for _, desc := range descs {
+		shards = append(shards, r.shardByID(desc.id))
+	}
+	defer r.lockShards(shards)()
+
+	cs := &r.shards[r.shardByID(collectorID)]
+	if _, exists := cs.collectorsByID[collectorID]; !exists {
+		return false
+	}
+	delete(cs.collectorsByID, collectorID)
+	for _, desc := range descs {
+		delete(r.shards[r.shardByID(desc.id)].descIDs, desc.id)
+	}
+	// dimHashesByName and unitsByName are left untouched as those must be
+	// consistent throughout the lifetime of a program.
+	return true
+}
+
+// Gather implements Gatherer.
+func (r *ShardedRegistry) Gather() ([]*dto.MetricFamily, error) {
+	// Take a snapshot of all Collectors and use the gathering of a
+	// temporary Registry, which is consistent as the collector IDs are
+	// unique across shards.
+	snapshot := &Registry{collectorsByID: map[uint64]Collector{}}
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mtx.RLock()
+		for id, c := range s.collectorsByID {
+			snapshot.collectorsByID[id] = c
+		}
+		snapshot.uncheckedCollectors = append(snapshot.uncheckedCollectors, s.uncheckedCollectors...)
+		s.mtx.RUnlock()
+	}
+	return snapshot.Gather()
+}
+
+// units implements unitsGatherer. It merges the units of all shards.
+func (r *ShardedRegistry) units() map[string]string {
+	var units map[string]string
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mtx.RLock()
+		for name, unit := range s.unitsByName {
+			if units == nil {
+				units = map[string]string{}
+			}
+			units[name] = unit
+		}
+		s.mtx.RUnlock()
+	}
+	return units
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestShardedRegistry(t *testing.T) {
+	reg := prometheus.NewShardedRegistry(8)
+
+	const tenants = 100
+	var wg sync.WaitGroup
+	for i := 0; i < tenants; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+				Name:        "tenant_requests_total",
+				Help:        "Requests of tenants.",
+				ConstLabels: prometheus.Labels{"tenant": strconv.Itoa(i)},
+			}))
+		}(i)
+	}
+	wg.Wait()
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || len(mfs[0].Metric) != tenants {
+		t.Fatalf("unexpected metric families %v", mfs)
+	}
+
+	// Duplicates and inconsistencies are detected across shards.
+	dup := prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "tenant_requests_total",
+		Help:        "Requests of tenants.",
+		ConstLabels: prometheus.Labels{"tenant": "0"},
+	})
+	are := prometheus.AlreadyRegisteredError{}
+	if err := reg.Register(dup); !errors.As(err, &are) {
+		t.Errorf("expected AlreadyRegisteredError, got %v", err)
+	}
+	if err := reg.Register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "tenant_requests_total",
+		Help:        "Different help.",
+		ConstLabels: prometheus.Labels{"tenant": "new"},
+	})); err == nil {
+		t.Error("expected error for inconsistent help string")
+	}
+	if err := reg.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tenant_requests_total",
+		Help: "Requests of tenants.",
+	}, []string{"tenant"})); err == nil {
+		t.Error("expected error for inconsistent label names")
+	}
+
+	if !reg.Unregister(are.ExistingCollector) {
+		t.Error("unregistering existing collector failed")
+	}
+	if reg.Unregister(are.ExistingCollector) {
+		t.Error("unregistering collector twice succeeded")
+	}
+	if err := reg.Register(dup); err != nil {
+		t.Errorf("re-registering after unregistering failed: %v", err)
+	}
+
+	reg.RequireNamePrefix("tenant_")
+	if err := reg.Register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "other_requests_total",
+		Help: "Other requests.",
+	})); err == nil {
+		t.Error("expected error for missing name prefix")
+	}
+}
+
+func BenchmarkConcurrentRegistration(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		reg  func() prometheus.Registerer
+	}{
+		{"Registry", func() prometheus.Registerer { return prometheus.NewRegistry() }},
+		{"ShardedRegistry", func() prometheus.Registerer { return prometheus.NewShardedRegistry(16) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			reg := bc.reg()
+			// Each tenant registers a Collector with a few Descs.
+			const descsPerCollector = 3
+			var id uint64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					tenant := strconv.FormatUint(atomic.AddUint64(&id, 1), 10)
+					descs := make([]*prometheus.Desc, descsPerCollector)
+					for i := range descs {
+						descs[i] = prometheus.NewDesc(
+							"tenant_metric_"+strconv.Itoa(i), "Help.",
+							nil, prometheus.Labels{"tenant": tenant},
+						)
+					}
+					if err := reg.Register(descsCollector(descs)); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+type descsCollector []*prometheus.Desc
+
+func (c descsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c {
+		ch <- d
+	}
+}
+
+func (c descsCollector) Collect(chan<- prometheus.Metric) {}
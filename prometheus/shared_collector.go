@@ -0,0 +1,78 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import "sync"
+
+type sharedCollector struct {
+	mtx sync.Mutex // Serializes calls of Describe and Collect of c.
+	c   Collector
+}
+
+// NewSharedCollector returns a Collector that wraps the provided Collector so
+// that it can be registered with multiple Registries at the same time, even if
+// its Describe and Collect methods are not safe for concurrent use, e.g.
+// because Collect updates a cache without any locking.
+//
+// In general, registering the same Collector with multiple Registries is fine,
+// but each Registry calls Collect independently, so that Collect might be
+// called concurrently if the Registries are gathered at the same time. The
+// Collector interface requires Collect to be concurrency-safe, and all
+// Collectors in this package are. NewSharedCollector is for those that aren't.
+// The returned Collector guarantees that only one call of Describe or Collect
+// of the wrapped Collector runs at any time. The collected Metrics are
+// buffered and only sent to the Registry after the wrapped Collect has
+// returned, so that a slow Registry doesn't block the others.
+//
+// Note that the wrapped Collector must not be registered directly anywhere, as
+// those calls would bypass the serialization.
+func NewSharedCollector(c Collector) Collector {
+	return &sharedCollector{c: c}
+}
+
+// Describe implements Collector.
+func (sc *sharedCollector) Describe(ch chan<- *Desc) {
+	descs := make(chan *Desc)
+	go func() {
+		sc.mtx.Lock()
+		defer sc.mtx.Unlock()
+		sc.c.Describe(descs)
+		close(descs)
+	}()
+	var buf []*Desc
+	for d := range descs {
+		buf = append(buf, d)
+	}
+	for _, d := range buf {
+		ch <- d
+	}
+}
+
+// Collect implements Collector.
+func (sc *sharedCollector) Collect(ch chan<- Metric) {
+	metrics := make(chan Metric)
+	go func() {
+		sc.mtx.Lock()
+		defer sc.mtx.Unlock()
+		sc.c.Collect(metrics)
+		close(metrics)
+	}()
+	var buf []Metric
+	for m := range metrics {
+		buf = append(buf, m)
+	}
+	for _, m := range buf {
+		ch <- m
+	}
+}
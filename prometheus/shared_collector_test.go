@@ -0,0 +1,80 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// cachingCollector is deliberately not safe for concurrent use.
+type cachingCollector struct {
+	desc    *prometheus.Desc
+	cache   map[string]float64
+	running bool
+	calls   int
+}
+
+func (c *cachingCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *cachingCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.running {
+		panic("concurrent call of Collect")
+	}
+	c.running = true
+	defer func() { c.running = false }()
+
+	c.calls++
+	c.cache["calls"] = float64(c.calls)
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, c.cache["calls"])
+}
+
+func TestSharedCollector(t *testing.T) {
+	inner := &cachingCollector{
+		desc:  prometheus.NewDesc("cached_calls_total", "Calls of Collect.", nil, nil),
+		cache: map[string]float64{},
+	}
+	shared := prometheus.NewSharedCollector(inner)
+
+	reg1, reg2 := prometheus.NewRegistry(), prometheus.NewRegistry()
+	reg1.MustRegister(shared)
+	reg2.MustRegister(shared)
+
+	const n = 100
+	var wg sync.WaitGroup
+	for _, reg := range []*prometheus.Registry{reg1, reg2} {
+		wg.Add(1)
+		go func(reg *prometheus.Registry) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				mfs, err := reg.Gather()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(mfs) != 1 || len(mfs[0].Metric) != 1 {
+					t.Errorf("unexpected metric families %v", mfs)
+					return
+				}
+			}
+		}(reg)
+	}
+	wg.Wait()
+
+	if inner.calls != 2*n {
+		t.Errorf("got %d calls of Collect, want %d", inner.calls, 2*n)
+	}
+}
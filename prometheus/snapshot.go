@@ -0,0 +1,277 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/adhimaswaskita/client_golang/prometheus/internal"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// restorer is implemented by Metrics whose value can be restored from a
+// snapshot taken with Registry.Snapshot. If the provided dto.Metric is
+// incompatible with the Metric, the Metric is left unchanged.
+type restorer interface {
+	restore(*dto.Metric)
+}
+
+// Snapshot gathers the Registry and serializes the values of all counters,
+// gauges, and histograms into a byte slice that can be handed to Restore,
+// usually of a Registry in a new instance of the same program after a graceful
+// in-place restart. Other metric types are not included. The format of the
+// returned byte slice is the delimited protobuf exposition format.
+//
+// If gathering returns an error, no snapshot is created, and the error is
+// returned.
+func (r *Registry) Snapshot() ([]byte, error) {
+	mfs, err := r.Gather()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim)
+	for _, mf := range mfs {
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER, dto.MetricType_GAUGE, dto.MetricType_HISTOGRAM:
+		default:
+			continue
+		}
+		if err := enc.Encode(mf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Restore loads the metric values from a snapshot created by Snapshot into
+// the metrics of the registered Collectors. Each series in the snapshot is
+// matched by metric name and label set to a metric currently collected by the
+// Registry. Children of registered CounterVecs, GaugeVecs, and HistogramVecs
+// are created as needed. Series without a matching metric, series whose type
+// doesn't match the type of the matching metric, and series of histograms with
+// different bucket boundaries are skipped. An error is only returned if the
+// snapshot cannot be decoded.
+//
+// Restored counter values and histogram observations are added to the current
+// values, while gauges are set to the restored value. Therefore, Restore should
+// be called once, after registering the Collectors but before any
+// observations, typically during startup. Note the following caveats:
+//
+//   - Only metrics implemented by this package (as returned by NewCounter,
+//     NewGauge, NewHistogram, and the respective vectors) can be restored.
+//     Metrics of custom Collectors, const metrics, and metrics created with
+//     NewCounterFunc and NewGaugeFunc are skipped.
+//   - Metrics collected via a Collector wrapped with WrapRegistererWith or
+//     WrapRegistererWithPrefix can be restored, but children of a wrapped
+//     vector are only restored if they already exist.
+//   - Native histogram buckets are not part of the restoration. Histograms
+//     with native buckets are skipped altogether to keep the histogram
+//     consistent.
+//   - Restoring preserves counter continuity for rate calculations only if
+//     the snapshot is restored promptly. Increments happening between taking
+//     the snapshot and stopping the old process are lost, and the (unchanged)
+//     created timestamps reflect the start of the new process.
+func (r *Registry) Restore(snapshot []byte) error {
+	seriesByKey := map[string]*dto.Metric{}
+	seriesByName := map[string][]*dto.Metric{}
+	dec := expfmt.NewDecoder(bytes.NewReader(snapshot), expfmt.FmtProtoDelim)
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("error decoding snapshot: %w", err)
+		}
+		for _, m := range mf.Metric {
+			seriesByKey[snapshotKey(mf.GetName(), m.Label)] = m
+		}
+		seriesByName[mf.GetName()] = append(seriesByName[mf.GetName()], mf.Metric...)
+	}
+
+	r.mtx.RLock()
+	collectors := make([]Collector, 0, len(r.collectorsByID)+len(r.uncheckedCollectors))
+	for _, c := range r.collectorsByID {
+		collectors = append(collectors, c)
+	}
+	collectors = append(collectors, r.uncheckedCollectors...)
+	r.mtx.RUnlock()
+
+	// Create missing children of vectors first so that they get collected
+	// below.
+	for _, c := range collectors {
+		vec := metricVecOf(c)
+		if vec == nil || len(vec.curry) > 0 {
+			continue
+		}
+		for _, m := range seriesByName[vec.desc.fqName] {
+			labels, ok := snapshotVariableLabels(vec.desc, m.Label)
+			if !ok {
+				continue
+			}
+			vec.GetMetricWith(labels) // An error simply means no restoration.
+		}
+	}
+
+	metricChan := make(chan Metric, capMetricChan)
+	go func() {
+		for _, c := range collectors {
+			c.Collect(metricChan)
+		}
+		close(metricChan)
+	}()
+	for metric := range metricChan {
+		dtoMetric := &dto.Metric{}
+		if err := metric.Write(dtoMetric); err != nil {
+			continue
+		}
+		snap, ok := seriesByKey[snapshotKey(metric.Desc().fqName, dtoMetric.Label)]
+		if !ok {
+			continue
+		}
+		for {
+			w, ok := metric.(*wrappingMetric)
+			if !ok {
+				break
+			}
+			metric = w.wrappedMetric
+		}
+		if res, ok := metric.(restorer); ok {
+			res.restore(snap)
+		}
+	}
+	return nil
+}
+
+// metricVecOf returns the MetricVec of the provided Collector if it is one of
+// the vectors of this package that can be restored, or nil otherwise.
+func metricVecOf(c Collector) *MetricVec {
+	switch v := c.(type) {
+	case *CounterVec:
+		return v.MetricVec
+	case *GaugeVec:
+		return v.MetricVec
+	case *HistogramVec:
+		return v.MetricVec
+	default:
+		return nil
+	}
+}
+
+// snapshotVariableLabels extracts the variable labels of desc from the
+// provided label pairs. It returns false if the label pairs are inconsistent
+// with desc.
+func snapshotVariableLabels(desc *Desc, lps []*dto.LabelPair) (Labels, bool) {
+	if len(lps) != len(desc.variableLabels.names)+len(desc.constLabelPairs) {
+		return nil, false
+	}
+	labels := make(Labels, len(desc.variableLabels.names))
+	for _, lp := range lps {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	for _, clp := range desc.constLabelPairs {
+		if v, ok := labels[clp.GetName()]; !ok || v != clp.GetValue() {
+			return nil, false
+		}
+		delete(labels, clp.GetName())
+	}
+	for _, n := range desc.variableLabels.names {
+		if _, ok := labels[n]; !ok {
+			return nil, false
+		}
+	}
+	return labels, true
+}
+
+// snapshotKey returns a string that identifies a series by metric name and
+// label set.
+func snapshotKey(name string, lps []*dto.LabelPair) string {
+	sorted := make([]*dto.LabelPair, len(lps))
+	copy(sorted, lps)
+	sort.Sort(internal.LabelPairSorter(sorted))
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, lp := range sorted {
+		b.WriteByte(model.SeparatorByte)
+		b.WriteString(lp.GetName())
+		b.WriteByte(model.SeparatorByte)
+		b.WriteString(lp.GetValue())
+	}
+	return b.String()
+}
+
+func (c *counter) restore(m *dto.Metric) {
+	if m.Counter == nil {
+		return
+	}
+	v := m.Counter.GetValue()
+	if v < 0 || math.IsNaN(v) {
+		return
+	}
+	c.Add(v)
+}
+
+func (g *gauge) restore(m *dto.Metric) {
+	if m.Gauge == nil {
+		return
+	}
+	g.Set(m.Gauge.GetValue())
+}
+
+func (h *histogram) restore(m *dto.Metric) {
+	his := m.Histogram
+	if his == nil || h.nativeHistogramSchema > math.MinInt32 || his.Schema != nil {
+		return
+	}
+	// Ignore an explicit +Inf bucket, which is added if it has an exemplar.
+	buckets := his.Bucket
+	if n := len(buckets); n > 0 && math.IsInf(buckets[n-1].GetUpperBound(), +1) {
+		buckets = buckets[:n-1]
+	}
+	if len(buckets) != len(h.upperBounds) {
+		return
+	}
+	for i, b := range buckets {
+		if b.GetUpperBound() != h.upperBounds[i] {
+			return
+		}
+	}
+	count := his.GetSampleCount()
+	if count == 0 {
+		return
+	}
+	// Add all restored observations at once in the same way as observe
+	// does for a single observation.
+	n := atomic.AddUint64(&h.countAndHotIdx, count)
+	hotCounts := h.counts[n>>63]
+	var prev uint64
+	for i, b := range buckets {
+		atomic.AddUint64(&hotCounts.buckets[i], b.GetCumulativeCount()-prev)
+		prev = b.GetCumulativeCount()
+	}
+	hotCounts.addSum(his.GetSampleSum())
+	atomic.AddUint64(&hotCounts.count, count)
+}
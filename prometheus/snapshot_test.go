@@ -0,0 +1,146 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+type snapshotTestMetrics struct {
+	counter    Counter
+	counterVec *CounterVec
+	gauge      Gauge
+	histogram  Histogram
+	otherHist  Histogram
+	summary    Summary
+}
+
+func newSnapshotTestRegistry(otherBuckets []float64) (*Registry, snapshotTestMetrics) {
+	m := snapshotTestMetrics{
+		counter: NewCounter(CounterOpts{Name: "test_counter_total", Help: "help"}),
+		counterVec: NewCounterVec(
+			CounterOpts{Name: "test_counter_vec_total", Help: "help", ConstLabels: Labels{"const": "x"}},
+			[]string{"code"},
+		),
+		gauge:     NewGauge(GaugeOpts{Name: "test_gauge", Help: "help"}),
+		histogram: NewHistogram(HistogramOpts{Name: "test_histogram", Help: "help", Buckets: []float64{1, 2}}),
+		otherHist: NewHistogram(HistogramOpts{Name: "test_other_histogram", Help: "help", Buckets: otherBuckets}),
+		summary:   NewSummary(SummaryOpts{Name: "test_summary", Help: "help"}),
+	}
+	reg := NewRegistry()
+	reg.MustRegister(m.counter, m.counterVec, m.gauge, m.histogram, m.otherHist, m.summary)
+	return reg, m
+}
+
+func TestRegistrySnapshotRestore(t *testing.T) {
+	oldReg, old := newSnapshotTestRegistry([]float64{1})
+	old.counter.Add(3.5)
+	old.counterVec.WithLabelValues("200").Add(10)
+	old.counterVec.WithLabelValues("500").Inc()
+	old.gauge.Set(-7)
+	old.histogram.Observe(0.5)
+	old.histogram.Observe(1.5)
+	old.histogram.Observe(5)
+	old.otherHist.Observe(0.5)
+	old.summary.Observe(1)
+
+	snapshot, err := oldReg.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newReg, cur := newSnapshotTestRegistry([]float64{1, 10})
+	cur.counter.Inc()
+	if err := newReg.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(m Metric) *dto.Metric {
+		out := &dto.Metric{}
+		if err := m.Write(out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	if got, want := write(cur.counter).GetCounter().GetValue(), 4.5; got != want {
+		t.Errorf("got counter value %v, want %v", got, want)
+	}
+	if got, want := write(cur.counterVec.WithLabelValues("200")).GetCounter().GetValue(), 10.; got != want {
+		t.Errorf("got counter vec child value %v, want %v", got, want)
+	}
+	if got, want := write(cur.counterVec.WithLabelValues("500")).GetCounter().GetValue(), 1.; got != want {
+		t.Errorf("got counter vec child value %v, want %v", got, want)
+	}
+	if got, want := write(cur.gauge).GetGauge().GetValue(), -7.; got != want {
+		t.Errorf("got gauge value %v, want %v", got, want)
+	}
+
+	his := write(cur.histogram).GetHistogram()
+	if got, want := his.GetSampleCount(), uint64(3); got != want {
+		t.Errorf("got histogram count %v, want %v", got, want)
+	}
+	if got, want := his.GetSampleSum(), 7.; got != want {
+		t.Errorf("got histogram sum %v, want %v", got, want)
+	}
+	for i, want := range []uint64{1, 2} {
+		if got := his.Bucket[i].GetCumulativeCount(); got != want {
+			t.Errorf("got cumulative count %v for bucket %v, want %v", got, his.Bucket[i].GetUpperBound(), want)
+		}
+	}
+	// Histograms with changed buckets are skipped.
+	if got := write(cur.otherHist).GetHistogram().GetSampleCount(); got != 0 {
+		t.Errorf("got count %v for histogram with different buckets, want 0", got)
+	}
+	// Summaries are not part of the snapshot.
+	if got := write(cur.summary).GetSummary().GetSampleCount(); got != 0 {
+		t.Errorf("got summary count %v, want 0", got)
+	}
+
+	if err := newReg.Restore([]byte("garbage")); err == nil {
+		t.Error("expected error restoring an invalid snapshot")
+	}
+}
+
+func TestRegistryRestoreWrapped(t *testing.T) {
+	oldReg := NewRegistry()
+	oldCounter := NewCounter(CounterOpts{Name: "counter_total", Help: "help"})
+	WrapRegistererWith(Labels{"foo": "bar"}, WrapRegistererWithPrefix("prefix_", oldReg)).MustRegister(oldCounter)
+	oldCounter.Add(42)
+
+	snapshot, err := oldReg.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(snapshot), "prefix_counter_total") {
+		t.Fatalf("snapshot does not contain wrapped counter")
+	}
+
+	newReg := NewRegistry()
+	newCounter := NewCounter(CounterOpts{Name: "counter_total", Help: "help"})
+	WrapRegistererWith(Labels{"foo": "bar"}, WrapRegistererWithPrefix("prefix_", newReg)).MustRegister(newCounter)
+	if err := newReg.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	out := &dto.Metric{}
+	if err := newCounter.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.GetCounter().GetValue(), 42.; got != want {
+		t.Errorf("got counter value %v, want %v", got, want)
+	}
+}
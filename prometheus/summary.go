@@ -14,6 +14,7 @@
 package prometheus
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"runtime"
@@ -64,6 +65,35 @@ type Summary interface {
 	Observe(float64)
 }
 
+// ObjectivesLister is implemented by the Summaries provided by this package.
+// Its Objectives method returns a copy of the configured objectives, mapping
+// each quantile rank to its allowed absolute error. The returned map is empty
+// for a Summary without objectives. It is meant for inspection and debugging.
+type ObjectivesLister interface {
+	Objectives() map[float64]float64
+}
+
+// ObjectivesSetter is implemented by the Summaries provided by this package. Its
+// SetObjectives method changes which quantiles the Summary reports from then
+// on, mapping each quantile rank to its allowed absolute error like
+// SummaryOpts.Objectives. It is meant for experimenting with the reported
+// quantiles without restarting the process.
+//
+// Only the reported quantiles change, not the estimator: the quantiles are
+// queried from the existing estimator state, which keeps being tuned for the
+// objectives the Summary was created with. Quantile ranks that were not part
+// of the original objectives, or that are configured with a smaller error than
+// originally, are therefore not guaranteed to stay within the newly configured
+// error. SetObjectives returns an error (and changes nothing) if a quantile
+// rank is outside of [0, 1], if an error is negative or NaN, or if objectives
+// are set for a Summary that was created without objectives (in which case
+// there is no estimator to query at all).
+type ObjectivesSetter interface {
+	SetObjectives(map[float64]float64) error
+}
+
+var errSummaryWithoutObjectives = errors.New("summary was created without objectives")
+
 var errQuantileLabelNotAllowed = fmt.Errorf(
 	"%q is not allowed as label name in summaries", quantileLabel,
 )
@@ -118,6 +148,16 @@ type SummaryOpts struct {
 	// https://prometheus.io/docs/instrumenting/writing_exporters/#target-labels-not-static-scraped-labels
 	ConstLabels Labels
 
+	// Unit is the unit of this metric, e.g. "seconds" or "bytes". If set,
+	// it is exposed in "# UNIT" lines in the OpenMetrics format. As
+	// required by OpenMetrics, it must be a suffix of the fully-qualified
+	// name (ignoring a "_total" suffix), e.g. the unit of
+	// "http_request_duration_seconds" can only be "seconds". Otherwise,
+	// registration fails.
+	//
+	// Metrics with the same fully-qualified name must have the same Unit.
+	Unit string
+
 	// Objectives defines the quantile rank estimates with their respective
 	// absolute error. If Objectives[q] = e, then the value reported for q
 	// will be the φ-quantile value for some φ between q-e and q+e.  The
@@ -147,6 +187,25 @@ type SummaryOpts struct {
 	// "github.com/bmizerany/perks/quantile").
 	BufCap uint32
 
+	// Weight, if not nil, is called for each observation to determine its
+	// weight in the quantile estimation. An observation with weight w
+	// counts as w observations of the same value, e.g. it is possible to
+	// give more room to the tail of a latency distribution within the
+	// same amount of memory. The weight has no effect on _sum and _count.
+	// Observations with a weight that is not positive and finite are
+	// ignored for the quantile estimation. The default is a weight of 1
+	// for all observations.
+	//
+	// Note that the reported quantiles are then quantiles of the weighted
+	// distribution, i.e. the error bounds defined in Objectives apply to
+	// the weighted rank. With weights increasing with the observed value,
+	// the reported quantiles are therefore higher than the unweighted ones.
+	// As weights are relative, using weights of at least 1 is recommended.
+	// Otherwise, an age bucket with a total weight below 1 results in NaN
+	// quantiles. Weight is called with an internal lock held and must not
+	// call the Summary. It is ignored for summaries without Objectives.
+	Weight func(value float64) float64
+
 	// now is for testing purposes, by default it's time.Now.
 	now func() time.Time
 }
@@ -178,13 +237,16 @@ type SummaryVecOpts struct {
 // on scrape time (see code up commit 6b9530d72ea715f0ba612c0120e6e09fbf1d49d0)
 // can't be used anymore.
 
-// NewSummary creates a new Summary based on the provided SummaryOpts.
+// NewSummary creates a new Summary based on the provided SummaryOpts. The
+// returned implementation also implements DurationObserver, LazyObserver,
+// ObjectivesLister, and ObjectivesSetter.
 func NewSummary(opts SummaryOpts) Summary {
 	return newSummary(
-		NewDesc(
+		newDesc(
 			BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 			opts.Help,
-			nil,
+			opts.Unit,
+			UnconstrainedLabels(nil),
 			opts.ConstLabels,
 		),
 		opts,
@@ -244,14 +306,14 @@ func newSummary(desc *Desc, opts SummaryOpts, labelValues ...string) Summary {
 	s := &summary{
 		desc: desc,
 
-		objectives:       opts.Objectives,
-		sortedObjectives: make([]float64, 0, len(opts.Objectives)),
+		targets: opts.Objectives,
 
 		labelPairs: MakeLabelPairs(desc, labelValues),
 
 		hotBuf:         make([]float64, 0, opts.BufCap),
 		coldBuf:        make([]float64, 0, opts.BufCap),
 		streamDuration: opts.MaxAge / time.Duration(opts.AgeBuckets),
+		weight:         opts.Weight,
 	}
 	s.headStreamExpTime = opts.now().Add(s.streamDuration)
 	s.hotBufExpTime = s.headStreamExpTime
@@ -261,10 +323,7 @@ func newSummary(desc *Desc, opts SummaryOpts, labelValues ...string) Summary {
 	}
 	s.headStream = s.streams[0]
 
-	for qu := range s.objectives {
-		s.sortedObjectives = append(s.sortedObjectives, qu)
-	}
-	sort.Float64s(s.sortedObjectives)
+	s.setObjectives(opts.Objectives)
 
 	s.init(s) // Init self-collection.
 	s.createdTs = timestamppb.New(opts.now())
@@ -280,8 +339,9 @@ type summary struct {
 
 	desc *Desc
 
-	objectives       map[float64]float64
-	sortedObjectives []float64
+	targets          map[float64]float64 // The objectives the streams are tuned for.
+	objectives       map[float64]float64 // The reported objectives, protected by mtx.
+	sortedObjectives []float64           // Protected by mtx.
 
 	labelPairs []*dto.LabelPair
 
@@ -290,6 +350,9 @@ type summary struct {
 
 	hotBuf, coldBuf []float64
 
+	weight          func(float64) float64
+	weightedSamples quantile.Samples // Only used if weight != nil.
+
 	streams                          []*quantile.Stream
 	streamDuration                   time.Duration
 	headStream                       *quantile.Stream
@@ -321,10 +384,9 @@ func (s *summary) Write(out *dto.Metric) error {
 	sum := &dto.Summary{
 		CreatedTimestamp: s.createdTs,
 	}
-	qs := make([]*dto.Quantile, 0, len(s.objectives))
-
 	s.bufMtx.Lock()
 	s.mtx.Lock()
+	qs := make([]*dto.Quantile, 0, len(s.sortedObjectives))
 	// Swap bufs even if hotBuf is empty to set new hotBufExpTime.
 	s.swapBufs(time.Now())
 	s.bufMtx.Unlock()
@@ -358,8 +420,68 @@ func (s *summary) Write(out *dto.Metric) error {
 	return nil
 }
 
+// ObserveDuration implements DurationObserver.
+func (s *summary) ObserveDuration(d time.Duration) {
+	s.Observe(d.Seconds())
+}
+
+// MaybeObserve implements LazyObserver.
+func (s *summary) MaybeObserve(f func() float64) {
+	s.Observe(f())
+}
+
+// Objectives implements ObjectivesLister.
+func (s *summary) Objectives() map[float64]float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	objectives := make(map[float64]float64, len(s.objectives))
+	for rank, epsilon := range s.objectives {
+		objectives[rank] = epsilon
+	}
+	return objectives
+}
+
+// SetObjectives implements ObjectivesSetter.
+func (s *summary) SetObjectives(objectives map[float64]float64) error {
+	if err := validateObjectives(objectives); err != nil {
+		return err
+	}
+	copied := make(map[float64]float64, len(objectives))
+	for rank, epsilon := range objectives {
+		copied[rank] = epsilon
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.setObjectives(copied)
+	return nil
+}
+
+// setObjectives needs mtx locked (or s not yet shared).
+func (s *summary) setObjectives(objectives map[float64]float64) {
+	s.objectives = objectives
+	s.sortedObjectives = make([]float64, 0, len(objectives))
+	for qu := range objectives {
+		s.sortedObjectives = append(s.sortedObjectives, qu)
+	}
+	sort.Float64s(s.sortedObjectives)
+}
+
+func validateObjectives(objectives map[float64]float64) error {
+	for rank, epsilon := range objectives {
+		if !(rank >= 0 && rank <= 1) {
+			return fmt.Errorf("quantile rank %v not in [0, 1]", rank)
+		}
+		if !(epsilon >= 0) {
+			return fmt.Errorf("illegal error %v for quantile rank %v", epsilon, rank)
+		}
+	}
+	return nil
+}
+
 func (s *summary) newStream() *quantile.Stream {
-	return quantile.NewTargeted(s.objectives)
+	return quantile.NewTargeted(s.targets)
 }
 
 // asyncFlush needs bufMtx locked.
@@ -391,6 +513,10 @@ func (s *summary) maybeRotateStreams() {
 
 // flushColdBuf needs mtx locked.
 func (s *summary) flushColdBuf() {
+	if s.weight != nil {
+		s.flushColdBufWeighted()
+		return
+	}
 	for _, v := range s.coldBuf {
 		for _, stream := range s.streams {
 			stream.Insert(v)
@@ -402,6 +528,31 @@ func (s *summary) flushColdBuf() {
 	s.maybeRotateStreams()
 }
 
+// flushColdBufWeighted is the version of flushColdBuf for weighted
+// observations. The quantile.Stream only offers weighted samples via Merge,
+// which is fine here, as merging a batch of raw samples is exactly what
+// Insert does internally once its buffer is full. Merge sorts the samples in
+// place, so they are only sorted for the first stream.
+//
+// flushColdBufWeighted needs mtx locked.
+func (s *summary) flushColdBufWeighted() {
+	s.weightedSamples = s.weightedSamples[:0]
+	for _, v := range s.coldBuf {
+		if w := s.weight(v); w > 0 && !math.IsInf(w, 0) {
+			s.weightedSamples = append(s.weightedSamples, quantile.Sample{Value: v, Width: w})
+		}
+		s.cnt++
+		s.sum += v
+	}
+	if len(s.weightedSamples) > 0 {
+		for _, stream := range s.streams {
+			stream.Merge(s.weightedSamples)
+		}
+	}
+	s.coldBuf = s.coldBuf[0:0]
+	s.maybeRotateStreams()
+}
+
 // swapBufs needs mtx AND bufMtx locked, coldBuf must be empty.
 func (s *summary) swapBufs(now time.Time) {
 	if len(s.coldBuf) != 0 {
@@ -527,6 +678,29 @@ func (s *noObjectivesSummary) Write(out *dto.Metric) error {
 	return nil
 }
 
+// ObserveDuration implements DurationObserver.
+func (s *noObjectivesSummary) ObserveDuration(d time.Duration) {
+	s.Observe(d.Seconds())
+}
+
+// MaybeObserve implements LazyObserver.
+func (s *noObjectivesSummary) MaybeObserve(f func() float64) {
+	s.Observe(f())
+}
+
+// Objectives implements ObjectivesLister.
+func (s *noObjectivesSummary) Objectives() map[float64]float64 {
+	return map[float64]float64{}
+}
+
+// SetObjectives implements ObjectivesSetter.
+func (s *noObjectivesSummary) SetObjectives(objectives map[float64]float64) error {
+	if len(objectives) == 0 {
+		return nil
+	}
+	return errSummaryWithoutObjectives
+}
+
 type quantSort []*dto.Quantile
 
 func (s quantSort) Len() int {
@@ -570,9 +744,10 @@ func (v2) NewSummaryVec(opts SummaryVecOpts) *SummaryVec {
 			panic(errQuantileLabelNotAllowed)
 		}
 	}
-	desc := V2.NewDesc(
+	desc := newDesc(
 		BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
 		opts.Help,
+		opts.Unit,
 		opts.VariableLabels,
 		opts.ConstLabels,
 	)
@@ -599,7 +774,8 @@ func (v2) NewSummaryVec(opts SummaryVecOpts) *SummaryVec {
 // example.
 //
 // An error is returned if the number of label values is not the same as the
-// number of variable labels in Desc (minus any curried labels).
+// number of variable labels in Desc (minus any curried labels) or if any of the
+// label values is not valid UTF-8.
 //
 // Note that for more than one label value, this method is prone to mistakes
 // caused by an incorrect order of arguments. Consider GetMetricWith(Labels) as
@@ -622,7 +798,8 @@ func (v *SummaryVec) GetMetricWithLabelValues(lvs ...string) (Observer, error) {
 // the same as for GetMetricWithLabelValues.
 //
 // An error is returned if the number and names of the Labels are inconsistent
-// with those of the variable labels in Desc (minus any curried labels).
+// with those of the variable labels in Desc (minus any curried labels) or if
+// any of the label values is not valid UTF-8.
 //
 // This method is used for the same purpose as
 // GetMetricWithLabelValues(...string). See there for pros and cons of the two
@@ -14,15 +14,19 @@
 package prometheus
 
 import (
+	"bytes"
 	"math"
 	"math/rand"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"testing/quick"
 	"time"
 
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
 func TestSummaryWithDefaultObjectives(t *testing.T) {
@@ -79,6 +83,173 @@ func TestSummaryWithoutObjectives(t *testing.T) {
 	}
 }
 
+func TestSummaryObjectives(t *testing.T) {
+	objectives := map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001, 0.999: 0.0001, 1e-05: 1e-06}
+	s := NewSummary(SummaryOpts{
+		Name:       "test_summary",
+		Help:       "helpless",
+		Objectives: objectives,
+	})
+
+	got := s.(ObjectivesLister).Objectives()
+	if !reflect.DeepEqual(got, objectives) {
+		t.Errorf("got objectives %v, want %v", got, objectives)
+	}
+	// The returned map is a copy.
+	got[0.5] = 1
+	if s.(ObjectivesLister).Objectives()[0.5] != 0.05 {
+		t.Error("modifying the returned objectives changed the summary")
+	}
+	if got := NewSummary(SummaryOpts{Name: "test", Help: "helpless"}).(ObjectivesLister).Objectives(); len(got) != 0 {
+		t.Errorf("expected no objectives, got %v", got)
+	}
+
+	s.Observe(1)
+	reg := NewRegistry()
+	reg.MustRegister(s)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := expfmt.MetricFamilyToText(&buf, mfs[0]); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`test_summary{quantile="1e-05"} 1`,
+		`test_summary{quantile="0.5"} 1`,
+		`test_summary{quantile="0.9"} 1`,
+		`test_summary{quantile="0.99"} 1`,
+		`test_summary{quantile="0.999"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("expected line %q in output:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestSummarySetObjectives(t *testing.T) {
+	s := NewSummary(SummaryOpts{
+		Name:       "test_summary",
+		Help:       "helpless",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01},
+	})
+	for i := 1; i <= 100; i++ {
+		s.Observe(float64(i))
+	}
+
+	for _, objectives := range []map[float64]float64{
+		{1.5: 0.01},
+		{0.5: -0.01},
+		{0.5: math.NaN()},
+	} {
+		if err := s.(ObjectivesSetter).SetObjectives(objectives); err == nil {
+			t.Errorf("expected error for objectives %v", objectives)
+		}
+	}
+
+	objectives := map[float64]float64{0.5: 0.05, 0.99: 0.001}
+	if err := s.(ObjectivesSetter).SetObjectives(objectives); err != nil {
+		t.Fatal(err)
+	}
+	objectives[0.1] = 0.01 // SetObjectives copies the map.
+	if got, want := s.(ObjectivesLister).Objectives(), map[float64]float64{0.5: 0.05, 0.99: 0.001}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got objectives %v, want %v", got, want)
+	}
+
+	m := &dto.Metric{}
+	if err := s.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	qs := m.GetSummary().GetQuantile()
+	if len(qs) != 2 || qs[0].GetQuantile() != 0.5 || qs[1].GetQuantile() != 0.99 {
+		t.Fatalf("unexpected quantiles %v", qs)
+	}
+	// The estimator state is kept, so the new quantile is reported right away.
+	if got := qs[1].GetValue(); got < 95 || got > 100 {
+		t.Errorf("got 0.99 quantile %v, want about 99", got)
+	}
+	if got := m.GetSummary().GetSampleCount(); got != 100 {
+		t.Errorf("got sample count %d, want 100", got)
+	}
+
+	noObjectives := NewSummary(SummaryOpts{Name: "test", Help: "helpless"})
+	if err := noObjectives.(ObjectivesSetter).SetObjectives(map[float64]float64{0.5: 0.05}); err == nil {
+		t.Error("expected error setting objectives for a summary without objectives")
+	}
+	if err := noObjectives.(ObjectivesSetter).SetObjectives(nil); err != nil {
+		t.Errorf("unexpected error clearing objectives: %v", err)
+	}
+}
+
+func TestSummaryWithWeight(t *testing.T) {
+	objectives := map[float64]float64{0.5: 0.01, 0.99: 0.001}
+	// Observations above 5000 count three times.
+	weight := func(v float64) float64 {
+		if v > 5000 {
+			return 3
+		}
+		return 1
+	}
+	// Weights that are not positive and finite are ignored.
+	invalidWeight := func(v float64) float64 {
+		if v > 5000 {
+			return math.NaN()
+		}
+		return 0
+	}
+
+	for _, tc := range []struct {
+		name       string
+		weight     func(float64) float64
+		wantMedian float64
+		wantNaN    bool
+	}{
+		{name: "unweighted", wantMedian: 5000},
+		{name: "constant weight", weight: func(float64) float64 { return 1 }, wantMedian: 5000},
+		// Weighted rank of the median is 10000 of 20000, reached at
+		// 5000 + 5000/3.
+		{name: "tail weighted", weight: weight, wantMedian: 5000 + 5000.0/3},
+		{name: "invalid weights", weight: invalidWeight, wantNaN: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewSummary(SummaryOpts{
+				Name:       "test_summary",
+				Help:       "helpless",
+				Objectives: objectives,
+				Weight:     tc.weight,
+			})
+			for _, i := range rand.Perm(10000) {
+				s.Observe(float64(i + 1))
+			}
+
+			m := &dto.Metric{}
+			if err := s.Write(m); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := m.GetSummary().GetSampleCount(), uint64(10000); got != want {
+				t.Errorf("got sample count %d, want %d", got, want)
+			}
+			if got, want := m.GetSummary().GetSampleSum(), float64(10000*10001/2); got != want {
+				t.Errorf("got sample sum %f, want %f", got, want)
+			}
+			median := m.GetSummary().GetQuantile()[0].GetValue()
+			if tc.wantNaN {
+				if !math.IsNaN(median) {
+					t.Errorf("got median %f, want NaN", median)
+				}
+				return
+			}
+			// The rank error of 0.01 is at most 200 of the total
+			// weight of 20000, which translates into at most 200
+			// in value.
+			if math.Abs(median-tc.wantMedian) > 200 {
+				t.Errorf("got median %f, want %f", median, tc.wantMedian)
+			}
+		})
+	}
+}
+
 func TestSummaryWithQuantileLabel(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
@@ -0,0 +1,240 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// DiffKind describes how a series differs between two Gatherers.
+type DiffKind int
+
+// These constants define the possible DiffKind values.
+const (
+	// SeriesAdded means the series is only present in the second Gatherer.
+	SeriesAdded DiffKind = iota
+	// SeriesRemoved means the series is only present in the first Gatherer.
+	SeriesRemoved
+	// SeriesChanged means the series is present in both Gatherers but with
+	// different values.
+	SeriesChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case SeriesAdded:
+		return "added"
+	case SeriesRemoved:
+		return "removed"
+	case SeriesChanged:
+		return "changed"
+	default:
+		return "DiffKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// SeriesDiff is a single difference between the series of two Gatherers, as
+// returned by DiffGatherers.
+type SeriesDiff struct {
+	// Name is the name of the series as it appears in the text format,
+	// e.g. "http_request_duration_seconds_bucket" for a bucket of a
+	// histogram.
+	Name string
+	// Labels are the labels of the series, including "le" and "quantile"
+	// labels of histograms and summaries.
+	Labels map[string]string
+	Kind   DiffKind
+	// Old is the value in the first Gatherer. It is 0 for added series.
+	Old float64
+	// New is the value in the second Gatherer. It is 0 for removed series.
+	New float64
+}
+
+// String returns the series in the text format together with the difference,
+// e.g. `requests_total{code="200"} changed: 3 -> 4`.
+func (d SeriesDiff) String() string {
+	switch d.Kind {
+	case SeriesAdded:
+		return fmt.Sprintf("%s added: %v", seriesString(d.Name, d.Labels), d.New)
+	case SeriesRemoved:
+		return fmt.Sprintf("%s removed: %v", seriesString(d.Name, d.Labels), d.Old)
+	default:
+		return fmt.Sprintf("%s %s: %v -> %v", seriesString(d.Name, d.Labels), d.Kind, d.Old, d.New)
+	}
+}
+
+// DiffOpts are the options for DiffGatherers.
+type DiffOpts struct {
+	// Tolerance is the maximum absolute difference between two values
+	// that are still considered equal. With the default of 0, values have
+	// to be exactly equal. NaN values are considered equal to each other.
+	Tolerance float64
+	// MetricNames restricts the comparison to the metric families with the
+	// provided names. All metric families are compared if empty.
+	MetricNames []string
+}
+
+// DiffGatherers gathers the metrics from both provided Gatherers and compares
+// them series by series. It returns the series that have been added, removed,
+// or changed from a to b, sorted by name and labels. This is useful to compare
+// the metrics of two instances of a program, e.g. during a canary rollout, in a
+// more structured way than with GatherAndCompare.
+//
+// Histograms and summaries are broken down into their individual series as in
+// the text format, i.e. each bucket, quantile, sum, and count is compared
+// separately. Native histogram buckets, exemplars, timestamps, help strings,
+// and metric types are not compared.
+func DiffGatherers(a, b prometheus.Gatherer, opts DiffOpts) ([]SeriesDiff, error) {
+	oldSeries, err := gatherSeries(a, opts.MetricNames)
+	if err != nil {
+		return nil, fmt.Errorf("gathering first metrics failed: %w", err)
+	}
+	newSeries, err := gatherSeries(b, opts.MetricNames)
+	if err != nil {
+		return nil, fmt.Errorf("gathering second metrics failed: %w", err)
+	}
+
+	var diffs []SeriesDiff
+	for key, o := range oldSeries {
+		n, ok := newSeries[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, SeriesDiff{Name: o.name, Labels: o.labels, Kind: SeriesRemoved, Old: o.value})
+		case !withinTolerance(o.value, n.value, opts.Tolerance):
+			diffs = append(diffs, SeriesDiff{Name: o.name, Labels: o.labels, Kind: SeriesChanged, Old: o.value, New: n.value})
+		}
+	}
+	for key, n := range newSeries {
+		if _, ok := oldSeries[key]; !ok {
+			diffs = append(diffs, SeriesDiff{Name: n.name, Labels: n.labels, Kind: SeriesAdded, New: n.value})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return seriesString(diffs[i].Name, diffs[i].Labels) < seriesString(diffs[j].Name, diffs[j].Labels)
+	})
+	return diffs, nil
+}
+
+type series struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// gatherSeries returns the series of the provided Gatherer by their string
+// representation.
+func gatherSeries(g prometheus.Gatherer, metricNames []string) (map[string]series, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	if len(metricNames) > 0 {
+		mfs = filterMetrics(mfs, metricNames)
+	}
+	result := map[string]series{}
+	add := func(name string, m *dto.Metric, value float64, extraName, extraValue string) {
+		labels := make(map[string]string, len(m.Label)+1)
+		for _, lp := range m.Label {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		if extraName != "" {
+			labels[extraName] = extraValue
+		}
+		result[seriesString(name, labels)] = series{name: name, labels: labels, value: value}
+	}
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			switch {
+			case m.Counter != nil:
+				add(name, m, m.Counter.GetValue(), "", "")
+			case m.Gauge != nil:
+				add(name, m, m.Gauge.GetValue(), "", "")
+			case m.Untyped != nil:
+				add(name, m, m.Untyped.GetValue(), "", "")
+			case m.Summary != nil:
+				for _, q := range m.Summary.Quantile {
+					add(name, m, q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+				}
+				add(name+"_sum", m, m.Summary.GetSampleSum(), "", "")
+				add(name+"_count", m, float64(m.Summary.GetSampleCount()), "", "")
+			case m.Histogram != nil:
+				var hasInf bool
+				for _, b := range m.Histogram.Bucket {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						hasInf = true
+					}
+					add(name+"_bucket", m, float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound()))
+				}
+				if !hasInf {
+					add(name+"_bucket", m, float64(m.Histogram.GetSampleCount()), "le", "+Inf")
+				}
+				add(name+"_sum", m, m.Histogram.GetSampleSum(), "", "")
+				add(name+"_count", m, float64(m.Histogram.GetSampleCount()), "", "")
+			}
+		}
+	}
+	return result, nil
+}
+
+// seriesString returns the series in the text format, with sorted labels.
+func seriesString(name string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(names) == 0 {
+		return sb.String()
+	}
+	sb.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(n)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(labels[n]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, +1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+func withinTolerance(a, b, tolerance float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b || math.Abs(a-b) <= tolerance
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"errors"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func TestDiffGatherers(t *testing.T) {
+	newRegistry := func(requests map[string]float64, latency []float64, temperature float64) *prometheus.Registry {
+		reg := prometheus.NewRegistry()
+		c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "help"}, []string{"code"})
+		for code, v := range requests {
+			c.WithLabelValues(code).Add(v)
+		}
+		h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency_seconds", Help: "help", Buckets: []float64{1}})
+		for _, v := range latency {
+			h.Observe(v)
+		}
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "temperature", Help: "help"})
+		g.Set(temperature)
+		reg.MustRegister(c, h, g)
+		return reg
+	}
+	a := newRegistry(map[string]float64{"200": 10, "500": 1}, []float64{0.5}, 20)
+	b := newRegistry(map[string]float64{"200": 10, "404": 2}, []float64{0.5, 2}, 20.05)
+
+	diffs, err := DiffGatherers(a, b, DiffOpts{Tolerance: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`latency_seconds_bucket{le="+Inf"} changed: 1 -> 2`,
+		`latency_seconds_count changed: 1 -> 2`,
+		`latency_seconds_sum changed: 0.5 -> 2.5`,
+		`requests_total{code="404"} added: 2`,
+		`requests_total{code="500"} removed: 1`,
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d diffs %v, want %d", len(diffs), diffs, len(want))
+	}
+	for i, d := range diffs {
+		if got := d.String(); got != want[i] {
+			t.Errorf("diff %d: got %q, want %q", i, got, want[i])
+		}
+	}
+	if d := diffs[0]; d.Name != "latency_seconds_bucket" || d.Labels["le"] != "+Inf" || d.Kind != SeriesChanged || d.Old != 1 || d.New != 2 {
+		t.Errorf("unexpected diff %+v", d)
+	}
+
+	// Without tolerance, the gauge differs, too.
+	diffs, err = DiffGatherers(a, b, DiffOpts{MetricNames: []string{"temperature"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Name != "temperature" || diffs[0].Kind != SeriesChanged {
+		t.Errorf("unexpected diffs %v", diffs)
+	}
+
+	// No differences with itself.
+	if diffs, err := DiffGatherers(a, a, DiffOpts{}); err != nil || len(diffs) != 0 {
+		t.Errorf("got diffs %v and error %v comparing a Gatherer with itself", diffs, err)
+	}
+
+	failing := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, errors.New("gather error")
+	})
+	if _, err := DiffGatherers(a, failing, DiffOpts{}); err == nil {
+		t.Error("expected error from failing Gatherer")
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+// CollectAndCompareExemplars registers the provided Collector with a newly
+// created pedantic Registry and gathers the exemplars of the metric family
+// with the provided name. Those are the exemplars of counters and of the
+// buckets of histograms, in the order of the metrics within the family (and of
+// the buckets within a histogram). It then compares them with the expected
+// exemplars and returns an error if they do not match. Label sets are compared
+// regardless of their order, and values must be equal (with NaN being equal to
+// NaN). Timestamps are only compared if the expected exemplar has one, in which
+// case it has to match exactly. Use CollectAndCompareExemplarsWithTolerance to
+// allow a deviation of the timestamps.
+func CollectAndCompareExemplars(c prometheus.Collector, expected []*dto.Exemplar, metricName string) error {
+	return CollectAndCompareExemplarsWithTolerance(c, expected, metricName, 0)
+}
+
+// CollectAndCompareExemplarsWithTolerance works like
+// CollectAndCompareExemplars, but the timestamp of a gathered exemplar may
+// deviate by up to the provided tolerance from the expected timestamp. This is
+// useful for exemplars that are timestamped with the current time upon
+// observation.
+func CollectAndCompareExemplarsWithTolerance(c prometheus.Collector, expected []*dto.Exemplar, metricName string, tolerance time.Duration) error {
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		return fmt.Errorf("registering collector failed: %w", err)
+	}
+	got, err := reg.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics failed: %w", err)
+	}
+	return compareExemplars(extractExemplars(got, metricName), expected, tolerance)
+}
+
+// extractExemplars returns the exemplars of the metric family with the
+// provided name.
+func extractExemplars(mfs []*dto.MetricFamily, metricName string) []*dto.Exemplar {
+	var exemplars []*dto.Exemplar
+	for _, mf := range mfs {
+		if mf.GetName() != metricName {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if e := m.GetCounter().GetExemplar(); e != nil {
+				exemplars = append(exemplars, e)
+			}
+			for _, b := range m.GetHistogram().GetBucket() {
+				if e := b.GetExemplar(); e != nil {
+					exemplars = append(exemplars, e)
+				}
+			}
+		}
+	}
+	return exemplars
+}
+
+// compareExemplars returns an error listing both the expected and the gathered
+// exemplars if they do not match.
+func compareExemplars(got, want []*dto.Exemplar, tolerance time.Duration) error {
+	match := len(got) == len(want)
+	for i := 0; match && i < len(got); i++ {
+		match = exemplarMatches(got[i], want[i], tolerance)
+	}
+	if match {
+		return nil
+	}
+	return fmt.Errorf(
+		"exemplars do not match expectation; want:\n%s\ngot:\n%s",
+		formatExemplars(want), formatExemplars(got),
+	)
+}
+
+func exemplarMatches(got, want *dto.Exemplar, tolerance time.Duration) bool {
+	if got.GetValue() != want.GetValue() && !(math.IsNaN(got.GetValue()) && math.IsNaN(want.GetValue())) {
+		return false
+	}
+	if formatExemplarLabels(got) != formatExemplarLabels(want) {
+		return false
+	}
+	if want.Timestamp == nil {
+		return true
+	}
+	if got.Timestamp == nil {
+		return false
+	}
+	d := got.Timestamp.AsTime().Sub(want.Timestamp.AsTime())
+	return d <= tolerance && d >= -tolerance
+}
+
+// formatExemplarLabels returns the labels of the provided exemplar in the text
+// format, sorted by label name.
+func formatExemplarLabels(e *dto.Exemplar) string {
+	pairs := make([]string, 0, len(e.GetLabel()))
+	for _, lp := range e.GetLabel() {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", lp.GetName(), lp.GetValue()))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatExemplars(exemplars []*dto.Exemplar) string {
+	if len(exemplars) == 0 {
+		return "\t(none)\n"
+	}
+	var b strings.Builder
+	for _, e := range exemplars {
+		fmt.Fprintf(&b, "\t%s %g", formatExemplarLabels(e), e.GetValue())
+		if e.Timestamp != nil {
+			fmt.Fprintf(&b, " %s", e.Timestamp.AsTime().Format(time.RFC3339Nano))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+)
+
+func exemplar(value float64, ts *timestamppb.Timestamp, kv ...string) *dto.Exemplar {
+	e := &dto.Exemplar{Value: proto.Float64(value), Timestamp: ts}
+	for i := 0; i < len(kv); i += 2 {
+		e.Label = append(e.Label, &dto.LabelPair{Name: proto.String(kv[i]), Value: proto.String(kv[i+1])})
+	}
+	return e
+}
+
+func TestCollectAndCompareExemplars(t *testing.T) {
+	his := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "request_duration_seconds",
+		Help:    "Duration of requests.",
+		Buckets: []float64{0.1, 1},
+	})
+	his.(prometheus.ExemplarObserver).ObserveWithExemplar(0.05, prometheus.Labels{"trace_id": "a", "span_id": "1"})
+	his.(prometheus.ExemplarObserver).ObserveWithExemplar(0.5, prometheus.Labels{"trace_id": "b"})
+
+	// Labels are compared regardless of their order, timestamps are ignored.
+	expected := []*dto.Exemplar{
+		exemplar(0.05, nil, "trace_id", "a", "span_id", "1"),
+		exemplar(0.5, nil, "trace_id", "b"),
+	}
+	if err := CollectAndCompareExemplars(his, expected, "request_duration_seconds"); err != nil {
+		t.Error(err)
+	}
+	if err := CollectAndCompareExemplars(his, nil, "other_metric"); err != nil {
+		t.Error(err)
+	}
+
+	for name, expected := range map[string][]*dto.Exemplar{
+		"missing exemplar": expected[:1],
+		"wrong value":      {expected[0], exemplar(0.25, nil, "trace_id", "b")},
+		"wrong labels":     {expected[0], exemplar(0.5, nil, "trace_id", "c")},
+	} {
+		err := CollectAndCompareExemplars(his, expected, "request_duration_seconds")
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.Contains(err.Error(), `{trace_id="b"} 0.5`) {
+			t.Errorf("%s: error doesn't contain gathered exemplar: %v", name, err)
+		}
+	}
+}
+
+func TestCollectAndCompareExemplarsWithTolerance(t *testing.T) {
+	cnt := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "requests_total",
+		Help: "Number of requests.",
+	})
+	now := time.Now()
+	cnt.(prometheus.ExemplarAdder).AddWithExemplar(1, prometheus.Labels{"trace_id": "a"})
+
+	expected := []*dto.Exemplar{exemplar(1, timestamppb.New(now), "trace_id", "a")}
+	if err := CollectAndCompareExemplarsWithTolerance(cnt, expected, "requests_total", time.Minute); err != nil {
+		t.Error(err)
+	}
+	expected = []*dto.Exemplar{exemplar(1, timestamppb.New(now.Add(-time.Hour)), "trace_id", "a")}
+	if err := CollectAndCompareExemplarsWithTolerance(cnt, expected, "requests_total", time.Minute); err == nil {
+		t.Error("expected error for timestamp outside of tolerance")
+	}
+}
@@ -26,11 +26,18 @@
 // single-value metric, but it has to be used with caution.
 //
 // End-to-end tests to verify all or larger parts of the metrics exposition can
-// be implemented with the CollectAndCompare or GatherAndCompare functions. The
+// be implemented with the CollectAndCompare or GatherAndCompare functions (or
+// their …Context variants, which bound the time a slow Collector may take). The
 // most appropriate use is not so much testing instrumentation of your code, but
 // testing custom prometheus.Collector implementations and in particular whole
 // exporters, i.e. programs that retrieve telemetry data from a 3rd party source
-// and convert it into Prometheus metrics.
+// and convert it into Prometheus metrics. As exemplars are not part of the
+// text exposition format used by those functions, CollectAndCompareExemplars is
+// provided to verify the exemplars of a metric.
+//
+// DiffGatherers compares the metrics of two Gatherers and returns the
+// differences series by series, which is useful to validate that two
+// instances of a program expose consistent metrics.
 //
 // In a similar pattern, CollectAndLint and GatherAndLint can be used to detect
 // metrics that have issues with their name, type, or metadata without being
@@ -39,6 +46,7 @@ package testutil
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -159,7 +167,52 @@ func GatherAndCount(g prometheus.Gatherer, metricNames ...string) (int, error) {
 // plain text format. Then it compares it with the results that the `expected` would return.
 // If the `metricNames` is not empty it would filter the comparison only to the given metric names.
 func ScrapeAndCompare(url string, expected io.Reader, metricNames ...string) error {
-	resp, err := http.Get(url)
+	return ScrapeAndCompareContext(context.Background(), url, expected, metricNames...)
+}
+
+// CollectAndCompare registers the provided Collector with a newly created
+// pedantic Registry. It then calls GatherAndCompare with that Registry and with
+// the provided metricNames.
+func CollectAndCompare(c prometheus.Collector, expected io.Reader, metricNames ...string) error {
+	return CollectAndCompareContext(context.Background(), c, expected, metricNames...)
+}
+
+// GatherAndCompare gathers all metrics from the provided Gatherer and compares
+// it to an expected output read from the provided Reader in the Prometheus text
+// exposition format. If any metricNames are provided, only metrics with those
+// names are compared.
+func GatherAndCompare(g prometheus.Gatherer, expected io.Reader, metricNames ...string) error {
+	return GatherAndCompareContext(context.Background(), g, expected, metricNames...)
+}
+
+// TransactionalGatherAndCompare gathers all metrics from the provided Gatherer and compares
+// it to an expected output read from the provided Reader in the Prometheus text
+// exposition format. If any metricNames are provided, only metrics with those
+// names are compared.
+func TransactionalGatherAndCompare(g prometheus.TransactionalGatherer, expected io.Reader, metricNames ...string) error {
+	got, done, err := g.Gather()
+	defer done()
+	if err != nil {
+		return fmt.Errorf("gathering metrics failed: %w", err)
+	}
+
+	wanted, err := convertReaderToMetricFamily(expected)
+	if err != nil {
+		return err
+	}
+
+	return compareMetricFamilies(got, wanted, metricNames...)
+}
+
+// ScrapeAndCompareContext works like ScrapeAndCompare, but the request is
+// performed with the provided context, so that it fails once the context is
+// canceled or its deadline is exceeded.
+func ScrapeAndCompareContext(ctx context.Context, url string, expected io.Reader, metricNames ...string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("scraping metrics failed: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("scraping metrics failed: %w", err)
 	}
@@ -183,34 +236,44 @@ func ScrapeAndCompare(url string, expected io.Reader, metricNames ...string) err
 	return compareMetricFamilies(scraped, wanted, metricNames...)
 }
 
-// CollectAndCompare registers the provided Collector with a newly created
-// pedantic Registry. It then calls GatherAndCompare with that Registry and with
-// the provided metricNames.
-func CollectAndCompare(c prometheus.Collector, expected io.Reader, metricNames ...string) error {
+// CollectAndCompareContext works like CollectAndCompare, but it returns an
+// error once the provided context is canceled or its deadline is exceeded,
+// even if the collection hasn't completed yet. See GatherAndCompareContext.
+func CollectAndCompareContext(ctx context.Context, c prometheus.Collector, expected io.Reader, metricNames ...string) error {
 	reg := prometheus.NewPedanticRegistry()
 	if err := reg.Register(c); err != nil {
 		return fmt.Errorf("registering collector failed: %w", err)
 	}
-	return GatherAndCompare(reg, expected, metricNames...)
+	return GatherAndCompareContext(ctx, reg, expected, metricNames...)
 }
 
-// GatherAndCompare gathers all metrics from the provided Gatherer and compares
-// it to an expected output read from the provided Reader in the Prometheus text
-// exposition format. If any metricNames are provided, only metrics with those
-// names are compared.
-func GatherAndCompare(g prometheus.Gatherer, expected io.Reader, metricNames ...string) error {
-	return TransactionalGatherAndCompare(prometheus.ToTransactionalGatherer(g), expected, metricNames...)
-}
+// GatherAndCompareContext works like GatherAndCompare, but it returns an error
+// once the provided context is canceled or its deadline is exceeded, even if
+// the gathering hasn't completed yet. This allows a test to fail cleanly
+// instead of hanging if a Collector blocks. As Gather cannot be interrupted,
+// it keeps running in the background in that case, and its result is thrown
+// away.
+func GatherAndCompareContext(ctx context.Context, g prometheus.Gatherer, expected io.Reader, metricNames ...string) error {
+	type result struct {
+		mfs []*dto.MetricFamily
+		err error
+	}
+	// Buffered so that the goroutine doesn't block forever after a timeout.
+	ch := make(chan result, 1)
+	go func() {
+		mfs, err := g.Gather()
+		ch <- result{mfs, err}
+	}()
 
-// TransactionalGatherAndCompare gathers all metrics from the provided Gatherer and compares
-// it to an expected output read from the provided Reader in the Prometheus text
-// exposition format. If any metricNames are provided, only metrics with those
-// names are compared.
-func TransactionalGatherAndCompare(g prometheus.TransactionalGatherer, expected io.Reader, metricNames ...string) error {
-	got, done, err := g.Gather()
-	defer done()
-	if err != nil {
-		return fmt.Errorf("gathering metrics failed: %w", err)
+	var got []*dto.MetricFamily
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			return fmt.Errorf("gathering metrics failed: %w", res.err)
+		}
+		got = res.mfs
+	case <-ctx.Done():
+		return fmt.Errorf("gathering metrics failed: %w", ctx.Err())
 	}
 
 	wanted, err := convertReaderToMetricFamily(expected)
@@ -14,11 +14,14 @@
 package testutil
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/adhimaswaskita/client_golang/prometheus"
 )
@@ -391,6 +394,67 @@ func TestScrapeAndCompareBadStatusCode(t *testing.T) {
 	}
 }
 
+type blockingCollector struct {
+	block chan struct{}
+}
+
+func (b blockingCollector) Describe(c chan<- *prometheus.Desc) {
+	c <- prometheus.NewDesc("blocking", "help", nil, nil)
+}
+
+func (b blockingCollector) Collect(c chan<- prometheus.Metric) {
+	<-b.block
+}
+
+func TestCollectAndCompareContext(t *testing.T) {
+	const expected = `
+		# HELP some_total A value that represents a counter.
+		# TYPE some_total counter
+
+		some_total{ label1 = "value1" } 1
+	`
+	c := prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "some_total",
+		Help:        "A value that represents a counter.",
+		ConstLabels: prometheus.Labels{"label1": "value1"},
+	})
+	c.Inc()
+
+	if err := CollectAndCompareContext(context.Background(), c, strings.NewReader(expected), "some_total"); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestCollectAndCompareContextDeadline(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := CollectAndCompareContext(ctx, blockingCollector{block}, strings.NewReader(""))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestScrapeAndCompareContextDeadline(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := ScrapeAndCompareContext(ctx, ts.URL, strings.NewReader(""), "some_total")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+}
+
 func TestCollectAndCount(t *testing.T) {
 	c := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
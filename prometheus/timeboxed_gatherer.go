@@ -0,0 +1,121 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+type timeboxedGatherer struct {
+	g Gatherer
+	d time.Duration
+}
+
+// NewTimeboxedGatherer returns a Gatherer that limits the duration of each
+// Gather call of the provided Gatherer to d. If gathering takes longer, the
+// returned Gatherer returns an error naming the timeout, together with
+// partial results where possible: If the provided Gatherer is a Gatherers
+// slice, its elements are gathered concurrently, and the metric families of
+// all elements that have completed in time are returned, merged in the same
+// way as Gatherers does. For any other Gatherer, no metric families are
+// returned upon timeout.
+//
+// This works at the Gatherer boundary and doesn't cancel anything. Gathering
+// that exceeds d keeps running in the background, and its eventual result is
+// thrown away. Thus, it is still recommended to implement a separate timeout in
+// potentially slow Collectors.
+func NewTimeboxedGatherer(g Gatherer, d time.Duration) Gatherer {
+	return &timeboxedGatherer{g: g, d: d}
+}
+
+type gatherResult struct {
+	mfs []*dto.MetricFamily
+	err error
+}
+
+// Gather implements Gatherer.
+func (tg *timeboxedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	gs, ok := tg.g.(Gatherers)
+	if !ok {
+		gs = Gatherers{tg.g}
+	}
+	// Buffered so that late results don't block the goroutines forever.
+	results := make([]chan gatherResult, len(gs))
+	for i, g := range gs {
+		results[i] = make(chan gatherResult, 1)
+		go func(g Gatherer, ch chan<- gatherResult) {
+			mfs, err := g.Gather()
+			ch <- gatherResult{mfs: mfs, err: err}
+		}(g, results[i])
+	}
+
+	timer := time.NewTimer(tg.d)
+	defer timer.Stop()
+	var (
+		completed Gatherers
+		expired   bool
+		timedOut  int
+	)
+	for _, ch := range results {
+		if !expired {
+			select {
+			case res := <-ch:
+				completed = append(completed, res.gatherer())
+				continue
+			case <-timer.C:
+				expired = true
+			}
+		}
+		// Once expired, only pick up results that are already available.
+		select {
+		case res := <-ch:
+			completed = append(completed, res.gatherer())
+		default:
+			timedOut++
+		}
+	}
+
+	switch {
+	case !ok && timedOut == 0:
+		// Return the result of a single Gatherer unchanged.
+		return completed[0].Gather()
+	case !ok:
+		return nil, fmt.Errorf("gathering timed out after %v", tg.d)
+	case timedOut == 0:
+		return completed.Gather()
+	}
+	mfs, err := completed.Gather()
+	errs := MultiError{}
+	if multiErr := (MultiError{}); errors.As(err, &multiErr) {
+		errs = append(errs, multiErr...)
+	} else {
+		errs.Append(err)
+	}
+	errs = append(errs, fmt.Errorf(
+		"gathering timed out after %v, %d of %d gatherers did not complete",
+		tg.d, timedOut, len(gs),
+	))
+	return mfs, errs
+}
+
+// gatherer returns a Gatherer that returns the result.
+func (res gatherResult) gatherer() Gatherer {
+	return GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return res.mfs, res.err
+	})
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/adhimaswaskita/client_golang/prometheus"
+	"github.com/adhimaswaskita/client_golang/prometheus/testutil"
+)
+
+func TestTimeboxedGatherer(t *testing.T) {
+	fast := prometheus.NewRegistry()
+	cnt := prometheus.NewCounter(prometheus.CounterOpts{Name: "fast_total", Help: "Fast."})
+	cnt.Inc()
+	fast.MustRegister(cnt)
+
+	block := make(chan struct{})
+	defer close(block)
+	slow := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		<-block
+		return nil, nil
+	})
+
+	const expected = `
+# HELP fast_total Fast.
+# TYPE fast_total counter
+fast_total 1
+`
+
+	// Everything completes in time.
+	g := prometheus.NewTimeboxedGatherer(prometheus.Gatherers{fast}, time.Minute)
+	if err := testutil.GatherAndCompare(g, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// Partial results of a Gatherers slice.
+	g = prometheus.NewTimeboxedGatherer(prometheus.Gatherers{slow, fast}, 10*time.Millisecond)
+	mfs, err := g.Gather()
+	if err == nil || !strings.Contains(err.Error(), "timed out after 10ms, 1 of 2 gatherers did not complete") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(mfs) != 1 || mfs[0].GetName() != "fast_total" {
+		t.Errorf("unexpected partial result %v", mfs)
+	}
+
+	// No partial results for a single Gatherer.
+	mfs, err = prometheus.NewTimeboxedGatherer(slow, 10*time.Millisecond).Gather()
+	if err == nil || !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(mfs) != 0 {
+		t.Errorf("unexpected result %v", mfs)
+	}
+}
@@ -20,6 +20,7 @@ import "time"
 type Timer struct {
 	begin    time.Time
 	observer Observer
+	now      func() time.Time
 }
 
 // NewTimer creates a new Timer. The provided Observer is used to observe a
@@ -45,19 +46,34 @@ func NewTimer(o Observer) *Timer {
 	return &Timer{
 		begin:    time.Now(),
 		observer: o,
+		now:      time.Now,
+	}
+}
+
+// NewTimerFunc is like NewTimer, but it uses the provided function instead of
+// time.Now to read the current time, both for the beginning of the Timer and
+// when observing the duration. This allows using a custom time source, e.g. a
+// simulated clock in tests. The durations are calculated with Time.Sub, so the
+// provided function should return times with a monotonic clock reading (as
+// time.Now does) or otherwise ensure that time does not go backwards.
+func NewTimerFunc(o Observer, now func() time.Time) *Timer {
+	return &Timer{
+		begin:    now(),
+		observer: o,
+		now:      now,
 	}
 }
 
 // ObserveDuration records the duration passed since the Timer was created with
-// NewTimer. It calls the Observe method of the Observer provided during
-// construction with the duration in seconds as an argument. The observed
-// duration is also returned. ObserveDuration is usually called with a defer
-// statement.
+// NewTimer or NewTimerFunc. It calls the Observe method of the Observer
+// provided during construction with the duration in seconds as an argument.
+// The observed duration is also returned. ObserveDuration is usually called
+// with a defer statement.
 //
 // Note that this method is only guaranteed to never observe negative durations
 // if used with Go1.9+.
 func (t *Timer) ObserveDuration() time.Duration {
-	d := time.Since(t.begin)
+	d := t.since()
 	if t.observer != nil {
 		t.observer.Observe(d.Seconds())
 	}
@@ -68,7 +84,7 @@ func (t *Timer) ObserveDuration() time.Duration {
 // observe exemplar with the duration unless exemplar is nil or provided Observer can't
 // be casted to ExemplarObserver.
 func (t *Timer) ObserveDurationWithExemplar(exemplar Labels) time.Duration {
-	d := time.Since(t.begin)
+	d := t.since()
 	eo, ok := t.observer.(ExemplarObserver)
 	if ok && exemplar != nil {
 		eo.ObserveWithExemplar(d.Seconds(), exemplar)
@@ -79,3 +95,12 @@ func (t *Timer) ObserveDurationWithExemplar(exemplar Labels) time.Duration {
 	}
 	return d
 }
+
+// since returns the duration passed since t.begin, using time.Now if t.now is
+// nil, as it is for a zero Timer.
+func (t *Timer) since() time.Duration {
+	if t.now == nil {
+		return time.Since(t.begin)
+	}
+	return t.now().Sub(t.begin)
+}
@@ -16,6 +16,7 @@ package prometheus
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
@@ -107,6 +108,36 @@ func TestTimerEmpty(t *testing.T) {
 	emptyTimer := NewTimer(nil)
 	emptyTimer.ObserveDuration()
 	// Do nothing, just demonstrate it works without panic.
+
+	// A zero Timer works, too, as it did before NewTimerFunc existed.
+	var zeroTimer Timer
+	zeroTimer.ObserveDuration()
+	zeroTimer.ObserveDurationWithExemplar(nil)
+}
+
+func TestTimerFunc(t *testing.T) {
+	var (
+		now      = time.Unix(1000, 0)
+		observed float64
+	)
+	timer := NewTimerFunc(ObserverFunc(func(v float64) { observed = v }), func() time.Time { return now })
+	now = now.Add(1500 * time.Millisecond)
+	if got, want := timer.ObserveDuration(), 1500*time.Millisecond; got != want {
+		t.Errorf("got duration %v, want %v", got, want)
+	}
+	if observed != 1.5 {
+		t.Errorf("got observed value %v, want 1.5", observed)
+	}
+
+	his := NewHistogram(HistogramOpts{Name: "test_histogram"})
+	timer = NewTimerFunc(his, func() time.Time { return now })
+	now = now.Add(2 * time.Second)
+	timer.ObserveDurationWithExemplar(Labels{"foo": "bar"})
+	m := &dto.Metric{}
+	his.Write(m)
+	if got := m.GetHistogram().GetSampleSum(); got != 2 {
+		t.Errorf("got sum %v, want 2", got)
+	}
 }
 
 func TestTimerConditionalTiming(t *testing.T) {
@@ -243,6 +243,12 @@ const ExemplarMaxRunes = 128
 // returned if any of the label names or values are invalid or if the total
 // number of runes in the label names and values exceeds ExemplarMaxRunes.
 func newExemplar(value float64, ts time.Time, l Labels) (*dto.Exemplar, error) {
+	return newExemplarWithMaxRunes(value, ts, l, ExemplarMaxRunes)
+}
+
+// newExemplarWithMaxRunes works like newExemplar but with a configurable limit
+// for the total number of runes in the label names and values.
+func newExemplarWithMaxRunes(value float64, ts time.Time, l Labels, maxRunes int) (*dto.Exemplar, error) {
 	e := &dto.Exemplar{}
 	e.Value = proto.Float64(value)
 	tsProto := timestamppb.New(ts)
@@ -266,8 +272,8 @@ func newExemplar(value float64, ts time.Time, l Labels) (*dto.Exemplar, error) {
 			Value: proto.String(value),
 		})
 	}
-	if runes > ExemplarMaxRunes {
-		return nil, fmt.Errorf("exemplar labels have %d runes, exceeding the limit of %d", runes, ExemplarMaxRunes)
+	if runes > maxRunes {
+		return nil, fmt.Errorf("exemplar labels have %d runes, exceeding the limit of %d", runes, maxRunes)
 	}
 	e.Label = labelPairs
 	return e, nil
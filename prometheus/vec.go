@@ -16,6 +16,9 @@ package prometheus
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/common/model"
 )
@@ -50,6 +53,7 @@ func NewMetricVec(desc *Desc, newMetric func(lvs ...string) Metric) *MetricVec {
 			metrics:   map[uint64][]metricWithLabelValues{},
 			desc:      desc,
 			newMetric: newMetric,
+			now:       time.Now,
 		},
 		hashAdd:     hashAdd,
 		hashAddByte: hashAddByte,
@@ -117,6 +121,19 @@ func (m *MetricVec) DeletePartialMatch(labels Labels) int {
 	return m.metricMap.deleteByLabels(labels, m.curry)
 }
 
+// DeleteFunc deletes all metrics for which pred returns true and returns the
+// number of metrics deleted. pred is called with all variable labels of each
+// metric. If called on a curried vector, only metrics matching the curried
+// labels are considered.
+//
+// The vector is locked while DeleteFunc iterates over the metrics, i.e. pred
+// is called with the lock held. Therefore, pred must not call any methods of
+// the vector (or of any vector curried from the same base vector), as that
+// would result in a deadlock.
+func (m *MetricVec) DeleteFunc(pred func(Labels) bool) int {
+	return m.metricMap.deleteByFunc(pred, m.curry)
+}
+
 // Without explicit forwarding of Describe, Collect, Reset, those methods won't
 // show up in GoDoc.
 
@@ -126,16 +143,104 @@ func (m *MetricVec) Describe(ch chan<- *Desc) { m.metricMap.Describe(ch) }
 // Collect implements Collector.
 func (m *MetricVec) Collect(ch chan<- Metric) { m.metricMap.Collect(ch) }
 
+// setTTL sets the TTL and initializes the last touch of all existing metrics.
+func (m *metricMap) setTTL(d time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.ttl = d
+	if d <= 0 {
+		return
+	}
+	now := m.now().UnixNano()
+	for _, metrics := range m.metrics {
+		for i := range metrics {
+			if metrics[i].lastTouch == nil {
+				lastTouch := now
+				metrics[i].lastTouch = &lastTouch
+			}
+		}
+	}
+}
+
+// deleteExpired deletes all metrics not touched within the TTL. It must be
+// called while holding the write lock, with a TTL > 0.
+func (m *metricMap) deleteExpired() {
+	deadline := m.now().Add(-m.ttl).UnixNano()
+	for h, metrics := range m.metrics {
+		kept := metrics[:0]
+		for _, metric := range metrics {
+			if metric.lastTouch == nil || atomic.LoadInt64(metric.lastTouch) > deadline {
+				kept = append(kept, metric)
+			}
+		}
+		if len(kept) == 0 {
+			delete(m.metrics, h)
+			continue
+		}
+		for i := len(kept); i < len(metrics); i++ {
+			metrics[i] = metricWithLabelValues{} // Allow GC.
+		}
+		m.metrics[h] = kept
+	}
+}
+
+// touch records the current time as the last touch of the provided metric if
+// a TTL is used. It must be called while holding at least the read lock.
+func (m *metricMap) touch(metric metricWithLabelValues) {
+	if m.ttl > 0 && metric.lastTouch != nil {
+		atomic.StoreInt64(metric.lastTouch, m.now().UnixNano())
+	}
+}
+
+// newMetricWithLabelValues creates a new metric with the provided (inlined)
+// label values, with its last touch set if a TTL is used. It must be called
+// while holding the write lock.
+func (m *metricMap) newMetricWithLabelValues(lvs []string) metricWithLabelValues {
+	metric := metricWithLabelValues{values: lvs, metric: m.newMetric(lvs...)}
+	if m.ttl > 0 {
+		lastTouch := m.now().UnixNano()
+		metric.lastTouch = &lastTouch
+	}
+	return metric
+}
+
 // Reset deletes all metrics in this vector.
 func (m *MetricVec) Reset() { m.metricMap.Reset() }
 
+// WithTTL makes the MetricVec delete children that haven't been touched for at
+// least the provided duration. As the typed vectors (like CounterVec) embed
+// MetricVec, it can be called directly on them. A child is touched whenever it
+// is retrieved from the MetricVec (with GetMetricWithLabelValues,
+// GetMetricWith, or the convenience methods WithLabelValues and With of the
+// typed vectors), including its creation. Expired children are deleted upon
+// Collect, while holding the same lock as for collecting, so a gathering
+// never sees an expired child, and an expired child is never sent to one
+// gathering but not to another. As a consequence, retrieving children from
+// the MetricVec is blocked while it is collected. A duration of zero or less
+// disables the TTL again. Curried vectors share the TTL with the MetricVec
+// they have been curried from.
+//
+// This is meant for label values of legitimately transient entities (e.g.
+// short-lived pods), for which deleting the children explicitly is not
+// practical. Note that an update of a child that has been retrieved once and is
+// then kept and used without retrieving it again does not count as a touch.
+// Such a child might be deleted while still in use, with all later updates
+// being lost. Thus, with a TTL, always retrieve the child from the MetricVec
+// for each update. Also note that a deleted child starts from scratch upon
+// re-creation, i.e. a counter resets to zero.
+func (m *MetricVec) WithTTL(d time.Duration) {
+	m.metricMap.setTTL(d)
+}
+
 // CurryWith returns a vector curried with the provided labels, i.e. the
 // returned vector has those labels pre-set for all labeled operations performed
 // on it. The cardinality of the curried vector is reduced accordingly. The
 // order of the remaining labels stays the same (just with the curried labels
 // taken out of the sequence – which is relevant for the
 // (GetMetric)WithLabelValues methods). It is possible to curry a curried
-// vector, but only with labels not yet used for currying before.
+// vector, but only with labels not yet used for currying before. An error is
+// returned if any of the label values is not valid UTF-8.
 //
 // The metrics contained in the MetricVec are shared between the curried and
 // uncurried vectors. They are just accessed differently. Curried and uncurried
@@ -164,10 +269,11 @@ func (m *MetricVec) CurryWith(labels Labels) (*MetricVec, error) {
 			if !ok {
 				continue // Label stays uncurried.
 			}
-			newCurry = append(newCurry, curriedLabelValue{
-				i,
-				m.desc.variableLabels.constrain(labelName, val),
-			})
+			val = m.desc.variableLabels.constrain(labelName, val)
+			if !utf8.ValidString(val) {
+				return nil, fmt.Errorf("label %s: value %q is not valid UTF-8", labelName, val)
+			}
+			newCurry = append(newCurry, curriedLabelValue{i, val})
 		}
 	}
 	if l := len(oldCurry) + len(labels) - len(newCurry); l > 0 {
@@ -198,7 +304,8 @@ func (m *MetricVec) CurryWith(labels Labels) (*MetricVec, error) {
 // Metric with the same label values is created later.
 //
 // An error is returned if the number of label values is not the same as the
-// number of variable labels in Desc (minus any curried labels).
+// number of variable labels in Desc (minus any curried labels) or if any of the
+// label values is not valid UTF-8.
 //
 // Note that for more than one label value, this method is prone to mistakes
 // caused by an incorrect order of arguments. Consider GetMetricWith(Labels) as
@@ -226,7 +333,8 @@ func (m *MetricVec) GetMetricWithLabelValues(lvs ...string) (Metric, error) {
 // are the same as for GetMetricWithLabelValues.
 //
 // An error is returned if the number and names of the Labels are inconsistent
-// with those of the variable labels in Desc (minus any curried labels).
+// with those of the variable labels in Desc (minus any curried labels) or if
+// any of the label values is not valid UTF-8.
 //
 // This method is used for the same purpose as
 // GetMetricWithLabelValues(...string). See there for pros and cons of the two
@@ -304,6 +412,9 @@ func (m *MetricVec) hashLabels(labels Labels) (uint64, error) {
 type metricWithLabelValues struct {
 	values []string
 	metric Metric
+	// lastTouch is the time of the last retrieval in nanoseconds since
+	// the Unix epoch. Accessed atomically. Only set if a TTL is used.
+	lastTouch *int64
 }
 
 // curriedLabelValue sets the curried value for a label at the given index.
@@ -315,10 +426,12 @@ type curriedLabelValue struct {
 // metricMap is a helper for metricVec and shared between differently curried
 // metricVecs.
 type metricMap struct {
-	mtx       sync.RWMutex // Protects metrics.
+	mtx       sync.RWMutex // Protects metrics and ttl.
 	metrics   map[uint64][]metricWithLabelValues
 	desc      *Desc
 	newMetric func(labelValues ...string) Metric
+	ttl       time.Duration // Disabled if <= 0.
+	now       func() time.Time
 }
 
 // Describe implements Collector. It will send exactly one Desc to the provided
@@ -330,7 +443,19 @@ func (m *metricMap) Describe(ch chan<- *Desc) {
 // Collect implements Collector.
 func (m *metricMap) Collect(ch chan<- Metric) {
 	m.mtx.RLock()
-	defer m.mtx.RUnlock()
+	if m.ttl > 0 {
+		// Upgrade to a write lock to delete expired metrics. Collecting
+		// happens under the same lock, so that eviction is atomic with
+		// gathering.
+		m.mtx.RUnlock()
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		if m.ttl > 0 {
+			m.deleteExpired()
+		}
+	} else {
+		defer m.mtx.RUnlock()
+	}
 
 	for _, metrics := range m.metrics {
 		for _, metric := range metrics {
@@ -426,6 +551,56 @@ func (m *metricMap) deleteByLabels(labels Labels, curry []curriedLabelValue) int
 	return numDeleted
 }
 
+// deleteByFunc deletes all metrics matching the curry for whose labels pred
+// returns true.
+func (m *metricMap) deleteByFunc(pred func(Labels) bool, curry []curriedLabelValue) int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var numDeleted int
+
+	for h, metrics := range m.metrics {
+		kept := metrics[:0]
+		for _, metric := range metrics {
+			if matchCurry(metric.values, curry) && pred(m.labels(metric.values)) {
+				numDeleted++
+				continue
+			}
+			kept = append(kept, metric)
+		}
+		if len(kept) == 0 {
+			delete(m.metrics, h)
+			continue
+		}
+		for i := len(kept); i < len(metrics); i++ {
+			metrics[i] = metricWithLabelValues{}
+		}
+		m.metrics[h] = kept
+	}
+
+	return numDeleted
+}
+
+// labels returns the variable labels with the provided values.
+func (m *metricMap) labels(values []string) Labels {
+	labels := make(Labels, len(values))
+	for i, name := range m.desc.variableLabels.names {
+		labels[name] = values[i]
+	}
+	return labels
+}
+
+// matchCurry returns whether the provided label values contain the curried
+// values.
+func matchCurry(values []string, curry []curriedLabelValue) bool {
+	for _, c := range curry {
+		if values[c.index] != c.value {
+			return false
+		}
+	}
+	return true
+}
+
 // findMetricWithPartialLabel returns the index of the matching metric or
 // len(metrics) if not found.
 func findMetricWithPartialLabels(
@@ -500,9 +675,9 @@ func (m *metricMap) getOrCreateMetricWithLabelValues(
 	defer m.mtx.Unlock()
 	metric, ok = m.getMetricWithHashAndLabelValues(hash, lvs, curry)
 	if !ok {
-		inlinedLVs := inlineLabelValues(lvs, curry)
-		metric = m.newMetric(inlinedLVs...)
-		m.metrics[hash] = append(m.metrics[hash], metricWithLabelValues{values: inlinedLVs, metric: metric})
+		newMetric := m.newMetricWithLabelValues(inlineLabelValues(lvs, curry))
+		metric = newMetric.metric
+		m.metrics[hash] = append(m.metrics[hash], newMetric)
 	}
 	return metric
 }
@@ -525,9 +700,9 @@ func (m *metricMap) getOrCreateMetricWithLabels(
 	defer m.mtx.Unlock()
 	metric, ok = m.getMetricWithHashAndLabels(hash, labels, curry)
 	if !ok {
-		lvs := extractLabelValues(m.desc, labels, curry)
-		metric = m.newMetric(lvs...)
-		m.metrics[hash] = append(m.metrics[hash], metricWithLabelValues{values: lvs, metric: metric})
+		newMetric := m.newMetricWithLabelValues(extractLabelValues(m.desc, labels, curry))
+		metric = newMetric.metric
+		m.metrics[hash] = append(m.metrics[hash], newMetric)
 	}
 	return metric
 }
@@ -540,6 +715,7 @@ func (m *metricMap) getMetricWithHashAndLabelValues(
 	metrics, ok := m.metrics[h]
 	if ok {
 		if i := findMetricWithLabelValues(metrics, lvs, curry); i < len(metrics) {
+			m.touch(metrics[i])
 			return metrics[i].metric, true
 		}
 	}
@@ -554,6 +730,7 @@ func (m *metricMap) getMetricWithHashAndLabels(
 	metrics, ok := m.metrics[h]
 	if ok {
 		if i := findMetricWithLabels(m.desc, metrics, labels, curry); i < len(metrics) {
+			m.touch(metrics[i])
 			return metrics[i].metric, true
 		}
 	}
@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	dto "github.com/prometheus/client_model/go"
 )
@@ -259,6 +260,126 @@ func testDeletePartialMatch(t *testing.T, baseVec *GaugeVec) {
 	assertNoMetric(t)
 }
 
+func TestDeleteFunc(t *testing.T) {
+	vec := NewGaugeVec(
+		GaugeOpts{
+			Name: "test",
+			Help: "helpless",
+		},
+		[]string{"pod", "code"},
+	)
+
+	if got, want := vec.DeleteFunc(func(Labels) bool { return true }), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	vec.WithLabelValues("alive", "200").Inc()
+	vec.WithLabelValues("alive", "500").Inc()
+	vec.WithLabelValues("gone", "200").Inc()
+	vec.WithLabelValues("gone", "500").Inc()
+	vec.WithLabelValues("gone", "404").Inc()
+
+	// Only the metrics matching the curried labels are considered.
+	curried := vec.MustCurryWith(Labels{"code": "500"})
+	if got, want := curried.DeleteFunc(func(l Labels) bool { return l["pod"] == "gone" }), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := vec.DeleteFunc(func(l Labels) bool { return l["pod"] == "gone" }), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(vec.metricMap.metrics), 2; got != want {
+		t.Errorf("got %v metrics, want %v", got, want)
+	}
+	if got, want := vec.DeleteFunc(func(l Labels) bool { return l["pod"] == "gone" }), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := vec.DeleteFunc(func(Labels) bool { return true }), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := len(vec.metricMap.metrics); n != 0 {
+		t.Error("expected no metrics, got", n)
+	}
+}
+
+func TestDeleteFuncHashCollision(t *testing.T) {
+	vec := NewGaugeVec(
+		GaugeOpts{
+			Name: "test",
+			Help: "helpless",
+		},
+		[]string{"l"},
+	)
+	// Put all metrics into the same hash bucket to simulate collisions.
+	for _, v := range []string{"a", "b", "c"} {
+		vec.metricMap.metrics[0] = append(vec.metricMap.metrics[0], metricWithLabelValues{
+			values: []string{v},
+			metric: vec.metricMap.newMetric(v),
+		})
+	}
+	if got, want := vec.DeleteFunc(func(l Labels) bool { return l["l"] == "b" }), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	metrics := vec.metricMap.metrics[0]
+	if len(metrics) != 2 || metrics[0].values[0] != "a" || metrics[1].values[0] != "c" {
+		t.Errorf("unexpected remaining metrics %v", metrics)
+	}
+}
+
+func collectAndCount(c Collector) int {
+	ch := make(chan Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var n int
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestMetricVecWithTTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	vec := NewCounterVec(CounterOpts{Name: "test", Help: "helpless"}, []string{"l1", "l2"})
+	vec.metricMap.now = func() time.Time { return now }
+	vec.WithLabelValues("a", "1").Inc() // Exists before the TTL is set.
+	vec.WithTTL(time.Minute)
+	vec.With(Labels{"l1": "b", "l2": "2"}).Inc()
+	curried := vec.MustCurryWith(Labels{"l1": "c"})
+	curried.WithLabelValues("3").Inc()
+
+	now = now.Add(40 * time.Second)
+	vec.WithLabelValues("a", "1").Inc()
+	if got := collectAndCount(vec); got != 3 {
+		t.Errorf("got %d children before expiry, want 3", got)
+	}
+
+	now = now.Add(30 * time.Second)
+	if got := collectAndCount(vec); got != 1 {
+		t.Errorf("got %d children after expiry, want 1", got)
+	}
+	if vec.DeleteLabelValues("b", "2") || curried.DeleteLabelValues("3") {
+		t.Error("expired children still present")
+	}
+
+	// A re-created child starts from scratch.
+	vec.WithLabelValues("b", "2").Inc()
+	m := &dto.Metric{}
+	if err := vec.WithLabelValues("b", "2").Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetCounter().GetValue(); got != 1 {
+		t.Errorf("got %f for re-created child, want 1", got)
+	}
+
+	// Disabling the TTL keeps all children.
+	vec.WithTTL(0)
+	now = now.Add(time.Hour)
+	if got := collectAndCount(vec); got != 2 {
+		t.Errorf("got %d children without TTL, want 2", got)
+	}
+}
+
 func TestMetricVec(t *testing.T) {
 	vec := NewGaugeVec(
 		GaugeOpts{
@@ -270,6 +391,32 @@ func TestMetricVec(t *testing.T) {
 	testMetricVec(t, vec)
 }
 
+func TestLabelsHash(t *testing.T) {
+	vec := NewGaugeVec(
+		GaugeOpts{
+			Name: "test",
+			Help: "helpless",
+		},
+		[]string{"code", "method"},
+	)
+	labels := Labels{"method": "GET", "code": "200"}
+	vec.With(labels).Set(1)
+
+	h := LabelsHash(labels)
+	if _, ok := vec.metricMap.metrics[h]; !ok {
+		t.Errorf("hash %d not found in metric vector", h)
+	}
+	if got := LabelsHash(Labels{"code": "200", "method": "GET"}); got != h {
+		t.Errorf("got hash %d for the same labels, want %d", got, h)
+	}
+	if got := LabelsHash(Labels{"code": "200GET"}); got == h {
+		t.Errorf("got same hash %d for different labels", got)
+	}
+	if got, want := LabelsHash(nil), hashNew(); got != want {
+		t.Errorf("got hash %d for empty labels, want %d", got, want)
+	}
+}
+
 func TestMetricVecWithCollisions(t *testing.T) {
 	vec := NewGaugeVec(
 		GaugeOpts{
@@ -355,6 +502,68 @@ func TestMetricVecWithConstraints(t *testing.T) {
 	testConstrainedMetricVec(t, vec, constraint)
 }
 
+func TestMetricVecWithAllowedValues(t *testing.T) {
+	allowed := map[string]bool{"x1": true, "x3": true}
+	constraint := func(s string) string {
+		if allowed["x"+s] {
+			return "x" + s
+		}
+		return OtherLabelValue
+	}
+	vec := V2.NewGaugeVec(GaugeVecOpts{
+		GaugeOpts{
+			Name: "test",
+			Help: "helpless",
+		},
+		ConstrainedLabels{
+			{Name: "l1"},
+			{Name: "l2", Constraint: func(s string) string { return "x" + s }, Values: []string{"x1", "x3"}},
+		},
+	})
+	testConstrainedMetricVec(t, vec, constraint)
+}
+
+func TestAllowedValuesAllVecTypes(t *testing.T) {
+	labels := ConstrainedLabels{{Name: "method", Values: []string{"GET", "POST"}}}
+	cv := V2.NewCounterVec(CounterVecOpts{CounterOpts: CounterOpts{Name: "c", Help: "help"}, VariableLabels: labels})
+	gv := V2.NewGaugeVec(GaugeVecOpts{GaugeOpts: GaugeOpts{Name: "g", Help: "help"}, VariableLabels: labels})
+	hv := V2.NewHistogramVec(HistogramVecOpts{HistogramOpts: HistogramOpts{Name: "h", Help: "help"}, VariableLabels: labels})
+	sv := V2.NewSummaryVec(SummaryVecOpts{SummaryOpts: SummaryOpts{Name: "s", Help: "help"}, VariableLabels: labels})
+	for _, method := range []string{"GET", "BREW", "PATCH", "POST"} {
+		cv.WithLabelValues(method).Inc()
+		gv.With(Labels{"method": method}).Inc()
+		hv.WithLabelValues(method).Observe(1)
+		sv.With(Labels{"method": method}).Observe(1)
+	}
+
+	reg := NewPedanticRegistry()
+	reg.MustRegister(cv, gv, hv, sv)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		var values []string
+		for _, m := range mf.Metric {
+			values = append(values, m.Label[0].GetValue())
+		}
+		if want := []string{"GET", "POST", OtherLabelValue}; !reflect.DeepEqual(values, want) {
+			t.Errorf("%s: got label values %v, want %v", mf.GetName(), values, want)
+		}
+	}
+
+	m := &dto.Metric{}
+	if err := cv.WithLabelValues("anything").Write(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Counter.GetValue(); got != 2 {
+		t.Errorf("expected 2 in the %q series, got %v", OtherLabelValue, got)
+	}
+	if !cv.DeleteLabelValues("BREW") {
+		t.Errorf("expected deletion of the %q series", OtherLabelValue)
+	}
+}
+
 func testConstrainedMetricVec(t *testing.T, vec *GaugeVec, constrain func(string) string) {
 	vec.Reset() // Actually test Reset now!
 
@@ -904,6 +1113,40 @@ func testConstrainedCurryVec(t *testing.T, vec *CounterVec, constraint func(stri
 	})
 }
 
+func TestInvalidUTF8LabelValues(t *testing.T) {
+	const invalid = "in\xffvalid"
+	vec := NewCounterVec(CounterOpts{Name: "test", Help: "helpless"}, []string{"a", "b"})
+
+	if _, err := vec.GetMetricWithLabelValues("valid", invalid); err == nil {
+		t.Error("expected error for invalid UTF-8 label value")
+	}
+	if _, err := vec.GetMetricWith(Labels{"a": "valid", "b": invalid}); err == nil {
+		t.Error("expected error for invalid UTF-8 label value")
+	}
+	if _, err := vec.CurryWith(Labels{"a": invalid}); err == nil {
+		t.Error("expected error for invalid UTF-8 curried label value")
+	}
+	for name, f := range map[string]func(){
+		"WithLabelValues": func() { vec.WithLabelValues(invalid, "valid") },
+		"With":            func() { vec.With(Labels{"a": invalid, "b": "valid"}) },
+		"MustCurryWith":   func() { vec.MustCurryWith(Labels{"b": invalid}) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic for invalid UTF-8 label value", name)
+				}
+			}()
+			f()
+		}()
+	}
+
+	// Nothing has been created.
+	if n := len(vec.metricMap.metrics); n != 0 {
+		t.Errorf("expected no metrics, got %d", n)
+	}
+}
+
 func BenchmarkMetricVecWithBasic(b *testing.B) {
 	benchmarkMetricVecWith(b, Labels{
 		"l1": "onevalue",
@@ -15,6 +15,7 @@ package prometheus
 
 import (
 	"fmt"
+	"runtime/debug"
 	"sort"
 
 	"github.com/adhimaswaskita/client_golang/prometheus/internal"
@@ -78,6 +79,28 @@ func WrapRegistererWithPrefix(prefix string, reg Registerer) Registerer {
 	}
 }
 
+// WrapRegistererWithBuildInfo is a convenience wrapper around
+// WrapRegistererWith. It adds the label "version" with the version of the main
+// module of the running binary (as reported by debug.ReadBuildInfo) to all
+// Metrics collected by Collectors registered with the returned Registerer.
+// Depending on how the binary has been built, the version might also be
+// "(devel)" or a pseudo-version. It is "unknown" if no build information is
+// available.
+//
+// Prefer the "go_build_info" metric of collectors.NewBuildInfoCollector, which
+// can be joined with other metrics in PromQL where needed. Adding the version
+// to all metrics is costly: Every deployment of a new version creates a new
+// set of series for every single metric, multiplying the number of series in
+// the Prometheus server until the old ones become stale. The same caveats as
+// for WrapRegistererWith apply.
+func WrapRegistererWithBuildInfo(reg Registerer) Registerer {
+	version := "unknown"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		version = bi.Main.Version
+	}
+	return WrapRegistererWith(Labels{"version": version}, reg)
+}
+
 type wrappingRegisterer struct {
 	wrappedRegisterer Registerer
 	prefix            string
@@ -196,6 +219,7 @@ func wrapDesc(desc *Desc, prefix string, labels Labels) *Desc {
 			return &Desc{
 				fqName:          desc.fqName,
 				help:            desc.help,
+				unit:            desc.unit,
 				variableLabels:  desc.variableLabels,
 				constLabelPairs: desc.constLabelPairs,
 				err:             fmt.Errorf("attempted wrapping with already existing label name %q", ln),
@@ -203,8 +227,9 @@ func wrapDesc(desc *Desc, prefix string, labels Labels) *Desc {
 		}
 		constLabels[ln] = lv
 	}
-	// NewDesc will do remaining validations.
-	newDesc := V2.NewDesc(prefix+desc.fqName, desc.help, desc.variableLabels, constLabels)
+	// newDesc will do remaining validations. Prepending the prefix keeps the
+	// unit a suffix of the name.
+	newDesc := newDesc(prefix+desc.fqName, desc.help, desc.unit, desc.variableLabels, constLabels)
 	// Propagate errors if there was any. This will override any errer
 	// created by NewDesc above, i.e. earlier errors get precedence.
 	if desc.err != nil {
@@ -15,6 +15,7 @@ package prometheus
 
 import (
 	"fmt"
+	"runtime/debug"
 	"strings"
 	"testing"
 	"time"
@@ -339,3 +340,24 @@ func TestNil(t *testing.T) {
 		t.Fatal("registering failed:", err)
 	}
 }
+
+func TestWrapWithBuildInfo(t *testing.T) {
+	want := "unknown"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		want = bi.Main.Version
+	}
+
+	reg := NewPedanticRegistry()
+	c := NewCounter(CounterOpts{Name: "test_total", Help: "help"})
+	if err := WrapRegistererWithBuildInfo(reg).Register(c); err != nil {
+		t.Fatal(err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := mfs[0].Metric[0].Label
+	if len(labels) != 1 || labels[0].GetName() != "version" || labels[0].GetValue() != want {
+		t.Errorf("got labels %v, want version=%q", labels, want)
+	}
+}